@@ -0,0 +1,15 @@
+-- OAuth2 consent grants: the scopes each user has approved for each client, so the
+-- authorize flow only asks again when a client requests scopes beyond these
+CREATE TABLE IF NOT EXISTS sso_oauth_consents (
+    id VARCHAR(100) PRIMARY KEY,
+    user_id VARCHAR(100) NOT NULL REFERENCES sso_users(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    UNIQUE (user_id, client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_oauth_consents_client_id ON sso_oauth_consents(client_id);
+
+COMMENT ON TABLE sso_oauth_consents IS 'OAuth2 scopes granted by users to clients';
@@ -0,0 +1,216 @@
+// Package bootstrap provides startup helpers shared by the service modules,
+// such as a structured startup summary and a dependency self-test.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Mode controls how Preflight reacts to an unreachable dependency
+type Mode int
+
+const (
+	// ModeStrict aborts startup when a required dependency is unreachable
+	ModeStrict Mode = iota
+	// ModeWarn only logs unreachable dependencies and lets startup continue
+	ModeWarn
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
+// Dependency describes an external dependency checked on boot
+type Dependency struct {
+	Name     string
+	Endpoint string
+	Required bool
+	Timeout  time.Duration
+	Check    func(ctx context.Context) error
+}
+
+// Options holds the startup summary and the preflight mode
+type Options struct {
+	Log      *logrus.Logger
+	Mode     Mode
+	App      string
+	Version  string
+	Features []string
+}
+
+// ParseMode converts a config value ("strict" or "warn") into a Mode, defaulting to strict
+func ParseMode(value string) Mode {
+	if value == "warn" {
+		return ModeWarn
+	}
+	return ModeStrict
+}
+
+// Preflight checks every dependency in strict mode using the standard logger
+func Preflight(ctx context.Context, deps ...Dependency) error {
+	return PreflightWithOptions(ctx, nil, deps...)
+}
+
+// PreflightWithOptions logs a structured startup summary and pings every dependency with its timeout.
+// In strict mode an unreachable required dependency aborts startup with an error;
+// in warn mode failures are only logged.
+func PreflightWithOptions(ctx context.Context, options *Options, deps ...Dependency) error {
+	if options == nil {
+		options = &Options{}
+	}
+	log := options.Log
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
+
+	endpoints := make(map[string]string, len(deps))
+	for _, dep := range deps {
+		endpoints[dep.Name] = dep.Endpoint
+	}
+
+	log.WithFields(logrus.Fields{
+		"app":        options.App,
+		"version":    options.Version,
+		"go_version": runtime.Version(),
+		"endpoints":  endpoints,
+		"features":   options.Features,
+	}).Info("Starting service")
+
+	var errs []error
+	for _, dep := range deps {
+		start := time.Now()
+		err := checkDependency(ctx, dep)
+		fields := logrus.Fields{
+			"dependency": dep.Name,
+			"endpoint":   dep.Endpoint,
+			"required":   dep.Required,
+			"duration":   time.Since(start).String(),
+		}
+
+		if err == nil {
+			log.WithFields(fields).Info("Dependency reachable")
+			continue
+		}
+
+		log.WithFields(fields).WithError(err).Warn("Dependency unreachable")
+		if dep.Required && options.Mode == ModeStrict {
+			errs = append(errs, fmt.Errorf("required dependency %s (%s) is unreachable: %w", dep.Name, dep.Endpoint, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkDependency(ctx context.Context, dep Dependency) error {
+	if dep.Check == nil {
+		return errors.New("no check configured")
+	}
+
+	timeout := dep.Timeout
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dep.Check(checkCtx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-checkCtx.Done():
+		return fmt.Errorf("check timed out after %v", timeout)
+	}
+}
+
+// DatabaseDependency creates a required dependency that pings the SQL connection behind a gorm DB
+func DatabaseDependency(db *gorm.DB, endpoint string) Dependency {
+	return Dependency{
+		Name:     "database",
+		Endpoint: endpoint,
+		Required: true,
+		Check: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+}
+
+// BrokerDependency creates a required dependency that pings a message broker
+func BrokerDependency(broker messagebroker.MessageBroker, endpoint string) Dependency {
+	return Dependency{
+		Name:     "broker",
+		Endpoint: endpoint,
+		Required: true,
+		Check:    broker.Ping,
+	}
+}
+
+// CacheDependency creates a required dependency that pings a cache manager
+func CacheDependency(cacheManager cache.CacheManager, endpoint string) Dependency {
+	return Dependency{
+		Name:     "cache",
+		Endpoint: endpoint,
+		Required: true,
+		Check:    cacheManager.Ping,
+	}
+}
+
+// TCPDependency creates a dependency that only verifies a TCP connection can be opened.
+// It is used for clients that do not expose a ping, such as the Kafka sync producer, whose
+// cluster is reachable through any one of its bootstrap servers: the check passes when any
+// address accepts a connection and otherwise reports the failure of each.
+func TCPDependency(name string, required bool, addresses ...string) Dependency {
+	return Dependency{
+		Name:     name,
+		Endpoint: strings.Join(addresses, ","),
+		Required: required,
+		Check: func(ctx context.Context) error {
+			if len(addresses) == 0 {
+				return errors.New("no addresses configured")
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			results := make(chan error, len(addresses))
+			for _, address := range addresses {
+				go func(address string) {
+					var dialer net.Dialer
+					conn, err := dialer.DialContext(ctx, "tcp", address)
+					if err != nil {
+						results <- fmt.Errorf("%s: %w", address, err)
+						return
+					}
+					results <- conn.Close()
+				}(address)
+			}
+
+			var errs []error
+			for range addresses {
+				err := <-results
+				if err == nil {
+					return nil
+				}
+				errs = append(errs, err)
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
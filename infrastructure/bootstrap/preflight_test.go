@@ -0,0 +1,136 @@
+package bootstrap_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/bootstrap"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func failingDependency(required bool) bootstrap.Dependency {
+	return bootstrap.Dependency{
+		Name:     "broker",
+		Endpoint: "localhost:9092",
+		Required: required,
+		Check: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		},
+	}
+}
+
+func healthyDependency() bootstrap.Dependency {
+	return bootstrap.Dependency{
+		Name:     "database",
+		Endpoint: "localhost:5432",
+		Required: true,
+		Check: func(ctx context.Context) error {
+			return nil
+		},
+	}
+}
+
+func warnings(hook *test.Hook) int {
+	count := 0
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.WarnLevel {
+			count++
+		}
+	}
+	return count
+}
+
+func TestPreflightStrictAbortsOnRequiredFailure(t *testing.T) {
+	log, hook := test.NewNullLogger()
+
+	err := bootstrap.PreflightWithOptions(context.Background(), &bootstrap.Options{
+		Log:  log,
+		Mode: bootstrap.ModeStrict,
+		App:  "test",
+	}, healthyDependency(), failingDependency(true))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broker (localhost:9092)")
+	assert.Equal(t, 1, warnings(hook))
+}
+
+func TestPreflightStrictIgnoresOptionalFailure(t *testing.T) {
+	log, hook := test.NewNullLogger()
+
+	err := bootstrap.PreflightWithOptions(context.Background(), &bootstrap.Options{
+		Log:  log,
+		Mode: bootstrap.ModeStrict,
+	}, failingDependency(false))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, warnings(hook))
+}
+
+func TestPreflightWarnOnlyLogs(t *testing.T) {
+	log, hook := test.NewNullLogger()
+
+	err := bootstrap.PreflightWithOptions(context.Background(), &bootstrap.Options{
+		Log:  log,
+		Mode: bootstrap.ModeWarn,
+	}, healthyDependency(), failingDependency(true))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, warnings(hook))
+}
+
+func TestPreflightTimesOutSlowDependency(t *testing.T) {
+	log, _ := test.NewNullLogger()
+
+	slow := bootstrap.Dependency{
+		Name:     "cache",
+		Endpoint: "localhost:6379",
+		Required: true,
+		Timeout:  20 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	}
+
+	start := time.Now()
+	err := bootstrap.PreflightWithOptions(context.Background(), &bootstrap.Options{Log: log}, slow)
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+// closedAddress returns a local address nothing listens on
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return address
+}
+
+func TestTCPDependencyPassesWhenAnyAddressIsReachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	dep := bootstrap.TCPDependency("kafka", true, closedAddress(t), listener.Addr().String())
+	assert.NoError(t, dep.Check(context.Background()))
+}
+
+func TestTCPDependencyReportsEveryUnreachableAddress(t *testing.T) {
+	first, second := closedAddress(t), closedAddress(t)
+
+	dep := bootstrap.TCPDependency("kafka", true, first, second)
+	assert.Equal(t, first+","+second, dep.Endpoint)
+
+	err := dep.Check(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), first)
+	assert.Contains(t, err.Error(), second)
+}
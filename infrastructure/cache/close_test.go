@@ -0,0 +1,107 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestCloseWhileOperationsAreRunning(t *testing.T) {
+	backends := map[string]func(t *testing.T) cache.CacheManager{
+		"inmemory": func(t *testing.T) cache.CacheManager {
+			cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{MaxSize: 100})
+			if err != nil {
+				t.Fatalf("Failed to create cache manager: %v", err)
+			}
+			if err := cacheManager.Connect(context.Background()); err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			return cacheManager
+		},
+		"redis": newRedisCache,
+	}
+
+	for name, newCache := range backends {
+		t.Run(name, func(t *testing.T) {
+			cacheManager := newCache(t)
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			failures := make(chan error, 8)
+			for worker := 0; worker < 8; worker++ {
+				wg.Add(1)
+				go func(worker int) {
+					defer wg.Done()
+					for i := 0; ; i++ {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+
+						key := fmt.Sprintf("key:%d:%d", worker, i%10)
+						if err := cacheManager.Set(ctx, key, i, time.Minute); err != nil && !cache.IsNotConnected(err) {
+							failures <- err
+							return
+						}
+						if _, err := cacheManager.Get(ctx, key); err != nil && !cache.IsNotConnected(err) && !cache.IsNotFound(err) {
+							failures <- err
+							return
+						}
+					}
+				}(worker)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			if err := cacheManager.Close(); err != nil {
+				t.Fatalf("Failed to close: %v", err)
+			}
+			close(stop)
+			wg.Wait()
+			close(failures)
+
+			for err := range failures {
+				t.Errorf("Unexpected error while closing: %v", err)
+			}
+
+			if err := cacheManager.Set(ctx, "after-close", "value", time.Minute); !cache.IsNotConnected(err) {
+				t.Errorf("Expected not connected error after Close, got %v", err)
+			}
+			if _, err := cacheManager.Get(ctx, "after-close"); !cache.IsNotConnected(err) {
+				t.Errorf("Expected not connected error after Close, got %v", err)
+			}
+			if _, err := cacheManager.Keys(ctx, "*"); !cache.IsNotConnected(err) {
+				t.Errorf("Expected not connected error after Close, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDisconnectThenCloseDoesNotPanic(t *testing.T) {
+	ctx := context.Background()
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{MaxSize: 100})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err := cacheManager.Disconnect(ctx); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	if err := cacheManager.Disconnect(ctx); err != nil {
+		t.Fatalf("Second Disconnect failed: %v", err)
+	}
+	if err := cacheManager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := cacheManager.Close(); err != nil {
+		t.Fatalf("Second Close failed: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package cache_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func newClusterCache(t *testing.T) cache.CacheManager {
+	server := miniredis.RunT(t)
+
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceRedis, &cache.CacheConfig{
+		RedisClusterAddrs: []string{server.Addr()},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { cacheManager.Close() })
+	return cacheManager
+}
+
+func TestRedisClusterFansOutAcrossMasters(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newClusterCache(t)
+
+	pairs := map[string]interface{}{"user:1": "a", "user:2": "b", "order:1": "c"}
+	if err := cacheManager.SetMultiple(ctx, pairs, time.Minute); err != nil {
+		t.Fatalf("SetMultiple failed: %v", err)
+	}
+
+	keys, err := cacheManager.Keys(ctx, "user:*")
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Errorf("Keys = %v, want [user:1 user:2]", keys)
+	}
+
+	var scanned []string
+	err = cacheManager.ScanKeys(ctx, "*", 2, func(batch []string) error {
+		scanned = append(scanned, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanKeys failed: %v", err)
+	}
+	if len(scanned) != 3 {
+		t.Errorf("ScanKeys found %v, want 3 keys", scanned)
+	}
+
+	if err := cacheManager.DeleteMultiple(ctx, []string{"user:1", "order:1"}); err != nil {
+		t.Fatalf("DeleteMultiple failed: %v", err)
+	}
+	if exists, _ := cacheManager.Exists(ctx, "user:1"); exists {
+		t.Error("user:1 survived DeleteMultiple")
+	}
+
+	if err := cacheManager.Clear(ctx); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if exists, _ := cacheManager.Exists(ctx, "user:2"); exists {
+		t.Error("user:2 survived Clear")
+	}
+}
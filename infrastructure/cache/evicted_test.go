@@ -0,0 +1,153 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+// evictions records OnEvicted calls
+type evictions struct {
+	mutex sync.Mutex
+	keys  []string
+}
+
+func (e *evictions) record(key string, value interface{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.keys = append(e.keys, key)
+}
+
+func (e *evictions) recorded() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]string(nil), e.keys...)
+}
+
+func newEvictingCache(t *testing.T, config *cache.CacheConfig) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, config)
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { cacheManager.Close() })
+	return cacheManager
+}
+
+func TestOnEvictedReportsExpiredReads(t *testing.T) {
+	ctx := context.Background()
+	var seen evictions
+	cacheManager := newEvictingCache(t, &cache.CacheConfig{OnEvicted: seen.record})
+
+	cacheManager.Set(ctx, "get", "value", 10*time.Millisecond)
+	cacheManager.Set(ctx, "exists", "value", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	cacheManager.Get(ctx, "get")
+	cacheManager.Exists(ctx, "exists")
+
+	if got := seen.recorded(); len(got) != 2 || got[0] != "get" || got[1] != "exists" {
+		t.Errorf("OnEvicted saw %v, want [get exists]", got)
+	}
+}
+
+func TestOnEvictedReportsCleanup(t *testing.T) {
+	ctx := context.Background()
+	var seen evictions
+	cacheManager := newEvictingCache(t, &cache.CacheConfig{
+		CleanupInterval: 10 * time.Millisecond,
+		OnEvicted:       seen.record,
+	})
+
+	cacheManager.Set(ctx, "session", "value", 5*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(seen.recorded()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Cleanup never reported the expired entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOnEvictedReportsCapacityEvictionAndDelete(t *testing.T) {
+	ctx := context.Background()
+	var seen evictions
+	cacheManager := newEvictingCache(t, &cache.CacheConfig{
+		MaxSize:        2,
+		EvictionPolicy: cache.EvictionFIFO,
+		OnEvicted:      seen.record,
+	})
+
+	cacheManager.Set(ctx, "a", 1, time.Minute)
+	cacheManager.Set(ctx, "b", 2, time.Minute)
+	cacheManager.Set(ctx, "c", 3, time.Minute)
+	cacheManager.Delete(ctx, "b")
+	cacheManager.DeleteMultiple(ctx, []string{"c", "missing"})
+
+	if got := seen.recorded(); len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+		t.Errorf("OnEvicted saw %v, want [a b c]", got)
+	}
+}
+
+func TestOnEvictedReportsEmptiedHash(t *testing.T) {
+	ctx := context.Background()
+	var seen evictions
+	cacheManager := newEvictingCache(t, &cache.CacheConfig{OnEvicted: seen.record})
+
+	if err := cacheManager.HSet(ctx, "profile", "name", "Jane"); err != nil {
+		t.Fatalf("HSet failed: %v", err)
+	}
+	if err := cacheManager.HDel(ctx, "profile", "name"); err != nil {
+		t.Fatalf("HDel failed: %v", err)
+	}
+
+	if got := seen.recorded(); len(got) != 1 || got[0] != "profile" {
+		t.Errorf("OnEvicted saw %v, want [profile]", got)
+	}
+}
+
+func TestOnEvictedReportsClear(t *testing.T) {
+	ctx := context.Background()
+	var seen evictions
+	cacheManager := newEvictingCache(t, &cache.CacheConfig{OnEvicted: seen.record})
+
+	cacheManager.Set(ctx, "first", 1, time.Minute)
+	cacheManager.Set(ctx, "second", 2, time.Minute)
+	if err := cacheManager.Clear(ctx); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+
+	if got := seen.recorded(); len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("OnEvicted saw %v, want [first second]", got)
+	}
+}
+
+func TestOnEvictedMayUseTheCache(t *testing.T) {
+	ctx := context.Background()
+	var cacheManager cache.CacheManager
+	cacheManager = newEvictingCache(t, &cache.CacheConfig{
+		MaxSize: 1,
+		OnEvicted: func(key string, value interface{}) {
+			cacheManager.Exists(ctx, key)
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cacheManager.Set(ctx, "a", 1, time.Minute)
+		cacheManager.Set(ctx, "b", 2, time.Minute)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnEvicted deadlocked re-entering the cache")
+	}
+}
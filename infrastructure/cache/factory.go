@@ -9,20 +9,54 @@ var (
 	errCacheNotConnected    = errors.New("cache manager not connected")
 	errKeyNotFound          = errors.New("key not found in cache")
 	errInvalidKeyType       = errors.New("invalid key type")
+	errLockNotHeld          = errors.New("lock not held")
 )
 
+// IsNotFound reports whether err means the key is missing from the cache
+func IsNotFound(err error) bool {
+	return errors.Is(err, errKeyNotFound)
+}
+
+// IsLockNotHeld reports whether err means an unlock found the lock expired or taken over
+func IsLockNotHeld(err error) bool {
+	return errors.Is(err, errLockNotHeld)
+}
+
+// IsNotConnected reports whether err means the cache was used before Connect or after Close
+func IsNotConnected(err error) bool {
+	return errors.Is(err, errCacheNotConnected)
+}
+
+// defaultScanBatch is used by ScanKeys when the batch size is not positive
+const defaultScanBatch = 100
+
 const (
 	InstanceRedis int = iota
 	InstanceInMemory
+	InstanceTiered
 )
 
-// NewCacheManagerFactory creates a new cache manager instance based on the specified type
+// NewCacheManagerFactory creates a new cache manager instance based on the specified type,
+// scoped to config.KeyPrefix when one is set
 func NewCacheManagerFactory(instance int, config *CacheConfig) (CacheManager, error) {
+	cacheManager, err := newCacheManager(instance, config)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.KeyPrefix != "" {
+		cacheManager = NewPrefixedCacheManager(cacheManager, config.KeyPrefix)
+	}
+	return cacheManager, nil
+}
+
+func newCacheManager(instance int, config *CacheConfig) (CacheManager, error) {
 	switch instance {
 	case InstanceRedis:
 		return NewRedisCacheManager(config)
 	case InstanceInMemory:
 		return NewInMemoryCacheManager(config)
+	case InstanceTiered:
+		return NewTieredCacheManager(config)
 	default:
 		return nil, errInvalidCacheInstance
 	}
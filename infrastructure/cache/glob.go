@@ -0,0 +1,96 @@
+package cache
+
+import "strings"
+
+// globMatch reports whether key matches pattern the way Redis KEYS and SCAN do, byte by
+// byte: * matches any run of bytes, ? any single byte, [abc], [a-z] and [^abc] a byte from
+// (or not from) a set, and a backslash escapes the next byte. path.Match is not used
+// because its * stops at slashes and it rejects patterns Redis accepts.
+func globMatch(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if globMatch(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if key == "" {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if key == "" {
+				return false
+			}
+			var matched bool
+			matched, pattern = matchClass(pattern[1:], key[0])
+			if !matched {
+				return false
+			}
+			key = key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if key == "" || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+	return key == ""
+}
+
+// matchClass matches c against the set at the start of pattern, just past its opening
+// bracket, and returns the pattern after the closing one. A set missing its closing
+// bracket runs to the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := strings.HasPrefix(pattern, "^")
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-':
+			low, high := pattern[0], pattern[2]
+			if low > high {
+				low, high = high, low
+			}
+			matched = matched || (c >= low && c <= high)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	pattern = strings.TrimPrefix(pattern, "]")
+
+	return matched != negate, pattern
+}
+
+// escapeGlob escapes the glob metacharacters in s so that it matches only itself
+func escapeGlob(s string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(s[i])
+	}
+	return escaped.String()
+}
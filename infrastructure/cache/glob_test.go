@@ -0,0 +1,63 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestKeysMatchGlobPatternsLikeRedis(t *testing.T) {
+	ctx := context.Background()
+	keys := []string{"user:1", "user:2", "user:10", "user:a/b", "order:1", "users", "hello", "hallo", "hxllo", "h*llo", "h[e]llo"}
+	patterns := []string{
+		"*", "user:*", "user:?", "user:[12]", "user:[^1]*", "user:[0-9]", "h?llo", "h[ae]llo",
+		"h[^e]llo", "h[a-b]llo", `h\*llo`, `h\[e\]llo`, "*:1", "user:*/*", "nothing*", "users",
+	}
+
+	backends := scanBackends(t)
+	for _, cacheManager := range backends {
+		for _, key := range keys {
+			if err := cacheManager.Set(ctx, key, "v", time.Minute); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			want := sortedKeys(t, backends["redis"], pattern)
+			if got := sortedKeys(t, backends["inmemory"], pattern); got != want {
+				t.Errorf("In-memory matched %s, Redis matched %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPrefixedKeysEscapesPrefix(t *testing.T) {
+	ctx := context.Background()
+	base := newScanCache(t)
+	tenant := cache.NewPrefixedCacheManager(base, "tenant[1]:")
+
+	tenant.Set(ctx, "user:1", "v", time.Minute)
+	tenant.Set(ctx, "order:1", "v", time.Minute)
+	base.Set(ctx, "tenant1:user:2", "v", time.Minute)
+
+	if got := sortedKeys(t, tenant, "user:*"); got != "[user:1]" {
+		t.Errorf("Got keys %s", got)
+	}
+}
+
+func sortedKeys(t *testing.T, cacheManager cache.CacheManager, pattern string) string {
+	t.Helper()
+
+	keys, err := cacheManager.Keys(context.Background(), pattern)
+	if err != nil {
+		t.Fatalf("Keys(%q) failed: %v", pattern, err)
+	}
+	sort.Strings(keys)
+	return fmt.Sprint(keys)
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+)
+
+// hashValue is the in-memory form of a hash. Its own type keeps a map stored with Set from
+// being mistaken for a hash.
+type hashValue map[string]interface{}
+
+// hashError maps the WRONGTYPE reply Redis gives for a hash command on another type to
+// errInvalidKeyType, which the in-memory manager returns in the same case
+func hashError(err error) error {
+	if err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		return errors.Join(errInvalidKeyType, err)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestHashFields(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := cacheManager.HSet(ctx, "user:1", "name", "Ada"); err != nil {
+				t.Fatalf("HSet failed: %v", err)
+			}
+			if err := cacheManager.HSet(ctx, "user:1", "email", "ada@example.com"); err != nil {
+				t.Fatalf("HSet failed: %v", err)
+			}
+			if err := cacheManager.HSet(ctx, "user:1", "name", "Ada Lovelace"); err != nil {
+				t.Fatalf("HSet failed: %v", err)
+			}
+
+			name, err := cacheManager.HGet(ctx, "user:1", "name")
+			if err != nil || fmt.Sprint(name) != "Ada Lovelace" {
+				t.Errorf("HGet = %v, %v; want Ada Lovelace", name, err)
+			}
+			if _, err := cacheManager.HGet(ctx, "user:1", "phone"); !cache.IsNotFound(err) {
+				t.Errorf("HGet of a missing field error = %v, want not found", err)
+			}
+
+			fields, err := cacheManager.HGetAll(ctx, "user:1")
+			if err != nil {
+				t.Fatalf("HGetAll failed: %v", err)
+			}
+			if len(fields) != 2 || fmt.Sprint(fields["email"]) != "ada@example.com" {
+				t.Errorf("HGetAll = %v, want name and email", fields)
+			}
+
+			if err := cacheManager.HDel(ctx, "user:1", "name"); err != nil {
+				t.Fatalf("HDel failed: %v", err)
+			}
+			if exists, _ := cacheManager.Exists(ctx, "user:1"); !exists {
+				t.Error("Hash removed while it still had a field")
+			}
+			if err := cacheManager.HDel(ctx, "user:1", "email"); err != nil {
+				t.Fatalf("HDel failed: %v", err)
+			}
+			if exists, _ := cacheManager.Exists(ctx, "user:1"); exists {
+				t.Error("Hash kept after its last field was removed")
+			}
+
+			fields, err = cacheManager.HGetAll(ctx, "user:1")
+			if err != nil || len(fields) != 0 {
+				t.Errorf("HGetAll of a missing hash = %v, %v; want an empty map", fields, err)
+			}
+		})
+	}
+}
+
+func TestHSetKeepsTTL(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := cacheManager.HSet(ctx, "profile", "theme", "dark"); err != nil {
+				t.Fatalf("HSet failed: %v", err)
+			}
+			if err := cacheManager.Expire(ctx, "profile", time.Hour); err != nil {
+				t.Fatalf("Expire failed: %v", err)
+			}
+			if err := cacheManager.HSet(ctx, "profile", "language", "en"); err != nil {
+				t.Fatalf("HSet failed: %v", err)
+			}
+
+			ttl, err := cacheManager.TTL(ctx, "profile")
+			if err != nil {
+				t.Fatalf("TTL failed: %v", err)
+			}
+			if ttl <= 0 || ttl > time.Hour {
+				t.Errorf("TTL after HSet = %v, want the hour set before it", ttl)
+			}
+		})
+	}
+}
+
+func TestHashCommandsOnAPlainValue(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := cacheManager.Set(ctx, "plain", "value", time.Minute); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if err := cacheManager.HSet(ctx, "plain", "field", "value"); err == nil {
+				t.Error("HSet on a plain value succeeded")
+			}
+			if _, err := cacheManager.HGet(ctx, "plain", "field"); err == nil || cache.IsNotFound(err) {
+				t.Errorf("HGet on a plain value error = %v, want a type error", err)
+			}
+		})
+	}
+}
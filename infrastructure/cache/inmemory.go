@@ -1,24 +1,41 @@
 package cache
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"strconv"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
 type cacheItem struct {
 	value      interface{}
 	expiration int64
+	element    *list.Element // position in the eviction order
 }
 
 type inMemoryCacheManager struct {
 	items           map[string]*cacheItem
+	order           *list.List // keys from first to last in line for eviction
 	mutex           sync.RWMutex
 	config          *CacheConfig
 	cleanupInterval time.Duration
 	stopCleanup     chan bool
+	stopOnce        sync.Once // Disconnect and Close may both stop the cleanup goroutine
+	closed          bool      // set by Close under the write lock
+	loads           singleflight.Group
+	locks           map[string]heldLock // kept apart from items so locks are never evicted
+	counters        cacheCounters
+	evicted         []evictedItem // removals waiting for OnEvicted, reported by unlock
+}
+
+// evictedItem is an entry removed from the cache, passed to OnEvicted
+type evictedItem struct {
+	key   string
+	value interface{}
 }
 
 // NewInMemoryCacheManager creates a new in-memory cache manager
@@ -41,9 +58,18 @@ func NewInMemoryCacheManager(config *CacheConfig) (CacheManager, error) {
 	if config.MaxSize == 0 {
 		config.MaxSize = 1000
 	}
+	switch config.EvictionPolicy {
+	case "":
+		config.EvictionPolicy = EvictionLRU
+	case EvictionLRU, EvictionFIFO, EvictionRandom:
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", config.EvictionPolicy)
+	}
 
 	manager := &inMemoryCacheManager{
 		items:           make(map[string]*cacheItem),
+		locks:           make(map[string]heldLock),
+		order:           list.New(),
 		config:          config,
 		cleanupInterval: config.CleanupInterval,
 		stopCleanup:     make(chan bool),
@@ -58,12 +84,18 @@ func (m *inMemoryCacheManager) Connect(ctx context.Context) error {
 	return nil
 }
 
-// Disconnect stops the cleanup goroutine
+// Disconnect stops the cleanup goroutine. It may be called more than once, and before or
+// after Close.
 func (m *inMemoryCacheManager) Disconnect(ctx context.Context) error {
-	close(m.stopCleanup)
+	m.stop()
 	return nil
 }
 
+// stop signals the cleanup goroutine to exit, closing stopCleanup only the first time
+func (m *inMemoryCacheManager) stop() {
+	m.stopOnce.Do(func() { close(m.stopCleanup) })
+}
+
 // isExpired checks if an item has expired
 func (item *cacheItem) isExpired() bool {
 	if item.expiration == 0 {
@@ -90,36 +122,96 @@ func (m *inMemoryCacheManager) startCleanup() {
 // cleanup removes expired items
 func (m *inMemoryCacheManager) cleanup() {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
 
 	for key, item := range m.items {
 		if item.isExpired() {
-			delete(m.items, key)
+			m.drop(key)
+		}
+	}
+}
+
+// store inserts or replaces an item. A new key makes room by evicting from the front of the
+// eviction order, or any key under EvictionRandom; neither can be the incoming key. Callers
+// must hold the write lock.
+func (m *inMemoryCacheManager) store(key string, value interface{}, expiration int64) *cacheItem {
+	if item, found := m.items[key]; found {
+		item.value = value
+		item.expiration = expiration
+		m.touch(item)
+		return item
+	}
+
+	for len(m.items) >= m.config.MaxSize && m.order.Len() > 0 {
+		m.drop(m.evictionCandidate())
+		m.counters.evictions.Add(1)
+	}
+
+	item := &cacheItem{
+		value:      value,
+		expiration: expiration,
+	}
+	item.element = m.order.PushBack(key)
+	m.items[key] = item
+	return item
+}
+
+// evictionCandidate returns the key to evict next. Callers must hold the write lock.
+func (m *inMemoryCacheManager) evictionCandidate() string {
+	if m.config.EvictionPolicy == EvictionRandom {
+		for key := range m.items {
+			return key
 		}
 	}
+	return m.order.Front().Value.(string)
+}
+
+// touch moves an item to the back of the eviction order under LRU. Callers must hold the write lock.
+func (m *inMemoryCacheManager) touch(item *cacheItem) {
+	if m.config.EvictionPolicy == EvictionLRU {
+		m.order.MoveToBack(item.element)
+	}
+}
+
+// drop removes a key that expired, was evicted or was deleted, queueing it for OnEvicted.
+// Callers must hold the write lock and release it with unlock.
+func (m *inMemoryCacheManager) drop(key string) {
+	if item, found := m.items[key]; found && m.config.OnEvicted != nil {
+		m.evicted = append(m.evicted, evictedItem{key: key, value: item.value})
+	}
+	m.remove(key)
+}
+
+// unlock releases the write lock and then reports the queued removals to OnEvicted, so the
+// callback may use the cache without deadlocking
+func (m *inMemoryCacheManager) unlock() {
+	evicted := m.evicted
+	m.evicted = nil
+	m.mutex.Unlock()
+
+	for _, item := range evicted {
+		m.config.OnEvicted(item.key, item.value)
+	}
+}
+
+// remove deletes a key from the map and the eviction order without notifying OnEvicted.
+// Callers must hold the write lock.
+func (m *inMemoryCacheManager) remove(key string) {
+	item, found := m.items[key]
+	if !found {
+		return
+	}
+	m.order.Remove(item.element)
+	delete(m.items, key)
 }
 
 // Set stores a value with the given key and expiration time
 func (m *inMemoryCacheManager) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	// Check if we need to make room
-	if len(m.items) >= m.config.MaxSize {
-		// Simple eviction: remove first expired item found, or oldest item
-		for k, item := range m.items {
-			if item.isExpired() {
-				delete(m.items, k)
-				break
-			}
-		}
-		// If still at max capacity, remove one item (simple FIFO)
-		if len(m.items) >= m.config.MaxSize {
-			for k := range m.items {
-				delete(m.items, k)
-				break
-			}
-		}
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
 	}
 
 	var exp int64
@@ -127,36 +219,67 @@ func (m *inMemoryCacheManager) Set(ctx context.Context, key string, value interf
 		exp = time.Now().Add(expiration).UnixNano()
 	}
 
-	m.items[key] = &cacheItem{
-		value:      value,
-		expiration: exp,
-	}
+	m.store(key, value, exp)
 
 	return nil
 }
 
+// SetNX stores a value unless a live item already holds the key, checking and writing
+// under one write lock
+func (m *inMemoryCacheManager) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return false, errCacheNotConnected
+	}
+
+	if item, found := m.items[key]; found && !item.isExpired() {
+		return false, nil
+	}
+
+	var exp int64
+	if expiration > 0 {
+		exp = time.Now().Add(expiration).UnixNano()
+	}
+
+	m.store(key, value, exp)
+
+	return true, nil
+}
+
 // Get retrieves a value by key
 func (m *inMemoryCacheManager) Get(ctx context.Context, key string) (interface{}, error) {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	// A read may reorder the LRU list or drop an expired item, so it takes the write lock
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
 
 	item, found := m.items[key]
 	if !found {
+		m.counters.read(false)
 		return nil, errKeyNotFound
 	}
 
 	if item.isExpired() {
-		m.mutex.RUnlock()
-		m.mutex.Lock()
-		delete(m.items, key)
-		m.mutex.Unlock()
-		m.mutex.RLock()
+		m.drop(key)
+		m.counters.read(false)
 		return nil, errKeyNotFound
 	}
 
+	m.touch(item)
+	m.counters.read(true)
 	return item.value, nil
 }
 
+// GetOrSet returns the value of key, loading it once for all concurrent callers on a miss
+func (m *inMemoryCacheManager) GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	return getOrSet(ctx, &m.loads, m, key, expiration, loader)
+}
+
 // GetString retrieves a string value by key
 func (m *inMemoryCacheManager) GetString(ctx context.Context, key string) (string, error) {
 	value, err := m.Get(ctx, key)
@@ -238,16 +361,24 @@ func (m *inMemoryCacheManager) GetFloat64(ctx context.Context, key string) (floa
 // Delete removes a value by key
 func (m *inMemoryCacheManager) Delete(ctx context.Context, key string) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
 
-	delete(m.items, key)
+	if m.closed {
+		return errCacheNotConnected
+	}
+
+	m.drop(key)
 	return nil
 }
 
 // Exists checks if a key exists in the cache
 func (m *inMemoryCacheManager) Exists(ctx context.Context, key string) (bool, error) {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return false, errCacheNotConnected
+	}
 
 	item, found := m.items[key]
 	if !found {
@@ -255,11 +386,7 @@ func (m *inMemoryCacheManager) Exists(ctx context.Context, key string) (bool, er
 	}
 
 	if item.isExpired() {
-		m.mutex.RUnlock()
-		m.mutex.Lock()
-		delete(m.items, key)
-		m.mutex.Unlock()
-		m.mutex.RLock()
+		m.drop(key)
 		return false, nil
 	}
 
@@ -271,24 +398,107 @@ func (m *inMemoryCacheManager) Keys(ctx context.Context, pattern string) ([]stri
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
+
 	var keys []string
 	for key, item := range m.items {
-		if !item.isExpired() {
-			// Simple pattern matching - just check if key contains pattern
-			// For more sophisticated pattern matching, you'd need a proper glob library
-			if pattern == "*" || key == pattern {
-				keys = append(keys, key)
+		if !item.isExpired() && keyMatches(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// ScanKeys takes a snapshot of the matching keys in eviction order and passes it to fn one
+// batch at a time, without holding the lock, so fn may use the cache. Reads and writes made
+// by fn do not change what the scan visits; keys removed since the snapshot are skipped.
+func (m *inMemoryCacheManager) ScanKeys(ctx context.Context, pattern string, batch int, fn func(keys []string) error) error {
+	if batch <= 0 {
+		batch = defaultScanBatch
+	}
+
+	snapshot, err := m.scanSnapshot(pattern)
+	if err != nil {
+		return err
+	}
+
+	for len(snapshot) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := batch
+		if n > len(snapshot) {
+			n = len(snapshot)
+		}
+		keys, err := m.liveKeys(snapshot[:n])
+		if err != nil {
+			return err
+		}
+		snapshot = snapshot[n:]
+
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
+
+// scanSnapshot returns the live keys matching pattern, in eviction order
+func (m *inMemoryCacheManager) scanSnapshot(pattern string) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
+
+	keys := make([]string, 0, m.order.Len())
+	for element := m.order.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		if item := m.items[key]; !item.isExpired() && keyMatches(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// liveKeys returns the keys from candidates that are still present and unexpired
+func (m *inMemoryCacheManager) liveKeys(candidates []string) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
 
+	keys := make([]string, 0, len(candidates))
+	for _, key := range candidates {
+		if item, found := m.items[key]; found && !item.isExpired() {
+			keys = append(keys, key)
+		}
+	}
 	return keys, nil
 }
 
+// keyMatches reports whether key matches the glob pattern, as Redis would
+func keyMatches(pattern, key string) bool {
+	return pattern == "*" || globMatch(pattern, key)
+}
+
 // Expire sets an expiration time for a key
 func (m *inMemoryCacheManager) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
 
 	item, found := m.items[key]
 	if !found {
@@ -296,7 +506,7 @@ func (m *inMemoryCacheManager) Expire(ctx context.Context, key string, expiratio
 	}
 
 	if item.isExpired() {
-		delete(m.items, key)
+		m.drop(key)
 		return errKeyNotFound
 	}
 
@@ -309,6 +519,10 @@ func (m *inMemoryCacheManager) TTL(ctx context.Context, key string) (time.Durati
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	if m.closed {
+		return 0, errCacheNotConnected
+	}
+
 	item, found := m.items[key]
 	if !found {
 		return 0, errKeyNotFound
@@ -330,17 +544,34 @@ func (m *inMemoryCacheManager) TTL(ctx context.Context, key string) (time.Durati
 	return ttl, nil
 }
 
-// Clear removes all keys from the cache
+// Clear removes all keys from the cache, reporting each to OnEvicted
 func (m *inMemoryCacheManager) Clear(ctx context.Context) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
 
+	if m.config.OnEvicted != nil {
+		for element := m.order.Front(); element != nil; element = element.Next() {
+			key := element.Value.(string)
+			m.evicted = append(m.evicted, evictedItem{key: key, value: m.items[key].value})
+		}
+	}
 	m.items = make(map[string]*cacheItem)
+	m.order.Init()
 	return nil
 }
 
-// Ping always returns nil for in-memory cache
+// Ping returns nil until the cache is closed
 func (m *inMemoryCacheManager) Ping(ctx context.Context) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
 	return nil
 }
 
@@ -359,6 +590,9 @@ func (m *inMemoryCacheManager) GetMultiple(ctx context.Context, keys []string) (
 	result := make(map[string]interface{})
 	for _, key := range keys {
 		value, err := m.Get(ctx, key)
+		if IsNotConnected(err) {
+			return nil, err
+		}
 		if err == nil {
 			result[key] = value
 		}
@@ -369,10 +603,14 @@ func (m *inMemoryCacheManager) GetMultiple(ctx context.Context, keys []string) (
 // DeleteMultiple removes multiple keys
 func (m *inMemoryCacheManager) DeleteMultiple(ctx context.Context, keys []string) error {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
 
 	for _, key := range keys {
-		delete(m.items, key)
+		m.drop(key)
 	}
 	return nil
 }
@@ -380,26 +618,21 @@ func (m *inMemoryCacheManager) DeleteMultiple(ctx context.Context, keys []string
 // Increment increments a numeric value
 func (m *inMemoryCacheManager) Increment(ctx context.Context, key string, value int64) (int64, error) {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
+
+	if m.closed {
+		return 0, errCacheNotConnected
+	}
 
 	item, found := m.items[key]
-	if !found {
+	if !found || item.isExpired() {
 		// Create new item with the increment value
-		m.items[key] = &cacheItem{
-			value:      value,
-			expiration: 0,
-		}
+		m.drop(key)
+		m.store(key, value, 0)
 		return value, nil
 	}
 
-	if item.isExpired() {
-		delete(m.items, key)
-		m.items[key] = &cacheItem{
-			value:      value,
-			expiration: 0,
-		}
-		return value, nil
-	}
+	m.touch(item)
 
 	// Try to convert existing value to int64
 	switch v := item.value.(type) {
@@ -429,11 +662,179 @@ func (m *inMemoryCacheManager) Decrement(ctx context.Context, key string, value
 	return m.Increment(ctx, key, -value)
 }
 
-// Close closes the in-memory cache manager
+// hash returns the live hash stored at key, or nil if there is none. Callers must hold the
+// write lock.
+func (m *inMemoryCacheManager) hash(key string) (*cacheItem, hashValue, error) {
+	item, found := m.items[key]
+	if !found {
+		return nil, nil, nil
+	}
+	if item.isExpired() {
+		m.drop(key)
+		return nil, nil, nil
+	}
+
+	hash, ok := item.value.(hashValue)
+	if !ok {
+		return nil, nil, errInvalidKeyType
+	}
+	return item, hash, nil
+}
+
+// HSet sets one field of a hash, keeping the expiration of an existing hash
+func (m *inMemoryCacheManager) HSet(ctx context.Context, key, field string, value interface{}) error {
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
+
+	item, hash, err := m.hash(key)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		m.store(key, hashValue{field: value}, 0)
+		return nil
+	}
+
+	hash[field] = value
+	m.touch(item)
+	return nil
+}
+
+// HGet retrieves one field of a hash
+func (m *inMemoryCacheManager) HGet(ctx context.Context, key, field string) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
+
+	item, hash, err := m.hash(key)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errKeyNotFound
+	}
+
+	value, found := hash[field]
+	if !found {
+		return nil, errKeyNotFound
+	}
+	m.touch(item)
+	return value, nil
+}
+
+// HGetAll returns a copy of every field of a hash
+func (m *inMemoryCacheManager) HGetAll(ctx context.Context, key string) (map[string]interface{}, error) {
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return nil, errCacheNotConnected
+	}
+
+	item, hash, err := m.hash(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(hash))
+	for field, value := range hash {
+		fields[field] = value
+	}
+	if item != nil {
+		m.touch(item)
+	}
+	return fields, nil
+}
+
+// HDel removes fields from a hash and the hash itself once it has no fields left
+func (m *inMemoryCacheManager) HDel(ctx context.Context, key string, fields ...string) error {
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return errCacheNotConnected
+	}
+
+	item, hash, err := m.hash(key)
+	if err != nil || item == nil {
+		return err
+	}
+
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		m.drop(key)
+	}
+	return nil
+}
+
+// Stats returns the counters and the number of stored items, which may include expired
+// items the cleanup goroutine has not removed yet
+func (m *inMemoryCacheManager) Stats(ctx context.Context) (CacheStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.closed {
+		return CacheStats{}, errCacheNotConnected
+	}
+	return m.counters.snapshot(int64(len(m.items))), nil
+}
+
+// Lock takes a lock held in this process only; it does not exclude other instances
+func (m *inMemoryCacheManager) Lock(ctx context.Context, key string, ttl time.Duration) (func() error, bool, error) {
+	if err := validateLockTTL(ttl); err != nil {
+		return nil, false, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	m.mutex.Lock()
+	defer m.unlock()
+
+	if m.closed {
+		return nil, false, errCacheNotConnected
+	}
+
+	now := time.Now().UnixNano()
+	if held, found := m.locks[key]; found && now <= held.expiration {
+		return nil, false, nil
+	}
+	m.locks[key] = heldLock{token: token, expiration: time.Now().Add(ttl).UnixNano()}
+
+	unlock := func() error {
+		m.mutex.Lock()
+		defer m.unlock()
+
+		held, found := m.locks[key]
+		if !found || held.token != token || time.Now().UnixNano() > held.expiration {
+			return errLockNotHeld
+		}
+		delete(m.locks, key)
+		return nil
+	}
+	return unlock, true, nil
+}
+
+// Close stops the cleanup goroutine and releases the items. Taking the write lock waits
+// for operations already holding the lock; any started afterwards return
+// errCacheNotConnected instead of touching the released map. Later calls are no-ops.
 func (m *inMemoryCacheManager) Close() error {
-	close(m.stopCleanup)
+	m.stop()
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	defer m.unlock()
+	m.closed = true
 	m.items = nil
+	m.locks = nil
+	m.order.Init()
 	return nil
 }
@@ -0,0 +1,129 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func newBoundedCache(t *testing.T, policy cache.EvictionPolicy) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{
+		MaxSize:        3,
+		EvictionPolicy: policy,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	return cacheManager
+}
+
+func presentKeys(t *testing.T, cacheManager cache.CacheManager, keys ...string) []string {
+	var present []string
+	for _, key := range keys {
+		exists, err := cacheManager.Exists(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Failed to check key %s: %v", key, err)
+		}
+		if exists {
+			present = append(present, key)
+		}
+	}
+	return present
+}
+
+func TestInMemorySetIntoFullCacheKeepsNewKey(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newBoundedCache(t, cache.EvictionFIFO)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cacheManager.Set(ctx, key, key, time.Minute); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	if err := cacheManager.Set(ctx, "d", "d", time.Minute); err != nil {
+		t.Fatalf("Failed to set d: %v", err)
+	}
+
+	present := presentKeys(t, cacheManager, "a", "b", "c", "d")
+	if len(present) != 3 {
+		t.Fatalf("Expected exactly one key evicted, present: %v", present)
+	}
+	if present[0] != "b" || present[2] != "d" {
+		t.Errorf("Expected the oldest key a to be evicted, present: %v", present)
+	}
+}
+
+func TestInMemoryDefaultPolicyKeepsHotKey(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newBoundedCache(t, "")
+
+	cacheManager.Set(ctx, "hot", "hot", time.Minute)
+	for i := 0; i < 10; i++ {
+		if _, err := cacheManager.Get(ctx, "hot"); err != nil {
+			t.Fatalf("Hot key was evicted after %d inserts: %v", i, err)
+		}
+		key := string(rune('a' + i))
+		cacheManager.Set(ctx, key, key, time.Minute)
+	}
+
+	if present := presentKeys(t, cacheManager, "hot", "h", "i", "j"); len(present) != 3 || present[0] != "hot" {
+		t.Errorf("Expected the hot key and the two newest keys, present: %v", present)
+	}
+}
+
+func TestInMemoryRandomEvictionKeepsNewKey(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newBoundedCache(t, cache.EvictionRandom)
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cacheManager.Set(ctx, key, key, time.Minute)
+	}
+
+	present := presentKeys(t, cacheManager, "a", "b", "c", "d")
+	if len(present) != 3 || present[2] != "d" {
+		t.Errorf("Expected one of a, b or c to be evicted, present: %v", present)
+	}
+}
+
+func TestInMemoryRejectsUnknownEvictionPolicy(t *testing.T) {
+	_, err := cache.NewInMemoryCacheManager(&cache.CacheConfig{EvictionPolicy: "lfu"})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown eviction policy")
+	}
+}
+
+func TestInMemoryOverwriteDoesNotEvict(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newBoundedCache(t, cache.EvictionFIFO)
+
+	for _, key := range []string{"a", "b", "c"} {
+		cacheManager.Set(ctx, key, key, time.Minute)
+	}
+	cacheManager.Set(ctx, "b", "updated", time.Minute)
+
+	if present := presentKeys(t, cacheManager, "a", "b", "c"); len(present) != 3 {
+		t.Errorf("Overwriting an existing key should not evict, present: %v", present)
+	}
+}
+
+func TestInMemoryLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newBoundedCache(t, cache.EvictionLRU)
+
+	for _, key := range []string{"a", "b", "c"} {
+		cacheManager.Set(ctx, key, key, time.Minute)
+	}
+	if _, err := cacheManager.Get(ctx, "a"); err != nil {
+		t.Fatalf("Failed to get a: %v", err)
+	}
+
+	cacheManager.Set(ctx, "d", "d", time.Minute)
+
+	present := presentKeys(t, cacheManager, "a", "b", "c", "d")
+	if len(present) != 3 || present[0] != "a" || present[1] != "c" {
+		t.Errorf("Expected b to be evicted, present: %v", present)
+	}
+}
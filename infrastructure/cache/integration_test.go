@@ -0,0 +1,39 @@
+//go:build integration
+
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/testharness"
+)
+
+func TestIntegrationRedisRoundTrip(t *testing.T) {
+	cacheManager := testharness.NewRedisCache(t)
+	ctx := context.Background()
+
+	if err := cacheManager.Set(ctx, "integration:key", "value", time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	got, err := cacheManager.Get(ctx, "integration:key")
+	if err != nil {
+		t.Fatalf("Failed to get value: %v", err)
+	}
+	if got != "value" {
+		t.Fatalf("Expected %q, got %v", "value", got)
+	}
+
+	if err := cacheManager.Delete(ctx, "integration:key"); err != nil {
+		t.Fatalf("Failed to delete value: %v", err)
+	}
+	exists, err := cacheManager.Exists(ctx, "integration:key")
+	if err != nil {
+		t.Fatalf("Failed to check existence: %v", err)
+	}
+	if exists {
+		t.Fatalf("Expected key to be deleted")
+	}
+}
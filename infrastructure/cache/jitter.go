@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// SetWithJitter stores value for ttl plus a random extra of up to jitter times ttl, drawn
+// uniformly from [0, jitter*ttl), so keys written together do not all expire together. A
+// jitter of 0, or a ttl of 0 (no expiration), calls Set with ttl unchanged.
+func SetWithJitter(ctx context.Context, cm CacheManager, key string, value interface{}, ttl time.Duration, jitter float64) error {
+	return cm.Set(ctx, key, value, jitteredTTL(ttl, jitter))
+}
+
+// jitteredTTL returns ttl extended by a uniformly random share of up to jitter of itself
+func jitteredTTL(ttl time.Duration, jitter float64) time.Duration {
+	spread := int64(float64(ttl) * jitter)
+	if ttl <= 0 || spread <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int64N(spread))
+}
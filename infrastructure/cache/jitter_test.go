@@ -0,0 +1,55 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestSetWithJitterSpreadsExpiry(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			seen := make(map[time.Duration]bool)
+			for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+				if err := cache.SetWithJitter(ctx, cacheManager, key, "value", time.Hour, 0.5); err != nil {
+					t.Fatalf("SetWithJitter failed: %v", err)
+				}
+				ttl, err := cacheManager.TTL(ctx, key)
+				if err != nil {
+					t.Fatalf("TTL failed: %v", err)
+				}
+				if ttl < time.Hour-time.Second || ttl >= 90*time.Minute {
+					t.Errorf("TTL of %s = %v, want within [1h, 1h30m)", key, ttl)
+				}
+				seen[ttl.Round(time.Second)] = true
+			}
+
+			if len(seen) < 2 {
+				t.Error("Every key got the same TTL")
+			}
+		})
+	}
+}
+
+func TestSetWithoutJitterKeepsTTL(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := cache.SetWithJitter(ctx, cacheManager, "exact", "value", time.Hour, 0); err != nil {
+				t.Fatalf("SetWithJitter failed: %v", err)
+			}
+			ttl, err := cacheManager.TTL(ctx, "exact")
+			if err != nil {
+				t.Fatalf("TTL failed: %v", err)
+			}
+			if ttl > time.Hour || ttl < time.Hour-time.Second {
+				t.Errorf("TTL = %v, want 1h", ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Loader produces the value for a key missing from the cache, typically from the database
+type Loader func(ctx context.Context) (interface{}, error)
+
+// getOrSet returns the cached value of key or, on a miss, loads and caches it. Concurrent
+// misses for the same key share one loader call, made with the first caller's context.
+// Loader errors reach every waiting caller and nothing is cached.
+func getOrSet(ctx context.Context, loads *singleflight.Group, m CacheManager, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	value, err := m.Get(ctx, key)
+	if !IsNotFound(err) {
+		return value, err
+	}
+
+	value, err, _ = loads.Do(key, func() (interface{}, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Set(ctx, key, value, expiration); err != nil {
+			return nil, err
+		}
+		return value, nil
+	})
+	return value, err
+}
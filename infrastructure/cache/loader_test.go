@@ -0,0 +1,104 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestGetOrSetLoadsOnceForConcurrentMisses(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var loads atomic.Int32
+			release := make(chan struct{})
+			loader := func(ctx context.Context) (interface{}, error) {
+				loads.Add(1)
+				<-release
+				return "from-db", nil
+			}
+
+			const callers = 20
+			var wg sync.WaitGroup
+			results := make(chan interface{}, callers)
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					value, err := cacheManager.GetOrSet(ctx, "product:42", time.Minute, loader)
+					if err != nil {
+						t.Errorf("GetOrSet failed: %v", err)
+					}
+					results <- value
+				}()
+			}
+
+			// Let the callers pile up on the miss before the load completes
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			close(results)
+
+			if n := loads.Load(); n != 1 {
+				t.Errorf("Expected the loader to run once, ran %d times", n)
+			}
+			for value := range results {
+				if value != "from-db" {
+					t.Errorf("Expected every caller to get the loaded value, got %v", value)
+				}
+			}
+
+			value, err := cacheManager.GetString(ctx, "product:42")
+			if err != nil || value != "from-db" {
+				t.Errorf("Expected the loaded value to be cached, got %q, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestGetOrSetDoesNotCacheLoaderErrors(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			loadErr := errors.New("database unavailable")
+
+			_, err := cacheManager.GetOrSet(ctx, "product:7", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return nil, loadErr
+			})
+			if !errors.Is(err, loadErr) {
+				t.Fatalf("Expected the loader error, got %v", err)
+			}
+			if exists, _ := cacheManager.Exists(ctx, "product:7"); exists {
+				t.Error("A failed load should not be cached")
+			}
+
+			value, err := cacheManager.GetOrSet(ctx, "product:7", time.Minute, func(ctx context.Context) (interface{}, error) {
+				return "recovered", nil
+			})
+			if err != nil || value != "recovered" {
+				t.Errorf("Expected the next call to load again, got %v, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestGetOrSetReturnsCachedValueWithoutLoading(t *testing.T) {
+	cacheManager := cache.NewPrefixedCacheManager(newScanCache(t), "catalog:")
+	ctx := context.Background()
+	if err := cacheManager.Set(ctx, "product:1", "cached", time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+
+	value, err := cacheManager.GetOrSet(ctx, "product:1", time.Minute, func(ctx context.Context) (interface{}, error) {
+		t.Error("Loader should not run on a hit")
+		return nil, nil
+	})
+	if err != nil || value != "cached" {
+		t.Errorf("Expected the cached value, got %v, %v", value, err)
+	}
+}
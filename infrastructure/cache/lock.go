@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseLockScript deletes the lock key only while it still holds the caller's token, so
+// an owner whose lock expired cannot release the lock of whoever acquired it next
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// heldLock is an in-memory lock and the token of the Lock call that holds it
+type heldLock struct {
+	token      string
+	expiration int64
+}
+
+// newToken returns a random token, identifying one Lock call or one tiered cache instance
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validateLockTTL rejects locks that would never expire if their owner died
+func validateLockTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("lock ttl must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestLockExcludesOtherHoldersUntilUnlocked(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			unlock, acquired, err := cacheManager.Lock(ctx, "jobs:leader", time.Minute)
+			if err != nil || !acquired {
+				t.Fatalf("Lock = %v, %v; want acquired", acquired, err)
+			}
+
+			if _, acquired, err := cacheManager.Lock(ctx, "jobs:leader", time.Minute); err != nil || acquired {
+				t.Fatalf("Second Lock = %v, %v; want not acquired", acquired, err)
+			}
+
+			if err := unlock(); err != nil {
+				t.Fatalf("Unlock failed: %v", err)
+			}
+			if err := unlock(); !cache.IsLockNotHeld(err) {
+				t.Errorf("Second unlock error = %v, want lock not held", err)
+			}
+
+			if _, acquired, err := cacheManager.Lock(ctx, "jobs:leader", time.Minute); err != nil || !acquired {
+				t.Errorf("Lock after unlock = %v, %v; want acquired", acquired, err)
+			}
+		})
+	}
+}
+
+func TestLockRejectsNonPositiveTTL(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := cacheManager.Lock(context.Background(), "jobs:leader", 0); err == nil {
+				t.Error("Lock without a ttl succeeded")
+			}
+		})
+	}
+}
+
+func TestExpiredLockUnlockKeepsNewOwner(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceRedis, &cache.CacheConfig{RedisAddr: server.Addr()})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer cacheManager.Close()
+
+	staleUnlock, _, err := cacheManager.Lock(ctx, "jobs:leader", time.Second)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	server.FastForward(2 * time.Second)
+
+	if _, acquired, err := cacheManager.Lock(ctx, "jobs:leader", time.Minute); err != nil || !acquired {
+		t.Fatalf("Lock after expiry = %v, %v; want acquired", acquired, err)
+	}
+
+	if err := staleUnlock(); !cache.IsLockNotHeld(err) {
+		t.Errorf("Stale unlock error = %v, want lock not held", err)
+	}
+	if !server.Exists("jobs:leader") {
+		t.Error("Stale unlock released the new owner's lock")
+	}
+}
+
+func TestInMemoryLockExpires(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newScanCache(t)
+
+	staleUnlock, _, err := cacheManager.Lock(ctx, "jobs:leader", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, acquired, err := cacheManager.Lock(ctx, "jobs:leader", time.Minute); err != nil || !acquired {
+		t.Fatalf("Lock after expiry = %v, %v; want acquired", acquired, err)
+	}
+	if err := staleUnlock(); !cache.IsLockNotHeld(err) {
+		t.Errorf("Stale unlock error = %v, want lock not held", err)
+	}
+}
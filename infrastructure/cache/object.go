@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SetObject stores value under key. Redis stores it as JSON; the in-memory manager keeps
+// the value itself, so GetObject can return it without decoding.
+func SetObject[T any](ctx context.Context, cm CacheManager, key string, value T, expiration time.Duration) error {
+	return cm.Set(ctx, key, value, expiration)
+}
+
+// GetObject returns the value at key as a T. A value stored as a T is returned as is, and
+// JSON, as Redis returns it, is unmarshaled. Any other value is converted through JSON.
+func GetObject[T any](ctx context.Context, cm CacheManager, key string) (T, error) {
+	raw, err := cm.Get(ctx, key)
+	if err != nil {
+		var object T
+		return object, err
+	}
+	return decodeObject[T](key, raw)
+}
+
+// GetOrSetObject is GetOrSet for a typed loader. The value is decoded like GetObject, so a
+// hit and a load return the same T on every backend, where GetOrSet would return JSON for
+// a Redis hit.
+func GetOrSetObject[T any](ctx context.Context, cm CacheManager, key string, expiration time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	raw, err := cm.GetOrSet(ctx, key, expiration, func(ctx context.Context) (interface{}, error) {
+		return loader(ctx)
+	})
+	if err != nil {
+		var object T
+		return object, err
+	}
+	return decodeObject[T](key, raw)
+}
+
+func decodeObject[T any](key string, raw interface{}) (T, error) {
+	var object T
+	if value, ok := raw.(T); ok {
+		return value, nil
+	}
+	if value, ok := raw.(*T); ok && value != nil {
+		return *value, nil
+	}
+
+	var data []byte
+	switch v := raw.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return object, fmt.Errorf("failed to marshal value: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, &object); err != nil {
+		return object, fmt.Errorf("failed to unmarshal value of %s: %w", key, err)
+	}
+	return object, nil
+}
@@ -0,0 +1,102 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+type cachedProfile struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			profile := cachedProfile{ID: 7, Name: "Ada", Roles: []string{"admin"}}
+
+			if err := cache.SetObject(ctx, cacheManager, "profile:7", profile, time.Minute); err != nil {
+				t.Fatalf("Failed to set object: %v", err)
+			}
+
+			got, err := cache.GetObject[cachedProfile](ctx, cacheManager, "profile:7")
+			if err != nil {
+				t.Fatalf("Failed to get object: %v", err)
+			}
+			if got.ID != 7 || got.Name != "Ada" || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+				t.Errorf("Unexpected object: %+v", got)
+			}
+
+			if err := cache.SetObject(ctx, cacheManager, "greeting", "hello", time.Minute); err != nil {
+				t.Fatalf("Failed to set string: %v", err)
+			}
+			if greeting, err := cache.GetObject[string](ctx, cacheManager, "greeting"); err != nil || greeting != "hello" {
+				t.Errorf("Expected hello, got %q, %v", greeting, err)
+			}
+		})
+	}
+}
+
+func TestGetObjectDecodesOtherRepresentations(t *testing.T) {
+	cacheManager := newScanCache(t)
+	ctx := context.Background()
+
+	cacheManager.Set(ctx, "json", `{"id":1,"name":"Grace"}`, time.Minute)
+	cacheManager.Set(ctx, "pointer", &cachedProfile{ID: 2, Name: "Linus"}, time.Minute)
+	cacheManager.Set(ctx, "map", map[string]interface{}{"id": 3, "name": "Ken"}, time.Minute)
+
+	for key, want := range map[string]string{"json": "Grace", "pointer": "Linus", "map": "Ken"} {
+		got, err := cache.GetObject[cachedProfile](ctx, cacheManager, key)
+		if err != nil || got.Name != want {
+			t.Errorf("%s: expected %s, got %+v, %v", key, want, got, err)
+		}
+	}
+}
+
+func TestGetObjectErrors(t *testing.T) {
+	cacheManager := newScanCache(t)
+	ctx := context.Background()
+
+	if _, err := cache.GetObject[cachedProfile](ctx, cacheManager, "missing"); !cache.IsNotFound(err) {
+		t.Errorf("Expected a not-found error, got %v", err)
+	}
+
+	cacheManager.Set(ctx, "corrupt", "not json", time.Minute)
+	if _, err := cache.GetObject[cachedProfile](ctx, cacheManager, "corrupt"); err == nil || cache.IsNotFound(err) {
+		t.Errorf("Expected a decode error, got %v", err)
+	}
+}
+
+func TestGetOrSetObjectReturnsOneTypeOnHitAndLoad(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			loads := 0
+			loader := func(ctx context.Context) (cachedProfile, error) {
+				loads++
+				return cachedProfile{ID: 9, Name: "Grace", Roles: []string{"viewer"}}, nil
+			}
+
+			loaded, err := cache.GetOrSetObject(ctx, cacheManager, "profile:9", time.Minute, loader)
+			if err != nil {
+				t.Fatalf("Failed to load object: %v", err)
+			}
+			cached, err := cache.GetOrSetObject(ctx, cacheManager, "profile:9", time.Minute, loader)
+			if err != nil {
+				t.Fatalf("Failed to get cached object: %v", err)
+			}
+
+			if loads != 1 {
+				t.Errorf("Expected one load, got %d", loads)
+			}
+			if cached.ID != loaded.ID || cached.Name != loaded.Name || len(cached.Roles) != 1 || cached.Roles[0] != "viewer" {
+				t.Errorf("Expected the cached object to match the loaded one, got %+v and %+v", cached, loaded)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// prefixedCacheManager scopes every key of an underlying cache manager under a prefix,
+// so several logical caches can share one backend without their keys colliding
+type prefixedCacheManager struct {
+	CacheManager
+	prefix string
+}
+
+// NewPrefixedCacheManager wraps base so that all keys are stored as prefix+key. Keys and
+// Clear only see and remove keys under the prefix.
+func NewPrefixedCacheManager(base CacheManager, prefix string) CacheManager {
+	return &prefixedCacheManager{
+		CacheManager: base,
+		prefix:       prefix,
+	}
+}
+
+func (p *prefixedCacheManager) key(key string) string {
+	return p.prefix + key
+}
+
+// pattern scopes a glob pattern to the prefix, escaping any wildcards in the prefix itself
+func (p *prefixedCacheManager) pattern(pattern string) string {
+	return escapeGlob(p.prefix) + pattern
+}
+
+func (p *prefixedCacheManager) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return p.CacheManager.Set(ctx, p.key(key), value, expiration)
+}
+
+func (p *prefixedCacheManager) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return p.CacheManager.SetNX(ctx, p.key(key), value, expiration)
+}
+
+func (p *prefixedCacheManager) Get(ctx context.Context, key string) (interface{}, error) {
+	return p.CacheManager.Get(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	return p.CacheManager.GetOrSet(ctx, p.key(key), expiration, loader)
+}
+
+func (p *prefixedCacheManager) GetString(ctx context.Context, key string) (string, error) {
+	return p.CacheManager.GetString(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) GetInt(ctx context.Context, key string) (int, error) {
+	return p.CacheManager.GetInt(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) GetBool(ctx context.Context, key string) (bool, error) {
+	return p.CacheManager.GetBool(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) GetFloat64(ctx context.Context, key string) (float64, error) {
+	return p.CacheManager.GetFloat64(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) Delete(ctx context.Context, key string) error {
+	return p.CacheManager.Delete(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) Exists(ctx context.Context, key string) (bool, error) {
+	return p.CacheManager.Exists(ctx, p.key(key))
+}
+
+// Keys matches pattern within the prefix and returns keys with the prefix removed
+func (p *prefixedCacheManager) Keys(ctx context.Context, pattern string) ([]string, error) {
+	scopedPattern := p.pattern(pattern)
+
+	keys, err := p.CacheManager.Keys(ctx, scopedPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, p.prefix) {
+			scoped = append(scoped, strings.TrimPrefix(key, p.prefix))
+		}
+	}
+	return scoped, nil
+}
+
+// ScanKeys scans within the prefix and passes keys with the prefix removed
+func (p *prefixedCacheManager) ScanKeys(ctx context.Context, pattern string, batch int, fn func(keys []string) error) error {
+	scopedPattern := p.pattern(pattern)
+
+	return p.CacheManager.ScanKeys(ctx, scopedPattern, batch, func(keys []string) error {
+		scoped := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if strings.HasPrefix(key, p.prefix) {
+				scoped = append(scoped, strings.TrimPrefix(key, p.prefix))
+			}
+		}
+		if len(scoped) == 0 {
+			return nil
+		}
+		return fn(scoped)
+	})
+}
+
+func (p *prefixedCacheManager) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return p.CacheManager.Expire(ctx, p.key(key), expiration)
+}
+
+func (p *prefixedCacheManager) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return p.CacheManager.TTL(ctx, p.key(key))
+}
+
+// Clear removes only the keys under the prefix
+func (p *prefixedCacheManager) Clear(ctx context.Context) error {
+	keys, err := p.Keys(ctx, "*")
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return p.DeleteMultiple(ctx, keys)
+}
+
+func (p *prefixedCacheManager) SetMultiple(ctx context.Context, pairs map[string]interface{}, expiration time.Duration) error {
+	prefixed := make(map[string]interface{}, len(pairs))
+	for key, value := range pairs {
+		prefixed[p.key(key)] = value
+	}
+	return p.CacheManager.SetMultiple(ctx, prefixed, expiration)
+}
+
+func (p *prefixedCacheManager) GetMultiple(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	values, err := p.CacheManager.GetMultiple(ctx, p.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		scoped[strings.TrimPrefix(key, p.prefix)] = value
+	}
+	return scoped, nil
+}
+
+func (p *prefixedCacheManager) DeleteMultiple(ctx context.Context, keys []string) error {
+	return p.CacheManager.DeleteMultiple(ctx, p.keys(keys))
+}
+
+func (p *prefixedCacheManager) Increment(ctx context.Context, key string, value int64) (int64, error) {
+	return p.CacheManager.Increment(ctx, p.key(key), value)
+}
+
+func (p *prefixedCacheManager) Decrement(ctx context.Context, key string, value int64) (int64, error) {
+	return p.CacheManager.Decrement(ctx, p.key(key), value)
+}
+
+func (p *prefixedCacheManager) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return p.CacheManager.HSet(ctx, p.key(key), field, value)
+}
+
+func (p *prefixedCacheManager) HGet(ctx context.Context, key, field string) (interface{}, error) {
+	return p.CacheManager.HGet(ctx, p.key(key), field)
+}
+
+func (p *prefixedCacheManager) HGetAll(ctx context.Context, key string) (map[string]interface{}, error) {
+	return p.CacheManager.HGetAll(ctx, p.key(key))
+}
+
+func (p *prefixedCacheManager) HDel(ctx context.Context, key string, fields ...string) error {
+	return p.CacheManager.HDel(ctx, p.key(key), fields...)
+}
+
+func (p *prefixedCacheManager) Lock(ctx context.Context, key string, ttl time.Duration) (func() error, bool, error) {
+	return p.CacheManager.Lock(ctx, p.key(key), ttl)
+}
+
+func (p *prefixedCacheManager) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = p.key(key)
+	}
+	return prefixed
+}
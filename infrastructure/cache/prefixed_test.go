@@ -0,0 +1,66 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestKeyPrefixScopesKeysAndClear(t *testing.T) {
+	server := miniredis.RunT(t)
+	backends := map[string]struct {
+		instance int
+		config   *cache.CacheConfig
+	}{
+		"inmemory": {cache.InstanceInMemory, &cache.CacheConfig{KeyPrefix: "billing:"}},
+		"redis":    {cache.InstanceRedis, &cache.CacheConfig{RedisAddr: server.Addr(), KeyPrefix: "billing:"}},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			server.FlushAll()
+			server.Set("orders:1", "another service's key")
+
+			cacheManager, err := cache.NewCacheManagerFactory(backend.instance, backend.config)
+			if err != nil {
+				t.Fatalf("Failed to create cache manager: %v", err)
+			}
+			if err := cacheManager.Connect(ctx); err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer cacheManager.Close()
+
+			if err := cacheManager.Set(ctx, "invoice:1", "paid", time.Minute); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if value, err := cacheManager.GetString(ctx, "invoice:1"); err != nil || value != "paid" {
+				t.Errorf("GetString = %q, %v; want paid", value, err)
+			}
+			if name == "redis" && !server.Exists("billing:invoice:1") {
+				t.Error("Key was not stored under the prefix")
+			}
+
+			keys, err := cacheManager.Keys(ctx, "*")
+			if err != nil {
+				t.Fatalf("Keys failed: %v", err)
+			}
+			if len(keys) != 1 || keys[0] != "invoice:1" {
+				t.Errorf("Keys = %v, want [invoice:1]", keys)
+			}
+
+			if err := cacheManager.Clear(ctx); err != nil {
+				t.Fatalf("Clear failed: %v", err)
+			}
+			if exists, _ := cacheManager.Exists(ctx, "invoice:1"); exists {
+				t.Error("Clear kept a key under the prefix")
+			}
+			if !server.Exists("orders:1") {
+				t.Error("Clear removed a key outside the prefix")
+			}
+		})
+	}
+}
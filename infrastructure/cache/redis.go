@@ -6,14 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 type redisCacheManager struct {
-	client *redis.Client
+	client redis.UniversalClient
 	config *CacheConfig
+
+	state    sync.RWMutex // guards client and closed against Close
+	closed   bool
+	inFlight sync.WaitGroup // operations that Close waits for
+	loads    singleflight.Group
+	counters cacheCounters // hits and misses seen by this process, not the server's
 }
 
 // NewRedisCacheManager creates a new Redis-based cache manager
@@ -21,6 +29,12 @@ func NewRedisCacheManager(config *CacheConfig) (CacheManager, error) {
 	if config == nil {
 		return nil, errors.New("cache config is required")
 	}
+	if (config.RedisMasterName == "") != (len(config.RedisSentinelAddrs) == 0) {
+		return nil, errors.New("redis sentinel requires both a master name and sentinel addresses")
+	}
+	if config.RedisMasterName != "" && len(config.RedisClusterAddrs) > 0 {
+		return nil, errors.New("redis cluster and sentinel addresses cannot both be set")
+	}
 
 	return &redisCacheManager{
 		config: config,
@@ -29,86 +43,201 @@ func NewRedisCacheManager(config *CacheConfig) (CacheManager, error) {
 
 // Connect establishes connection to Redis
 func (r *redisCacheManager) Connect(ctx context.Context) error {
-	r.client = redis.NewClient(&redis.Options{
-		Addr:            r.config.RedisAddr,
-		Password:        r.config.RedisPassword,
-		DB:              r.config.RedisDB,
-		MaxRetries:      r.config.MaxRetries,
-		PoolSize:        r.config.PoolSize,
-		MinIdleConns:    r.config.MinIdleConns,
-		DialTimeout:     r.config.DialTimeout,
-		ReadTimeout:     r.config.ReadTimeout,
-		WriteTimeout:    r.config.WriteTimeout,
-		PoolTimeout:     r.config.PoolTimeout,
-		ConnMaxIdleTime: r.config.IdleTimeout,
+	client := newRedisClient(r.config)
+
+	r.state.Lock()
+	r.client = client
+	r.closed = false
+	r.state.Unlock()
+
+	return client.Ping(ctx).Err()
+}
+
+// newRedisClient returns a cluster client when RedisClusterAddrs is set, a Sentinel-backed
+// failover client when RedisMasterName is, and a single-node client for RedisAddr
+// otherwise, all with the configured pool and timeouts
+func newRedisClient(config *CacheConfig) redis.UniversalClient {
+	if len(config.RedisClusterAddrs) > 0 {
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           config.RedisClusterAddrs,
+			Password:        config.RedisPassword,
+			MaxRetries:      config.MaxRetries,
+			PoolSize:        config.PoolSize,
+			MinIdleConns:    config.MinIdleConns,
+			DialTimeout:     config.DialTimeout,
+			ReadTimeout:     config.ReadTimeout,
+			WriteTimeout:    config.WriteTimeout,
+			PoolTimeout:     config.PoolTimeout,
+			ConnMaxIdleTime: config.IdleTimeout,
+		})
+	}
+
+	if config.RedisMasterName != "" {
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       config.RedisMasterName,
+			SentinelAddrs:    config.RedisSentinelAddrs,
+			SentinelPassword: config.RedisSentinelPassword,
+			Password:         config.RedisPassword,
+			DB:               config.RedisDB,
+			MaxRetries:       config.MaxRetries,
+			PoolSize:         config.PoolSize,
+			MinIdleConns:     config.MinIdleConns,
+			DialTimeout:      config.DialTimeout,
+			ReadTimeout:      config.ReadTimeout,
+			WriteTimeout:     config.WriteTimeout,
+			PoolTimeout:      config.PoolTimeout,
+			ConnMaxIdleTime:  config.IdleTimeout,
+		})
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:            config.RedisAddr,
+		Password:        config.RedisPassword,
+		DB:              config.RedisDB,
+		MaxRetries:      config.MaxRetries,
+		PoolSize:        config.PoolSize,
+		MinIdleConns:    config.MinIdleConns,
+		DialTimeout:     config.DialTimeout,
+		ReadTimeout:     config.ReadTimeout,
+		WriteTimeout:    config.WriteTimeout,
+		PoolTimeout:     config.PoolTimeout,
+		ConnMaxIdleTime: config.IdleTimeout,
 	})
+}
 
-	return r.client.Ping(ctx).Err()
+// nodes returns the clients that hold the keyspace: every master of a cluster, or the
+// single client otherwise. Commands such as KEYS, SCAN and FLUSHDB only see the node they
+// run on, so they have to be sent to each of these.
+func (r *redisCacheManager) nodes(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{r.client}, nil
+	}
+
+	var mu sync.Mutex
+	var masters []redis.Cmdable
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		mu.Lock()
+		masters = append(masters, master)
+		mu.Unlock()
+		return nil
+	})
+	return masters, err
 }
 
-// Disconnect closes the Redis connection
+// Disconnect closes the Redis connection once in-flight operations have finished
 func (r *redisCacheManager) Disconnect(ctx context.Context) error {
-	if r.client == nil {
-		return nil
+	return r.Close()
+}
+
+// acquire registers an in-flight operation. It reports false before Connect and after
+// Close, and each true result must be paired with release.
+func (r *redisCacheManager) acquire() bool {
+	r.state.RLock()
+	defer r.state.RUnlock()
+
+	if r.client == nil || r.closed {
+		return false
 	}
-	return r.client.Close()
+	r.inFlight.Add(1)
+	return true
+}
+
+// release marks an operation started by acquire as finished
+func (r *redisCacheManager) release() {
+	r.inFlight.Done()
 }
 
 // Set stores a value with the given key and expiration time
 func (r *redisCacheManager) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
-	var data []byte
-	var err error
+	data, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
 
+	return r.client.Set(ctx, key, data, expiration).Err()
+}
+
+// SetNX stores a value with SET NX, so only one of several concurrent callers stores it
+func (r *redisCacheManager) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	if !r.acquire() {
+		return false, errCacheNotConnected
+	}
+	defer r.release()
+
+	data, err := encodeValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(ctx, key, data, expiration).Result()
+}
+
+// encodeValue stores strings and byte slices as they are and anything else as JSON
+func encodeValue(value interface{}) ([]byte, error) {
 	switch v := value.(type) {
 	case string:
-		data = []byte(v)
+		return []byte(v), nil
 	case []byte:
-		data = v
+		return v, nil
 	default:
-		data, err = json.Marshal(value)
+		data, err := json.Marshal(value)
 		if err != nil {
-			return fmt.Errorf("failed to marshal value: %w", err)
+			return nil, fmt.Errorf("failed to marshal value: %w", err)
 		}
+		return data, nil
 	}
-
-	return r.client.Set(ctx, key, data, expiration).Err()
 }
 
 // Get retrieves a value by key
 func (r *redisCacheManager) Get(ctx context.Context, key string) (interface{}, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return nil, errCacheNotConnected
 	}
+	defer r.release()
 
 	val, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
+			r.counters.read(false)
 			return nil, errKeyNotFound
 		}
 		return nil, err
 	}
 
+	r.counters.read(true)
 	return val, nil
 }
 
+// GetOrSet returns the value of key, loading it once per process for all concurrent
+// callers on a miss. A hit returns the stored string, as Get does, while a load returns the
+// loader's value; GetOrSetObject decodes both to one type.
+func (r *redisCacheManager) GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	return getOrSet(ctx, &r.loads, r, key, expiration, loader)
+}
+
 // GetString retrieves a string value by key
 func (r *redisCacheManager) GetString(ctx context.Context, key string) (string, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return "", errCacheNotConnected
 	}
+	defer r.release()
 
 	val, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
+			r.counters.read(false)
 			return "", errKeyNotFound
 		}
 		return "", err
 	}
 
+	r.counters.read(true)
 	return val, nil
 }
 
@@ -159,73 +288,195 @@ func (r *redisCacheManager) GetFloat64(ctx context.Context, key string) (float64
 
 // Delete removes a value by key
 func (r *redisCacheManager) Delete(ctx context.Context, key string) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.Del(ctx, key).Err()
 }
 
 // Exists checks if a key exists in the cache
 func (r *redisCacheManager) Exists(ctx context.Context, key string) (bool, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return false, errCacheNotConnected
 	}
+	defer r.release()
 
 	count, err := r.client.Exists(ctx, key).Result()
 	return count > 0, err
 }
 
-// Keys returns all keys matching the given pattern
+// Keys returns all keys matching the given pattern. It collects them with SCAN rather than
+// KEYS, which blocks the server while it walks the whole keyspace.
 func (r *redisCacheManager) Keys(ctx context.Context, pattern string) ([]string, error) {
-	if r.client == nil {
-		return nil, errCacheNotConnected
+	seen := make(map[string]struct{})
+	keys := []string{}
+	err := r.scan(ctx, pattern, r.scanCount(), func(found []string) error {
+		for _, key := range found {
+			if _, dup := seen[key]; !dup {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return keys, nil
+}
 
-	return r.client.Keys(ctx, pattern).Result()
+// scanCount returns the configured SCAN COUNT hint
+func (r *redisCacheManager) scanCount() int64 {
+	if r.config.ScanCount > 0 {
+		return int64(r.config.ScanCount)
+	}
+	return defaultScanBatch
+}
+
+// scan passes each page of keys SCAN returns for pattern to fn, walking the nodes one
+// after another. Each command runs under its own acquire, released before fn is called, so
+// fn may use the cache and even Close it; the scan then ends with errCacheNotConnected.
+func (r *redisCacheManager) scan(ctx context.Context, pattern string, count int64, fn func(keys []string) error) error {
+	if !r.acquire() {
+		return errCacheNotConnected
+	}
+	nodes, err := r.nodes(ctx)
+	r.release()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		var cursor uint64
+		for {
+			if !r.acquire() {
+				return errCacheNotConnected
+			}
+			keys, next, err := node.Scan(ctx, cursor, pattern, count).Result()
+			r.release()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(keys); err != nil {
+					return err
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// ScanKeys iterates with SCAN, one node after another on a cluster, buffering its results so
+// fn always receives batch keys except for the last call. SCAN may return a key more than
+// once while the keyspace changes. fn runs without holding the connection, so it may call
+// Close without deadlocking.
+func (r *redisCacheManager) ScanKeys(ctx context.Context, pattern string, batch int, fn func(keys []string) error) error {
+	if batch <= 0 {
+		batch = defaultScanBatch
+	}
+
+	pending := make([]string, 0, batch)
+	err := r.scan(ctx, pattern, int64(batch), func(keys []string) error {
+		pending = append(pending, keys...)
+		for len(pending) >= batch {
+			if err := fn(pending[:batch:batch]); err != nil {
+				return err
+			}
+			pending = append(pending[:0:0], pending[batch:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fn(pending)
+	}
+	return nil
 }
 
 // Expire sets an expiration time for a key
 func (r *redisCacheManager) Expire(ctx context.Context, key string, expiration time.Duration) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.Expire(ctx, key, expiration).Err()
 }
 
 // TTL returns the time to live for a key
 func (r *redisCacheManager) TTL(ctx context.Context, key string) (time.Duration, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return 0, errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.TTL(ctx, key).Result()
 }
 
-// Clear removes all keys from the cache
+// Clear removes all keys from the cache with FLUSHDB or, when ClearPattern is set, deletes
+// the keys matching it. Those are all found with SCAN before any is deleted, since deleting
+// mid-scan can make some servers skip keys, and then deleted ScanCount at a time.
 func (r *redisCacheManager) Clear(ctx context.Context) error {
-	if r.client == nil {
+	if r.config.ClearPattern != "" {
+		keys, err := r.Keys(ctx, r.config.ClearPattern)
+		if err != nil {
+			return err
+		}
+		for len(keys) > 0 {
+			chunk := keys[:min(len(keys), int(r.scanCount()))]
+			if err := r.DeleteMultiple(ctx, chunk); err != nil {
+				return err
+			}
+			keys = keys[len(chunk):]
+		}
+		return nil
+	}
+
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
+
+	nodes, err := r.nodes(ctx)
+	if err != nil {
+		return err
+	}
 
-	return r.client.FlushDB(ctx).Err()
+	for _, node := range nodes {
+		if err := node.FlushDB(ctx).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Ping checks if Redis is accessible
 func (r *redisCacheManager) Ping(ctx context.Context) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.Ping(ctx).Err()
 }
 
 // SetMultiple stores multiple key-value pairs
 func (r *redisCacheManager) SetMultiple(ctx context.Context, pairs map[string]interface{}, expiration time.Duration) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
 	pipe := r.client.Pipeline()
 	for key, value := range pairs {
@@ -253,9 +504,10 @@ func (r *redisCacheManager) SetMultiple(ctx context.Context, pairs map[string]in
 
 // GetMultiple retrieves multiple values by keys
 func (r *redisCacheManager) GetMultiple(ctx context.Context, keys []string) (map[string]interface{}, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return nil, errCacheNotConnected
 	}
+	defer r.release()
 
 	pipe := r.client.Pipeline()
 	cmds := make(map[string]*redis.StringCmd)
@@ -272,8 +524,12 @@ func (r *redisCacheManager) GetMultiple(ctx context.Context, keys []string) (map
 	result := make(map[string]interface{})
 	for key, cmd := range cmds {
 		val, err := cmd.Result()
-		if err == nil {
+		switch err {
+		case nil:
 			result[key] = val
+			r.counters.read(true)
+		case redis.Nil:
+			r.counters.read(false)
 		}
 	}
 
@@ -282,39 +538,222 @@ func (r *redisCacheManager) GetMultiple(ctx context.Context, keys []string) (map
 
 // DeleteMultiple removes multiple keys
 func (r *redisCacheManager) DeleteMultiple(ctx context.Context, keys []string) error {
-	if r.client == nil {
+	if !r.acquire() {
 		return errCacheNotConnected
 	}
+	defer r.release()
 
 	if len(keys) == 0 {
 		return nil
 	}
 
+	return r.del(ctx, keys)
+}
+
+// del deletes keys. Callers must hold acquire.
+func (r *redisCacheManager) del(ctx context.Context, keys []string) error {
+	// A cluster rejects a multi-key DEL whose keys hash to different slots, so each key
+	// gets its own DEL in a pipeline, which the cluster client routes per slot.
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		return err
+	}
+
 	return r.client.Del(ctx, keys...).Err()
 }
 
 // Increment increments a numeric value
 func (r *redisCacheManager) Increment(ctx context.Context, key string, value int64) (int64, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return 0, errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.IncrBy(ctx, key, value).Result()
 }
 
 // Decrement decrements a numeric value
 func (r *redisCacheManager) Decrement(ctx context.Context, key string, value int64) (int64, error) {
-	if r.client == nil {
+	if !r.acquire() {
 		return 0, errCacheNotConnected
 	}
+	defer r.release()
 
 	return r.client.DecrBy(ctx, key, value).Result()
 }
 
-// Close closes the Redis connection
+// HSet sets one field of a hash with HSET, which leaves the key's TTL alone
+func (r *redisCacheManager) HSet(ctx context.Context, key, field string, value interface{}) error {
+	if !r.acquire() {
+		return errCacheNotConnected
+	}
+	defer r.release()
+
+	data, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	return hashError(r.client.HSet(ctx, key, field, data).Err())
+}
+
+// HGet retrieves one field of a hash as a string
+func (r *redisCacheManager) HGet(ctx context.Context, key, field string) (interface{}, error) {
+	if !r.acquire() {
+		return nil, errCacheNotConnected
+	}
+	defer r.release()
+
+	val, err := r.client.HGet(ctx, key, field).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, errKeyNotFound
+		}
+		return nil, hashError(err)
+	}
+
+	return val, nil
+}
+
+// HGetAll retrieves every field of a hash as strings
+func (r *redisCacheManager) HGetAll(ctx context.Context, key string) (map[string]interface{}, error) {
+	if !r.acquire() {
+		return nil, errCacheNotConnected
+	}
+	defer r.release()
+
+	values, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, hashError(err)
+	}
+
+	fields := make(map[string]interface{}, len(values))
+	for field, value := range values {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// HDel removes fields from a hash; Redis deletes the key with its last field
+func (r *redisCacheManager) HDel(ctx context.Context, key string, fields ...string) error {
+	if !r.acquire() {
+		return errCacheNotConnected
+	}
+	defer r.release()
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return hashError(r.client.HDel(ctx, key, fields...).Err())
+}
+
+// Stats returns the hits and misses of this manager and the key count from DBSIZE, summed
+// over the masters of a cluster
+func (r *redisCacheManager) Stats(ctx context.Context) (CacheStats, error) {
+	if !r.acquire() {
+		return CacheStats{}, errCacheNotConnected
+	}
+	defer r.release()
+
+	nodes, err := r.nodes(ctx)
+	if err != nil {
+		return CacheStats{}, err
+	}
+
+	var keys int64
+	for _, node := range nodes {
+		size, err := node.DBSize(ctx).Result()
+		if err != nil {
+			return CacheStats{}, err
+		}
+		keys += size
+	}
+	return r.counters.snapshot(keys), nil
+}
+
+// Lock takes the lock with SET NX PX and a random token. Unlock deletes the key only while
+// it still holds that token.
+func (r *redisCacheManager) Lock(ctx context.Context, key string, ttl time.Duration) (func() error, bool, error) {
+	if err := validateLockTTL(ttl); err != nil {
+		return nil, false, err
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !r.acquire() {
+		return nil, false, errCacheNotConnected
+	}
+	defer r.release()
+
+	acquired, err := r.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+
+	unlock := func() error {
+		if !r.acquire() {
+			return errCacheNotConnected
+		}
+		defer r.release()
+
+		// The caller's context may be done by the time a deferred unlock runs
+		deleted, err := releaseLockScript.Run(context.Background(), r.client, []string{key}, token).Int()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return errLockNotHeld
+		}
+		return nil
+	}
+	return unlock, true, nil
+}
+
+// publish sends payload to a pub/sub channel
+func (r *redisCacheManager) publish(ctx context.Context, channel string, payload []byte) error {
+	if !r.acquire() {
+		return errCacheNotConnected
+	}
+	defer r.release()
+
+	return r.client.Publish(ctx, channel, payload).Err()
+}
+
+// subscribe subscribes to a pub/sub channel, returning once Redis has confirmed it
+func (r *redisCacheManager) subscribe(ctx context.Context, channel string) (*redis.PubSub, error) {
+	if !r.acquire() {
+		return nil, errCacheNotConnected
+	}
+	defer r.release()
+
+	sub := r.client.Subscribe(ctx, channel)
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close rejects new operations, waits for in-flight ones (including pipelines) to finish
+// and then closes the Redis connection. Later calls are no-ops.
 func (r *redisCacheManager) Close() error {
-	if r.client == nil {
+	r.state.Lock()
+	client, closed := r.client, r.closed
+	r.closed = true
+	r.state.Unlock()
+
+	if client == nil || closed {
 		return nil
 	}
-	return r.client.Close()
+
+	r.inFlight.Wait()
+	return client.Close()
 }
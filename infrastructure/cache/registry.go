@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CacheRegistry holds named cache managers so a service can keep caches with different
+// backends and expiry policies side by side, e.g. a short-lived session cache next to a
+// long-lived reference-data cache
+type CacheRegistry struct {
+	mutex  sync.RWMutex
+	caches map[string]CacheManager
+}
+
+// NewCacheRegistry creates an empty registry
+func NewCacheRegistry() *CacheRegistry {
+	return &CacheRegistry{caches: make(map[string]CacheManager)}
+}
+
+// Register adds cacheManager under name. Names must be unique.
+func (r *CacheRegistry) Register(name string, cacheManager CacheManager) error {
+	if name == "" {
+		return errors.New("cache name is required")
+	}
+	if cacheManager == nil {
+		return fmt.Errorf("cache %q is nil", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.caches[name]; exists {
+		return fmt.Errorf("cache %q is already registered", name)
+	}
+	r.caches[name] = cacheManager
+	return nil
+}
+
+// Get returns the cache registered under name
+func (r *CacheRegistry) Get(name string) (CacheManager, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	cacheManager, ok := r.caches[name]
+	return cacheManager, ok
+}
+
+// Names returns the registered names in sorted order
+func (r *CacheRegistry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Connect connects every registered cache, stopping at the first failure
+func (r *CacheRegistry) Connect(ctx context.Context) error {
+	for _, name := range r.Names() {
+		cacheManager, _ := r.Get(name)
+		if err := cacheManager.Connect(ctx); err != nil {
+			return fmt.Errorf("failed to connect cache %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every registered cache and returns the failures joined together
+func (r *CacheRegistry) Close() error {
+	var errs []error
+	for _, name := range r.Names() {
+		cacheManager, _ := r.Get(name)
+		if err := cacheManager.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close cache %q: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// defaultTTLCacheManager applies a default expiration to writes that do not set one
+type defaultTTLCacheManager struct {
+	CacheManager
+	ttl time.Duration
+}
+
+// NewDefaultTTLCacheManager wraps base so that Set, SetNX, SetMultiple and GetOrSet with a zero
+// expiration store the value for ttl instead of indefinitely
+func NewDefaultTTLCacheManager(base CacheManager, ttl time.Duration) CacheManager {
+	return &defaultTTLCacheManager{
+		CacheManager: base,
+		ttl:          ttl,
+	}
+}
+
+func (d *defaultTTLCacheManager) expiration(expiration time.Duration) time.Duration {
+	if expiration == 0 {
+		return d.ttl
+	}
+	return expiration
+}
+
+func (d *defaultTTLCacheManager) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return d.CacheManager.Set(ctx, key, value, d.expiration(expiration))
+}
+
+func (d *defaultTTLCacheManager) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return d.CacheManager.SetNX(ctx, key, value, d.expiration(expiration))
+}
+
+func (d *defaultTTLCacheManager) SetMultiple(ctx context.Context, pairs map[string]interface{}, expiration time.Duration) error {
+	return d.CacheManager.SetMultiple(ctx, pairs, d.expiration(expiration))
+}
+
+func (d *defaultTTLCacheManager) GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	return d.CacheManager.GetOrSet(ctx, key, d.expiration(expiration), loader)
+}
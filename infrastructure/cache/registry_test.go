@@ -0,0 +1,54 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestCacheRegistryRejectsDuplicateNames(t *testing.T) {
+	registry := cache.NewCacheRegistry()
+	if err := registry.Register("session", newScanCache(t)); err != nil {
+		t.Fatalf("Failed to register cache: %v", err)
+	}
+	if err := registry.Register("session", newScanCache(t)); err == nil {
+		t.Fatal("Expected an error registering a duplicate name")
+	}
+	if err := registry.Register("", newScanCache(t)); err == nil {
+		t.Fatal("Expected an error registering an empty name")
+	}
+
+	if _, ok := registry.Get("session"); !ok {
+		t.Fatal("Expected the session cache to be registered")
+	}
+	if _, ok := registry.Get("reference"); ok {
+		t.Fatal("Expected no reference cache")
+	}
+}
+
+func TestDefaultTTLCacheManagerAppliesTTLToUnboundedWrites(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := cache.NewDefaultTTLCacheManager(newScanCache(t), time.Minute)
+
+	if err := cacheManager.Set(ctx, "default", "v", 0); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := cacheManager.Set(ctx, "explicit", "v", time.Hour); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := cacheManager.SetMultiple(ctx, map[string]interface{}{"multi": "v"}, 0); err != nil {
+		t.Fatalf("Failed to set multiple: %v", err)
+	}
+
+	for key, want := range map[string]time.Duration{"default": time.Minute, "explicit": time.Hour, "multi": time.Minute} {
+		ttl, err := cacheManager.TTL(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to get TTL of %s: %v", key, err)
+		}
+		if ttl <= want-time.Second || ttl > want {
+			t.Errorf("Expected TTL of %s near %v, got %v", key, want, ttl)
+		}
+	}
+}
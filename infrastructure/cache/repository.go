@@ -18,6 +18,10 @@ type CacheManager interface {
 	// Set stores a value with the given key and expiration time
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 
+	// SetNX stores a value only if the key is absent, reporting whether it was stored.
+	// The check and the write are atomic.
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+
 	// Get retrieves a value by key
 	Get(ctx context.Context, key string) (interface{}, error)
 
@@ -33,6 +37,13 @@ type CacheManager interface {
 	// GetFloat64 retrieves a float64 value by key
 	GetFloat64(ctx context.Context, key string) (float64, error)
 
+	// GetOrSet returns the value of key, calling loader and caching its result for
+	// expiration on a miss. Concurrent misses for one key share a single loader call, and
+	// a loader error is returned to all of them without being cached. Like Get, a Redis
+	// hit returns the stored JSON while a load returns the loader's value; GetOrSetObject
+	// returns one type for both.
+	GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error)
+
 	// Delete removes a value by key
 	Delete(ctx context.Context, key string) error
 
@@ -42,6 +53,11 @@ type CacheManager interface {
 	// Keys returns all keys matching the given pattern
 	Keys(ctx context.Context, pattern string) ([]string, error)
 
+	// ScanKeys passes keys matching the pattern to fn in slices of at most batch keys,
+	// stopping at the first error fn returns. Unlike Keys it does not hold the whole
+	// keyspace in memory at once.
+	ScanKeys(ctx context.Context, pattern string, batch int, fn func(keys []string) error) error
+
 	// Expire sets an expiration time for a key
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 
@@ -69,6 +85,27 @@ type CacheManager interface {
 	// Decrement decrements a numeric value
 	Decrement(ctx context.Context, key string, value int64) (int64, error)
 
+	// HSet sets one field of the hash at key, creating the hash without an expiration if
+	// it does not exist. An existing hash keeps its TTL.
+	HSet(ctx context.Context, key, field string, value interface{}) error
+
+	// HGet retrieves one field of the hash at key
+	HGet(ctx context.Context, key, field string) (interface{}, error)
+
+	// HGetAll retrieves every field of the hash at key, or an empty map if it does not exist
+	HGetAll(ctx context.Context, key string) (map[string]interface{}, error)
+
+	// HDel removes fields from the hash at key, removing the key with its last field
+	HDel(ctx context.Context, key string, fields ...string) error
+
+	// Stats returns the hit, miss and eviction counters and the current key count
+	Stats(ctx context.Context) (CacheStats, error)
+
+	// Lock tries once to take the lock named key for ttl. When acquired is true, unlock
+	// releases the lock and returns an IsLockNotHeld error if it had already expired and
+	// possibly been taken by someone else.
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func() error, acquired bool, err error)
+
 	// Close closes the cache manager and releases resources
 	Close() error
 }
@@ -80,6 +117,17 @@ type CacheConfig struct {
 	RedisPassword string `json:"redis_password"`
 	RedisDB       int    `json:"redis_db"`
 
+	// RedisClusterAddrs lists seed nodes of a Redis Cluster. When set, the cache connects to
+	// the cluster instead of RedisAddr and RedisDB is ignored.
+	RedisClusterAddrs []string `json:"redis_cluster_addrs"`
+
+	// RedisMasterName and RedisSentinelAddrs locate the master through Redis Sentinel, so the
+	// cache follows failovers. RedisSentinelPassword authenticates to the sentinels
+	// themselves; RedisPassword is still used for the master.
+	RedisMasterName       string   `json:"redis_master_name"`
+	RedisSentinelAddrs    []string `json:"redis_sentinel_addrs"`
+	RedisSentinelPassword string   `json:"redis_sentinel_password"`
+
 	// Connection pool settings
 	MaxRetries   int           `json:"max_retries"`
 	PoolSize     int           `json:"pool_size"`
@@ -90,8 +138,46 @@ type CacheConfig struct {
 	PoolTimeout  time.Duration `json:"pool_timeout"`
 	IdleTimeout  time.Duration `json:"idle_timeout"`
 
+	// ScanCount is the COUNT hint for each SCAN call Keys and Clear make on Redis. Larger
+	// values mean fewer round trips, each blocking the server slightly longer. Defaults to 100.
+	ScanCount int `json:"scan_count"`
+
+	// KeyPrefix, when set, is prepended to every key by managers built with
+	// NewCacheManagerFactory, and Clear removes only the keys under it, so several services
+	// can share one Redis database
+	KeyPrefix string `json:"key_prefix"`
+
+	// ClearPattern, when set, makes Clear on Redis delete only the keys matching it, found
+	// with SCAN, instead of flushing the whole database
+	ClearPattern string `json:"clear_pattern"`
+
 	// In-memory cache settings
-	DefaultExpiration time.Duration `json:"default_expiration"`
-	CleanupInterval   time.Duration `json:"cleanup_interval"`
-	MaxSize           int           `json:"max_size"`
+	DefaultExpiration time.Duration  `json:"default_expiration"`
+	CleanupInterval   time.Duration  `json:"cleanup_interval"`
+	MaxSize           int            `json:"max_size"`
+	EvictionPolicy    EvictionPolicy `json:"eviction_policy"`
+
+	// OnEvicted is called by the in-memory manager with each entry it removes because it
+	// expired, made room for another under MaxSize, or was deleted or cleared. It runs after
+	// the cache's lock is released, on the goroutine that removed the entry.
+	OnEvicted func(key string, value interface{}) `json:"-"`
+
+	// Tiered cache settings. L1TTL bounds how long an in-memory copy is served, which also
+	// bounds staleness should an invalidation be lost; it defaults to a minute.
+	// InvalidationChannel is the Redis pub/sub channel instances announce writes on so the
+	// others drop their copies; it defaults to "cache:invalidate".
+	L1TTL               time.Duration `json:"l1_ttl"`
+	InvalidationChannel string        `json:"invalidation_channel"`
 }
+
+// EvictionPolicy selects which in-memory entry is evicted when MaxSize is reached
+type EvictionPolicy string
+
+const (
+	// EvictionLRU evicts the entry that was read or written least recently (the default)
+	EvictionLRU EvictionPolicy = "lru"
+	// EvictionFIFO evicts the entry that was inserted first
+	EvictionFIFO EvictionPolicy = "fifo"
+	// EvictionRandom evicts an arbitrary entry
+	EvictionRandom EvictionPolicy = "random"
+)
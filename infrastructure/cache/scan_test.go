@@ -0,0 +1,254 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func newRedisCache(t *testing.T) cache.CacheManager {
+	server := miniredis.RunT(t)
+
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceRedis, &cache.CacheConfig{RedisAddr: server.Addr()})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { cacheManager.Close() })
+	return cacheManager
+}
+
+func newScanCache(t *testing.T) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{MaxSize: 10000})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	return cacheManager
+}
+
+func scanBackends(t *testing.T) map[string]cache.CacheManager {
+	return map[string]cache.CacheManager{
+		"inmemory": newScanCache(t),
+		"redis":    newRedisCache(t),
+	}
+}
+
+func TestScanKeysVisitsEveryKeyInBatches(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			want := make([]string, 0, 250)
+			for i := 0; i < 250; i++ {
+				key := fmt.Sprintf("session:%03d", i)
+				want = append(want, key)
+				if err := cacheManager.Set(ctx, key, i, time.Minute); err != nil {
+					t.Fatalf("Failed to set %s: %v", key, err)
+				}
+			}
+
+			seen := make(map[string]bool)
+			batches := 0
+			err := cacheManager.ScanKeys(ctx, "*", 40, func(keys []string) error {
+				batches++
+				if len(keys) > 40 {
+					t.Errorf("Batch of %d keys exceeds the batch size", len(keys))
+				}
+				for _, key := range keys {
+					seen[key] = true
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ScanKeys failed: %v", err)
+			}
+
+			got := make([]string, 0, len(seen))
+			for key := range seen {
+				got = append(got, key)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("Scanned %d keys, want %d", len(got), len(want))
+			}
+			if batches < 7 {
+				t.Errorf("Expected at least 7 batches, got %d", batches)
+			}
+		})
+	}
+}
+
+func TestScanKeysStopsOnCallbackError(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 50; i++ {
+				if err := cacheManager.Set(ctx, fmt.Sprintf("k%d", i), i, time.Minute); err != nil {
+					t.Fatalf("Failed to set key: %v", err)
+				}
+			}
+
+			stop := errors.New("stop")
+			calls := 0
+			err := cacheManager.ScanKeys(ctx, "*", 10, func(keys []string) error {
+				calls++
+				return stop
+			})
+			if !errors.Is(err, stop) {
+				t.Fatalf("Expected callback error, got %v", err)
+			}
+			if calls != 1 {
+				t.Errorf("Expected the scan to stop after one batch, got %d calls", calls)
+			}
+		})
+	}
+}
+
+func TestScanKeysCallbackMayCloseTheCache(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 10; i++ {
+				cacheManager.Set(ctx, fmt.Sprintf("job:%d", i), i, time.Minute)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				done <- cacheManager.ScanKeys(ctx, "*", 2, func(keys []string) error {
+					return cacheManager.Close()
+				})
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("ScanKeys deadlocked when its callback closed the cache")
+			}
+		})
+	}
+}
+
+func TestScanKeysEndsWhenCallbackReadsKeys(t *testing.T) {
+	cacheManager := newScanCache(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 100; i++ {
+		if err := cacheManager.Set(ctx, fmt.Sprintf("user:%02d", i), i, time.Minute); err != nil {
+			t.Fatalf("Failed to set key: %v", err)
+		}
+	}
+
+	visited := 0
+	err := cacheManager.ScanKeys(ctx, "*", 10, func(keys []string) error {
+		for _, key := range keys {
+			visited++
+			// Under LRU a read moves the key to the back of the eviction order
+			if _, err := cacheManager.Get(ctx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanKeys failed: %v", err)
+	}
+	if visited != 100 {
+		t.Errorf("Expected each key to be visited once, got %d visits", visited)
+	}
+}
+
+func TestRedisScanKeysMatchesPattern(t *testing.T) {
+	ctx := context.Background()
+	cacheManager := newRedisCache(t)
+
+	for _, key := range []string{"user:1", "user:2", "order:1"} {
+		if err := cacheManager.Set(ctx, key, "v", time.Minute); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	var got []string
+	err := cacheManager.ScanKeys(ctx, "user:*", 1, func(keys []string) error {
+		got = append(got, keys...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanKeys failed: %v", err)
+	}
+
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[user:1 user:2]" {
+		t.Errorf("Got keys %v", got)
+	}
+}
+
+func TestPrefixedScanKeysStripsPrefix(t *testing.T) {
+	ctx := context.Background()
+	base := newScanCache(t)
+	tenant := cache.NewPrefixedCacheManager(base, "tenant:1:")
+
+	if err := tenant.Set(ctx, "profile", "v", time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := base.Set(ctx, "tenant:2:profile", "v", time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+
+	var got []string
+	err := tenant.ScanKeys(ctx, "*", 10, func(keys []string) error {
+		got = append(got, keys...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanKeys failed: %v", err)
+	}
+	if fmt.Sprint(got) != "[profile]" {
+		t.Errorf("Got keys %v", got)
+	}
+}
+
+func TestRedisClearPatternKeepsOtherKeys(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceRedis, &cache.CacheConfig{
+		RedisAddr:    server.Addr(),
+		ScanCount:    10,
+		ClearPattern: "orders:*",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer cacheManager.Close()
+
+	for i := 0; i < 50; i++ {
+		server.Set(fmt.Sprintf("orders:%d", i), "order")
+	}
+	server.Set("billing:1", "invoice")
+
+	keys, err := cacheManager.Keys(ctx, "orders:*")
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if len(keys) != 50 {
+		t.Errorf("Keys returned %d keys, want 50", len(keys))
+	}
+
+	if err := cacheManager.Clear(ctx); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if remaining := server.Keys(); len(remaining) != 1 || remaining[0] != "billing:1" {
+		t.Errorf("Keys left after Clear = %v, want [billing:1]", remaining)
+	}
+}
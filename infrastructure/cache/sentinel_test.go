@@ -0,0 +1,38 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestRedisSentinelConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  cache.CacheConfig
+		wantErr bool
+	}{
+		{name: "direct address", config: cache.CacheConfig{RedisAddr: "localhost:6379"}},
+		{name: "sentinel", config: cache.CacheConfig{RedisMasterName: "mymaster", RedisSentinelAddrs: []string{"localhost:26379"}}},
+		{name: "master name without sentinels", config: cache.CacheConfig{RedisMasterName: "mymaster"}, wantErr: true},
+		{name: "sentinels without master name", config: cache.CacheConfig{RedisSentinelAddrs: []string{"localhost:26379"}}, wantErr: true},
+		{
+			name: "sentinel and cluster",
+			config: cache.CacheConfig{
+				RedisMasterName:    "mymaster",
+				RedisSentinelAddrs: []string{"localhost:26379"},
+				RedisClusterAddrs:  []string{"localhost:7000"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cache.NewRedisCacheManager(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRedisCacheManager() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetNXStoresOnlyWhenAbsent(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			stored, err := cacheManager.SetNX(ctx, "token", "first", time.Minute)
+			if err != nil || !stored {
+				t.Fatalf("SetNX = %v, %v; want stored", stored, err)
+			}
+
+			stored, err = cacheManager.SetNX(ctx, "token", "second", time.Minute)
+			if err != nil || stored {
+				t.Fatalf("Second SetNX = %v, %v; want not stored", stored, err)
+			}
+
+			if value, _ := cacheManager.GetString(ctx, "token"); value != "first" {
+				t.Errorf("GetString = %q, want %q", value, "first")
+			}
+		})
+	}
+}
+
+func TestSetNXHasOneWinnerUnderContention(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			var winners atomic.Int32
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					stored, err := cacheManager.SetNX(ctx, "contended", fmt.Sprint(i), time.Minute)
+					if err != nil {
+						t.Errorf("SetNX failed: %v", err)
+					}
+					if stored {
+						winners.Add(1)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if got := winners.Load(); got != 1 {
+				t.Errorf("%d callers stored the key, want 1", got)
+			}
+		})
+	}
+}
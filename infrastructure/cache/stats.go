@@ -0,0 +1,51 @@
+package cache
+
+import "sync/atomic"
+
+// CacheStats is a snapshot of a cache manager's counters. Hits and Misses count key reads
+// since the manager was created, across Get, the typed getters, GetMultiple and GetOrSet.
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"` // entries dropped to respect MaxSize; always 0 on Redis
+	Keys      int64 `json:"keys"`      // keys currently stored, including other clients' on Redis
+
+	// L1Hits and L2Hits split Hits between the tiers of a tiered cache
+	L1Hits int64 `json:"l1_hits,omitempty"`
+	L2Hits int64 `json:"l2_hits,omitempty"`
+}
+
+// HitRatio returns the share of reads that found their key, or 0 before any read
+func (s CacheStats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(reads)
+}
+
+// cacheCounters accumulates the counters reported by Stats without taking any lock
+type cacheCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// read records the outcome of looking up one key
+func (c *cacheCounters) read(found bool) {
+	if found {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+// snapshot returns the counters as CacheStats with the given key count
+func (c *cacheCounters) snapshot(keys int64) CacheStats {
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Keys:      keys,
+	}
+}
@@ -0,0 +1,64 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func TestStatsCountsHitsAndMisses(t *testing.T) {
+	for name, cacheManager := range scanBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			if err := cacheManager.Set(ctx, "present", "value", time.Minute); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			cacheManager.Get(ctx, "present")
+			cacheManager.GetString(ctx, "present")
+			cacheManager.Get(ctx, "absent")
+			cacheManager.GetMultiple(ctx, []string{"present", "absent"})
+
+			stats, err := cacheManager.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats failed: %v", err)
+			}
+			if stats.Hits != 3 || stats.Misses != 2 || stats.Keys != 1 {
+				t.Errorf("Stats = %+v, want 3 hits, 2 misses and 1 key", stats)
+			}
+			if ratio := stats.HitRatio(); ratio != 0.6 {
+				t.Errorf("HitRatio = %v, want 0.6", ratio)
+			}
+		})
+	}
+}
+
+func TestStatsCountsEvictions(t *testing.T) {
+	ctx := context.Background()
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{MaxSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := cacheManager.Set(ctx, key, key, time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	stats, err := cacheManager.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Evictions != 2 || stats.Keys != 2 {
+		t.Errorf("Stats = %+v, want 2 evictions and 2 keys", stats)
+	}
+}
+
+func TestHitRatioWithoutReads(t *testing.T) {
+	if ratio := (cache.CacheStats{}).HitRatio(); ratio != 0 {
+		t.Errorf("HitRatio = %v, want 0", ratio)
+	}
+}
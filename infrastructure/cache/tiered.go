@@ -0,0 +1,460 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultL1TTL               = time.Minute
+	defaultInvalidationChannel = "cache:invalidate"
+)
+
+// invalidation is published after every write so that other instances drop their L1 copies
+// of Keys, or all of them when All is set
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	All    bool     `json:"all,omitempty"`
+}
+
+// tieredCacheManager serves reads from an in-memory L1 in front of Redis as L2. Writes go
+// to Redis first and are then applied to the local L1 and announced to other instances.
+// L1 holds values as Redis returns them, so a read gives the same result from either tier.
+type tieredCacheManager struct {
+	l1      CacheManager
+	l2      *redisCacheManager
+	l1TTL   time.Duration
+	channel string
+	origin  string // identifies this instance's own invalidations
+
+	sub       *redis.PubSub
+	listening sync.WaitGroup
+	closeOnce sync.Once
+	loads     singleflight.Group
+
+	l1Hits atomic.Int64
+	l2Hits atomic.Int64
+	misses atomic.Int64
+}
+
+// NewTieredCacheManager creates a cache that keeps recently read keys in memory, bounded
+// by MaxSize and L1TTL, in front of Redis
+func NewTieredCacheManager(config *CacheConfig) (CacheManager, error) {
+	if config == nil {
+		return nil, errors.New("cache config is required")
+	}
+
+	l1Config := *config
+	l1, err := NewInMemoryCacheManager(&l1Config)
+	if err != nil {
+		return nil, err
+	}
+	l2, err := NewRedisCacheManager(config)
+	if err != nil {
+		return nil, err
+	}
+	origin, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &tieredCacheManager{
+		l1:      l1,
+		l2:      l2.(*redisCacheManager),
+		l1TTL:   config.L1TTL,
+		channel: config.InvalidationChannel,
+		origin:  origin,
+	}
+	if manager.l1TTL <= 0 {
+		manager.l1TTL = defaultL1TTL
+	}
+	if manager.channel == "" {
+		manager.channel = defaultInvalidationChannel
+	}
+	return manager, nil
+}
+
+// Connect connects both tiers and starts listening for other instances' invalidations
+func (t *tieredCacheManager) Connect(ctx context.Context) error {
+	if err := t.l1.Connect(ctx); err != nil {
+		return err
+	}
+	if err := t.l2.Connect(ctx); err != nil {
+		return err
+	}
+
+	sub, err := t.l2.subscribe(ctx, t.channel)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", t.channel, err)
+	}
+	t.sub = sub
+
+	t.listening.Add(1)
+	go t.listen(sub.Channel())
+	return nil
+}
+
+// listen drops the L1 copies named by invalidations from other instances until the
+// subscription is closed
+func (t *tieredCacheManager) listen(messages <-chan *redis.Message) {
+	defer t.listening.Done()
+
+	for message := range messages {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(message.Payload), &inv); err != nil || inv.Origin == t.origin {
+			continue
+		}
+		ctx := context.Background()
+		if inv.All {
+			t.l1.Clear(ctx)
+		} else {
+			t.l1.DeleteMultiple(ctx, inv.Keys)
+		}
+	}
+}
+
+// invalidate drops keys from L1 and tells the other instances to do the same
+func (t *tieredCacheManager) invalidate(ctx context.Context, keys ...string) error {
+	if err := t.l1.DeleteMultiple(ctx, keys); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Origin: t.origin, Keys: keys})
+}
+
+// announce publishes an invalidation for the other instances
+func (t *tieredCacheManager) announce(ctx context.Context, inv invalidation) error {
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	if err := t.l2.publish(ctx, t.channel, payload); err != nil {
+		return fmt.Errorf("failed to publish cache invalidation: %w", err)
+	}
+	return nil
+}
+
+// l1Expiration caps an L2 expiration at L1TTL
+func (t *tieredCacheManager) l1Expiration(expiration time.Duration) time.Duration {
+	if expiration <= 0 || expiration > t.l1TTL {
+		return t.l1TTL
+	}
+	return expiration
+}
+
+// Disconnect stops listening for invalidations and closes both tiers
+func (t *tieredCacheManager) Disconnect(ctx context.Context) error {
+	return t.Close()
+}
+
+// Set writes the value through to Redis and L1, then invalidates other instances' copies
+func (t *tieredCacheManager) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if err := t.l2.Set(ctx, key, data, expiration); err != nil {
+		return err
+	}
+	if err := t.l1.Set(ctx, key, string(data), t.l1Expiration(expiration)); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Origin: t.origin, Keys: []string{key}})
+}
+
+// SetNX stores the value in Redis only if the key is absent there
+func (t *tieredCacheManager) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	stored, err := t.l2.SetNX(ctx, key, value, expiration)
+	if err != nil || !stored {
+		return stored, err
+	}
+	return true, t.invalidate(ctx, key)
+}
+
+// Get reads L1 first and falls back to Redis, copying a value found there into L1
+func (t *tieredCacheManager) Get(ctx context.Context, key string) (interface{}, error) {
+	value, err := t.l1.Get(ctx, key)
+	if err == nil {
+		t.l1Hits.Add(1)
+		return value, nil
+	}
+
+	value, err = t.l2.Get(ctx, key)
+	if err != nil {
+		if IsNotFound(err) {
+			t.misses.Add(1)
+		}
+		return nil, err
+	}
+	t.l2Hits.Add(1)
+
+	if err := t.l1.Set(ctx, key, value, t.l1TTL); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetOrSet returns the value of key, loading it once per process for all concurrent
+// callers on a miss in both tiers
+func (t *tieredCacheManager) GetOrSet(ctx context.Context, key string, expiration time.Duration, loader Loader) (interface{}, error) {
+	return getOrSet(ctx, &t.loads, t, key, expiration, loader)
+}
+
+// GetString retrieves a string value by key
+func (t *tieredCacheManager) GetString(ctx context.Context, key string) (string, error) {
+	value, err := t.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// GetInt retrieves an integer value by key
+func (t *tieredCacheManager) GetInt(ctx context.Context, key string) (int, error) {
+	val, err := t.GetString(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, errInvalidKeyType
+	}
+
+	return result, nil
+}
+
+// GetBool retrieves a boolean value by key
+func (t *tieredCacheManager) GetBool(ctx context.Context, key string) (bool, error) {
+	val, err := t.GetString(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, errInvalidKeyType
+	}
+
+	return result, nil
+}
+
+// GetFloat64 retrieves a float64 value by key
+func (t *tieredCacheManager) GetFloat64(ctx context.Context, key string) (float64, error) {
+	val, err := t.GetString(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, errInvalidKeyType
+	}
+
+	return result, nil
+}
+
+// Delete removes the key from Redis and from every instance's L1
+func (t *tieredCacheManager) Delete(ctx context.Context, key string) error {
+	if err := t.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, key)
+}
+
+// Exists checks Redis, which holds every key L1 does
+func (t *tieredCacheManager) Exists(ctx context.Context, key string) (bool, error) {
+	return t.l2.Exists(ctx, key)
+}
+
+// Keys returns the matching keys in Redis
+func (t *tieredCacheManager) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return t.l2.Keys(ctx, pattern)
+}
+
+// ScanKeys scans the matching keys in Redis
+func (t *tieredCacheManager) ScanKeys(ctx context.Context, pattern string, batch int, fn func(keys []string) error) error {
+	return t.l2.ScanKeys(ctx, pattern, batch, fn)
+}
+
+// Expire sets the expiration in Redis and drops the L1 copies, whose expiry may now be later
+func (t *tieredCacheManager) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	if err := t.l2.Expire(ctx, key, expiration); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, key)
+}
+
+// TTL returns the time to live of the key in Redis
+func (t *tieredCacheManager) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.l2.TTL(ctx, key)
+}
+
+// Clear empties Redis and every instance's L1
+func (t *tieredCacheManager) Clear(ctx context.Context) error {
+	if err := t.l2.Clear(ctx); err != nil {
+		return err
+	}
+	if err := t.l1.Clear(ctx); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Origin: t.origin, All: true})
+}
+
+// Ping checks that Redis is accessible
+func (t *tieredCacheManager) Ping(ctx context.Context) error {
+	return t.l2.Ping(ctx)
+}
+
+// SetMultiple writes the pairs through to Redis and L1
+func (t *tieredCacheManager) SetMultiple(ctx context.Context, pairs map[string]interface{}, expiration time.Duration) error {
+	encoded := make(map[string]interface{}, len(pairs))
+	keys := make([]string, 0, len(pairs))
+	for key, value := range pairs {
+		data, err := encodeValue(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
+		}
+		encoded[key] = string(data)
+		keys = append(keys, key)
+	}
+
+	if err := t.l2.SetMultiple(ctx, encoded, expiration); err != nil {
+		return err
+	}
+	if err := t.l1.SetMultiple(ctx, encoded, t.l1Expiration(expiration)); err != nil {
+		return err
+	}
+	return t.announce(ctx, invalidation{Origin: t.origin, Keys: keys})
+}
+
+// GetMultiple reads L1 first and fetches the rest from Redis, copying them into L1
+func (t *tieredCacheManager) GetMultiple(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	result, err := t.l1.GetMultiple(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	t.l1Hits.Add(int64(len(result)))
+
+	missing := make([]string, 0, len(keys)-len(result))
+	for _, key := range keys {
+		if _, found := result[key]; !found {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	fetched, err := t.l2.GetMultiple(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	t.l2Hits.Add(int64(len(fetched)))
+	t.misses.Add(int64(len(missing) - len(fetched)))
+
+	if len(fetched) > 0 {
+		if err := t.l1.SetMultiple(ctx, fetched, t.l1TTL); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range fetched {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// DeleteMultiple removes the keys from Redis and from every instance's L1
+func (t *tieredCacheManager) DeleteMultiple(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := t.l2.DeleteMultiple(ctx, keys); err != nil {
+		return err
+	}
+	return t.invalidate(ctx, keys...)
+}
+
+// Increment increments the value in Redis and invalidates the L1 copies
+func (t *tieredCacheManager) Increment(ctx context.Context, key string, value int64) (int64, error) {
+	result, err := t.l2.Increment(ctx, key, value)
+	if err != nil {
+		return 0, err
+	}
+	return result, t.invalidate(ctx, key)
+}
+
+// Decrement decrements the value in Redis and invalidates the L1 copies
+func (t *tieredCacheManager) Decrement(ctx context.Context, key string, value int64) (int64, error) {
+	return t.Increment(ctx, key, -value)
+}
+
+// HSet sets a field in Redis. Hashes are not kept in L1.
+func (t *tieredCacheManager) HSet(ctx context.Context, key, field string, value interface{}) error {
+	return t.l2.HSet(ctx, key, field, value)
+}
+
+// HGet retrieves a field from Redis
+func (t *tieredCacheManager) HGet(ctx context.Context, key, field string) (interface{}, error) {
+	return t.l2.HGet(ctx, key, field)
+}
+
+// HGetAll retrieves every field of a hash from Redis
+func (t *tieredCacheManager) HGetAll(ctx context.Context, key string) (map[string]interface{}, error) {
+	return t.l2.HGetAll(ctx, key)
+}
+
+// HDel removes fields from a hash in Redis
+func (t *tieredCacheManager) HDel(ctx context.Context, key string, fields ...string) error {
+	return t.l2.HDel(ctx, key, fields...)
+}
+
+// Stats returns the hits served by each tier, the reads neither could serve, the entries
+// L1 evicted and the number of keys in Redis
+func (t *tieredCacheManager) Stats(ctx context.Context) (CacheStats, error) {
+	l1, err := t.l1.Stats(ctx)
+	if err != nil {
+		return CacheStats{}, err
+	}
+	l2, err := t.l2.Stats(ctx)
+	if err != nil {
+		return CacheStats{}, err
+	}
+
+	l1Hits, l2Hits := t.l1Hits.Load(), t.l2Hits.Load()
+	return CacheStats{
+		Hits:      l1Hits + l2Hits,
+		Misses:    t.misses.Load(),
+		Evictions: l1.Evictions,
+		Keys:      l2.Keys,
+		L1Hits:    l1Hits,
+		L2Hits:    l2Hits,
+	}, nil
+}
+
+// Lock takes the lock in Redis, so it excludes every instance
+func (t *tieredCacheManager) Lock(ctx context.Context, key string, ttl time.Duration) (func() error, bool, error) {
+	return t.l2.Lock(ctx, key, ttl)
+}
+
+// Close stops listening for invalidations and closes both tiers. Later calls are no-ops.
+func (t *tieredCacheManager) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		if t.sub != nil {
+			t.sub.Close()
+			t.listening.Wait()
+		}
+		err = errors.Join(t.l2.Close(), t.l1.Close())
+	})
+	return err
+}
@@ -0,0 +1,124 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+func newTieredCache(t *testing.T, server *miniredis.Miniredis, maxSize int) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceTiered, &cache.CacheConfig{
+		RedisAddr: server.Addr(),
+		MaxSize:   maxSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { cacheManager.Close() })
+	return cacheManager
+}
+
+func TestTieredCacheServesRepeatReadsFromL1(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	writer := newTieredCache(t, server, 100)
+	reader := newTieredCache(t, server, 100)
+
+	if err := writer.Set(ctx, "greeting", "hello", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if value, err := reader.GetString(ctx, "greeting"); err != nil || value != "hello" {
+			t.Fatalf("GetString = %q, %v; want hello", value, err)
+		}
+	}
+	if _, err := reader.Get(ctx, "absent"); !cache.IsNotFound(err) {
+		t.Errorf("Get of a missing key error = %v, want not found", err)
+	}
+
+	stats, err := reader.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.L2Hits != 1 || stats.L1Hits != 2 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Stats = %+v, want 1 L2 hit, 2 L1 hits and 1 miss", stats)
+	}
+
+	// A value only in L1 is served without Redis
+	server.Del("greeting")
+	if value, err := reader.GetString(ctx, "greeting"); err != nil || value != "hello" {
+		t.Errorf("GetString after the Redis copy went = %q, %v; want the L1 copy", value, err)
+	}
+}
+
+func TestTieredCacheInvalidatesOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	writer := newTieredCache(t, server, 100)
+	reader := newTieredCache(t, server, 100)
+
+	if err := writer.Set(ctx, "config", "v1", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if value, _ := reader.GetString(ctx, "config"); value != "v1" {
+		t.Fatalf("GetString = %q, want v1", value)
+	}
+
+	if err := writer.Set(ctx, "config", "v2", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	eventually(t, func() bool {
+		value, _ := reader.GetString(ctx, "config")
+		return value == "v2"
+	}, "reader never saw the updated value")
+
+	if err := writer.Delete(ctx, "config"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	eventually(t, func() bool {
+		_, err := reader.Get(ctx, "config")
+		return cache.IsNotFound(err)
+	}, "reader kept serving a deleted key")
+}
+
+func TestTieredCacheBoundsL1(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	cacheManager := newTieredCache(t, server, 2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cacheManager.Set(ctx, key, key, time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	stats, err := cacheManager.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Evictions != 1 || stats.Keys != 3 {
+		t.Errorf("Stats = %+v, want 1 L1 eviction and 3 keys in Redis", stats)
+	}
+
+	// The evicted key is still read from Redis
+	if value, err := cacheManager.GetString(ctx, "a"); err != nil || value != "a" {
+		t.Errorf("GetString = %q, %v; want a", value, err)
+	}
+}
+
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
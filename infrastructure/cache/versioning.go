@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionMarker starts every versioned value. It can never begin a JSON document,
+// so values written before versioning was introduced are read as version 0.
+const versionMarker = "\x1e"
+
+// Migrator upgrades data stored under an older schema version to the current one.
+// Returning an error invalidates the entry and the read is treated as a miss.
+type Migrator func(fromVersion int, data []byte) ([]byte, error)
+
+type valueSchema struct {
+	version  int
+	migrator Migrator
+}
+
+// VersionedCache stores JSON values behind a schema version header so that a change
+// to a cached struct does not leave stale entries that decode incorrectly
+type VersionedCache struct {
+	CacheManager
+	// Decode unmarshals values read by GetInto; json.Unmarshal when nil. Set it to
+	// DecodeJSONStrict when values hold large integers in untyped fields.
+	Decode  func(data []byte, dest interface{}) error
+	schemas map[string]valueSchema
+	mutex   sync.RWMutex
+}
+
+// NewVersionedCache wraps a cache manager with value versioning
+func NewVersionedCache(cacheManager CacheManager) *VersionedCache {
+	return &VersionedCache{
+		CacheManager: cacheManager,
+		schemas:      make(map[string]valueSchema),
+	}
+}
+
+// Register sets the current schema version for keys starting with prefix. The
+// migrator may be nil, in which case entries with an older version are dropped.
+func (c *VersionedCache) Register(prefix string, version int, migrator Migrator) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.schemas[prefix] = valueSchema{version: version, migrator: migrator}
+}
+
+// SetVersioned stores value as JSON tagged with the current version for its key
+func (c *VersionedCache) SetVersioned(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return c.CacheManager.Set(ctx, key, encodeVersioned(c.schemaFor(key).version, data), expiration)
+}
+
+// GetInto decodes the value at key into dest. Entries written under an older version
+// are upgraded through the registered migrator and written back; entries that cannot
+// be upgraded are deleted and reported as a miss.
+func (c *VersionedCache) GetInto(ctx context.Context, key string, dest interface{}) error {
+	raw, err := c.CacheManager.GetString(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	schema := c.schemaFor(key)
+	version, data := decodeVersioned(raw)
+
+	switch {
+	case version == schema.version:
+	case version > schema.version:
+		// Written by a newer deployment; leave it in place for the instances that understand it
+		return errKeyNotFound
+	default:
+		data, err = c.migrate(ctx, key, schema, version, data)
+		if err != nil {
+			return errKeyNotFound
+		}
+	}
+
+	decode := c.Decode
+	if decode == nil {
+		decode = json.Unmarshal
+	}
+	if err := decode(data, dest); err != nil {
+		_ = c.CacheManager.Delete(ctx, key)
+		return errKeyNotFound
+	}
+
+	return nil
+}
+
+func (c *VersionedCache) migrate(ctx context.Context, key string, schema valueSchema, version int, data []byte) ([]byte, error) {
+	if schema.migrator == nil {
+		_ = c.CacheManager.Delete(ctx, key)
+		return nil, errKeyNotFound
+	}
+
+	upgraded, err := schema.migrator(version, data)
+	if err != nil {
+		_ = c.CacheManager.Delete(ctx, key)
+		return nil, err
+	}
+
+	// Keep the remaining lifetime of the original entry when writing it back
+	ttl, err := c.CacheManager.TTL(ctx, key)
+	if err != nil || ttl < 0 {
+		ttl = 0
+	}
+	_ = c.CacheManager.Set(ctx, key, encodeVersioned(schema.version, upgraded), ttl)
+
+	return upgraded, nil
+}
+
+// schemaFor returns the schema registered for the longest prefix matching key
+func (c *VersionedCache) schemaFor(key string) valueSchema {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var match valueSchema
+	matchLen := -1
+	for prefix, schema := range c.schemas {
+		if strings.HasPrefix(key, prefix) && len(prefix) > matchLen {
+			match = schema
+			matchLen = len(prefix)
+		}
+	}
+	return match
+}
+
+func encodeVersioned(version int, data []byte) string {
+	return versionMarker + strconv.Itoa(version) + ":" + string(data)
+}
+
+func decodeVersioned(raw string) (int, []byte) {
+	if !strings.HasPrefix(raw, versionMarker) {
+		return 0, []byte(raw)
+	}
+
+	header, data, found := strings.Cut(raw[len(versionMarker):], ":")
+	if !found {
+		return 0, []byte(raw)
+	}
+
+	version, err := strconv.Atoi(header)
+	if err != nil {
+		return 0, []byte(raw)
+	}
+	return version, []byte(data)
+}
+
+// DecodeJSONStrict unmarshals data like json.Unmarshal, except that numbers decoded into
+// interface{} values become json.Number instead of float64. Integers beyond 2^53, such as
+// offsets or Snowflake IDs in a map[string]interface{}, keep every digit.
+func DecodeJSONStrict(data []byte, dest interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(dest); err != nil {
+		return err
+	}
+
+	// json.Unmarshal rejects anything after the value; the decoder would leave it unread
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+type profileV2 struct {
+	FullName string `json:"full_name"`
+}
+
+func newVersionedCache(t *testing.T) (*cache.VersionedCache, cache.CacheManager) {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	if err != nil {
+		t.Fatalf("Failed to create cache manager: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return cache.NewVersionedCache(cacheManager), cacheManager
+}
+
+func TestVersionedCacheRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	versioned, _ := newVersionedCache(t)
+	versioned.Register("profile:", 2, nil)
+
+	if err := versioned.SetVersioned(ctx, "profile:1", profileV2{FullName: "Jane Doe"}, time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	var profile profileV2
+	if err := versioned.GetInto(ctx, "profile:1", &profile); err != nil {
+		t.Fatalf("Failed to get value: %v", err)
+	}
+	if profile.FullName != "Jane Doe" {
+		t.Errorf("Expected Jane Doe, got %s", profile.FullName)
+	}
+}
+
+func TestVersionedCacheMigratesOldVersion(t *testing.T) {
+	ctx := context.Background()
+	versioned, cacheManager := newVersionedCache(t)
+
+	// Written before versioning existed, with the old field name
+	if err := cacheManager.Set(ctx, "profile:1", `{"name":"Jane Doe"}`, time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	versioned.Register("profile:", 2, func(fromVersion int, data []byte) ([]byte, error) {
+		var old struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+		return json.Marshal(profileV2{FullName: old.Name})
+	})
+
+	var profile profileV2
+	if err := versioned.GetInto(ctx, "profile:1", &profile); err != nil {
+		t.Fatalf("Failed to get migrated value: %v", err)
+	}
+	if profile.FullName != "Jane Doe" {
+		t.Errorf("Expected migrated Jane Doe, got %q", profile.FullName)
+	}
+
+	// The upgraded value is written back and readable without the migrator
+	versioned.Register("profile:", 2, nil)
+	profile = profileV2{}
+	if err := versioned.GetInto(ctx, "profile:1", &profile); err != nil {
+		t.Fatalf("Failed to read written-back value: %v", err)
+	}
+	if profile.FullName != "Jane Doe" {
+		t.Errorf("Expected Jane Doe after write-back, got %q", profile.FullName)
+	}
+}
+
+func TestVersionedCacheStaleVersionWithoutMigratorIsMiss(t *testing.T) {
+	ctx := context.Background()
+	versioned, cacheManager := newVersionedCache(t)
+
+	versioned.Register("profile:", 1, nil)
+	if err := versioned.SetVersioned(ctx, "profile:1", map[string]string{"name": "Jane Doe"}, time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	versioned.Register("profile:", 2, nil)
+
+	var profile profileV2
+	err := versioned.GetInto(ctx, "profile:1", &profile)
+	if !cache.IsNotFound(err) {
+		t.Fatalf("Expected a cache miss, got %v", err)
+	}
+
+	exists, _ := cacheManager.Exists(ctx, "profile:1")
+	if exists {
+		t.Error("Stale entry should be deleted")
+	}
+}
+
+func TestVersionedCacheFailedMigrationIsMiss(t *testing.T) {
+	ctx := context.Background()
+	versioned, cacheManager := newVersionedCache(t)
+
+	if err := cacheManager.Set(ctx, "profile:1", `{"name":"Jane Doe"}`, time.Minute); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	versioned.Register("profile:", 2, func(fromVersion int, data []byte) ([]byte, error) {
+		return nil, errors.New("cannot upgrade")
+	})
+
+	var profile profileV2
+	if err := versioned.GetInto(ctx, "profile:1", &profile); !cache.IsNotFound(err) {
+		t.Fatalf("Expected a cache miss, got %v", err)
+	}
+}
+
+func TestVersionedCacheStrictDecodeKeepsLargeIntegers(t *testing.T) {
+	ctx := context.Background()
+	versioned, _ := newVersionedCache(t)
+
+	const id int64 = 1<<62 + 1
+	if err := versioned.SetVersioned(ctx, "order:1", map[string]interface{}{"id": id}, time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+
+	var lossy map[string]interface{}
+	if err := versioned.GetInto(ctx, "order:1", &lossy); err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	if int64(lossy["id"].(float64)) == id {
+		t.Fatalf("Expected the default decoder to round %d", id)
+	}
+
+	versioned.Decode = cache.DecodeJSONStrict
+	var exact map[string]interface{}
+	if err := versioned.GetInto(ctx, "order:1", &exact); err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	got, err := exact["id"].(json.Number).Int64()
+	if err != nil || got != id {
+		t.Fatalf("Expected %d, got %v (%v)", id, exact["id"], err)
+	}
+}
+
+func TestDecodeJSONStrictRejectsTrailingData(t *testing.T) {
+	var value map[string]interface{}
+	if err := cache.DecodeJSONStrict([]byte(`{"id": 1} {"id": 2}`), &value); err == nil {
+		t.Error("DecodeJSONStrict accepted a second value after the first")
+	}
+	if err := cache.DecodeJSONStrict([]byte(" {\"id\": 1}\n"), &value); err != nil {
+		t.Errorf("DecodeJSONStrict rejected surrounding whitespace: %v", err)
+	}
+}
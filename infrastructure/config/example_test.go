@@ -54,22 +54,19 @@ func Example_environmentVariables() {
 		return
 	}
 
-	// Cast to viperConfigManager to access additional methods
-	if viperManager, ok := configManager.(*viperConfigManager); ok {
-		// Enable environment variable support with prefix
-		viperManager.SetEnvironmentVariables("APP")
-
-		// Load configuration
-		if err := viperManager.Load(EnvLocal, ModuleHealth); err != nil {
-			fmt.Printf("Error loading config: %v\n", err)
-			return
-		}
-
-		// Now you can access config values that can be overridden by environment variables
-		// For example, APP_WEB_PORT will override web.port
-		webPort := viperManager.GetInt("web.port")
-		fmt.Printf("Web Port (with env override): %d\n", webPort)
+	// Read overrides from BILLING_* instead of the default APP_* variables
+	configManager.EnableEnvOverrides("BILLING")
+
+	// Load configuration
+	if err := configManager.Load(EnvLocal, ModuleHealth); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
 	}
+
+	// Now you can access config values that can be overridden by environment variables
+	// For example, BILLING_WEB_PORT will override web.port
+	webPort := configManager.GetInt("web.port")
+	fmt.Printf("Web Port (with env override): %d\n", webPort)
 }
 
 // TestConfigManagerBasicFunctionality tests basic config manager functionality
@@ -28,9 +28,36 @@ type ConfigManager interface {
 	// IsSet checks if a configuration key is set
 	IsSet(key string) bool
 
+	// Unmarshal decodes the whole configuration into the struct pointed to by v, matching
+	// fields by name or by their mapstructure tag. time.Duration fields accept strings such
+	// as "30s", and slices accept comma-separated strings.
+	Unmarshal(v interface{}) error
+
+	// UnmarshalKey decodes the configuration under key into v, as Unmarshal does
+	UnmarshalKey(key string, v interface{}) error
+
+	// Validate returns an error listing every key in requiredKeys that is unset or empty,
+	// so a misconfigured deployment fails at startup rather than at first use
+	Validate(requiredKeys []string) error
+
+	// ValidateStruct unmarshals the configuration into the struct v points to and checks
+	// its validate tags, such as validate:"required", returning an error that lists every
+	// failing field by its config key
+	ValidateStruct(v interface{}) error
+
 	// GetAll returns all configuration as a map
 	GetAll() map[string]interface{}
 
 	// GetViper returns the underlying viper instance for advanced operations
 	GetViper() *viper.Viper
+
+	// EnableEnvOverrides sets the prefix of the environment variables that override
+	// configuration keys, e.g. APP_JWT_SECRET for jwt.secret with prefix APP. Overrides are
+	// on by default with DefaultEnvPrefix. Call it before Load and before sharing the manager.
+	EnableEnvOverrides(prefix string)
+
+	// Watch reloads the configuration when a loaded file changes and then calls onChange.
+	// The getters are safe to call while a reload is in progress. Calling Watch again adds
+	// another callback without starting more watchers.
+	Watch(onChange func()) error
 }
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// structValidator checks validate tags, naming fields by their config key
+var structValidator = newStructValidator()
+
+func newStructValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return v
+}
+
+// missingKeys returns the keys in required that config does not set, or sets to an empty
+// string, slice or map
+func missingKeys(config ConfigManager, required []string) []string {
+	var missing []string
+	for _, key := range required {
+		if !config.IsSet(key) || isEmpty(config.Get(key)) {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(rv.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	default:
+		return false
+	}
+}
+
+// validateStruct checks the validate tags of the struct v points to and lists every failing
+// field by its config key, e.g. "jwt.secret (required)"
+func validateStruct(v interface{}) error {
+	err := structValidator.Struct(v)
+
+	var failures validator.ValidationErrors
+	if !errors.As(err, &failures) {
+		return err
+	}
+
+	// Drop the root struct's name, which anonymous structs lack, so paths read as config keys
+	root := reflect.Indirect(reflect.ValueOf(v)).Type().Name() + "."
+
+	fields := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		key := strings.TrimPrefix(failure.Namespace(), root)
+		fields = append(fields, fmt.Sprintf("%s (%s)", key, failure.Tag()))
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(fields, ", "))
+}
@@ -1,156 +1,363 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+// configExtensions are the config file formats the loaders accept, in the order they are
+// looked for, so a JSON file wins over a YAML or TOML file with the same name
+var configExtensions = []string{"json", "yaml", "yml", "toml"}
+
+// errConfigNotFound is returned when no file with a supported extension exists
+var errConfigNotFound = errors.New("config file not found")
+
+// findConfigFile returns the first file named name plus one of configExtensions in paths,
+// searching the paths in order
+func findConfigFile(name string, paths ...string) (string, error) {
+	for _, path := range paths {
+		for _, ext := range configExtensions {
+			file := filepath.Join(path, name+"."+ext)
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				return file, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s.{%s} in %s", errConfigNotFound, name, strings.Join(configExtensions, ","), strings.Join(paths, ", "))
+}
+
+// readConfigFile reads the config file named name from paths into v, parsing it according
+// to its extension
+func readConfigFile(v *viper.Viper, name string, paths ...string) error {
+	file, err := findConfigFile(name, paths...)
+	if err != nil {
+		return err
+	}
+	v.SetConfigFile(file)
+	return v.ReadInConfig()
+}
+
+// viperConfigManager reads configuration through viper. Load and reloads triggered by
+// Watch build a fresh viper instance and swap it in under mutex, so the getters may run
+// concurrently with a reload and see either the old or the new configuration, never a mix.
 type viperConfigManager struct {
+	mutex       sync.RWMutex // guards viper, source, files, envPrefix, watching and onChange
 	viper       *viper.Viper
 	environment string
 	module      string
+	envPrefix   string       // set by EnableEnvOverrides and applied to every load
+	source      configSource // reads the configuration again for reloads triggered by Watch
+	files       []string     // config files read by the last load, watched by Watch
+	watching    bool         // set once Watch has started the file watchers
+	onChange    []func()     // callbacks registered by Watch, called after each reload
+
+	reloading sync.Mutex // serializes reloads triggered by Watch
 }
 
-// NewViperConfigManager creates a new Viper-based configuration manager
+// configSource reads a configuration into a new viper instance and returns it with the
+// files it read
+type configSource func() (*viper.Viper, []string, error)
+
+// DefaultEnvPrefix is the environment variable prefix a new config manager reads overrides
+// from, so APP_JWT_SECRET overrides jwt.secret
+const DefaultEnvPrefix = "APP"
+
+// NewViperConfigManager creates a new Viper-based configuration manager that reads
+// environment overrides with DefaultEnvPrefix
 func NewViperConfigManager() (ConfigManager, error) {
 	v := viper.New()
+	bindEnvironment(v, DefaultEnvPrefix)
 	return &viperConfigManager{
-		viper: v,
+		viper:     v,
+		envPrefix: DefaultEnvPrefix,
 	}, nil
 }
 
 // Load loads configuration from the specified environment and module
 func (v *viperConfigManager) Load(environment, module string) error {
+	loaded, files, err := v.load(environment, module)
+	if err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 	v.environment = environment
 	v.module = module
+	v.viper = loaded
+	v.source = func() (*viper.Viper, []string, error) { return v.load(environment, module) }
+	v.files = files
+	return nil
+}
 
-	// Reset viper instance
-	v.viper = viper.New()
-
-	// Set config type
-	v.viper.SetConfigType("json")
+// load reads the configuration of environment and module into a new viper instance and
+// returns it with the files it read
+func (v *viperConfigManager) load(environment, module string) (*viper.Viper, []string, error) {
+	loaded := viper.New()
+	var files []string
 
-	// Load base configuration from config/{module}/{environment}.json
+	// Load base configuration from config/{module}/{environment}.{json,yaml,yml,toml}
 	if module != "" {
 		configPath := filepath.Join("config", module)
-		v.viper.AddConfigPath(configPath)
-		v.viper.SetConfigName(environment)
 
-		if err := v.viper.ReadInConfig(); err != nil {
-			return fmt.Errorf("failed to read base config for module %s and environment %s: %w", module, environment, err)
+		if err := readConfigFile(loaded, environment, configPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to read base config for module %s and environment %s: %w", module, environment, err)
 		}
+		files = append(files, loaded.ConfigFileUsed())
 	}
 
 	// Load module-specific overrides from modules/{module}/config if they exist
 	if module != "" {
 		moduleConfigPath := filepath.Join("modules", module, "config")
 
-		// Try to read module-specific config file
+		// Try to read module-specific config file, in whichever format it is written
 		moduleViper := viper.New()
-		moduleViper.SetConfigType("json")
-		moduleViper.AddConfigPath(moduleConfigPath)
-		moduleViper.SetConfigName(environment)
-
-		// If module-specific config exists, merge it
-		if err := moduleViper.ReadInConfig(); err == nil {
-			// Merge module-specific config into main config
-			for key, value := range moduleViper.AllSettings() {
-				v.viper.Set(key, value)
+
+		// If module-specific config exists, merge it key by key into the config layer, below
+		// environment overrides
+		if err := readConfigFile(moduleViper, environment, moduleConfigPath); err == nil {
+			if err := loaded.MergeConfigMap(moduleViper.AllSettings()); err != nil {
+				return nil, nil, fmt.Errorf("failed to merge module config for module %s and environment %s: %w", module, environment, err)
 			}
+			files = append(files, moduleViper.ConfigFileUsed())
 		}
 	}
 
+	v.mutex.RLock()
+	bindEnvironment(loaded, v.envPrefix)
+	v.mutex.RUnlock()
+
+	return loaded, files, nil
+}
+
+// Watch reloads the configuration whenever one of the files read by Load or LoadFromPaths
+// changes, then calls onChange. A module override file that did not exist at Load is not
+// watched. A change that fails to load or leaves the configuration empty, such as a file
+// caught mid-write, keeps the previous configuration. It is logged, and onChange is not
+// called. onChange runs on a watcher goroutine. The watchers start on the first call;
+// later calls only add their callback, so a change still reloads once and calls each
+// callback once.
+func (v *viperConfigManager) Watch(onChange func()) error {
+	v.mutex.Lock()
+	files := v.files
+	if len(files) == 0 {
+		v.mutex.Unlock()
+		return errors.New("no config files loaded to watch")
+	}
+	if onChange != nil {
+		v.onChange = append(v.onChange, onChange)
+	}
+	started := v.watching
+	v.watching = true
+	v.mutex.Unlock()
+
+	if started {
+		return nil
+	}
+	for _, file := range files {
+		watcher := viper.New()
+		watcher.SetConfigFile(file)
+		watcher.OnConfigChange(func(fsnotify.Event) {
+			v.reload()
+		})
+		watcher.WatchConfig()
+	}
 	return nil
 }
 
+// reload reads the source of the last load again and swaps the result in if it loads
+// cleanly and is not empty
+func (v *viperConfigManager) reload() {
+	v.reloading.Lock()
+	defer v.reloading.Unlock()
+
+	v.mutex.RLock()
+	source, previous := v.source, v.files
+	v.mutex.RUnlock()
+
+	loaded, files, err := source()
+	if err == nil && len(loaded.AllKeys()) == 0 {
+		err = errors.New("configuration is empty")
+	}
+	if err != nil {
+		log.Printf("config: reload of %s failed, keeping the previous configuration: %v", strings.Join(previous, ", "), err)
+		return
+	}
+
+	v.mutex.Lock()
+	v.viper = loaded
+	v.files = files
+	callbacks := v.onChange
+	v.mutex.Unlock()
+
+	for _, onChange := range callbacks {
+		onChange()
+	}
+}
+
+// current returns the viper instance of the latest load
+func (v *viperConfigManager) current() *viper.Viper {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return v.viper
+}
+
 // Get retrieves a configuration value by key
 func (v *viperConfigManager) Get(key string) interface{} {
-	return v.viper.Get(key)
+	return v.current().Get(key)
 }
 
 // GetString retrieves a string configuration value
 func (v *viperConfigManager) GetString(key string) string {
-	return v.viper.GetString(key)
+	return v.current().GetString(key)
 }
 
 // GetInt retrieves an integer configuration value
 func (v *viperConfigManager) GetInt(key string) int {
-	return v.viper.GetInt(key)
+	return v.current().GetInt(key)
 }
 
 // GetBool retrieves a boolean configuration value
 func (v *viperConfigManager) GetBool(key string) bool {
-	return v.viper.GetBool(key)
+	return v.current().GetBool(key)
 }
 
 // GetFloat64 retrieves a float64 configuration value
 func (v *viperConfigManager) GetFloat64(key string) float64 {
-	return v.viper.GetFloat64(key)
+	return v.current().GetFloat64(key)
 }
 
 // IsSet checks if a configuration key is set
 func (v *viperConfigManager) IsSet(key string) bool {
-	return v.viper.IsSet(key)
+	return v.current().IsSet(key)
+}
+
+// Unmarshal decodes the whole configuration into out
+func (v *viperConfigManager) Unmarshal(out interface{}) error {
+	return v.current().Unmarshal(out)
+}
+
+// UnmarshalKey decodes the configuration under key into out
+func (v *viperConfigManager) UnmarshalKey(key string, out interface{}) error {
+	return v.current().UnmarshalKey(key, out)
+}
+
+// Validate reports the required keys that are unset or empty
+func (v *viperConfigManager) Validate(requiredKeys []string) error {
+	if missing := missingKeys(v, requiredKeys); len(missing) > 0 {
+		return fmt.Errorf("missing required config keys: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ValidateStruct unmarshals into out and checks its validate tags
+func (v *viperConfigManager) ValidateStruct(out interface{}) error {
+	if err := v.Unmarshal(out); err != nil {
+		return err
+	}
+	return validateStruct(out)
 }
 
 // GetAll returns all configuration as a map
 func (v *viperConfigManager) GetAll() map[string]interface{} {
-	return v.viper.AllSettings()
+	return v.current().AllSettings()
 }
 
-// GetViper returns the underlying viper instance for advanced operations
+// GetViper returns the underlying viper instance for advanced operations. A reload replaces
+// the instance, so callers that watch for changes should call GetViper again afterwards.
 func (v *viperConfigManager) GetViper() *viper.Viper {
-	return v.viper
+	return v.current()
 }
 
 // LoadFromPaths loads configuration from specific paths (utility method)
 func (v *viperConfigManager) LoadFromPaths(configName string, paths ...string) error {
-	v.viper = viper.New()
-	v.viper.SetConfigType("json")
-	v.viper.SetConfigName(configName)
+	loaded, files, err := v.loadFromPaths(configName, paths...)
+	if err != nil {
+		return err
+	}
 
-	for _, path := range paths {
-		v.viper.AddConfigPath(path)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.viper = loaded
+	v.source = func() (*viper.Viper, []string, error) { return v.loadFromPaths(configName, paths...) }
+	v.files = files
+	return nil
+}
+
+// loadFromPaths reads the config file named configName from paths into a new viper instance
+// and returns it with the file it read
+func (v *viperConfigManager) loadFromPaths(configName string, paths ...string) (*viper.Viper, []string, error) {
+	loaded := viper.New()
+	if err := readConfigFile(loaded, configName, paths...); err != nil {
+		return nil, nil, err
 	}
 
-	return v.viper.ReadInConfig()
+	v.mutex.RLock()
+	bindEnvironment(loaded, v.envPrefix)
+	v.mutex.RUnlock()
+
+	return loaded, []string{loaded.ConfigFileUsed()}, nil
+}
+
+// EnableEnvOverrides makes PREFIX_SECTION_KEY environment variables override section.key,
+// in place of DefaultEnvPrefix. An empty prefix reads SECTION_KEY.
+func (v *viperConfigManager) EnableEnvOverrides(prefix string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.envPrefix = prefix
+	bindEnvironment(v.viper, prefix)
 }
 
-// SetEnvironmentVariables enables environment variable support with prefix
+// SetEnvironmentVariables enables environment variable support with prefix.
+//
+// Deprecated: use EnableEnvOverrides.
 func (v *viperConfigManager) SetEnvironmentVariables(prefix string) {
-	v.viper.SetEnvPrefix(prefix)
-	v.viper.AutomaticEnv()
-	v.viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.EnableEnvOverrides(prefix)
+}
+
+// bindEnvironment makes config read PREFIX_SECTION_KEY environment variables for section.key
+func bindEnvironment(config *viper.Viper, prefix string) {
+	config.SetEnvPrefix(prefix)
+	config.AutomaticEnv()
+	config.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 }
 
 // GetEnvironment returns the currently loaded environment
 func (v *viperConfigManager) GetEnvironment() string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
 	return v.environment
 }
 
 // GetModule returns the currently loaded module
 func (v *viperConfigManager) GetModule() string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
 	return v.module
 }
 
 // NewViper creates a new Viper configuration loader with simplified setup
 // This provides backwards compatibility with the existing simple Viper usage
 // configPath: path to config directory (e.g., "config/healthcare")
-// configName: name of config file without extension (e.g., "local", "development", "production")
+// configName: name of config file without extension (e.g., "local", "development", "production");
+// the file may be JSON, YAML or TOML, chosen by its extension
 func NewViper(configPath, configName string) *viper.Viper {
 	config := viper.New()
 
-	config.SetConfigName(configName)
-	config.SetConfigType("json")
-	config.AddConfigPath(configPath)
-	config.AddConfigPath("./../../" + configPath)
-	config.AddConfigPath("./../" + configPath)
-	config.AddConfigPath("./" + configPath)
-
-	err := config.ReadInConfig()
+	err := readConfigFile(config, configName,
+		configPath,
+		"./../../"+configPath,
+		"./../"+configPath,
+		"./"+configPath,
+	)
 	if err != nil {
 		panic(fmt.Errorf("fatal error config file: %w", err))
 	}
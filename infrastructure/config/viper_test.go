@@ -0,0 +1,352 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadDetectsFormatFromExtension(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "app:\n  name: billing\nweb:\n  port: 8080\n")
+	writeConfigFile(t, "modules/billing/config/local.toml", "[web]\nport = 9090\n")
+
+	configManager, err := NewViperConfigManager()
+	if err != nil {
+		t.Fatalf("Failed to create config manager: %v", err)
+	}
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if name := configManager.GetString("app.name"); name != "billing" {
+		t.Errorf("app.name = %q, want billing", name)
+	}
+	if port := configManager.GetInt("web.port"); port != 9090 {
+		t.Errorf("web.port = %d, want the module override 9090", port)
+	}
+}
+
+func TestLoadPrefersJSON(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.json", `{"app": {"name": "from json"}}`)
+	writeConfigFile(t, "config/billing/local.yaml", "app:\n  name: from yaml\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if name := configManager.GetString("app.name"); name != "from json" {
+		t.Errorf("app.name = %q, want the JSON value", name)
+	}
+}
+
+func TestLoadWithoutConfigFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err == nil {
+		t.Error("Load succeeded without a config file")
+	}
+}
+
+func TestWatchReloadsChangedFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "feature:\n  enabled: false\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	if err := configManager.Watch(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	writeConfigFile(t, "config/billing/local.yaml", "feature:\n  enabled: true\n")
+
+	deadline := time.After(5 * time.Second)
+	for !configManager.GetBool("feature.enabled") {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("Configuration was not reloaded after the file changed")
+		}
+	}
+}
+
+func TestWatchTwiceCallsEachCallbackPerReload(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "feature:\n  enabled: false\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var first, second atomic.Int32
+	if err := configManager.Watch(func() { first.Add(1) }); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if err := configManager.Watch(func() { second.Add(1) }); err != nil {
+		t.Fatalf("second Watch failed: %v", err)
+	}
+
+	writeConfigFile(t, "config/billing/local.yaml", "feature:\n  enabled: true\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for second.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if first.Load() != second.Load() {
+		t.Errorf("callbacks ran %d and %d times, want the same count", first.Load(), second.Load())
+	}
+	if second.Load() == 0 {
+		t.Fatal("Configuration was not reloaded after the file changed")
+	}
+}
+
+func TestWatchReloadsLoadFromPaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "service.yaml")
+	writeConfigFile(t, file, "feature:\n  enabled: false\nname: billing\n")
+
+	manager, _ := NewViperConfigManager()
+	configManager := manager.(*viperConfigManager)
+	if err := configManager.LoadFromPaths("service", dir); err != nil {
+		t.Fatalf("LoadFromPaths failed: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	if err := configManager.Watch(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	writeConfigFile(t, file, "feature:\n  enabled: true\nname: billing\n")
+
+	deadline := time.After(5 * time.Second)
+	for !configManager.GetBool("feature.enabled") {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("Configuration was not reloaded after the file changed")
+		}
+	}
+	if name := configManager.GetString("name"); name != "billing" {
+		t.Errorf("name = %q after reload, want billing", name)
+	}
+}
+
+func TestWatchKeepsConfigWhenReloadIsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "service.yaml")
+	writeConfigFile(t, file, "name: billing\n")
+
+	manager, _ := NewViperConfigManager()
+	configManager := manager.(*viperConfigManager)
+	if err := configManager.LoadFromPaths("service", dir); err != nil {
+		t.Fatalf("LoadFromPaths failed: %v", err)
+	}
+
+	var reloads atomic.Int32
+	if err := configManager.Watch(func() { reloads.Add(1) }); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	writeConfigFile(t, file, "")
+	time.Sleep(300 * time.Millisecond)
+
+	if name := configManager.GetString("name"); name != "billing" {
+		t.Errorf("name = %q after an empty reload, want the previous billing", name)
+	}
+	if reloads.Load() != 0 {
+		t.Errorf("onChange ran %d times for an empty reload", reloads.Load())
+	}
+}
+
+func TestWatchBeforeLoad(t *testing.T) {
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Watch(func() {}); err == nil {
+		t.Error("Watch succeeded before Load")
+	}
+}
+
+func TestUnmarshalIntoTypedConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", `
+web:
+  port: 8080
+  read_timeout: 30s
+  allowed_origins: https://a.example,https://b.example
+database:
+  host: db.internal
+`)
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	type webConfig struct {
+		Port           int           `mapstructure:"port"`
+		ReadTimeout    time.Duration `mapstructure:"read_timeout"`
+		AllowedOrigins []string      `mapstructure:"allowed_origins"`
+	}
+	var appConfig struct {
+		Web      webConfig `mapstructure:"web"`
+		Database struct {
+			Host string
+		}
+	}
+	if err := configManager.Unmarshal(&appConfig); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if appConfig.Web.Port != 8080 || appConfig.Web.ReadTimeout != 30*time.Second || appConfig.Database.Host != "db.internal" {
+		t.Errorf("Unmarshal = %+v", appConfig)
+	}
+	if len(appConfig.Web.AllowedOrigins) != 2 {
+		t.Errorf("AllowedOrigins = %v, want two origins", appConfig.Web.AllowedOrigins)
+	}
+
+	var web webConfig
+	if err := configManager.UnmarshalKey("web", &web); err != nil {
+		t.Fatalf("UnmarshalKey failed: %v", err)
+	}
+	if web.Port != 8080 || web.ReadTimeout != 30*time.Second {
+		t.Errorf("UnmarshalKey = %+v", web)
+	}
+}
+
+func TestValidateListsEveryMissingKey(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "jwt:\n  secret: \"\"\n  ttl: 15m\nweb:\n  port: 0\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if err := configManager.Validate([]string{"jwt.ttl", "web.port"}); err != nil {
+		t.Errorf("Validate of set keys failed: %v", err)
+	}
+
+	err := configManager.Validate([]string{"jwt.secret", "jwt.ttl", "database.host"})
+	if err == nil {
+		t.Fatal("Validate succeeded with missing keys")
+	}
+	if got, want := err.Error(), "missing required config keys: jwt.secret, database.host"; got != want {
+		t.Errorf("Validate error = %q, want %q", got, want)
+	}
+}
+
+func TestValidateStructUsesConfigKeys(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "jwt:\n  ttl: 15m\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var appConfig struct {
+		JWT struct {
+			Secret string        `mapstructure:"secret" validate:"required"`
+			TTL    time.Duration `mapstructure:"ttl" validate:"required"`
+		} `mapstructure:"jwt"`
+		Database struct {
+			Host string `validate:"required"`
+		}
+	}
+	err := configManager.ValidateStruct(&appConfig)
+	if err == nil {
+		t.Fatal("ValidateStruct succeeded with missing fields")
+	}
+	if got, want := err.Error(), "invalid config: jwt.secret (required), database.host (required)"; got != want {
+		t.Errorf("ValidateStruct error = %q, want %q", got, want)
+	}
+	if appConfig.JWT.TTL != 15*time.Minute {
+		t.Errorf("TTL = %v, want it unmarshaled before validation", appConfig.JWT.TTL)
+	}
+}
+
+func TestValidateStructNamedRoot(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "web:\n  port: 8080\n")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	type AppConfig struct {
+		Web struct {
+			Port int    `mapstructure:"port" validate:"required"`
+			Host string `mapstructure:"host" validate:"required"`
+		} `mapstructure:"web"`
+	}
+	err := configManager.ValidateStruct(&AppConfig{})
+	if err == nil || err.Error() != "invalid config: web.host (required)" {
+		t.Errorf("ValidateStruct error = %v, want web.host (required)", err)
+	}
+}
+
+func TestEnvOverridesBeatFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeConfigFile(t, "config/billing/local.yaml", "jwt:\n  secret: from-file\n  issuer: billing\nweb:\n  port: 8080\n  host: localhost\n")
+	writeConfigFile(t, "modules/billing/config/local.yaml", "web:\n  port: 9090\n")
+	t.Setenv("APP_JWT_SECRET", "from-env")
+	t.Setenv("APP_WEB_PORT", "7070")
+	t.Setenv("BILLING_JWT_ISSUER", "from-custom-prefix")
+
+	configManager, _ := NewViperConfigManager()
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if secret := configManager.GetString("jwt.secret"); secret != "from-env" {
+		t.Errorf("jwt.secret = %q, want the APP_JWT_SECRET value", secret)
+	}
+	if port := configManager.GetInt("web.port"); port != 7070 {
+		t.Errorf("web.port = %d, want APP_WEB_PORT to beat the module override", port)
+	}
+	if host := configManager.GetString("web.host"); host != "localhost" {
+		t.Errorf("web.host = %q, want the base value kept next to the module override", host)
+	}
+
+	configManager.EnableEnvOverrides("BILLING")
+	if err := configManager.Load(EnvLocal, "billing"); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if issuer := configManager.GetString("jwt.issuer"); issuer != "from-custom-prefix" {
+		t.Errorf("jwt.issuer = %q, want the BILLING_JWT_ISSUER value", issuer)
+	}
+	if secret := configManager.GetString("jwt.secret"); secret != "from-file" {
+		t.Errorf("jwt.secret = %q, want APP_ variables ignored after changing the prefix", secret)
+	}
+}
@@ -13,12 +13,12 @@ var (
 	errInvalidLoggerInstance = errors.New("invalid log instance")
 )
 
-func NewLoggerFactory(instance int) (Logger, error) {
+func NewLoggerFactory(instance int, opts ...Option) (Logger, error) {
 	switch instance {
 	case InstanceZapLogger:
-		return NewZapLogger()
+		return NewZapLogger(opts...)
 	case InstanceLogrusLogger:
-		return NewLogrusLogger()
+		return NewLogrusLogger(opts...)
 	default:
 		return nil, errInvalidLoggerInstance
 	}
@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DefaultLevel is the level used when none is configured
+const DefaultLevel = logrus.InfoLevel
+
+// ParseLevel reads a level name such as "debug" or "warn". For older configs it also accepts
+// a logrus level number, from 0 (panic) to 6 (trace). An empty or nil value means
+// DefaultLevel; anything else is an error rather than a silent fallback.
+func ParseLevel(value interface{}) (logrus.Level, error) {
+	switch v := value.(type) {
+	case nil:
+		return DefaultLevel, nil
+	case string:
+		name := strings.TrimSpace(v)
+		if name == "" {
+			return DefaultLevel, nil
+		}
+		if number, err := strconv.Atoi(name); err == nil {
+			return levelFromNumber(number)
+		}
+		return logrus.ParseLevel(name)
+	case int:
+		return levelFromNumber(v)
+	case int32:
+		return levelFromNumber(int(v))
+	case int64:
+		return levelFromNumber(int(v))
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("not a valid logrus Level: %v", v)
+		}
+		return levelFromNumber(int(v))
+	default:
+		return 0, fmt.Errorf("not a valid logrus Level: %v", v)
+	}
+}
+
+func levelFromNumber(number int) (logrus.Level, error) {
+	if number < int(logrus.PanicLevel) || number > int(logrus.TraceLevel) {
+		return 0, fmt.Errorf("not a valid logrus Level: %d", number)
+	}
+	return logrus.Level(number), nil
+}
+
+// LevelFromConfig returns the level configured under log.level, so a service can refuse to
+// start with a misspelled level
+func LevelFromConfig(config *viper.Viper) (logrus.Level, error) {
+	level, err := ParseLevel(config.Get("log.level"))
+	if err != nil {
+		return DefaultLevel, fmt.Errorf("invalid log.level: %w", err)
+	}
+	return level, nil
+}
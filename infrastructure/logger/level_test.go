@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLevel(t *testing.T) {
+	valid := map[interface{}]logrus.Level{
+		nil:        DefaultLevel,
+		"":         DefaultLevel,
+		"debug":    logrus.DebugLevel,
+		" WARN ":   logrus.WarnLevel,
+		"6":        logrus.TraceLevel,
+		4:          logrus.InfoLevel,
+		float64(2): logrus.ErrorLevel,
+	}
+	for value, want := range valid {
+		got, err := ParseLevel(value)
+		if err != nil {
+			t.Errorf("ParseLevel(%#v) returned error: %v", value, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseLevel(%#v) = %s, want %s", value, got, want)
+		}
+	}
+
+	for _, value := range []interface{}{"inf", "7", -1, 2.5, true} {
+		if _, err := ParseLevel(value); err == nil {
+			t.Errorf("ParseLevel(%#v) expected an error", value)
+		}
+	}
+}
+
+func TestNewLogrusLoggerRejectsInvalidLevel(t *testing.T) {
+	if _, err := NewLogrusLogger(WithLevel("verbose")); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := NewZapLogger(WithLevel("verbose")); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := NewLogrusLogger(WithLevel("debug")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLogrusLoggerDropsDebugAtInfoLevel(t *testing.T) {
+	var out bytes.Buffer
+
+	log, err := NewLogrusLogger()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log.(*logrusLogger).logger.SetOutput(&out)
+
+	log.Debugf("dropped")
+	log.WithFields(Fields{"k": "v"}).Tracef("dropped")
+	if out.Len() != 0 {
+		t.Errorf("expected debug and trace to be dropped at info level, got %q", out.String())
+	}
+
+	debug, err := NewLogrusLogger(WithLevel("trace"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	debug.(*logrusLogger).logger.SetOutput(&out)
+	debug.WithFields(Fields{"k": "v"}).Tracef("kept")
+	if line := decodeLine(t, &out); line["msg"] != "kept" || line["level"] != "trace" {
+		t.Errorf("unexpected line %v", line)
+	}
+}
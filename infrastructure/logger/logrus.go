@@ -9,8 +9,14 @@ type logrusLogger struct {
 	logger *logrus.Logger
 }
 
-func NewLogrusLogger() (Logger, error) {
+func NewLogrusLogger(opts ...Option) (Logger, error) {
+	level, err := ParseLevel(newOptions(opts).level)
+	if err != nil {
+		return nil, err
+	}
+
 	log := logrus.New()
+	log.SetLevel(level)
 	log.SetFormatter(&logrus.JSONFormatter{
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
@@ -18,6 +24,14 @@ func NewLogrusLogger() (Logger, error) {
 	return &logrusLogger{logger: log}, nil
 }
 
+func (l *logrusLogger) Tracef(format string, args ...interface{}) {
+	l.logger.Tracef(format, args...)
+}
+
+func (l *logrusLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(format, args...)
+}
+
 func (l *logrusLogger) Infof(format string, args ...interface{}) {
 	l.logger.Infof(format, args...)
 }
@@ -50,6 +64,14 @@ type logrusLogEntry struct {
 	entry *logrus.Entry
 }
 
+func (l *logrusLogEntry) Tracef(format string, args ...interface{}) {
+	l.entry.Tracef(format, args...)
+}
+
+func (l *logrusLogEntry) Debugf(format string, args ...interface{}) {
+	l.entry.Debugf(format, args...)
+}
+
 func (l *logrusLogEntry) Infof(format string, args ...interface{}) {
 	l.entry.Infof(format, args...)
 }
@@ -88,11 +110,18 @@ func convertToLogrusFields(fields Fields) logrus.Fields {
 }
 
 // NewLogger creates a new Logrus logger instance based on configuration
-// This creates a configured logrus.Logger for backwards compatibility
+// This creates a configured logrus.Logger for backwards compatibility.
+// log.level may be a name such as "info" or a logrus level number; an invalid level is
+// reported as a warning and DefaultLevel is used. Call LevelFromConfig to reject it instead.
 func NewLogger(viper *viper.Viper) *logrus.Logger {
 	log := logrus.New()
-	log.SetLevel(logrus.Level(viper.GetInt32("log.level")))
 	log.SetFormatter(&logrus.JSONFormatter{})
+
+	level, err := LevelFromConfig(viper)
+	if err != nil {
+		log.WithError(err).Warnf("Falling back to log level %s", level)
+	}
+	log.SetLevel(level)
 	return log
 }
 
@@ -0,0 +1,24 @@
+package logger
+
+// Option configures a logger built by NewLoggerFactory
+type Option func(*options)
+
+type options struct {
+	level string
+}
+
+// WithLevel sets the minimum level logged, by name ("trace", "debug", "info", "warn",
+// "error", "fatal" or "panic") or logrus number. The default is DefaultLevel.
+func WithLevel(level string) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
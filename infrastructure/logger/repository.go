@@ -1,6 +1,8 @@
 package logger
 
 type Logger interface {
+	Tracef(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
 	Infof(format string, args ...interface{})
 	Warnf(format string, args ...interface{})
 	Errorf(format string, args ...interface{})
@@ -1,53 +1,131 @@
 package logger
 
 import (
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// logrusTimeFormat matches the TimestampFormat of the logrus JSON formatter
+const logrusTimeFormat = "2006-01-02 15:04:05"
+
 type zapLogger struct {
-	logger *zap.SugaredLogger
+	logger *zap.Logger
+	sugar  *zap.SugaredLogger
 }
 
-func NewZapLogger() (Logger, error) {
-	log, err := zap.NewProduction()
+// NewZapLogger creates a zap logger whose JSON lines have the same shape as the logrus
+// logger's: level, msg and time keys, logrus level names and the same time format
+func NewZapLogger(opts ...Option) (Logger, error) {
+	level, err := ParseLevel(newOptions(opts).level)
 	if err != nil {
 		return nil, err
 	}
 
-	sugar := log.Sugar()
-	defer log.Sync()
+	return newZapLogger(level, zapcore.Lock(os.Stderr)), nil
+}
+
+func newZapLogger(level logrus.Level, output zapcore.WriteSyncer) *zapLogger {
+	encoderConfig := zapcore.EncoderConfig{
+		MessageKey:     logrus.FieldKeyMsg,
+		LevelKey:       logrus.FieldKeyLevel,
+		TimeKey:        logrus.FieldKeyTime,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    encodeLogrusLevel,
+		EncodeTime:     zapcore.TimeEncoderOfLayout(logrusTimeFormat),
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), output, zapLevel(level))
+
+	return newZapLoggerFrom(zap.New(core))
+}
+
+func newZapLoggerFrom(log *zap.Logger) *zapLogger {
+	return &zapLogger{logger: log, sugar: log.Sugar()}
+}
+
+// zapLevel maps a logrus level to zap's nearest, which has no trace level
+func zapLevel(level logrus.Level) zapcore.Level {
+	switch level {
+	case logrus.PanicLevel:
+		return zapcore.PanicLevel
+	case logrus.FatalLevel:
+		return zapcore.FatalLevel
+	case logrus.ErrorLevel:
+		return zapcore.ErrorLevel
+	case logrus.WarnLevel:
+		return zapcore.WarnLevel
+	case logrus.InfoLevel:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
+// encodeLogrusLevel writes levels as logrus names them, e.g. "warning" rather than "warn"
+func encodeLogrusLevel(level zapcore.Level, encoder zapcore.PrimitiveArrayEncoder) {
+	switch level {
+	case zapcore.WarnLevel:
+		encoder.AppendString(logrus.WarnLevel.String())
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		encoder.AppendString(logrus.PanicLevel.String())
+	default:
+		encoder.AppendString(level.String())
+	}
+}
+
+// Tracef logs at debug level, as zap has no trace level
+func (l *zapLogger) Tracef(format string, args ...interface{}) {
+	l.sugar.Debugf(format, args...)
+}
 
-	return &zapLogger{logger: sugar}, nil
+func (l *zapLogger) Debugf(format string, args ...interface{}) {
+	l.sugar.Debugf(format, args...)
 }
 
 func (l *zapLogger) Infof(format string, args ...interface{}) {
-	l.logger.Infof(format, args...)
+	l.sugar.Infof(format, args...)
 }
 
 func (l *zapLogger) Warnf(format string, args ...interface{}) {
-	l.logger.Warnf(format, args...)
+	l.sugar.Warnf(format, args...)
 }
 
 func (l *zapLogger) Errorf(format string, args ...interface{}) {
-	l.logger.Errorf(format, args...)
+	l.sugar.Errorf(format, args...)
 }
 
 func (l *zapLogger) Fatalln(args ...interface{}) {
-	l.logger.Fatal(args)
+	l.sugar.Fatalln(args...)
 }
 
 func (l *zapLogger) WithFields(fields Fields) Logger {
-	var f = make([]interface{}, 0)
-	for index, field := range fields {
-		f = append(f, index)
-		f = append(f, field)
-	}
-
-	log := l.logger.With(f...)
-	return &zapLogger{logger: log}
+	return newZapLoggerFrom(l.logger.With(convertToZapFields(fields)...))
 }
 
+// WithError adds the error message under the same "error" key logrus uses
 func (l *zapLogger) WithError(err error) Logger {
-	var log = l.logger.With(err.Error())
-	return &zapLogger{logger: log}
+	return newZapLoggerFrom(l.logger.With(zap.Error(err)))
+}
+
+// convertToZapFields converts fields in key order, so output is stable like logrus's
+func convertToZapFields(fields Fields) []zap.Field {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	zapFields := make([]zap.Field, 0, len(fields))
+	for _, key := range keys {
+		if err, ok := fields[key].(error); ok {
+			zapFields = append(zapFields, zap.NamedError(key, err))
+			continue
+		}
+		zapFields = append(zapFields, zap.Any(key, fields[key]))
+	}
+	return zapFields
 }
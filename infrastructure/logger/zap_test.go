@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap/zapcore"
+)
+
+// decodeLine parses one JSON log line written by either logger
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v: %q", err, buf.String())
+	}
+	return line
+}
+
+func TestZapLoggerMatchesLogrusShape(t *testing.T) {
+	var zapOut, logrusOut bytes.Buffer
+
+	zapLog := newZapLogger(logrus.InfoLevel, zapcore.AddSync(&zapOut))
+	zapLog.WithFields(Fields{"user": "alice", "attempt": 2}).WithError(errors.New("boom")).Warnf("login %s", "failed")
+
+	logrusLog := logrus.New()
+	logrusLog.SetOutput(&logrusOut)
+	logrusLog.SetFormatter(&logrus.JSONFormatter{TimestampFormat: logrusTimeFormat})
+	(&logrusLogger{logger: logrusLog}).WithFields(Fields{"user": "alice", "attempt": 2}).WithError(errors.New("boom")).Warnf("login %s", "failed")
+
+	zapLine := decodeLine(t, &zapOut)
+	logrusLine := decodeLine(t, &logrusOut)
+
+	for _, key := range []string{"level", "msg", "user", "attempt", "error"} {
+		if zapLine[key] != logrusLine[key] {
+			t.Errorf("%s: zap wrote %v, logrus wrote %v", key, zapLine[key], logrusLine[key])
+		}
+	}
+	if len(zapLine) != len(logrusLine) {
+		t.Errorf("zap wrote keys %v, logrus wrote %v", zapLine, logrusLine)
+	}
+	if zapTime, ok := zapLine["time"].(string); !ok || len(zapTime) != len(logrusTimeFormat) {
+		t.Errorf("unexpected time %v", zapLine["time"])
+	}
+}
+
+func TestZapLoggerRespectsLevel(t *testing.T) {
+	var out bytes.Buffer
+
+	log := newZapLogger(logrus.WarnLevel, zapcore.AddSync(&out))
+	log.Infof("dropped")
+	if out.Len() != 0 {
+		t.Errorf("expected info to be dropped at warn level, got %q", out.String())
+	}
+
+	log.Errorf("kept")
+	if line := decodeLine(t, &out); line["msg"] != "kept" || line["level"] != "error" {
+		t.Errorf("unexpected line %v", line)
+	}
+}
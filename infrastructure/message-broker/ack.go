@@ -0,0 +1,99 @@
+package messagebroker
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// acknowledger settles a received message with the broker that delivered it
+type acknowledger interface {
+	ack() error
+	nack(requeue bool) error
+}
+
+// Ack tells the broker the message has been processed. Handlers call it when subscribed
+// with SubscribeOptions.ManualAck; otherwise the broker acknowledges on its own.
+func (m *Message) Ack() error {
+	if m.acknowledger == nil {
+		return fmt.Errorf("acknowledging message from %s: %w", m.Topic, errNotSupported)
+	}
+	return m.acknowledger.ack()
+}
+
+// Nack rejects the message. With requeue the broker delivers it again: RabbitMQ puts it back
+// on the queue and NATS JetStream redelivers it. Kafka and the in-memory broker cannot
+// requeue and return an error satisfying IsNotSupported. Without requeue RabbitMQ
+// dead-letters or drops the message, JetStream stops redelivering it, and Kafka marks its
+// offset so the group moves past it.
+func (m *Message) Nack(requeue bool) error {
+	if m.acknowledger == nil {
+		return fmt.Errorf("rejecting message from %s: %w", m.Topic, errNotSupported)
+	}
+	return m.acknowledger.nack(requeue)
+}
+
+// rabbitMQAcknowledger settles a delivery consumed without auto-ack
+type rabbitMQAcknowledger struct {
+	delivery amqp.Delivery
+}
+
+func (a rabbitMQAcknowledger) ack() error {
+	return a.delivery.Ack(false)
+}
+
+func (a rabbitMQAcknowledger) nack(requeue bool) error {
+	return a.delivery.Nack(false, requeue)
+}
+
+// jetStreamAcknowledger settles a message delivered by a JetStream consumer
+type jetStreamAcknowledger struct {
+	msg *nats.Msg
+}
+
+func (a jetStreamAcknowledger) ack() error {
+	return a.msg.Ack()
+}
+
+func (a jetStreamAcknowledger) nack(requeue bool) error {
+	if requeue {
+		return a.msg.Nak()
+	}
+	return a.msg.Term()
+}
+
+// kafkaAcknowledger marks a record's offset on the consumer group session that claimed it
+type kafkaAcknowledger struct {
+	session sarama.ConsumerGroupSession
+	msg     *sarama.ConsumerMessage
+}
+
+func (a kafkaAcknowledger) ack() error {
+	a.session.MarkMessage(a.msg, "")
+	return nil
+}
+
+func (a kafkaAcknowledger) nack(requeue bool) error {
+	if requeue {
+		return fmt.Errorf("requeueing Kafka record at offset %d: %w", a.msg.Offset, errNotSupported)
+	}
+	a.session.MarkMessage(a.msg, "")
+	return nil
+}
+
+// inMemoryAcknowledger accepts acks so handlers written for ManualAck run unchanged against
+// the in-memory broker, which has nothing to settle
+type inMemoryAcknowledger struct{}
+
+func (inMemoryAcknowledger) ack() error {
+	return nil
+}
+
+func (inMemoryAcknowledger) nack(requeue bool) error {
+	if requeue {
+		return fmt.Errorf("requeueing in-memory message: %w", errNotSupported)
+	}
+	return nil
+}
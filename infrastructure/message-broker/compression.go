@@ -0,0 +1,111 @@
+package messagebroker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HeaderContentEncoding names the compression applied to a message's payload
+const HeaderContentEncoding = "Content-Encoding"
+
+// Payload compressions accepted in PublishOptions.Compression
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// DefaultMaxDecompressedSize is the largest payload a compressed message may expand to when
+// SubscribeOptions.MaxDecompressedSize is not set
+const DefaultMaxDecompressedSize = 64 << 20
+
+// compressPublish wraps the broker's final publish step so the payload is compressed after
+// every interceptor has seen it in plain form
+func compressPublish(next PublishFunc) PublishFunc {
+	return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+		if options == nil {
+			return next(ctx, topic, message, options)
+		}
+
+		switch strings.ToLower(options.Compression) {
+		case "", CompressionNone:
+			return next(ctx, topic, message, options)
+		case CompressionGzip:
+		default:
+			return fmt.Errorf("unknown compression %q: use %q or %q", options.Compression, CompressionNone, CompressionGzip)
+		}
+
+		compressed, err := gzipPayload(message)
+		if err != nil {
+			return fmt.Errorf("failed to compress message for %s: %w", topic, err)
+		}
+
+		// Leave the caller's options untouched, they may be reused for other messages
+		encoded := *options
+		encoded.Headers = make(map[string]string, len(options.Headers)+1)
+		for k, v := range options.Headers {
+			encoded.Headers[k] = v
+		}
+		encoded.Headers[HeaderContentEncoding] = CompressionGzip
+
+		return next(ctx, topic, compressed, &encoded)
+	}
+}
+
+func gzipPayload(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodeMessage decompresses message.Data in place according to its Content-Encoding
+// header and removes the header, so handlers only see plain payloads and a retried
+// message is not decoded twice. A payload that cannot be decoded, or that expands past
+// maxSize bytes, fails permanently.
+func decodeMessage(message *Message, maxSize int64) error {
+	encoding, exists := message.Headers[HeaderContentEncoding]
+	if !exists {
+		return nil
+	}
+
+	switch strings.ToLower(encoding) {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(message.Data))
+		if err != nil {
+			return Permanent(fmt.Errorf("failed to decompress message from %s: %w", message.Topic, err))
+		}
+		// Read one byte past the limit to tell a payload of exactly maxSize from a larger one
+		data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+		if err != nil {
+			return Permanent(fmt.Errorf("failed to decompress message from %s: %w", message.Topic, err))
+		}
+		if int64(len(data)) > maxSize {
+			return Permanent(fmt.Errorf("message from %s decompresses to more than %d bytes", message.Topic, maxSize))
+		}
+		message.Data = data
+	case "identity":
+	default:
+		return Permanent(fmt.Errorf("message from %s has unsupported content encoding %q", message.Topic, encoding))
+	}
+
+	delete(message.Headers, HeaderContentEncoding)
+	return nil
+}
+
+// maxDecompressedSize returns MaxDecompressedSize, or the default when options or the
+// field is unset
+func (o *SubscribeOptions) maxDecompressedSize() int64 {
+	if o == nil || o.MaxDecompressedSize <= 0 {
+		return DefaultMaxDecompressedSize
+	}
+	return o.MaxDecompressedSize
+}
@@ -0,0 +1,119 @@
+package messagebroker_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipCompressionRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"patient":"1234","status":"admitted"}`), 1000)
+
+	for name, broker := range map[string]messagebroker.MessageBroker{
+		"inmemory": newInMemoryBroker(t),
+		"nats":     newNATSBroker(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			received := make(chan *messagebroker.Message, 1)
+			require.NoError(t, broker.Subscribe(ctx, "admissions", func(ctx context.Context, message *messagebroker.Message) error {
+				received <- message
+				return nil
+			}, nil))
+
+			options := &messagebroker.PublishOptions{
+				Headers:     map[string]string{"X-Source": "ward-7"},
+				Compression: messagebroker.CompressionGzip,
+			}
+			require.NoError(t, broker.Publish(ctx, "admissions", payload, options))
+			assert.Equal(t, map[string]string{"X-Source": "ward-7"}, options.Headers, "caller's headers are not modified")
+
+			select {
+			case message := <-received:
+				assert.Equal(t, payload, message.Data)
+				assert.Equal(t, "ward-7", message.Headers["X-Source"])
+				assert.NotContains(t, message.Headers, messagebroker.HeaderContentEncoding)
+			case <-time.After(5 * time.Second):
+				t.Fatal("message was not delivered")
+			}
+		})
+	}
+}
+
+func TestInterceptorsSeeUncompressedPayload(t *testing.T) {
+	var seen []byte
+	broker := newInMemoryBroker(t, messagebroker.WithPublishInterceptors(func(next messagebroker.PublishFunc) messagebroker.PublishFunc {
+		return func(ctx context.Context, topic string, message []byte, options *messagebroker.PublishOptions) error {
+			seen = message
+			return next(ctx, topic, message, options)
+		}
+	}))
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("plain"), &messagebroker.PublishOptions{Compression: messagebroker.CompressionGzip}))
+	assert.Equal(t, "plain", string(seen))
+}
+
+func TestPublishRejectsUnknownCompression(t *testing.T) {
+	broker := newInMemoryBroker(t)
+
+	err := broker.Publish(context.Background(), "orders", []byte("data"), &messagebroker.PublishOptions{Compression: "zstd"})
+	assert.ErrorContains(t, err, `unknown compression "zstd"`)
+}
+
+func TestUndecodablePayloadFailsPermanently(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger))
+	ctx := context.Background()
+
+	calls := 0
+	require.NoError(t, broker.Subscribe(ctx, "orders", func(context.Context, *messagebroker.Message) error {
+		calls++
+		return nil
+	}, &messagebroker.SubscribeOptions{MaxRetries: 3}))
+
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("not gzip"), &messagebroker.PublishOptions{
+		Headers: map[string]string{messagebroker.HeaderContentEncoding: "gzip"},
+	}))
+
+	assert.Zero(t, calls, "the handler never sees a payload it cannot read")
+	require.Len(t, hook.AllEntries(), 1, "the message is not retried")
+	err, _ := hook.LastEntry().Data[logrus.ErrorKey].(error)
+	assert.True(t, messagebroker.IsPermanent(err))
+}
+
+func TestOversizedDecompressedPayloadFailsPermanently(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger))
+	ctx := context.Background()
+
+	received := make(chan []byte, 1)
+	require.NoError(t, broker.Subscribe(ctx, "reports", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message.Data
+		return nil
+	}, &messagebroker.SubscribeOptions{MaxRetries: 3, MaxDecompressedSize: 1024}))
+
+	// Highly compressible, so a small message expands far past the limit
+	options := &messagebroker.PublishOptions{Compression: messagebroker.CompressionGzip}
+	require.NoError(t, broker.Publish(ctx, "reports", make([]byte, 1<<20), options))
+
+	require.Len(t, hook.AllEntries(), 1, "the message is not retried")
+	err, _ := hook.LastEntry().Data[logrus.ErrorKey].(error)
+	assert.True(t, messagebroker.IsPermanent(err))
+	assert.ErrorContains(t, err, "more than 1024 bytes")
+
+	// A payload of exactly the limit is still delivered
+	require.NoError(t, broker.Publish(ctx, "reports", make([]byte, 1024), options))
+	select {
+	case data := <-received:
+		assert.Len(t, data, 1024)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+}
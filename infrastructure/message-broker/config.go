@@ -176,6 +176,7 @@ const (
 	TypeRabbitMQ BrokerType = "rabbitmq"
 	TypeNATS     BrokerType = "nats"
 	TypeKafka    BrokerType = "kafka"
+	TypeInMemory BrokerType = "inmemory"
 )
 
 // CreateBroker is a convenience function to create a broker with config
@@ -187,6 +188,8 @@ func CreateBroker(brokerType BrokerType, config *BrokerConfig) (MessageBroker, e
 		return NewMessageBrokerFactory(InstanceNATS, config)
 	case TypeKafka:
 		return NewMessageBrokerFactory(InstanceKafka, config)
+	case TypeInMemory:
+		return NewMessageBrokerFactory(InstanceInMemory, config)
 	default:
 		return nil, fmt.Errorf("unsupported broker type: %s", brokerType)
 	}
@@ -0,0 +1,108 @@
+package messagebroker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// Deduplicator remembers the IDs of processed messages so a message delivered again, for
+// example after a Kafka rebalance, is not handled twice. Set one in
+// SubscribeOptions.Deduplicator.
+type Deduplicator interface {
+	// Seen reports whether a message with this ID has been processed
+	Seen(id string) (bool, error)
+	// Mark records that the message with this ID has been processed
+	Mark(id string) error
+}
+
+// deduplicating wraps handler so that messages whose ID the subscription's Deduplicator has
+// seen are skipped, and so still acked, and the IDs of handled messages are marked. When
+// the Deduplicator fails the message is handled anyway, keeping at-least-once delivery.
+func (c *BrokerConfig) deduplicating(options *SubscribeOptions, handler MessageHandler) MessageHandler {
+	if options == nil || options.Deduplicator == nil {
+		return handler
+	}
+
+	deduplicator := options.Deduplicator
+	return func(ctx context.Context, message *Message) error {
+		if message.ID == "" {
+			return handler(ctx, message)
+		}
+
+		seen, err := deduplicator.Seen(message.ID)
+		if err != nil {
+			c.logSampled(logrus.WarnLevel, err, logrus.Fields{"topic": message.Topic, "message_id": message.ID}, "Failed to check for duplicate message")
+		}
+		if seen {
+			return nil
+		}
+
+		if err := handler(ctx, message); err != nil {
+			return err
+		}
+
+		if err := deduplicator.Mark(message.ID); err != nil {
+			c.logSampled(logrus.WarnLevel, err, logrus.Fields{"topic": message.Topic, "message_id": message.ID}, "Failed to record processed message")
+		}
+		return nil
+	}
+}
+
+// CacheDeduplicator is a Deduplicator keeping processed IDs in a cache.CacheManager, each
+// for a fixed time
+type CacheDeduplicator struct {
+	manager cache.CacheManager
+	prefix  string
+	ttl     time.Duration
+}
+
+// NewCacheDeduplicator stores processed IDs in manager under prefix for ttl. The ttl should
+// exceed the longest time a message can take to be redelivered.
+func NewCacheDeduplicator(manager cache.CacheManager, prefix string, ttl time.Duration) *CacheDeduplicator {
+	return &CacheDeduplicator{manager: manager, prefix: prefix, ttl: ttl}
+}
+
+// NewInMemoryDeduplicator keeps up to maxSize processed IDs in this process, evicting the
+// oldest first. It only catches duplicates delivered to the same instance.
+func NewInMemoryDeduplicator(ttl time.Duration, maxSize int) (*CacheDeduplicator, error) {
+	manager, err := cache.NewInMemoryCacheManager(&cache.CacheConfig{DefaultExpiration: ttl, MaxSize: maxSize})
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.Connect(context.Background()); err != nil {
+		return nil, err
+	}
+	return NewCacheDeduplicator(manager, "", ttl), nil
+}
+
+// NewRedisDeduplicator keeps processed IDs in Redis, so every instance of a consumer skips
+// messages any of them has handled
+func NewRedisDeduplicator(ctx context.Context, config *cache.CacheConfig, prefix string, ttl time.Duration) (*CacheDeduplicator, error) {
+	manager, err := cache.NewRedisCacheManager(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect deduplication store: %w", err)
+	}
+	return NewCacheDeduplicator(manager, prefix, ttl), nil
+}
+
+// Seen reports whether id is in the cache
+func (d *CacheDeduplicator) Seen(id string) (bool, error) {
+	return d.manager.Exists(context.Background(), d.prefix+id)
+}
+
+// Mark stores id in the cache for the deduplicator's ttl
+func (d *CacheDeduplicator) Mark(id string) error {
+	return d.manager.Set(context.Background(), d.prefix+id, true, d.ttl)
+}
+
+// Close disconnects the underlying cache
+func (d *CacheDeduplicator) Close() error {
+	return d.manager.Disconnect(context.Background())
+}
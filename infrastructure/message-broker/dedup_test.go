@@ -0,0 +1,81 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicatorSkipsRedeliveredMessages(t *testing.T) {
+	deduplicator, err := messagebroker.NewInMemoryDeduplicator(time.Minute, 100)
+	require.NoError(t, err)
+	t.Cleanup(func() { deduplicator.Close() })
+
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(5))
+	ctx := context.Background()
+
+	var received []string
+	failNext := true
+	require.NoError(t, broker.Subscribe(ctx, "invoices", func(ctx context.Context, message *messagebroker.Message) error {
+		if string(message.Data) == "flaky" && failNext {
+			failNext = false
+			return messagebroker.Permanent(errors.New("ledger locked"))
+		}
+		received = append(received, string(message.Data))
+		return nil
+	}, &messagebroker.SubscribeOptions{Deduplicator: deduplicator}))
+
+	require.NoError(t, broker.Publish(ctx, "invoices", []byte("one"), nil))
+	require.NoError(t, broker.Publish(ctx, "invoices", []byte("flaky"), nil))
+	require.NoError(t, broker.Replay(ctx, "invoices"))
+
+	// "one" is skipped on replay; "flaky" failed the first time, so it was not recorded
+	assert.Equal(t, []string{"one", "flaky"}, received)
+}
+
+func TestNATSPublishSetsStableMessageID(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	received := make(chan *messagebroker.Message, 2)
+	require.NoError(t, broker.Subscribe(ctx, "invoices", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message
+		return nil
+	}, nil))
+
+	require.NoError(t, broker.Publish(ctx, "invoices", []byte("one"), nil))
+	require.NoError(t, broker.Publish(ctx, "invoices", []byte("two"), &messagebroker.PublishOptions{
+		Headers: map[string]string{nats.MsgIdHdr: "invoice-2"},
+	}))
+
+	first, second := <-received, <-received
+	assert.NotEmpty(t, first.ID)
+	assert.NotContains(t, first.Headers, nats.MsgIdHdr)
+	assert.Equal(t, "invoice-2", second.ID, "a caller-chosen ID is kept")
+}
+
+func TestCacheDeduplicatorUsesPrefixAndTTL(t *testing.T) {
+	manager, err := cache.NewInMemoryCacheManager(nil)
+	require.NoError(t, err)
+	deduplicator := messagebroker.NewCacheDeduplicator(manager, "orders-consumer:", time.Hour)
+
+	seen, err := deduplicator.Seen("orders-0-42")
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	require.NoError(t, deduplicator.Mark("orders-0-42"))
+	seen, err = deduplicator.Seen("orders-0-42")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	ttl, err := manager.TTL(context.Background(), "orders-consumer:orders-0-42")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour.Seconds(), ttl.Seconds(), 5)
+}
@@ -0,0 +1,87 @@
+package messagebroker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDisconnectTimeout bounds how long Disconnect waits for in-flight messages when
+// BrokerConfig.DisconnectTimeout is not set
+const defaultDisconnectTimeout = 30 * time.Second
+
+// handling counts the messages a subscription's handlers are processing, so Disconnect can
+// let them finish before canceling the subscription and closing the connection. The zero
+// value is ready to use.
+type handling struct {
+	mutex    sync.Mutex
+	draining bool
+	active   sync.WaitGroup
+}
+
+// begin registers a message about to be handled. It returns false once draining has
+// started; the message must then be left for redelivery instead of handled.
+func (h *handling) begin() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.draining {
+		return false
+	}
+	h.active.Add(1)
+	return true
+}
+
+// end marks a message registered with begin as handled
+func (h *handling) end() {
+	h.active.Done()
+}
+
+// drain refuses further messages and returns a channel that is closed once the messages
+// in progress have been handled
+func (h *handling) drain() <-chan struct{} {
+	h.mutex.Lock()
+	h.draining = true
+	h.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(drained)
+	}()
+	return drained
+}
+
+// awaitDrained drains every tracker and waits until their messages have been handled, the
+// DisconnectTimeout has passed or ctx is done. It reports whether all messages finished.
+func (c *BrokerConfig) awaitDrained(ctx context.Context, trackers []*handling) bool {
+	pending := make([]<-chan struct{}, 0, len(trackers))
+	for _, tracker := range trackers {
+		pending = append(pending, tracker.drain())
+	}
+	if len(pending) == 0 {
+		return true
+	}
+
+	timeout := defaultDisconnectTimeout
+	if c != nil && c.DisconnectTimeout > 0 {
+		timeout = c.DisconnectTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for _, drained := range pending {
+		select {
+		case <-drained:
+		case <-timer.C:
+			c.logSampled(logrus.WarnLevel, nil, logrus.Fields{"timeout": timeout}, "Disconnecting with messages still in flight")
+			return false
+		case <-ctx.Done():
+			c.logSampled(logrus.WarnLevel, ctx.Err(), nil, "Disconnecting with messages still in flight")
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package messagebroker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingHandler signals when it starts and then holds its message until release is closed
+// or its context ends, reporting which happened on finished
+func blockingHandler(started chan<- struct{}, release <-chan struct{}, finished chan<- error) messagebroker.MessageHandler {
+	return func(ctx context.Context, message *messagebroker.Message) error {
+		started <- struct{}{}
+		select {
+		case <-release:
+			finished <- nil
+		case <-ctx.Done():
+			finished <- ctx.Err()
+		}
+		return nil
+	}
+}
+
+func TestNATSDisconnectWaitsForInFlightMessage(t *testing.T) {
+	broker := newNATSBroker(t)
+	started, release, finished := make(chan struct{}, 1), make(chan struct{}), make(chan error, 1)
+	require.NoError(t, broker.Subscribe(context.Background(), "discharges", blockingHandler(started, release, finished), nil))
+	require.NoError(t, broker.Publish(context.Background(), "discharges", []byte("1"), nil))
+	<-started
+
+	disconnected := make(chan error, 1)
+	go func() { disconnected <- broker.Disconnect(context.Background()) }()
+
+	select {
+	case <-disconnected:
+		t.Fatal("Disconnect returned while the handler was still processing")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-finished, "the handler finishes without its context being canceled")
+	select {
+	case err := <-disconnected:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Disconnect did not return after the handler finished")
+	}
+}
+
+func TestNATSDisconnectTimeoutCancelsSlowHandler(t *testing.T) {
+	broker := newNATSBroker(t, messagebroker.WithDisconnectTimeout(50*time.Millisecond))
+	started, finished := make(chan struct{}, 1), make(chan error, 1)
+	require.NoError(t, broker.Subscribe(context.Background(), "discharges", blockingHandler(started, nil, finished), nil))
+	require.NoError(t, broker.Publish(context.Background(), "discharges", []byte("1"), nil))
+	<-started
+
+	start := time.Now()
+	require.NoError(t, broker.Disconnect(context.Background()))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.ErrorIs(t, <-finished, context.Canceled)
+}
@@ -0,0 +1,140 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PermanentError marks a handler failure that will not succeed on retry, such as a
+// malformed payload. Brokers skip the remaining retries and dead-letter the message.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return "permanent: " + e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// RetryableError marks a transient handler failure. Delay, when set, overrides
+// SubscribeOptions.RetryDelay before the next attempt.
+type RetryableError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryableError) Error() string {
+	return "retryable: " + e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned in place of a handler that panicked. It is retried and
+// dead-lettered like any other handler failure.
+type PanicError struct {
+	Value interface{}
+	// Stack is the goroutine stack at the panic
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Permanent wraps err as a PermanentError; a nil err stays nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Retryable wraps err as a RetryableError retried after delay (zero keeps the configured delay)
+func Retryable(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err, Delay: delay}
+}
+
+// IsPermanent reports whether err or any error it wraps is a PermanentError
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// IsRetryable reports whether a failed handler should be retried. Errors that are not
+// classified either way are treated as retryable.
+func IsRetryable(err error) bool {
+	return err != nil && !IsPermanent(err)
+}
+
+// retryDelay returns the delay before the next attempt after err
+func retryDelay(err error, options *SubscribeOptions) time.Duration {
+	var retryable *RetryableError
+	if errors.As(err, &retryable) && retryable.Delay > 0 {
+		return retryable.Delay
+	}
+	return options.RetryDelay
+}
+
+// runWithRetries invokes the handler until it succeeds, fails permanently, or has used
+// MaxRetries retries. It returns the last handler error, or the context's error when ctx
+// is done while waiting to retry.
+func runWithRetries(ctx context.Context, handler MessageHandler, message *Message, options *SubscribeOptions) error {
+	for retry := 0; ; retry++ {
+		message.Retry = retry
+		message.MaxRetries = options.MaxRetries
+
+		err := callHandler(ctx, handler, message, options)
+		if err == nil || !IsRetryable(err) || retry >= options.MaxRetries {
+			return err
+		}
+
+		timer := time.NewTimer(retryDelay(err, options))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// abandoned reports whether a handler failure is due to ctx ending, typically on shutdown,
+// rather than to the message. Such a message should be left for redelivery instead of
+// being dead-lettered or committed.
+func abandoned(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
+
+// callHandler invokes handler with the message's payload decompressed, recovering a panic
+// as a PanicError so that a bad message cannot take down the consumer goroutine. options
+// may be nil.
+func callHandler(ctx context.Context, handler MessageHandler, message *Message, options *SubscribeOptions) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+
+	if err := decodeMessage(message, options.maxDecompressedSize()); err != nil {
+		return err
+	}
+	return handler(ctx, message)
+}
+
+// ErrorReportingSubscriber is implemented by brokers whose consumers can report errors to
+// the application instead of only logging them, so it can alert on or restart a
+// subscription that keeps failing
+type ErrorReportingSubscriber interface {
+	SubscribeWithErrors(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) (<-chan error, error)
+}
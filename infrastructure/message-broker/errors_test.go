@@ -0,0 +1,169 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClassificationHelpers(t *testing.T) {
+	cause := errors.New("malformed payload")
+
+	permanent := fmt.Errorf("decode order: %w", messagebroker.Permanent(cause))
+	assert.True(t, messagebroker.IsPermanent(permanent))
+	assert.False(t, messagebroker.IsRetryable(permanent))
+	assert.ErrorIs(t, permanent, cause)
+
+	var target *messagebroker.PermanentError
+	require.ErrorAs(t, permanent, &target)
+	assert.Equal(t, cause, target.Err)
+
+	retryable := messagebroker.Retryable(errors.New("timeout"), time.Second)
+	assert.True(t, messagebroker.IsRetryable(retryable))
+	assert.True(t, messagebroker.IsRetryable(errors.New("unclassified")))
+	assert.False(t, messagebroker.IsRetryable(nil))
+	assert.Nil(t, messagebroker.Permanent(nil))
+}
+
+func TestPermanentErrorIsDeadLetteredWithoutRetries(t *testing.T) {
+	for _, redeliver := range []bool{false, true} {
+		t.Run(fmt.Sprintf("redeliver=%v", redeliver), func(t *testing.T) {
+			broker := newNATSBroker(t)
+			ctx := context.Background()
+
+			var attempts atomic.Int32
+			require.NoError(t, broker.Subscribe(ctx, "orders.import", func(ctx context.Context, message *messagebroker.Message) error {
+				attempts.Add(1)
+				return messagebroker.Permanent(errors.New("malformed payload"))
+			}, &messagebroker.SubscribeOptions{
+				MaxRetries:      5,
+				RetryDelay:      time.Second,
+				Redeliver:       redeliver,
+				DeadLetterTopic: "orders.import.dlq",
+			}))
+
+			deadLettered := make(chan *messagebroker.Message, 1)
+			require.NoError(t, broker.Subscribe(ctx, "orders.import.dlq", func(ctx context.Context, message *messagebroker.Message) error {
+				deadLettered <- message
+				return nil
+			}, nil))
+
+			start := time.Now()
+			require.NoError(t, broker.Publish(ctx, "orders.import", []byte("not-json"), nil))
+
+			select {
+			case message := <-deadLettered:
+				assert.Equal(t, "not-json", string(message.Data))
+				assert.Equal(t, "0", message.Headers[messagebroker.HeaderRedeliveryCount])
+			case <-time.After(5 * time.Second):
+				t.Fatal("message was not dead-lettered")
+			}
+
+			// No retry delay was spent and the handler ran exactly once
+			assert.Less(t, time.Since(start), time.Second)
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, int32(1), attempts.Load())
+		})
+	}
+}
+
+func TestRetryableErrorIsRetried(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	done := make(chan int, 1)
+	require.NoError(t, broker.Subscribe(ctx, "orders.sync", func(ctx context.Context, message *messagebroker.Message) error {
+		if message.Retry < 2 {
+			return messagebroker.Retryable(errors.New("upstream unavailable"), time.Millisecond)
+		}
+		done <- message.Retry
+		return nil
+	}, &messagebroker.SubscribeOptions{MaxRetries: 3, RetryDelay: time.Hour}))
+
+	require.NoError(t, broker.Publish(ctx, "orders.sync", []byte("sync"), nil))
+
+	select {
+	case retry := <-done:
+		assert.Equal(t, 2, retry)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retryable error was not retried with its own delay")
+	}
+}
+
+func TestOnErrorReceivesMessagesThatExhaustRetries(t *testing.T) {
+	for name, broker := range map[string]messagebroker.MessageBroker{
+		"inmemory": newInMemoryBroker(t),
+		"nats":     newNATSBroker(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			handlerErr := errors.New("ledger locked")
+			failed := make(chan *messagebroker.Message, 2)
+			require.NoError(t, broker.Subscribe(context.Background(), "ledger.post", func(ctx context.Context, message *messagebroker.Message) error {
+				if string(message.Data) == "ok" {
+					return nil
+				}
+				return handlerErr
+			}, &messagebroker.SubscribeOptions{
+				MaxRetries: 1,
+				RetryDelay: time.Millisecond,
+				OnError: func(ctx context.Context, message *messagebroker.Message, err error) {
+					assert.ErrorIs(t, err, handlerErr)
+					failed <- message
+				},
+			}))
+
+			require.NoError(t, broker.Publish(context.Background(), "ledger.post", []byte("ok"), nil))
+			require.NoError(t, broker.Publish(context.Background(), "ledger.post", []byte("entry-7"), nil))
+
+			select {
+			case message := <-failed:
+				assert.Equal(t, "entry-7", string(message.Data))
+				assert.Equal(t, 1, message.Retry)
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnError was not called")
+			}
+			assert.Empty(t, failed, "successful messages are not reported")
+		})
+	}
+}
+
+func TestHandlerTimeoutFailsStuckAttempts(t *testing.T) {
+	for name, broker := range map[string]messagebroker.MessageBroker{
+		"inmemory": newInMemoryBroker(t),
+		"nats":     newNATSBroker(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var attempts atomic.Int32
+			failed := make(chan error, 1)
+			require.NoError(t, broker.Subscribe(context.Background(), "reports.render", func(ctx context.Context, message *messagebroker.Message) error {
+				attempts.Add(1)
+				<-ctx.Done()
+				return ctx.Err()
+			}, &messagebroker.SubscribeOptions{
+				MaxRetries:     1,
+				RetryDelay:     time.Millisecond,
+				HandlerTimeout: 20 * time.Millisecond,
+				OnError: func(ctx context.Context, message *messagebroker.Message, err error) {
+					failed <- err
+				},
+			}))
+
+			require.NoError(t, broker.Publish(context.Background(), "reports.render", []byte("monthly"), nil))
+
+			select {
+			case err := <-failed:
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+				assert.Equal(t, int32(2), attempts.Load(), "a timed-out attempt is retried")
+			case <-time.After(5 * time.Second):
+				t.Fatal("stuck handler was not timed out")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package messagebroker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type orderPlaced struct {
+	OrderID string    `json:"orderId"`
+	Placed  time.Time `json:"placed"`
+}
+
+func (e *orderPlaced) Topic() string         { return "orders.events" }
+func (e *orderPlaced) Key() string           { return e.OrderID }
+func (e *orderPlaced) EventType() string     { return "order.placed" }
+func (e *orderPlaced) OccurredAt() time.Time { return e.Placed }
+
+type orderShipped struct {
+	OrderID string `json:"orderId"`
+}
+
+func (e orderShipped) Topic() string { return "orders.events" }
+func (e orderShipped) Key() string   { return e.OrderID }
+
+func subscribeOne(t *testing.T, broker messagebroker.MessageBroker, topic string) *[]*messagebroker.Message {
+	t.Helper()
+
+	var received []*messagebroker.Message
+	require.NoError(t, broker.Subscribe(context.Background(), topic, func(ctx context.Context, message *messagebroker.Message) error {
+		received = append(received, message)
+		return nil
+	}, nil))
+	return &received
+}
+
+func TestPublishEventStampsStandardHeaders(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	received := subscribeOne(t, broker, "orders.events")
+
+	publisher, err := messagebroker.NewEventPublisher(broker)
+	require.NoError(t, err)
+
+	placed := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	ctx := messagebroker.ContextWithCorrelationID(context.Background(), "req-42")
+	require.NoError(t, publisher.PublishEvent(ctx, &orderPlaced{OrderID: "order-7", Placed: placed}))
+
+	require.Len(t, *received, 1)
+	message := (*received)[0]
+	assert.Equal(t, "orders.events", message.Topic)
+	assert.JSONEq(t, `{"orderId":"order-7","placed":"2024-03-01T12:30:00Z"}`, string(message.Data))
+	assert.Equal(t, "order-7", message.Headers[messagebroker.HeaderKafkaKey])
+	assert.Equal(t, "order.placed", message.Headers[messagebroker.HeaderEventType])
+	assert.Equal(t, "2024-03-01T12:30:00Z", message.Headers[messagebroker.HeaderOccurredAt])
+	assert.Equal(t, "req-42", message.Headers[messagebroker.HeaderCorrelationID])
+}
+
+func TestPublishEventFillsMissingTypeTimeAndCorrelationID(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	received := subscribeOne(t, broker, "orders.events")
+
+	publisher, err := messagebroker.NewEventPublisher(broker)
+	require.NoError(t, err)
+
+	before := time.Now().UTC()
+	require.NoError(t, publisher.PublishEvent(context.Background(), orderShipped{OrderID: "order-7"}))
+
+	require.Len(t, *received, 1)
+	headers := (*received)[0].Headers
+	assert.Equal(t, "orderShipped", headers[messagebroker.HeaderEventType])
+	assert.NotEmpty(t, headers[messagebroker.HeaderCorrelationID])
+
+	occurredAt, err := time.Parse(time.RFC3339Nano, headers[messagebroker.HeaderOccurredAt])
+	require.NoError(t, err)
+	assert.False(t, occurredAt.Before(before.Truncate(time.Second)))
+}
+
+func TestNewEventPublisherRequiresBroker(t *testing.T) {
+	_, err := messagebroker.NewEventPublisher(nil)
+	assert.Error(t, err)
+}
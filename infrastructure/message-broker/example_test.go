@@ -11,13 +11,8 @@ import (
 )
 
 func TestRabbitMQBroker(t *testing.T) {
-	// Skip if RabbitMQ is not available
-	t.Skip("RabbitMQ integration test - requires running RabbitMQ server")
-
-	config := &messagebroker.BrokerConfig{
-		RabbitMQURL:      "amqp://localhost:5672",
-		RabbitMQExchange: "test-exchange",
-	}
+	config := integrationBrokerConfig(t, messagebroker.InstanceRabbitMQ)
+	config.RabbitMQExchange = "test-exchange"
 
 	broker, err := messagebroker.NewMessageBrokerFactory(messagebroker.InstanceRabbitMQ, config)
 	require.NoError(t, err)
@@ -57,12 +52,7 @@ func TestRabbitMQBroker(t *testing.T) {
 }
 
 func TestNATSBroker(t *testing.T) {
-	// Skip if NATS is not available
-	t.Skip("NATS integration test - requires running NATS server")
-
-	config := &messagebroker.BrokerConfig{
-		NATSURL: "nats://localhost:4222",
-	}
+	config := integrationBrokerConfig(t, messagebroker.InstanceNATS)
 
 	broker, err := messagebroker.NewMessageBrokerFactory(messagebroker.InstanceNATS, config)
 	require.NoError(t, err)
@@ -103,13 +93,8 @@ func TestNATSBroker(t *testing.T) {
 }
 
 func TestKafkaBroker(t *testing.T) {
-	// Skip if Kafka is not available
-	t.Skip("Kafka integration test - requires running Kafka server")
-
-	config := &messagebroker.BrokerConfig{
-		KafkaBrokers:       []string{"localhost:9092"},
-		KafkaConsumerGroup: "test-group",
-	}
+	config := integrationBrokerConfig(t, messagebroker.InstanceKafka)
+	config.KafkaConsumerGroup = "test-group"
 
 	broker, err := messagebroker.NewMessageBrokerFactory(messagebroker.InstanceKafka, config)
 	require.NoError(t, err)
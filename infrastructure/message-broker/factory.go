@@ -12,12 +12,19 @@ var (
 	errInvalidMessage        = errors.New("invalid message format")
 	errPublishFailed         = errors.New("failed to publish message")
 	errSubscribeFailed       = errors.New("failed to subscribe to topic")
+	errNotSupported          = errors.New("operation not supported by this broker")
 )
 
+// IsNotSupported reports whether err means the broker cannot perform the operation
+func IsNotSupported(err error) bool {
+	return errors.Is(err, errNotSupported)
+}
+
 const (
 	InstanceRabbitMQ int = iota
 	InstanceNATS
 	InstanceKafka
+	InstanceInMemory
 )
 
 // NewMessageBrokerFactory creates a new message broker instance based on the specified type
@@ -29,6 +36,8 @@ func NewMessageBrokerFactory(instance int, config *BrokerConfig) (MessageBroker,
 		return NewNATSBroker(config)
 	case InstanceKafka:
 		return NewKafkaBroker(config)
+	case InstanceInMemory:
+		return NewInMemoryBroker(config)
 	default:
 		return nil, errInvalidBrokerInstance
 	}
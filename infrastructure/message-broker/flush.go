@@ -0,0 +1,73 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Flusher is implemented by brokers that buffer outgoing messages and confirm them
+// asynchronously
+type Flusher interface {
+	// Flush blocks until every buffered message has been confirmed by the broker or ctx
+	// is done. It returns the delivery failures seen since the previous Flush.
+	Flush(ctx context.Context) error
+}
+
+// inFlight counts messages handed to an async producer that are not yet confirmed
+type inFlight struct {
+	mutex   sync.Mutex
+	pending int
+	idle    chan struct{}
+	errs    []error
+}
+
+func (f *inFlight) add(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.pending == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.pending += n
+}
+
+// done confirms one message, recording err when its delivery failed
+func (f *inFlight) done(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err != nil {
+		f.errs = append(f.errs, err)
+	}
+	if f.pending == 0 {
+		return
+	}
+	f.pending--
+	if f.pending == 0 {
+		close(f.idle)
+	}
+}
+
+// wait blocks until nothing is pending, then returns and clears the recorded failures
+func (f *inFlight) wait(ctx context.Context) error {
+	f.mutex.Lock()
+	idle := f.idle
+	pending := f.pending
+	f.mutex.Unlock()
+
+	if pending > 0 {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := errors.Join(f.errs...)
+	f.errs = nil
+	return err
+}
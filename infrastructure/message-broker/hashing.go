@@ -0,0 +1,123 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/IBM/sarama"
+)
+
+const (
+	// HeaderKafkaKey sets the record key of a Kafka message
+	HeaderKafkaKey = "kafka.key"
+	// HeaderKafkaPartition pins a Kafka message to a partition
+	HeaderKafkaPartition = "kafka.partition"
+)
+
+// BucketRouter turns the bucket chosen for a key into a broker-specific destination. It
+// returns the topic to publish to and the options to publish with; options must not be
+// modified in place.
+type BucketRouter func(topic string, key string, bucket int, options *PublishOptions) (string, *PublishOptions)
+
+// KafkaPartitionRouter keeps the topic and pins the message to partition number bucket
+func KafkaPartitionRouter(topic string, key string, bucket int, options *PublishOptions) (string, *PublishOptions) {
+	options = withHeader(options, HeaderKafkaKey, key)
+	return topic, withHeader(options, HeaderKafkaPartition, strconv.Itoa(bucket))
+}
+
+// SubjectBucketRouter appends ".<bucket>" to the topic, yielding one NATS subject or
+// RabbitMQ routing key per bucket, e.g. "orders.3"
+func SubjectBucketRouter(topic string, key string, bucket int, options *PublishOptions) (string, *PublishOptions) {
+	return topic + "." + strconv.Itoa(bucket), options
+}
+
+// ConsistentHashPublisher sends every message with the same key to the same bucket, so
+// consumers that own a bucket see that key's messages in publish order on any broker.
+// Keys are placed with jump consistent hashing: buckets receive an even share of keys,
+// and growing from n to n+1 buckets moves only about 1/(n+1) of them.
+type ConsistentHashPublisher struct {
+	broker  MessageBroker
+	buckets int
+	route   BucketRouter
+}
+
+// NewConsistentHashPublisher publishes through broker over a fixed number of buckets.
+// buckets must match the downstream layout, e.g. the topic's partition count on Kafka.
+func NewConsistentHashPublisher(broker MessageBroker, buckets int, route BucketRouter) (*ConsistentHashPublisher, error) {
+	if broker == nil {
+		return nil, errors.New("broker is required")
+	}
+	if buckets <= 0 {
+		return nil, errors.New("bucket count must be positive")
+	}
+	if route == nil {
+		return nil, errors.New("bucket router is required")
+	}
+
+	return &ConsistentHashPublisher{
+		broker:  broker,
+		buckets: buckets,
+		route:   route,
+	}, nil
+}
+
+// Bucket returns the bucket, in [0, buckets), that key maps to
+func (p *ConsistentHashPublisher) Bucket(key string) int {
+	return jumpHash(hashKey(key), p.buckets)
+}
+
+// Publish sends message to the bucket owning key
+func (p *ConsistentHashPublisher) Publish(ctx context.Context, topic string, key string, message []byte, options *PublishOptions) error {
+	destination, routed := p.route(topic, key, p.Bucket(key), options)
+	return p.broker.Publish(ctx, destination, message, routed)
+}
+
+// PublishJSON sends a JSON-encoded message to the bucket owning key
+func (p *ConsistentHashPublisher) PublishJSON(ctx context.Context, topic string, key string, message interface{}, options *PublishOptions) error {
+	destination, routed := p.route(topic, key, p.Bucket(key), options)
+	return p.broker.PublishJSON(ctx, destination, message, routed)
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// explicitPartitioner honours the kafka.partition header and otherwise hashes the record
+// key like sarama's default partitioner
+type explicitPartitioner struct {
+	sarama.Partitioner
+}
+
+func newExplicitPartitioner(topic string) sarama.Partitioner {
+	return &explicitPartitioner{Partitioner: sarama.NewHashPartitioner(topic)}
+}
+
+func (p *explicitPartitioner) Partition(message *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	for _, header := range message.Headers {
+		if string(header.Key) != HeaderKafkaPartition {
+			continue
+		}
+		partition, err := strconv.ParseInt(string(header.Value), 10, 32)
+		if err != nil || partition < 0 || int32(partition) >= numPartitions {
+			return -1, fmt.Errorf("invalid %s header %q for %d partitions", HeaderKafkaPartition, header.Value, numPartitions)
+		}
+		return int32(partition), nil
+	}
+	return p.Partitioner.Partition(message, numPartitions)
+}
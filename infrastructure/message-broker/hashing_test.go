@@ -0,0 +1,129 @@
+package messagebroker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistentHashPublisherMappingIsStable(t *testing.T) {
+	first, err := NewConsistentHashPublisher(&inMemoryBroker{}, 12, SubjectBucketRouter)
+	require.NoError(t, err)
+	second, err := NewConsistentHashPublisher(&inMemoryBroker{}, 12, KafkaPartitionRouter)
+	require.NoError(t, err)
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("customer-%d", i)
+		bucket := first.Bucket(key)
+		assert.GreaterOrEqual(t, bucket, 0)
+		assert.Less(t, bucket, 12)
+		assert.Equal(t, bucket, first.Bucket(key), "same key must map to the same bucket")
+		assert.Equal(t, bucket, second.Bucket(key), "mapping must not depend on the broker")
+	}
+}
+
+func TestConsistentHashPublisherDistributesEvenly(t *testing.T) {
+	const buckets, keys = 10, 100000
+	publisher, err := NewConsistentHashPublisher(&inMemoryBroker{}, buckets, SubjectBucketRouter)
+	require.NoError(t, err)
+
+	counts := make([]int, buckets)
+	for i := 0; i < keys; i++ {
+		counts[publisher.Bucket(fmt.Sprintf("order-%d", i))]++
+	}
+
+	expected := keys / buckets
+	for bucket, count := range counts {
+		assert.InDelta(t, expected, count, float64(expected)*0.05, "bucket %d holds %d keys", bucket, count)
+	}
+}
+
+func TestConsistentHashPublisherMovesFewKeysWhenGrowing(t *testing.T) {
+	const keys = 10000
+	before, err := NewConsistentHashPublisher(&inMemoryBroker{}, 10, SubjectBucketRouter)
+	require.NoError(t, err)
+	after, err := NewConsistentHashPublisher(&inMemoryBroker{}, 11, SubjectBucketRouter)
+	require.NoError(t, err)
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if before.Bucket(key) != after.Bucket(key) {
+			moved++
+			assert.Equal(t, 10, after.Bucket(key), "keys only move to the new bucket")
+		}
+	}
+	assert.Less(t, moved, keys/11+keys/50)
+}
+
+func TestConsistentHashPublisherRoutesToBucketSubject(t *testing.T) {
+	broker, err := NewInMemoryBroker(&BrokerConfig{InMemoryRetention: 10})
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+
+	publisher, err := NewConsistentHashPublisher(broker, 4, SubjectBucketRouter)
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.Publish(context.Background(), "orders", "customer-7", []byte("a"), nil))
+	require.NoError(t, publisher.PublishJSON(context.Background(), "orders", "customer-7", map[string]int{"n": 2}, nil))
+
+	subject := fmt.Sprintf("orders.%d", publisher.Bucket("customer-7"))
+	history := broker.(ReplayableBroker).History(subject)
+	require.Len(t, history, 2)
+	assert.Equal(t, "a", string(history[0].Data))
+	assert.JSONEq(t, `{"n":2}`, string(history[1].Data))
+}
+
+func TestKafkaPartitionRouterPinsPartition(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	publisher, err := NewConsistentHashPublisher(broker, 6, KafkaPartitionRouter)
+	require.NoError(t, err)
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	options := &PublishOptions{Headers: map[string]string{"X-Source": "test"}}
+	require.NoError(t, publisher.Publish(context.Background(), "orders", "customer-7", []byte("a"), options))
+
+	require.NotNil(t, sent)
+	assert.Equal(t, "orders", sent.Topic)
+	assert.Equal(t, fmt.Sprint(publisher.Bucket("customer-7")), recordHeader(sent, HeaderKafkaPartition))
+	assert.Equal(t, "customer-7", recordHeader(sent, HeaderKafkaKey))
+	assert.Equal(t, "test", recordHeader(sent, "X-Source"))
+	assert.Len(t, options.Headers, 1, "caller options must not be modified")
+
+	partition, err := newExplicitPartitioner("orders").Partition(sent, 6)
+	require.NoError(t, err)
+	assert.Equal(t, int32(publisher.Bucket("customer-7")), partition)
+}
+
+func TestExplicitPartitionerFallsBackToKeyHash(t *testing.T) {
+	partitioner := newExplicitPartitioner("orders")
+	message := &sarama.ProducerMessage{Topic: "orders", Key: sarama.StringEncoder("customer-7")}
+
+	first, err := partitioner.Partition(message, 8)
+	require.NoError(t, err)
+	second, err := partitioner.Partition(message, 8)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	message.Headers = []sarama.RecordHeader{{Key: []byte(HeaderKafkaPartition), Value: []byte("9")}}
+	_, err = partitioner.Partition(message, 8)
+	assert.Error(t, err)
+}
+
+func TestNewConsistentHashPublisherValidates(t *testing.T) {
+	_, err := NewConsistentHashPublisher(nil, 3, SubjectBucketRouter)
+	assert.Error(t, err)
+	_, err = NewConsistentHashPublisher(&inMemoryBroker{}, 0, SubjectBucketRouter)
+	assert.Error(t, err)
+	_, err = NewConsistentHashPublisher(&inMemoryBroker{}, 3, nil)
+	assert.Error(t, err)
+}
@@ -4,8 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
 )
 
 // DefaultPublishOptions returns default publish options
@@ -31,6 +36,14 @@ func DefaultSubscribeOptions() *SubscribeOptions {
 	}
 }
 
+// DeadLetterSubscribeOptions returns default subscribe options that dead-letter failed
+// RabbitMQ messages to exchange dlx, where they collect in the queue "<queue>.dlq"
+func DeadLetterSubscribeOptions(dlx string) *SubscribeOptions {
+	opts := DefaultSubscribeOptions()
+	opts.DeadLetterExchange = dlx
+	return opts
+}
+
 // DefaultTopicOptions returns default topic options
 func DefaultTopicOptions() *TopicOptions {
 	return &TopicOptions{
@@ -65,8 +78,14 @@ func MessageWithTimeout(handler MessageHandler, timeout time.Duration) MessageHa
 		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
 
+		// The handler runs on its own goroutine, out of reach of callHandler's recover
 		done := make(chan error, 1)
 		go func() {
+			defer func() {
+				if value := recover(); value != nil {
+					done <- &PanicError{Value: value, Stack: debug.Stack()}
+				}
+			}()
 			done <- handler(ctx, message)
 		}()
 
@@ -74,11 +93,19 @@ func MessageWithTimeout(handler MessageHandler, timeout time.Duration) MessageHa
 		case err := <-done:
 			return err
 		case <-ctx.Done():
-			return fmt.Errorf("message handler timeout after %v", timeout)
+			return fmt.Errorf("message handler timeout after %v: %w", timeout, ctx.Err())
 		}
 	}
 }
 
+// timingOut applies SubscribeOptions.HandlerTimeout to handler
+func timingOut(options *SubscribeOptions, handler MessageHandler) MessageHandler {
+	if options == nil || options.HandlerTimeout <= 0 {
+		return handler
+	}
+	return MessageWithTimeout(handler, options.HandlerTimeout)
+}
+
 // MessageWithRetries wraps a message handler with custom retry logic
 func MessageWithRetries(handler MessageHandler, maxRetries int, retryDelay time.Duration) MessageHandler {
 	return func(ctx context.Context, message *Message) error {
@@ -105,7 +132,9 @@ func MessageWithRetries(handler MessageHandler, maxRetries int, retryDelay time.
 	}
 }
 
-// JSONMessageHandler creates a handler that automatically unmarshals JSON messages
+// JSONMessageHandler creates a handler that automatically unmarshals JSON messages with
+// json.Unmarshal. For Avro, protobuf or other formats, use TypedMessageHandler with a
+// Deserializer.
 func JSONMessageHandler[T any](handler func(ctx context.Context, data T) error) MessageHandler {
 	return func(ctx context.Context, message *Message) error {
 		var data T
@@ -117,6 +146,19 @@ func JSONMessageHandler[T any](handler func(ctx context.Context, data T) error)
 	}
 }
 
+// StrictJSONMessageHandler is JSONMessageHandler decoding with cache.DecodeJSONStrict, for
+// payloads whose untyped fields carry integers too large for float64
+func StrictJSONMessageHandler[T any](handler func(ctx context.Context, data T) error) MessageHandler {
+	return func(ctx context.Context, message *Message) error {
+		var data T
+		if err := cache.DecodeJSONStrict(message.Data, &data); err != nil {
+			return fmt.Errorf("failed to unmarshal JSON message: %w", err)
+		}
+
+		return handler(ctx, data)
+	}
+}
+
 // LoggingMessageHandler wraps a message handler with logging
 func LoggingMessageHandler(handler MessageHandler, logger func(level string, msg string, args ...interface{})) MessageHandler {
 	return func(ctx context.Context, message *Message) error {
@@ -145,38 +187,95 @@ func LoggingMessageHandler(handler MessageHandler, logger func(level string, msg
 	}
 }
 
-// BulkMessageHandler creates a handler that processes messages in batches
-func BulkMessageHandler(batchSize int, flushInterval time.Duration, handler func(ctx context.Context, messages []*Message) error) MessageHandler {
+// BulkMessageHandler creates a handler that processes messages in batches. A batch is handed
+// to handler once it holds batchSize messages, and a background timer flushes whatever has
+// accumulated every flushInterval, so a partial batch is delivered even when traffic stops.
+// handler owns the slice it is given and may keep it.
+//
+// A message is accepted as soon as it is added to the batch, so the broker settles it
+// before the batch is handled. A batch whose flush fails is kept and handed to handler
+// again by the next flush; handler reports its own failures. When the flush triggered by a
+// message fails, that message is left out of the kept batch and the error is returned, so
+// the caller retries it like any other failed message. A failed timer flush is not
+// reported to later messages, which are unrelated to it.
+//
+// The timer runs until ctx is canceled, at which point any remaining messages are flushed
+// one last time with a context that is no longer canceled. A flushInterval of zero or less
+// disables the timer and batches are only flushed when full.
+func BulkMessageHandler(ctx context.Context, batchSize int, flushInterval time.Duration, handler func(ctx context.Context, messages []*Message) error) MessageHandler {
+	bulk, _ := NewBulkMessageHandler(ctx, batchSize, flushInterval, handler)
+	return bulk
+}
+
+// NewBulkMessageHandler is BulkMessageHandler that also returns a wait function. Once ctx is
+// canceled, wait blocks until the final flush has finished and returns its error, so a
+// caller can make sure the last batch is handled before it returns.
+func NewBulkMessageHandler(ctx context.Context, batchSize int, flushInterval time.Duration, handler func(ctx context.Context, messages []*Message) error) (MessageHandler, func() error) {
 	batch := make([]*Message, 0, batchSize)
-	lastFlush := time.Now()
+	var finalErr error
 	var mutex sync.Mutex
+	done := make(chan struct{})
 
+	// flushBatch must be called with the mutex held. The batch is only replaced once handler
+	// accepts it, so a failed batch is offered again by the next flush, and a new slice is
+	// started so handler may keep the one it was given.
 	flushBatch := func(ctx context.Context) error {
 		if len(batch) == 0 {
 			return nil
 		}
 
-		err := handler(ctx, batch)
-		batch = batch[:0] // Clear the batch
-		lastFlush = time.Now()
-		return err
+		if err := handler(ctx, batch); err != nil {
+			return err
+		}
+		batch = make([]*Message, 0, batchSize)
+		return nil
 	}
 
-	return func(ctx context.Context, message *Message) error {
+	if flushInterval > 0 {
+		go func() {
+			defer close(done)
+			ticker := time.NewTicker(flushInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					// A failed batch stays in place for the next tick
+					mutex.Lock()
+					_ = flushBatch(ctx)
+					mutex.Unlock()
+				case <-ctx.Done():
+					mutex.Lock()
+					finalErr = flushBatch(context.WithoutCancel(ctx))
+					mutex.Unlock()
+					return
+				}
+			}
+		}()
+	} else {
+		close(done)
+	}
+
+	bulk := func(ctx context.Context, message *Message) error {
 		mutex.Lock()
 		defer mutex.Unlock()
 
 		batch = append(batch, message)
-
-		// Flush if batch is full or flush interval has passed
-		shouldFlush := len(batch) >= batchSize || time.Since(lastFlush) >= flushInterval
-
-		if shouldFlush {
-			return flushBatch(ctx)
+		if len(batch) >= batchSize {
+			if err := flushBatch(ctx); err != nil {
+				// The caller retries this message, so only the ones before it are kept
+				batch = batch[:len(batch)-1]
+				return err
+			}
 		}
 
 		return nil
 	}
+	wait := func() error {
+		<-done
+		return finalErr
+	}
+	return bulk, wait
 }
 
 // ValidateMessage validates a message structure
@@ -196,9 +295,17 @@ func ValidateMessage(message *Message) error {
 	return nil
 }
 
-// CreateBatchMessage creates a batch message from individual components
+// CreateBatchMessage creates a batch message from individual components, encoding data
+// other than []byte and string as JSON
 func CreateBatchMessage(topic string, data interface{}, headers map[string]string) (BatchMessage, error) {
+	return CreateBatchMessageWithSerializer(JSONSerializer{}, topic, data, headers)
+}
+
+// CreateBatchMessageWithSerializer is CreateBatchMessage encoding data with serializer. The
+// content type it reports is added as the Content-Type header unless headers has one.
+func CreateBatchMessageWithSerializer(serializer Serializer, topic string, data interface{}, headers map[string]string) (BatchMessage, error) {
 	var payload []byte
+	var contentType string
 	var err error
 
 	switch v := data.(type) {
@@ -207,7 +314,7 @@ func CreateBatchMessage(topic string, data interface{}, headers map[string]strin
 	case string:
 		payload = []byte(v)
 	default:
-		payload, err = json.Marshal(data)
+		payload, contentType, err = serializer.Serialize(data)
 		if err != nil {
 			return BatchMessage{}, fmt.Errorf("failed to marshal data: %w", err)
 		}
@@ -216,6 +323,15 @@ func CreateBatchMessage(topic string, data interface{}, headers map[string]strin
 	if headers == nil {
 		headers = make(map[string]string)
 	}
+	if _, exists := headers[HeaderContentType]; contentType != "" && !exists {
+		// Copy rather than modify the caller's map
+		withContentType := make(map[string]string, len(headers)+1)
+		for k, v := range headers {
+			withContentType[k] = v
+		}
+		withContentType[HeaderContentType] = contentType
+		headers = withContentType
+	}
 
 	return BatchMessage{
 		Topic:   topic,
@@ -224,9 +340,13 @@ func CreateBatchMessage(topic string, data interface{}, headers map[string]strin
 	}, nil
 }
 
-// MessageRouter provides simple message routing based on topic patterns
+// MessageRouter provides simple message routing based on topic patterns. Patterns are split
+// into dot-separated segments, where "*" matches exactly one segment and "#" matches zero or
+// more, so "user.*" matches "user.created" and "order.#" matches "order" and
+// "order.item.added". A lone "*" is a fallback for topics no other route matches.
 type MessageRouter struct {
-	routes map[string]MessageHandler
+	routes   map[string]MessageHandler
+	patterns []string // Route patterns, most specific first
 }
 
 // NewMessageRouter creates a new message router
@@ -236,17 +356,26 @@ func NewMessageRouter() *MessageRouter {
 	}
 }
 
-// AddRoute adds a route for a specific topic pattern
+// AddRoute adds a route for a topic pattern, replacing any handler already registered for it
 func (r *MessageRouter) AddRoute(pattern string, handler MessageHandler) {
+	if _, exists := r.routes[pattern]; !exists && pattern != "*" {
+		r.patterns = append(r.patterns, pattern)
+		sort.SliceStable(r.patterns, func(i, j int) bool {
+			return moreSpecificPattern(r.patterns[i], r.patterns[j])
+		})
+	}
 	r.routes[pattern] = handler
 }
 
-// Route returns a message handler that routes messages based on topic
+// Route returns a message handler that routes messages based on topic. When several patterns
+// match, the one with the longest literal prefix wins, so an exact topic beats "user.*",
+// which beats "user.#", which beats "#".
 func (r *MessageRouter) Route() MessageHandler {
 	return func(ctx context.Context, message *Message) error {
-		// Simple exact match routing (could be extended to support patterns)
-		if handler, exists := r.routes[message.Topic]; exists {
-			return handler(ctx, message)
+		for _, pattern := range r.patterns {
+			if topicMatches(pattern, message.Topic) {
+				return r.routes[pattern](ctx, message)
+			}
 		}
 
 		// Look for wildcard match
@@ -258,6 +387,69 @@ func (r *MessageRouter) Route() MessageHandler {
 	}
 }
 
+// moreSpecificPattern orders patterns by the length of their literal prefix, then prefers
+// fewer "#" wildcards and more segments, falling back to the pattern text so the order is
+// always deterministic
+func moreSpecificPattern(a, b string) bool {
+	if prefixA, prefixB := literalPrefixLength(a), literalPrefixLength(b); prefixA != prefixB {
+		return prefixA > prefixB
+	}
+	if hashesA, hashesB := strings.Count(a, "#"), strings.Count(b, "#"); hashesA != hashesB {
+		return hashesA < hashesB
+	}
+	if segmentsA, segmentsB := strings.Count(a, "."), strings.Count(b, "."); segmentsA != segmentsB {
+		return segmentsA > segmentsB
+	}
+	return a < b
+}
+
+// literalPrefixLength counts the characters before the pattern's first wildcard segment
+func literalPrefixLength(pattern string) int {
+	length := 0
+	for i, segment := range strings.Split(pattern, ".") {
+		if segment == "*" || segment == "#" {
+			break
+		}
+		if i > 0 {
+			length++ // The separating dot
+		}
+		length += len(segment)
+	}
+	return length
+}
+
+// topicMatches reports whether topic matches the dot-separated pattern
+func topicMatches(pattern, topic string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(topic, "."))
+}
+
+func matchSegments(pattern, topic []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			if len(pattern) == 1 {
+				return true
+			}
+			for skip := 0; skip <= len(topic); skip++ {
+				if matchSegments(pattern[1:], topic[skip:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(topic) == 0 {
+				return false
+			}
+		default:
+			if len(topic) == 0 || pattern[0] != topic[0] {
+				return false
+			}
+		}
+		pattern, topic = pattern[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
+
 // KafkaPublishOptions returns publish options optimized for Kafka messages
 func KafkaPublishOptions(key string, partition int) *PublishOptions {
 	opts := DefaultPublishOptions()
@@ -0,0 +1,277 @@
+package messagebroker_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// snowflakeID needs all 63 bits; float64 keeps only 53
+const snowflakeID int64 = 1<<62 + 1
+
+type trackedEvent struct {
+	Offset int64                  `json:"offset"`
+	Meta   map[string]interface{} `json:"meta"`
+}
+
+func encodeTrackedEvent(t *testing.T) *messagebroker.Message {
+	data, err := json.Marshal(trackedEvent{Offset: snowflakeID, Meta: map[string]interface{}{"id": snowflakeID}})
+	require.NoError(t, err)
+	return &messagebroker.Message{Data: data}
+}
+
+func TestJSONMessageHandlerRoundsUntypedIntegers(t *testing.T) {
+	var received trackedEvent
+	handler := messagebroker.JSONMessageHandler(func(ctx context.Context, event trackedEvent) error {
+		received = event
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), encodeTrackedEvent(t)))
+
+	assert.Equal(t, snowflakeID, received.Offset)
+	assert.IsType(t, float64(0), received.Meta["id"])
+	assert.NotEqual(t, snowflakeID, int64(received.Meta["id"].(float64)))
+}
+
+func TestStrictJSONMessageHandlerKeepsIntegerPrecision(t *testing.T) {
+	var received trackedEvent
+	handler := messagebroker.StrictJSONMessageHandler(func(ctx context.Context, event trackedEvent) error {
+		received = event
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), encodeTrackedEvent(t)))
+
+	assert.Equal(t, snowflakeID, received.Offset)
+	id, err := received.Meta["id"].(json.Number).Int64()
+	require.NoError(t, err)
+	assert.Equal(t, snowflakeID, id)
+
+	// Re-encoding writes the number back unchanged
+	data, err := json.Marshal(received)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(encodeTrackedEvent(t).Data), string(data))
+}
+
+func TestMessageRouterPicksMostSpecificPattern(t *testing.T) {
+	router := messagebroker.NewMessageRouter()
+	route := func(name string) messagebroker.MessageHandler {
+		return func(ctx context.Context, message *messagebroker.Message) error {
+			message.Headers["route"] = name
+			return nil
+		}
+	}
+	router.AddRoute("#", route("#"))
+	router.AddRoute("user.#", route("user.#"))
+	router.AddRoute("user.*", route("user.*"))
+	router.AddRoute("user.created", route("user.created"))
+	router.AddRoute("user.*.audit", route("user.*.audit"))
+	router.AddRoute("order.#", route("order.#"))
+	router.AddRoute("*", route("*"))
+
+	for topic, expected := range map[string]string{
+		"user.created":          "user.created",
+		"user.updated":          "user.*",
+		"user.updated.audit":    "user.*.audit",
+		"user.updated.profile":  "user.#",
+		"user":                  "user.#",
+		"order":                 "order.#",
+		"order.item.added":      "order.#",
+		"invoice.paid":          "#",
+		"userprofile.something": "#",
+	} {
+		message := &messagebroker.Message{Topic: topic, Headers: map[string]string{}}
+		require.NoError(t, router.Route()(context.Background(), message))
+		assert.Equal(t, expected, message.Headers["route"], topic)
+	}
+}
+
+func TestMessageRouterFallsBackToStar(t *testing.T) {
+	router := messagebroker.NewMessageRouter()
+	var routed []string
+	router.AddRoute("order.*", collect(&routed))
+	router.AddRoute("*", func(ctx context.Context, message *messagebroker.Message) error {
+		routed = append(routed, "fallback:"+message.Topic)
+		return nil
+	})
+
+	handler := router.Route()
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "order.paid", Data: []byte("paid")}))
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "order.item.added"}))
+	assert.Equal(t, []string{"paid", "fallback:order.item.added"}, routed)
+}
+
+func TestMessageRouterWithoutMatchingRoute(t *testing.T) {
+	router := messagebroker.NewMessageRouter()
+	router.AddRoute("user.*", collect(new([]string)))
+
+	err := router.Route()(context.Background(), &messagebroker.Message{Topic: "user"})
+	assert.EqualError(t, err, "no route found for topic: user")
+}
+
+// batchRecorder collects the batches a BulkMessageHandler flushes
+type batchRecorder struct {
+	batches chan []string
+}
+
+func (r *batchRecorder) handle(ctx context.Context, messages []*messagebroker.Message) error {
+	batch := make([]string, 0, len(messages))
+	for _, message := range messages {
+		batch = append(batch, string(message.Data))
+	}
+	r.batches <- batch
+	return nil
+}
+
+func (r *batchRecorder) next(t *testing.T) []string {
+	t.Helper()
+	select {
+	case batch := <-r.batches:
+		return batch
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed")
+		return nil
+	}
+}
+
+func TestBulkMessageHandlerFlushesPartialBatchOnTimer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := &batchRecorder{batches: make(chan []string, 4)}
+	handler := messagebroker.BulkMessageHandler(ctx, 10, 20*time.Millisecond, recorder.handle)
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("a")}))
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("b")}))
+
+	assert.Equal(t, []string{"a", "b"}, recorder.next(t))
+}
+
+func TestBulkMessageHandlerFlushesFullBatchImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := &batchRecorder{batches: make(chan []string, 4)}
+	handler := messagebroker.BulkMessageHandler(ctx, 2, time.Hour, recorder.handle)
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("a")}))
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("b")}))
+
+	select {
+	case batch := <-recorder.batches:
+		assert.Equal(t, []string{"a", "b"}, batch)
+	default:
+		t.Fatal("full batch was not flushed by the call that filled it")
+	}
+}
+
+func TestBulkMessageHandlerFlushesRemainderOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	recorder := &batchRecorder{batches: make(chan []string, 4)}
+	flushed := make(chan error, 1)
+	handler := messagebroker.BulkMessageHandler(ctx, 10, time.Hour, func(flushCtx context.Context, messages []*messagebroker.Message) error {
+		flushed <- flushCtx.Err()
+		return recorder.handle(flushCtx, messages)
+	})
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("last")}))
+	cancel()
+
+	assert.Equal(t, []string{"last"}, recorder.next(t))
+	assert.NoError(t, <-flushed, "the final flush gets a live context")
+}
+
+// failingOnce fails the first flush with err, closing failed, and records the batches after it
+func (r *batchRecorder) failingOnce(err error, failed chan struct{}) func(ctx context.Context, messages []*messagebroker.Message) error {
+	var calls atomic.Int32
+	return func(ctx context.Context, messages []*messagebroker.Message) error {
+		if calls.Add(1) == 1 {
+			close(failed)
+			return err
+		}
+		return r.handle(ctx, messages)
+	}
+}
+
+func TestBulkMessageHandlerRetriesFailedTimerFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := &batchRecorder{batches: make(chan []string, 4)}
+	failed := make(chan struct{})
+	handler := messagebroker.BulkMessageHandler(ctx, 10, 100*time.Millisecond, recorder.failingOnce(errors.New("write failed"), failed))
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("a")}))
+	<-failed
+
+	// A message taken after the failed flush is not blamed for it
+	assert.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("b")}))
+
+	// The failed batch is kept and flushed again with the message taken after it
+	assert.Equal(t, []string{"a", "b"}, recorder.next(t))
+}
+
+func TestBulkMessageHandlerKeepsFailedFullBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recorder := &batchRecorder{batches: make(chan []string, 4)}
+	handler := messagebroker.BulkMessageHandler(ctx, 2, time.Hour, recorder.failingOnce(errors.New("write failed"), make(chan struct{})))
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("a")}))
+	assert.EqualError(t, handler(ctx, &messagebroker.Message{Data: []byte("b")}), "write failed")
+
+	// The caller retries the message whose flush failed
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("b")}))
+	assert.Equal(t, []string{"a", "b"}, recorder.next(t))
+}
+
+func TestNewBulkMessageHandlerWaitsForFinalFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var flushed atomic.Bool
+	handler, wait := messagebroker.NewBulkMessageHandler(ctx, 10, time.Hour, func(ctx context.Context, messages []*messagebroker.Message) error {
+		time.Sleep(50 * time.Millisecond)
+		flushed.Store(true)
+		return nil
+	})
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("last")}))
+	cancel()
+	require.NoError(t, wait())
+
+	assert.True(t, flushed.Load(), "wait returned before the final flush finished")
+}
+
+func TestNewBulkMessageHandlerWaitReturnsFinalFlushError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	handler, wait := messagebroker.NewBulkMessageHandler(ctx, 10, time.Hour, func(ctx context.Context, messages []*messagebroker.Message) error {
+		return errors.New("write failed")
+	})
+
+	require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte("last")}))
+	cancel()
+
+	assert.EqualError(t, wait(), "write failed")
+}
+
+func TestBulkMessageHandlerLetsHandlerKeepBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var kept [][]*messagebroker.Message
+	handler := messagebroker.BulkMessageHandler(ctx, 2, time.Hour, func(ctx context.Context, messages []*messagebroker.Message) error {
+		kept = append(kept, messages)
+		return nil
+	})
+
+	for _, data := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, handler(ctx, &messagebroker.Message{Data: []byte(data)}))
+	}
+
+	require.Len(t, kept, 2)
+	assert.Equal(t, "a", string(kept[0][0].Data), "a later batch overwrote a kept one")
+	assert.Equal(t, "c", string(kept[1][0].Data))
+}
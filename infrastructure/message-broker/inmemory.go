@@ -0,0 +1,504 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReplayableBroker is implemented by brokers that keep a history of published messages,
+// letting tests subscribe after publishing and inspect what was sent
+type ReplayableBroker interface {
+	MessageBroker
+
+	// History returns copies of the messages retained for the topic, oldest first
+	History(topic string) []*Message
+
+	// Replay delivers the retained messages for the topic to its current subscriber
+	Replay(ctx context.Context, topic string) error
+}
+
+type inMemoryBroker struct {
+	config      *BrokerConfig
+	subscribers map[string]*inMemorySubscription
+	topics      map[string]struct{}
+	history     map[string][]*Message
+	published   map[string]int64
+	consumed    map[string]int64
+	sequence    int64
+	mutex       sync.RWMutex
+	connected   bool
+}
+
+type inMemorySubscription struct {
+	handler MessageHandler
+	options *SubscribeOptions
+	ctx     context.Context
+	topic   string   // Key in subscribers: the topic, or the SubscribeMany handle
+	topics  []string // Topics delivered when created by SubscribeMany
+	gate    pauseGate
+}
+
+// NewInMemoryBroker creates a broker that delivers messages in-process, synchronously,
+// from Publish. It is intended for tests and local runs without external infrastructure.
+// When InMemoryRetention is set, the last InMemoryRetention messages per topic are kept
+// and delivered to subscribers that join after they were published. Handlers run on the
+// publishing goroutine, so SubscribeOptions.Concurrency is ignored: concurrent publishers
+// call a handler concurrently, and a handler may publish to its own topic.
+func NewInMemoryBroker(config *BrokerConfig) (MessageBroker, error) {
+	if config == nil {
+		return nil, errors.New("broker config is required")
+	}
+
+	if config.InMemoryRetention < 0 {
+		return nil, errors.New("in-memory retention cannot be negative")
+	}
+
+	return &inMemoryBroker{
+		config:      config,
+		subscribers: make(map[string]*inMemorySubscription),
+		topics:      make(map[string]struct{}),
+		history:     make(map[string][]*Message),
+		published:   make(map[string]int64),
+		consumed:    make(map[string]int64),
+	}, nil
+}
+
+// Connect marks the broker as connected; there is nothing to dial
+func (b *inMemoryBroker) Connect(ctx context.Context) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.connected = true
+	return nil
+}
+
+// Disconnect drops all subscriptions. Retained history is kept so it can still be inspected.
+func (b *inMemoryBroker) Disconnect(ctx context.Context) error {
+	b.mutex.Lock()
+	stopped := b.subscribers
+	b.subscribers = make(map[string]*inMemorySubscription)
+	b.connected = false
+	b.mutex.Unlock()
+
+	for _, subscription := range stopped {
+		notifySubscription(subscription.options.OnStop, subscription.event())
+	}
+	return nil
+}
+
+// Publish sends a message to the specified topic/queue
+func (b *inMemoryBroker) Publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(b.config.publishInterceptors(), b.config.observingPublish(b.publish))(ctx, topic, message, options)
+}
+
+// PublishAsync delivers like Publish; in-memory delivery never waits on a remote broker
+func (b *inMemoryBroker) PublishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return b.Publish(ctx, topic, message, options)
+}
+
+func (b *inMemoryBroker) publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	b.mutex.Lock()
+	if !b.connected {
+		b.mutex.Unlock()
+		return errBrokerNotConnected
+	}
+
+	b.sequence++
+	msg := &Message{
+		ID:        strconv.FormatInt(b.sequence, 10),
+		Topic:     topic,
+		Data:      append([]byte(nil), message...),
+		Headers:   make(map[string]string),
+		Timestamp: time.Now(),
+	}
+	if options != nil {
+		for k, v := range options.Headers {
+			msg.Headers[k] = v
+		}
+	}
+
+	b.published[topic]++
+	b.mutex.Unlock()
+
+	// A delayed message is neither retained nor delivered until its delay has passed
+	if options != nil && options.Delay > 0 {
+		time.AfterFunc(options.Delay, func() { b.arrive(msg) })
+		return nil
+	}
+
+	b.arrive(msg)
+	return nil
+}
+
+// arrive retains msg and hands it to the topic's subscriber, if any. Messages arriving
+// after Disconnect are dropped.
+func (b *inMemoryBroker) arrive(msg *Message) {
+	b.mutex.Lock()
+	if !b.connected {
+		b.mutex.Unlock()
+		return
+	}
+	b.retain(msg)
+	subscription := b.subscriberFor(msg.Topic)
+	b.mutex.Unlock()
+
+	// Handlers run without the lock held so they can publish or subscribe themselves
+	if subscription != nil {
+		b.deliver(subscription, msg)
+	}
+}
+
+// retain appends msg to the topic history, dropping the oldest entries beyond the limit.
+// The caller must hold the write lock.
+func (b *inMemoryBroker) retain(msg *Message) {
+	limit := b.config.InMemoryRetention
+	if limit == 0 {
+		return
+	}
+
+	history := append(b.history[msg.Topic], msg)
+	if len(history) > limit {
+		history = append([]*Message(nil), history[len(history)-limit:]...)
+	}
+	b.history[msg.Topic] = history
+}
+
+// deliver hands a copy of msg to the subscription's handler, dropping it while paused
+func (b *inMemoryBroker) deliver(subscription *inMemorySubscription, msg *Message) {
+	if subscription.gate.IsPaused() {
+		return
+	}
+
+	message := copyMessage(msg)
+	message.acknowledger = inMemoryAcknowledger{}
+	if err := runWithRetries(subscription.ctx, subscription.handler, message, subscription.options); err != nil {
+		b.config.messageFailed(subscription.ctx, subscription.options, message, err, logrus.Fields{"topic": msg.Topic}, "Failed to process in-memory message")
+		return
+	}
+
+	b.mutex.Lock()
+	b.consumed[msg.Topic]++
+	b.mutex.Unlock()
+}
+
+// PublishJSON sends a message encoded by the configured Serializer, JSON by default, to
+// the specified topic/queue
+func (b *inMemoryBroker) PublishJSON(ctx context.Context, topic string, message interface{}, options *PublishOptions) error {
+	data, options, err := serializeForPublish(b.config, message, options)
+	if err != nil {
+		return err
+	}
+
+	return b.Publish(ctx, topic, data, options)
+}
+
+// Subscribe registers the handler for the topic and immediately delivers any retained messages
+func (b *inMemoryBroker) Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error {
+	return b.subscribe(ctx, topic, nil, handler, options)
+}
+
+// SubscribeMany registers the handler for every topic under one handle and delivers the
+// retained messages of each topic in turn
+func (b *inMemoryBroker) SubscribeMany(ctx context.Context, topics []string, handler MessageHandler, options *SubscribeOptions) (string, error) {
+	if err := validateTopics(topics); err != nil {
+		return "", err
+	}
+
+	handle := SubscriptionHandle(topics)
+	if err := b.subscribe(ctx, handle, append([]string(nil), topics...), handler, options); err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+// subscribe stores the subscription under key, delivering topics or just key when topics
+// is empty
+func (b *inMemoryBroker) subscribe(ctx context.Context, key string, topics []string, handler MessageHandler, options *SubscribeOptions) error {
+	handler = b.config.extractingTraceContext(b.config.deduplicating(options, b.config.observingConsume(timingOut(options, handler))))
+
+	if options == nil {
+		options = &SubscribeOptions{
+			AutoAck:     true,
+			MaxRetries:  3,
+			RetryDelay:  time.Second,
+			Concurrency: 1,
+		}
+	}
+
+	b.mutex.Lock()
+	if !b.connected {
+		b.mutex.Unlock()
+		return errBrokerNotConnected
+	}
+
+	subscription := &inMemorySubscription{
+		handler: handler,
+		options: options,
+		ctx:     ctx,
+		topic:   key,
+		topics:  topics,
+	}
+	replaced := b.subscribers[key]
+	b.subscribers[key] = subscription
+	var retained []*Message
+	for _, topic := range subscription.deliveredTopics() {
+		retained = append(retained, b.history[topic]...)
+	}
+	b.mutex.Unlock()
+
+	if replaced != nil {
+		notifySubscription(replaced.options.OnStop, replaced.event())
+	}
+	notifySubscription(options.OnStart, subscription.event())
+
+	for _, msg := range retained {
+		b.deliver(subscription, msg)
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes from the specified topic/queue
+func (b *inMemoryBroker) Unsubscribe(ctx context.Context, topic string) error {
+	b.mutex.Lock()
+	subscription, exists := b.subscribers[topic]
+	if !exists {
+		b.mutex.Unlock()
+		return errSubscriptionNotFound
+	}
+	delete(b.subscribers, topic)
+	b.mutex.Unlock()
+
+	notifySubscription(subscription.options.OnStop, subscription.event())
+	return nil
+}
+
+func (s *inMemorySubscription) event() SubscriptionEvent {
+	return SubscriptionEvent{Topic: s.topic, Group: s.options.QueueName}
+}
+
+// deliveredTopics returns the topics whose messages the subscription receives
+func (s *inMemorySubscription) deliveredTopics() []string {
+	if len(s.topics) > 0 {
+		return s.topics
+	}
+	return []string{s.topic}
+}
+
+// subscriberFor finds the subscription receiving topic: one made for the topic itself, or
+// else a SubscribeMany subscription listing it. The caller holds the mutex.
+func (b *inMemoryBroker) subscriberFor(topic string) *inMemorySubscription {
+	if subscription, exists := b.subscribers[topic]; exists {
+		return subscription
+	}
+	for _, subscription := range b.subscribers {
+		for _, subscribed := range subscription.topics {
+			if subscribed == topic {
+				return subscription
+			}
+		}
+	}
+	return nil
+}
+
+// History returns copies of the messages retained for the topic, oldest first
+func (b *inMemoryBroker) History(topic string) []*Message {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	history := make([]*Message, 0, len(b.history[topic]))
+	for _, msg := range b.history[topic] {
+		history = append(history, copyMessage(msg))
+	}
+	return history
+}
+
+// Replay delivers the retained messages for the topic to its current subscriber, which
+// may be a SubscribeMany subscription listing the topic
+func (b *inMemoryBroker) Replay(ctx context.Context, topic string) error {
+	b.mutex.RLock()
+	subscription := b.subscriberFor(topic)
+	retained := append([]*Message(nil), b.history[topic]...)
+	b.mutex.RUnlock()
+
+	if subscription == nil {
+		return errSubscriptionNotFound
+	}
+
+	for _, msg := range retained {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.deliver(subscription, msg)
+	}
+	return nil
+}
+
+// PauseSubscription drops messages published to the topic until it is resumed
+func (b *inMemoryBroker) PauseSubscription(topic string) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	subscription, exists := b.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	subscription.gate.Pause()
+	return nil
+}
+
+// ResumeSubscription restarts delivery for a paused topic
+func (b *inMemoryBroker) ResumeSubscription(topic string) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	subscription, exists := b.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	subscription.gate.Resume()
+	return nil
+}
+
+// Pause stops invoking the topic's handler until Resume; see PauseSubscription
+func (b *inMemoryBroker) Pause(ctx context.Context, topic string) error {
+	return changePause(ctx, b.PauseSubscription, topic)
+}
+
+// Resume restarts delivery to a paused topic; see ResumeSubscription
+func (b *inMemoryBroker) Resume(ctx context.Context, topic string) error {
+	return changePause(ctx, b.ResumeSubscription, topic)
+}
+
+// CreateTopic registers the topic so it is reported by ListTopics
+func (b *inMemoryBroker) CreateTopic(ctx context.Context, topic string, options *TopicOptions) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.topics[topic] = struct{}{}
+	return nil
+}
+
+// DeleteTopic removes the topic together with its subscription and retained history,
+// running the subscription's OnStop
+func (b *inMemoryBroker) DeleteTopic(ctx context.Context, topic string) error {
+	b.mutex.Lock()
+	subscription, subscribed := b.subscribers[topic]
+	delete(b.topics, topic)
+	delete(b.subscribers, topic)
+	delete(b.history, topic)
+	delete(b.published, topic)
+	delete(b.consumed, topic)
+	b.mutex.Unlock()
+
+	if subscribed {
+		notifySubscription(subscription.options.OnStop, subscription.event())
+	}
+	return nil
+}
+
+// ListTopics returns the topics that were created, subscribed to or published to, sorted
+func (b *inMemoryBroker) ListTopics(ctx context.Context) ([]string, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	seen := make(map[string]struct{}, len(b.topics))
+	for topic := range b.topics {
+		seen[topic] = struct{}{}
+	}
+	for _, subscription := range b.subscribers {
+		for _, topic := range subscription.deliveredTopics() {
+			seen[topic] = struct{}{}
+		}
+	}
+	for topic := range b.published {
+		seen[topic] = struct{}{}
+	}
+
+	topics := make([]string, 0, len(seen))
+	for topic := range seen {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// Ping checks if the broker is connected
+func (b *inMemoryBroker) Ping(ctx context.Context) error {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if !b.connected {
+		return errBrokerNotConnected
+	}
+	return nil
+}
+
+// PublishBatch publishes the messages one by one, in order
+func (b *inMemoryBroker) PublishBatch(ctx context.Context, messages []BatchMessage, options *PublishOptions) error {
+	for _, msg := range messages {
+		if err := b.Publish(ctx, msg.Topic, msg.Data, batchMessageOptions(options, msg.Headers)); err != nil {
+			return fmt.Errorf("failed to publish batch message to topic %s: %w", msg.Topic, err)
+		}
+	}
+	return nil
+}
+
+// GetStats returns per-topic publish and consume counts
+func (b *inMemoryBroker) GetStats(ctx context.Context) (*BrokerStats, error) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	stats := &BrokerStats{
+		ConnectedClients: 1,
+		Custom: map[string]interface{}{
+			"type":        "InMemory",
+			"subscribers": len(b.subscribers),
+			"retention":   b.config.InMemoryRetention,
+		},
+	}
+
+	for topic, published := range b.published {
+		subscribers := 0
+		if b.subscriberFor(topic) != nil {
+			subscribers = 1
+		}
+
+		stats.MessagesPublished += published
+		stats.MessagesConsumed += b.consumed[topic]
+		stats.Topics = append(stats.Topics, TopicStats{
+			Name:              topic,
+			MessagesPublished: published,
+			MessagesConsumed:  b.consumed[topic],
+			Subscribers:       subscribers,
+		})
+	}
+	sort.Slice(stats.Topics, func(i, j int) bool { return stats.Topics[i].Name < stats.Topics[j].Name })
+
+	return stats, nil
+}
+
+// Close closes the in-memory broker
+func (b *inMemoryBroker) Close() error {
+	return b.Disconnect(context.Background())
+}
+
+// copyMessage gives each handler and History caller its own message so that mutations
+// do not leak into the retained history
+func copyMessage(msg *Message) *Message {
+	clone := *msg
+	clone.Data = append([]byte(nil), msg.Data...)
+	clone.Headers = make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		clone.Headers[k] = v
+	}
+	return &clone
+}
@@ -0,0 +1,210 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInMemoryBroker(t *testing.T, opts ...messagebroker.BrokerOption) messagebroker.ReplayableBroker {
+	t.Helper()
+
+	broker, err := messagebroker.NewInMemoryBroker(messagebroker.NewBrokerConfig(opts...))
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { _ = broker.Close() })
+
+	replayable, ok := broker.(messagebroker.ReplayableBroker)
+	require.True(t, ok)
+	return replayable
+}
+
+func collect(received *[]string) messagebroker.MessageHandler {
+	return func(ctx context.Context, message *messagebroker.Message) error {
+		*received = append(*received, string(message.Data))
+		return nil
+	}
+}
+
+func TestInMemoryBrokerDeliversRetainedMessagesToLateSubscriber(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(10))
+	ctx := context.Background()
+
+	require.NoError(t, broker.Publish(ctx, "user.registered", []byte("alice"), nil))
+	require.NoError(t, broker.Publish(ctx, "user.registered", []byte("bob"), nil))
+
+	var received []string
+	require.NoError(t, broker.Subscribe(ctx, "user.registered", collect(&received), nil))
+	assert.Equal(t, []string{"alice", "bob"}, received)
+
+	require.NoError(t, broker.Publish(ctx, "user.registered", []byte("carol"), nil))
+	assert.Equal(t, []string{"alice", "bob", "carol"}, received)
+}
+
+func TestInMemoryBrokerWithoutRetentionDeliversOnlyLiveMessages(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	ctx := context.Background()
+
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("before"), nil))
+
+	var received []string
+	require.NoError(t, broker.Subscribe(ctx, "orders", collect(&received), nil))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("after"), nil))
+
+	assert.Equal(t, []string{"after"}, received)
+	assert.Empty(t, broker.History("orders"))
+}
+
+func TestInMemoryBrokerHistory(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(2))
+	ctx := context.Background()
+
+	require.NoError(t, broker.PublishJSON(ctx, "emails", map[string]string{"to": "a@example.com"}, nil))
+	require.NoError(t, broker.Publish(ctx, "emails", []byte("second"), &messagebroker.PublishOptions{
+		Headers: map[string]string{"X-Kind": "verification"},
+	}))
+	require.NoError(t, broker.Publish(ctx, "emails", []byte("third"), nil))
+	require.NoError(t, broker.Publish(ctx, "other", []byte("unrelated"), nil))
+
+	history := broker.History("emails")
+	require.Len(t, history, 2, "only the most recent messages are retained")
+	assert.Equal(t, "second", string(history[0].Data))
+	assert.Equal(t, "verification", history[0].Headers["X-Kind"])
+	assert.Equal(t, "emails", history[0].Topic)
+	assert.Equal(t, "third", string(history[1].Data))
+
+	// Mutating the returned messages must not change what is retained
+	history[0].Data[0] = 'X'
+	history[0].Headers["X-Kind"] = "changed"
+	again := broker.History("emails")
+	assert.Equal(t, "second", string(again[0].Data))
+	assert.Equal(t, "verification", again[0].Headers["X-Kind"])
+}
+
+func TestInMemoryBrokerReplay(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(5))
+	ctx := context.Background()
+
+	assert.Error(t, broker.Replay(ctx, "events"), "replay requires a subscriber")
+
+	var received []string
+	require.NoError(t, broker.Subscribe(ctx, "events", collect(&received), nil))
+	require.NoError(t, broker.Publish(ctx, "events", []byte("one"), nil))
+	require.NoError(t, broker.Publish(ctx, "events", []byte("two"), nil))
+
+	require.NoError(t, broker.Replay(ctx, "events"))
+	assert.Equal(t, []string{"one", "two", "one", "two"}, received)
+}
+
+func TestInMemoryBrokerTopicsAndStats(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(5))
+	ctx := context.Background()
+
+	require.NoError(t, broker.CreateTopic(ctx, "created", nil))
+	require.NoError(t, broker.PublishBatch(ctx, []messagebroker.BatchMessage{
+		{Topic: "published", Data: []byte("1")},
+		{Topic: "published", Data: []byte("2")},
+	}, nil))
+
+	topics, err := broker.ListTopics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"created", "published"}, topics)
+
+	stats, err := broker.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.MessagesPublished)
+
+	require.NoError(t, broker.DeleteTopic(ctx, "published"))
+	assert.Empty(t, broker.History("published"))
+}
+
+func TestInMemoryBrokerReplaysToSubscribeMany(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(5))
+	ctx := context.Background()
+
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("one"), nil))
+	var received []string
+	_, err := broker.SubscribeMany(ctx, []string{"orders", "payments"}, collect(&received), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Replay(ctx, "orders"))
+	assert.Equal(t, []string{"one", "one"}, received)
+}
+
+func TestInMemoryBrokerCountsOnlyHandledMessages(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	ctx := context.Background()
+
+	require.NoError(t, broker.Subscribe(ctx, "orders", func(ctx context.Context, message *messagebroker.Message) error {
+		if string(message.Data) == "bad" {
+			return messagebroker.Permanent(errors.New("malformed order"))
+		}
+		return nil
+	}, &messagebroker.SubscribeOptions{AutoAck: true, OnError: func(context.Context, *messagebroker.Message, error) {}}))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("good"), nil))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("bad"), nil))
+
+	stats, err := broker.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.MessagesPublished)
+	assert.Equal(t, int64(1), stats.MessagesConsumed)
+}
+
+func TestInMemoryBrokerDeleteTopicStopsSubscription(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	ctx := context.Background()
+
+	var stopped []string
+	options := &messagebroker.SubscribeOptions{AutoAck: true, OnStop: func(event messagebroker.SubscriptionEvent) {
+		stopped = append(stopped, event.Topic)
+	}}
+	require.NoError(t, broker.Subscribe(ctx, "orders", func(context.Context, *messagebroker.Message) error { return nil }, options))
+
+	require.NoError(t, broker.DeleteTopic(ctx, "orders"))
+	assert.Equal(t, []string{"orders"}, stopped)
+}
+
+func TestInMemoryBrokerRequiresConnection(t *testing.T) {
+	broker, err := messagebroker.NewInMemoryBroker(&messagebroker.BrokerConfig{})
+	require.NoError(t, err)
+
+	assert.Error(t, broker.Publish(context.Background(), "topic", []byte("data"), nil))
+	assert.Error(t, broker.Ping(context.Background()))
+
+	_, err = messagebroker.NewInMemoryBroker(&messagebroker.BrokerConfig{InMemoryRetention: -1})
+	assert.Error(t, err)
+}
+
+func TestCreateInMemoryBroker(t *testing.T) {
+	broker, err := messagebroker.CreateBroker(messagebroker.TypeInMemory, messagebroker.NewBrokerConfig())
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, broker.Connect(ctx))
+	t.Cleanup(func() { _ = broker.Close() })
+
+	var received []string
+	require.NoError(t, broker.Subscribe(ctx, "orders", collect(&received), &messagebroker.SubscribeOptions{Concurrency: 4}))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("first"), nil))
+
+	assert.Equal(t, []string{"first"}, received, "delivery is synchronous")
+
+	require.NoError(t, broker.Unsubscribe(ctx, "orders"))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("second"), nil))
+	assert.Equal(t, []string{"first"}, received)
+}
+
+func TestPublishWithResultWithoutBrokerSupport(t *testing.T) {
+	broker := newInMemoryBroker(t)
+
+	var received []string
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", collect(&received), nil))
+
+	result, err := messagebroker.PublishWithResult(context.Background(), broker, "orders", []byte("1"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, messagebroker.PublishResult{}, *result)
+	assert.Equal(t, []string{"1"}, received)
+}
@@ -0,0 +1,17 @@
+//go:build !integration
+
+package messagebroker_test
+
+import (
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+)
+
+// integrationBrokerConfig skips the test; build with -tags integration to run it against
+// containers started by the testharness package
+func integrationBrokerConfig(t *testing.T, instance int) *messagebroker.BrokerConfig {
+	t.Helper()
+	t.Skip("integration test - run with -tags integration and a Docker daemon")
+	return nil
+}
@@ -0,0 +1,124 @@
+//go:build integration
+
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/prayaspoudel/infrastructure/testharness"
+	"github.com/stretchr/testify/require"
+)
+
+// integrationBrokerConfig starts a container for instance and returns a config for it
+func integrationBrokerConfig(t *testing.T, instance int) *messagebroker.BrokerConfig {
+	t.Helper()
+	return testharness.BrokerConfig(t, instance)
+}
+
+func TestIntegrationPublishSubscribeRoundTrip(t *testing.T) {
+	brokers := []struct {
+		name     string
+		instance int
+		topic    string
+		options  *messagebroker.SubscribeOptions
+	}{
+		{"RabbitMQ", messagebroker.InstanceRabbitMQ, "integration.round-trip", messagebroker.DefaultSubscribeOptions()},
+		{"NATS", messagebroker.InstanceNATS, "integration.round-trip", messagebroker.DefaultSubscribeOptions()},
+		{"Kafka", messagebroker.InstanceKafka, "integration-round-trip", messagebroker.KafkaSubscribeOptions("integration-group", 1)},
+	}
+
+	for _, tt := range brokers {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := testharness.NewBroker(t, tt.instance)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if tt.instance == messagebroker.InstanceKafka {
+				require.NoError(t, broker.CreateTopic(ctx, tt.topic, messagebroker.KafkaTopicOptions(1, 1)))
+			}
+
+			received := make(chan *messagebroker.Message, 1)
+			handler := func(ctx context.Context, message *messagebroker.Message) error {
+				select {
+				case received <- message:
+				default:
+				}
+				return nil
+			}
+			require.NoError(t, broker.Subscribe(ctx, tt.topic, handler, tt.options))
+
+			// Consumers attach asynchronously, so keep publishing until one message lands
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				require.NoError(t, broker.Publish(ctx, tt.topic, []byte("ping"), &messagebroker.PublishOptions{
+					Headers: map[string]string{"source": "integration"},
+				}))
+
+				select {
+				case message := <-received:
+					require.Equal(t, "ping", string(message.Data))
+					require.Equal(t, "integration", message.Headers["source"])
+					require.NotEmpty(t, message.ID, "a Deduplicator needs a stable ID")
+					return
+				case <-ticker.C:
+				case <-ctx.Done():
+					t.Fatalf("no message received from %s: %v", tt.name, ctx.Err())
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationRabbitMQDeadLettersFailedMessages(t *testing.T) {
+	broker := testharness.NewBroker(t, messagebroker.InstanceRabbitMQ)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	options := messagebroker.DeadLetterSubscribeOptions("payments.dlx")
+	options.MaxRetries = 0
+	require.NoError(t, broker.Subscribe(ctx, "payments", func(ctx context.Context, message *messagebroker.Message) error {
+		return messagebroker.Permanent(errors.New("malformed payment"))
+	}, options))
+
+	deadLetters := make(chan *messagebroker.Message, 1)
+	deadLetterOptions := messagebroker.DefaultSubscribeOptions()
+	deadLetterOptions.QueueName = "payments.dlq"
+	require.NoError(t, broker.Subscribe(ctx, "payments.dead", func(ctx context.Context, message *messagebroker.Message) error {
+		deadLetters <- message
+		return nil
+	}, deadLetterOptions))
+
+	require.NoError(t, broker.Publish(ctx, "payments", []byte("payment-1"), &messagebroker.PublishOptions{
+		Headers: map[string]string{"tenant": "acme"},
+	}))
+
+	select {
+	case message := <-deadLetters:
+		require.Equal(t, "payment-1", string(message.Data))
+		require.Equal(t, "acme", message.Headers["tenant"])
+		require.Contains(t, message.Headers, "x-death")
+	case <-ctx.Done():
+		t.Fatal("failed message was not dead-lettered")
+	}
+}
+
+func TestIntegrationRabbitMQConfirmedPublish(t *testing.T) {
+	broker := testharness.NewBroker(t, messagebroker.InstanceRabbitMQ)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	require.NoError(t, broker.Subscribe(ctx, "ledger.entries", func(context.Context, *messagebroker.Message) error {
+		return nil
+	}, messagebroker.DefaultSubscribeOptions()))
+
+	confirm := &messagebroker.PublishOptions{Confirm: true, Persistent: true}
+	require.NoError(t, broker.Publish(ctx, "ledger.entries", []byte("entry-1"), confirm))
+
+	err := broker.Publish(ctx, "ledger.nobody-listens", []byte("entry-2"), confirm)
+	require.ErrorIs(t, err, messagebroker.ErrUnroutable)
+}
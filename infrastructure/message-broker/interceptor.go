@@ -0,0 +1,97 @@
+package messagebroker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// HeaderCorrelationID carries the correlation ID stamped by CorrelationIDInterceptor
+const HeaderCorrelationID = "X-Correlation-ID"
+
+// PublishFunc sends a single message; it matches the signature of MessageBroker.Publish
+type PublishFunc func(ctx context.Context, topic string, message []byte, options *PublishOptions) error
+
+// PublishInterceptor wraps the publish path, mirroring how handler wrappers decorate
+// the consume path. Interceptors may mutate the topic, payload or options before
+// calling next, or return an error to stop the message from being sent.
+type PublishInterceptor func(next PublishFunc) PublishFunc
+
+// chainPublish composes interceptors so that the first one registered runs first. The
+// payload is compressed after the last interceptor, right before final sends it.
+func chainPublish(interceptors []PublishInterceptor, final PublishFunc) PublishFunc {
+	publish := compressPublish(final)
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		publish = interceptors[i](publish)
+	}
+	return publish
+}
+
+type correlationIDKey struct{}
+
+// ContextWithCorrelationID returns a context whose published messages carry the given correlation ID
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID stored by ContextWithCorrelationID
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	correlationID, ok := ctx.Value(correlationIDKey{}).(string)
+	return correlationID, ok && correlationID != ""
+}
+
+// CorrelationIDInterceptor stamps every message with an X-Correlation-ID header. An ID already
+// present in the headers is kept; otherwise it is taken from the context or freshly generated.
+func CorrelationIDInterceptor() PublishInterceptor {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+			if options != nil && options.Headers[HeaderCorrelationID] != "" {
+				return next(ctx, topic, message, options)
+			}
+
+			correlationID, ok := CorrelationIDFromContext(ctx)
+			if !ok {
+				correlationID = uuid.NewString()
+			}
+
+			return next(ctx, topic, message, withHeader(options, HeaderCorrelationID, correlationID))
+		}
+	}
+}
+
+// withHeader returns a copy of options with the header set, leaving the caller's options untouched
+func withHeader(options *PublishOptions, key, value string) *PublishOptions {
+	copied := &PublishOptions{}
+	if options != nil {
+		*copied = *options
+	}
+
+	headers := make(map[string]string, len(copied.Headers)+1)
+	for k, v := range copied.Headers {
+		headers[k] = v
+	}
+	headers[key] = value
+	copied.Headers = headers
+
+	return copied
+}
+
+// batchMessageOptions returns a copy of the batch-wide options with the message's own
+// headers merged over them. The header map is always new, so no two messages share one.
+func batchMessageOptions(options *PublishOptions, headers map[string]string) *PublishOptions {
+	publishOptions := &PublishOptions{}
+	if options != nil {
+		*publishOptions = *options
+	}
+
+	publishOptions.Headers = make(map[string]string, len(publishOptions.Headers)+len(headers))
+	if options != nil {
+		for k, v := range options.Headers {
+			publishOptions.Headers[k] = v
+		}
+	}
+	for k, v := range headers {
+		publishOptions.Headers[k] = v
+	}
+	return publishOptions
+}
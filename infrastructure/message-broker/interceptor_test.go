@@ -0,0 +1,80 @@
+package messagebroker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishInterceptorsRunInOrderAndMutateHeaders(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	srv := natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	var order []string
+	recorder := func(name string) messagebroker.PublishInterceptor {
+		return func(next messagebroker.PublishFunc) messagebroker.PublishFunc {
+			return func(ctx context.Context, topic string, message []byte, options *messagebroker.PublishOptions) error {
+				order = append(order, name)
+				return next(ctx, topic, message, options)
+			}
+		}
+	}
+
+	broker, err := messagebroker.NewNATSBrokerWithOptions(
+		messagebroker.WithNATSURL(srv.ClientURL()),
+		messagebroker.WithPublishInterceptors(recorder("first"), recorder("second"), messagebroker.CorrelationIDInterceptor()),
+	)
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { broker.Close() })
+
+	received := make(chan *messagebroker.Message, 2)
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message
+		return nil
+	}, nil))
+
+	ctx := messagebroker.ContextWithCorrelationID(context.Background(), "corr-123")
+	require.NoError(t, broker.PublishJSON(ctx, "orders", map[string]int{"id": 1}, nil))
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "corr-123", message.Headers[messagebroker.HeaderCorrelationID])
+		assert.Equal(t, "application/json", message.Headers["Content-Type"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	assert.Equal(t, []string{"first", "second"}, order)
+
+	// Without a correlation ID in the context one is generated
+	require.NoError(t, broker.PublishBatch(context.Background(), []messagebroker.BatchMessage{{Topic: "orders", Data: []byte("batch")}}, nil))
+
+	select {
+	case message := <-received:
+		assert.NotEmpty(t, message.Headers[messagebroker.HeaderCorrelationID])
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch message was not delivered")
+	}
+	assert.Equal(t, []string{"first", "second", "first", "second"}, order)
+}
+
+func TestCorrelationIDInterceptorKeepsExistingHeader(t *testing.T) {
+	headers := map[string]string{messagebroker.HeaderCorrelationID: "existing"}
+
+	var sent *messagebroker.PublishOptions
+	publish := messagebroker.CorrelationIDInterceptor()(func(ctx context.Context, topic string, message []byte, options *messagebroker.PublishOptions) error {
+		sent = options
+		return nil
+	})
+
+	require.NoError(t, publish(context.Background(), "orders", nil, &messagebroker.PublishOptions{Headers: headers}))
+	assert.Equal(t, "existing", sent.Headers[messagebroker.HeaderCorrelationID])
+}
@@ -2,12 +2,12 @@ package messagebroker
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -24,15 +24,47 @@ type kafkaBroker struct {
 	mutex         sync.RWMutex
 	connected     bool
 	client        sarama.Client
+
+	// asyncPending tracks messages sent through asyncProducer until they are confirmed
+	asyncPending inFlight
+	counters     messageCounters
+
+	// newConsumerGroup creates subscription consumer groups; sarama.NewConsumerGroup when nil
+	newConsumerGroup func(addrs []string, groupID string, config *sarama.Config) (sarama.ConsumerGroup, error)
 }
 
+// Headers added to a Kafka message republished to SubscribeOptions.DeadLetterTopic. The
+// original record's key and headers are kept.
+const (
+	HeaderDeadLetterError     = "x-error"
+	HeaderDeadLetterRetries   = "x-retry-count"
+	HeaderDeadLetterTopic     = "x-original-topic"
+	HeaderDeadLetterPartition = "x-original-partition"
+	HeaderDeadLetterOffset    = "x-original-offset"
+)
+
+// consumerErrorBuffer is the capacity of the channel returned by SubscribeWithErrors
+const consumerErrorBuffer = 16
+
 type kafkaSubscription struct {
 	consumerGroup sarama.ConsumerGroup
 	handler       MessageHandler
 	options       *SubscribeOptions
 	cancel        context.CancelFunc
-	topic         string
+	topic         string   // Key in subscribers: the topic, or the SubscribeMany handle
+	topics        []string // Topics consumed when created by SubscribeMany
 	groupID       string
+	gate          pauseGate
+	handling      handling
+
+	// startApplied records partitions already moved to StartFromTime
+	startApplied map[topicPartition]bool
+	startMutex   sync.Mutex
+
+	// started is set by the first session's Setup; later sessions follow a rebalance
+	started atomic.Bool
+	// reconnects counts the Consume calls made since the group last failed
+	reconnects atomic.Int32
 }
 
 // kafkaConsumerGroupHandler implements sarama.ConsumerGroupHandler
@@ -51,12 +83,98 @@ func NewKafkaBroker(config *BrokerConfig) (MessageBroker, error) {
 		return nil, errors.New("Kafka brokers list or URL is required")
 	}
 
+	if err := configureKafkaProducer(sarama.NewConfig(), config); err != nil {
+		return nil, err
+	}
+	if err := configureKafkaSecurity(sarama.NewConfig(), config); err != nil {
+		return nil, err
+	}
+
 	return &kafkaBroker{
 		config:      config,
 		subscribers: make(map[string]*kafkaSubscription),
 	}, nil
 }
 
+// defaultKafkaVersion is the protocol version used when BrokerConfig.KafkaVersion is unset
+var defaultKafkaVersion = sarama.V2_8_0_0
+
+// kafkaVersion parses config.KafkaVersion, falling back to defaultKafkaVersion with a
+// warning when it is not a version sarama understands
+func kafkaVersion(config *BrokerConfig) sarama.KafkaVersion {
+	if config.KafkaVersion == "" {
+		return defaultKafkaVersion
+	}
+
+	version, err := sarama.ParseKafkaVersion(config.KafkaVersion)
+	if err != nil {
+		config.logSampled(logrus.WarnLevel, err, logrus.Fields{"kafka_version": config.KafkaVersion},
+			"Invalid Kafka version, using "+defaultKafkaVersion.String())
+		return defaultKafkaVersion
+	}
+	return version
+}
+
+// Kafka initial offsets accepted in SubscribeOptions.InitialOffset
+const (
+	KafkaOffsetOldest = "oldest"
+	KafkaOffsetNewest = "newest"
+)
+
+// kafkaInitialOffset maps SubscribeOptions.InitialOffset to the sarama offset constant
+func kafkaInitialOffset(options *SubscribeOptions) (int64, error) {
+	switch strings.ToLower(options.InitialOffset) {
+	case "", KafkaOffsetOldest:
+		return sarama.OffsetOldest, nil
+	case KafkaOffsetNewest:
+		return sarama.OffsetNewest, nil
+	default:
+		return 0, fmt.Errorf("unknown Kafka initial offset %q: use %q or %q",
+			options.InitialOffset, KafkaOffsetOldest, KafkaOffsetNewest)
+	}
+}
+
+// Kafka producer acknowledgement levels accepted in BrokerConfig.KafkaRequiredAcks
+const (
+	KafkaAcksAll    = "all"
+	KafkaAcksLeader = "leader"
+	KafkaAcksNone   = "none"
+)
+
+// configureKafkaProducer applies the producer settings from config, rejecting acks levels
+// that are unknown or that idempotent producing cannot work with
+func configureKafkaProducer(saramaConfig *sarama.Config, config *BrokerConfig) error {
+	switch strings.ToLower(config.KafkaRequiredAcks) {
+	case "", KafkaAcksAll:
+		saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	case KafkaAcksLeader:
+		saramaConfig.Producer.RequiredAcks = sarama.WaitForLocal
+	case KafkaAcksNone:
+		saramaConfig.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		return fmt.Errorf("unknown Kafka required acks %q: use %q, %q or %q",
+			config.KafkaRequiredAcks, KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone)
+	}
+
+	saramaConfig.Producer.Retry.Max = 3
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+	saramaConfig.Producer.Partitioner = newExplicitPartitioner
+
+	if config.KafkaIdempotentProducer {
+		if saramaConfig.Producer.RequiredAcks != sarama.WaitForAll {
+			return fmt.Errorf("Kafka idempotent producer requires required acks %q, got %q",
+				KafkaAcksAll, config.KafkaRequiredAcks)
+		}
+		// The broker deduplicates by sequence number, which only holds when requests
+		// on a connection cannot overtake each other
+		saramaConfig.Producer.Idempotent = true
+		saramaConfig.Net.MaxOpenRequests = 1
+	}
+
+	return nil
+}
+
 // Connect establishes connection to Kafka
 func (k *kafkaBroker) Connect(ctx context.Context) error {
 	k.mutex.Lock()
@@ -66,13 +184,12 @@ func (k *kafkaBroker) Connect(ctx context.Context) error {
 
 	// Create Sarama configuration
 	saramaConfig := sarama.NewConfig()
-	saramaConfig.Version = sarama.V2_8_0_0 // Use a stable version
+	saramaConfig.Version = kafkaVersion(k.config)
 
 	// Producer configuration
-	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
-	saramaConfig.Producer.Retry.Max = 3
-	saramaConfig.Producer.Return.Successes = true
-	saramaConfig.Producer.Return.Errors = true
+	if err := configureKafkaProducer(saramaConfig, k.config); err != nil {
+		return err
+	}
 
 	// Consumer configuration
 	saramaConfig.Consumer.Return.Errors = true
@@ -80,29 +197,9 @@ func (k *kafkaBroker) Connect(ctx context.Context) error {
 	saramaConfig.Consumer.Group.Heartbeat.Interval = 3 * time.Second
 	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
 
-	// Authentication
-	if k.config.Username != "" && k.config.Password != "" {
-		saramaConfig.Net.SASL.Enable = true
-		saramaConfig.Net.SASL.User = k.config.Username
-		saramaConfig.Net.SASL.Password = k.config.Password
-
-		// Set SASL mechanism
-		switch k.config.KafkaSASLMechanism {
-		case "SCRAM-SHA-256":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
-		case "SCRAM-SHA-512":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
-		case "PLAIN":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-		default:
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-		}
-	}
-
-	// TLS configuration
-	if k.config.TLSEnabled {
-		saramaConfig.Net.TLS.Enable = true
-		// Additional TLS configuration can be added here
+	// Authentication and TLS
+	if err := configureKafkaSecurity(saramaConfig, k.config); err != nil {
+		return err
 	}
 
 	// Create client
@@ -128,6 +225,7 @@ func (k *kafkaBroker) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to create Kafka async producer: %w", err)
 	}
 	k.asyncProducer = asyncProducer
+	go k.drainAsync(asyncProducer)
 
 	k.connected = true
 	return nil
@@ -135,9 +233,33 @@ func (k *kafkaBroker) Connect(ctx context.Context) error {
 
 // Disconnect closes all Kafka connections
 func (k *kafkaBroker) Disconnect(ctx context.Context) error {
-	k.mutex.Lock()
-	defer k.mutex.Unlock()
+	// Let handlers finish their messages, and mark them, before the sessions end. The lock
+	// is not held meanwhile since handlers may publish.
+	k.mutex.RLock()
+	trackers := make([]*handling, 0, len(k.subscribers))
+	for _, sub := range k.subscribers {
+		trackers = append(trackers, &sub.handling)
+	}
+	async := k.asyncProducer != nil
+	k.mutex.RUnlock()
+	k.config.awaitDrained(ctx, trackers)
+
+	// Wait for buffered async messages before closing the producer that holds them. The
+	// lock is not held meanwhile either, since OnPublishError may publish a retry.
+	var flushErr error
+	if async {
+		flushCtx := ctx
+		if _, ok := ctx.Deadline(); !ok && k.config.Timeout > 0 {
+			var cancel context.CancelFunc
+			flushCtx, cancel = context.WithTimeout(ctx, k.config.Timeout)
+			defer cancel()
+		}
+		if err := k.asyncPending.wait(flushCtx); err != nil {
+			flushErr = fmt.Errorf("failed to flush Kafka async producer: %w", err)
+		}
+	}
 
+	k.mutex.Lock()
 	// Stop all subscriptions
 	for _, sub := range k.subscribers {
 		if sub.cancel != nil {
@@ -149,25 +271,55 @@ func (k *kafkaBroker) Disconnect(ctx context.Context) error {
 	}
 	k.subscribers = make(map[string]*kafkaSubscription)
 
-	// Close producers
-	if k.asyncProducer != nil {
-		k.asyncProducer.Close()
-		k.asyncProducer = nil
-	}
+	asyncProducer, producer, client := k.asyncProducer, k.producer, k.client
+	k.asyncProducer, k.producer, k.client = nil, nil, nil
+	k.connected = false
+	k.mutex.Unlock()
 
-	if k.producer != nil {
-		k.producer.Close()
-		k.producer = nil
+	// Close producers outside the lock; closing the async producer reports what it still
+	// held through drainAsync
+	if asyncProducer != nil {
+		asyncProducer.Close()
+	}
+	if producer != nil {
+		producer.Close()
 	}
 
 	// Close client
-	if k.client != nil {
-		k.client.Close()
-		k.client = nil
+	if client != nil {
+		client.Close()
 	}
 
-	k.connected = false
-	return nil
+	return flushErr
+}
+
+// Flush waits until every message queued by PublishAsync has been acknowledged, returning
+// the delivery errors reported since the previous flush
+func (k *kafkaBroker) Flush(ctx context.Context) error {
+	return k.asyncPending.wait(ctx)
+}
+
+// drainAsync consumes the async producer's result channels until it is closed
+func (k *kafkaBroker) drainAsync(producer sarama.AsyncProducer) {
+	successes, errs := producer.Successes(), producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			k.counters.recordPublished(msg.Topic)
+			k.asyncPending.done(nil)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			k.config.publishFailed(err.Msg.Topic, err.Err)
+			k.asyncPending.done(fmt.Errorf("topic %s: %w", err.Msg.Topic, err.Err))
+		}
+	}
 }
 
 func (k *kafkaBroker) getBrokers() []string {
@@ -184,6 +336,10 @@ func (k *kafkaBroker) getBrokers() []string {
 
 // Publish sends a message to the specified topic
 func (k *kafkaBroker) Publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(k.config.publishInterceptors(), k.config.observingPublish(k.publish))(ctx, topic, message, options)
+}
+
+func (k *kafkaBroker) publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
 	k.mutex.RLock()
 	defer k.mutex.RUnlock()
 
@@ -191,68 +347,154 @@ func (k *kafkaBroker) Publish(ctx context.Context, topic string, message []byte,
 		return errBrokerNotConnected
 	}
 
-	// Create producer message
-	msg := &sarama.ProducerMessage{
-		Topic:     topic,
-		Value:     sarama.ByteEncoder(message),
-		Timestamp: time.Now(),
+	var headers map[string]string
+	if options != nil {
+		headers = options.Headers
+	}
+
+	// Send message synchronously
+	msg := newProducerMessage(topic, message, headers)
+	partition, offset, err := k.producer.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("failed to send message to Kafka: %w", err)
 	}
 
+	k.counters.recordPublished(topic)
+	recordPublishResult(ctx, PublishResult{Partition: partition, Offset: offset, Timestamp: msg.Timestamp, Supported: true})
+	return nil
+}
+
+// PublishWithResult publishes like Publish and returns the partition and offset the
+// message was written to
+func (k *kafkaBroker) PublishWithResult(ctx context.Context, topic string, message []byte, options *PublishOptions) (*PublishResult, error) {
+	result := &PublishResult{}
+	if err := k.Publish(withPublishResult(ctx, result), topic, message, options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PublishAsync queues the message on the async producer without waiting for Kafka to
+// acknowledge it. Failures go to BrokerConfig.OnPublishError and are also returned by the
+// next Flush.
+func (k *kafkaBroker) PublishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(k.config.publishInterceptors(), k.config.observingPublish(k.publishAsync))(ctx, topic, message, options)
+}
+
+func (k *kafkaBroker) publishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if !k.connected {
+		return errBrokerNotConnected
+	}
+
+	var headers map[string]string
 	if options != nil {
-		// Add headers
-		if options.Headers != nil {
-			headers := make([]sarama.RecordHeader, 0, len(options.Headers))
-			for k, v := range options.Headers {
-				headers = append(headers, sarama.RecordHeader{
-					Key:   []byte(k),
-					Value: []byte(v),
-				})
-			}
-			msg.Headers = headers
-		}
+		headers = options.Headers
+	}
+	msg := newProducerMessage(topic, message, headers)
 
-		// Set key for partitioning if provided in headers
-		if key, exists := options.Headers["kafka.key"]; exists {
-			msg.Key = sarama.StringEncoder(key)
+	if k.asyncProducer == nil {
+		if _, _, err := k.producer.SendMessage(msg); err != nil {
+			return fmt.Errorf("failed to send message to Kafka: %w", err)
 		}
+		k.counters.recordPublished(topic)
+		return nil
+	}
 
-		// Set partition if provided in headers
-		if partitionStr, exists := options.Headers["kafka.partition"]; exists {
-			if partition, err := strconv.Atoi(partitionStr); err == nil {
-				msg.Partition = int32(partition)
-			}
+	// The input channel only blocks when the producer's buffer is full
+	k.asyncPending.add(1)
+	select {
+	case k.asyncProducer.Input() <- msg:
+		return nil
+	case <-ctx.Done():
+		k.asyncPending.done(nil)
+		return ctx.Err()
+	}
+}
+
+// newProducerMessage builds a Sarama message, honoring the kafka.key and kafka.partition headers
+func newProducerMessage(topic string, data []byte, headers map[string]string) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Value:     sarama.ByteEncoder(data),
+		Timestamp: time.Now(),
+	}
+
+	if len(headers) > 0 {
+		recordHeaders := make([]sarama.RecordHeader, 0, len(headers))
+		for k, v := range headers {
+			recordHeaders = append(recordHeaders, sarama.RecordHeader{
+				Key:   []byte(k),
+				Value: []byte(v),
+			})
 		}
+		msg.Headers = recordHeaders
 	}
 
-	// Send message synchronously
-	_, _, err := k.producer.SendMessage(msg)
-	if err != nil {
-		return fmt.Errorf("failed to send message to Kafka: %w", err)
+	// Set key for partitioning if provided in headers
+	if key, exists := headers[HeaderKafkaKey]; exists {
+		msg.Key = sarama.StringEncoder(key)
 	}
 
-	return nil
+	// Set partition if provided in headers
+	if partitionStr, exists := headers[HeaderKafkaPartition]; exists {
+		if partition, err := strconv.Atoi(partitionStr); err == nil {
+			msg.Partition = int32(partition)
+		}
+	}
+
+	return msg
 }
 
-// PublishJSON sends a JSON-encoded message to the specified topic
+// PublishJSON sends a message encoded by the configured Serializer, JSON by default, to
+// the specified topic
 func (k *kafkaBroker) PublishJSON(ctx context.Context, topic string, message interface{}, options *PublishOptions) error {
-	data, err := json.Marshal(message)
+	data, options, err := serializeForPublish(k.config, message, options)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	if options == nil {
-		options = &PublishOptions{}
-	}
-	if options.Headers == nil {
-		options.Headers = make(map[string]string)
+		return err
 	}
-	options.Headers["Content-Type"] = "application/json"
 
 	return k.Publish(ctx, topic, data, options)
 }
 
 // Subscribe subscribes to messages from the specified topic
 func (k *kafkaBroker) Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error {
+	return k.subscribe(ctx, topic, nil, handler, options, nil)
+}
+
+// SubscribeMany consumes all topics with one consumer group, so they share a single
+// group membership and consume loop. The returned handle identifies the subscription.
+func (k *kafkaBroker) SubscribeMany(ctx context.Context, topics []string, handler MessageHandler, options *SubscribeOptions) (string, error) {
+	if err := validateTopics(topics); err != nil {
+		return "", err
+	}
+
+	handle := SubscriptionHandle(topics)
+	if err := k.subscribe(ctx, handle, append([]string(nil), topics...), handler, options, nil); err != nil {
+		return "", err
+	}
+	return handle, nil
+}
+
+// SubscribeWithErrors subscribes like Subscribe and also returns the consumer errors that
+// are otherwise only logged, such as failed joins or authentication failures. The channel
+// is closed once the consume loop has exited after ctx is canceled or the topic is
+// unsubscribed. Errors are dropped while the channel's buffer is full.
+func (k *kafkaBroker) SubscribeWithErrors(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) (<-chan error, error) {
+	errs := make(chan error, consumerErrorBuffer)
+	if err := k.subscribe(ctx, topic, nil, handler, options, errs); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// subscribe stores the subscription under topic and consumes topics, or just topic when
+// topics is empty
+func (k *kafkaBroker) subscribe(ctx context.Context, topic string, topics []string, handler MessageHandler, options *SubscribeOptions, errs chan error) error {
+	handler = k.config.extractingTraceContext(k.config.deduplicating(options, k.config.observingConsume(timingOut(options, handler))))
+
 	k.mutex.Lock()
 	defer k.mutex.Unlock()
 
@@ -269,6 +511,11 @@ func (k *kafkaBroker) Subscribe(ctx context.Context, topic string, handler Messa
 		}
 	}
 
+	initialOffset, err := kafkaInitialOffset(options)
+	if err != nil {
+		return err
+	}
+
 	// Create consumer group ID
 	groupID := k.config.KafkaConsumerGroup
 	if groupID == "" {
@@ -280,37 +527,24 @@ func (k *kafkaBroker) Subscribe(ctx context.Context, topic string, handler Messa
 
 	// Create consumer group configuration
 	saramaConfig := sarama.NewConfig()
-	saramaConfig.Version = sarama.V2_8_0_0
+	saramaConfig.Version = kafkaVersion(k.config)
 	saramaConfig.Consumer.Return.Errors = true
 	saramaConfig.Consumer.Group.Session.Timeout = 10 * time.Second
 	saramaConfig.Consumer.Group.Heartbeat.Interval = 3 * time.Second
-	saramaConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
-
-	// Authentication (reuse from main config)
-	if k.config.Username != "" && k.config.Password != "" {
-		saramaConfig.Net.SASL.Enable = true
-		saramaConfig.Net.SASL.User = k.config.Username
-		saramaConfig.Net.SASL.Password = k.config.Password
-
-		switch k.config.KafkaSASLMechanism {
-		case "SCRAM-SHA-256":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
-		case "SCRAM-SHA-512":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
-		case "PLAIN":
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-		default:
-			saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-		}
-	}
+	saramaConfig.Consumer.Offsets.Initial = initialOffset
 
-	if k.config.TLSEnabled {
-		saramaConfig.Net.TLS.Enable = true
+	// Authentication and TLS (reuse from main config)
+	if err := configureKafkaSecurity(saramaConfig, k.config); err != nil {
+		return err
 	}
 
 	// Create consumer group
 	brokers := k.getBrokers()
-	consumerGroup, err := sarama.NewConsumerGroup(brokers, groupID, saramaConfig)
+	newConsumerGroup := k.newConsumerGroup
+	if newConsumerGroup == nil {
+		newConsumerGroup = sarama.NewConsumerGroup
+	}
+	consumerGroup, err := newConsumerGroup(brokers, groupID, saramaConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create Kafka consumer group: %w", err)
 	}
@@ -323,6 +557,7 @@ func (k *kafkaBroker) Subscribe(ctx context.Context, topic string, handler Messa
 		options:       options,
 		cancel:        cancel,
 		topic:         topic,
+		topics:        topics,
 		groupID:       groupID,
 	}
 
@@ -334,46 +569,221 @@ func (k *kafkaBroker) Subscribe(ctx context.Context, topic string, handler Messa
 		broker:       k,
 	}
 
-	// Start consuming in a goroutine
-	go func() {
-		defer func() {
-			consumerGroup.Close()
-		}()
-
-		for {
-			select {
-			case <-subCtx.Done():
-				return
-			default:
-				// Consume should be called inside an infinite loop
-				if err := consumerGroup.Consume(subCtx, []string{topic}, cgHandler); err != nil {
-					if errors.Is(err, sarama.ErrClosedConsumerGroup) {
-						return
-					}
-					fmt.Printf("Error from consumer group: %v\n", err)
-					time.Sleep(time.Second)
-				}
-			}
-		}
-	}()
+	go k.consume(subCtx, subscription, cgHandler, errs)
+
+	return nil
+}
 
-	// Handle consumer group errors
+// consume runs the consumer group until ctx is canceled or the group is closed. Errors from
+// Consume and from the group's error channel are logged and, when errs is set, forwarded
+// to it; errs is closed after the loop has exited. Once the reconnect attempts are used up
+// the subscription is removed, so the topic can be subscribed again.
+func (k *kafkaBroker) consume(ctx context.Context, subscription *kafkaSubscription, handler sarama.ConsumerGroupHandler, errs chan error) {
+	consumerGroup := subscription.consumerGroup
+
+	var forwarding sync.WaitGroup
+	forwarding.Add(1)
 	go func() {
+		defer forwarding.Done()
 		for err := range consumerGroup.Errors() {
-			fmt.Printf("Consumer group error: %v\n", err)
+			k.reportConsumerError(subscription.topic, err, errs)
 		}
 	}()
 
+	attempts, wait := k.config.reconnectLimits()
+	for ctx.Err() == nil {
+		// Consume returns at every rebalance, so it is called in a loop
+		err := consumerGroup.Consume(ctx, subscription.subscribedTopics(), handler)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			break
+		}
+		k.reportConsumerError(subscription.topic, err, errs)
+
+		// Every further Consume is a reconnect attempt; Setup reports the one that succeeds
+		attempt := int(subscription.reconnects.Add(1))
+		if attempt > 1 {
+			k.config.reportReconnect(TypeKafka, attempt-1, err)
+		}
+		if attempts >= 0 && attempt > attempts {
+			k.config.logError(err, logrus.Fields{"topic": subscription.topic, "attempts": attempts}, "Giving up reconnecting Kafka consumer group")
+			k.reportConsumerError(subscription.topic, fmt.Errorf("gave up reconnecting Kafka consumer group after %d attempts: %w", attempts, err), errs)
+			k.removeSubscription(subscription)
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+
+	// Closing the group ends its error channel, so the forwarder finishes before errs closes
+	consumerGroup.Close()
+	forwarding.Wait()
+	if errs != nil {
+		close(errs)
+	}
+
+	notifySubscription(subscription.options.OnStop, subscription.event())
+}
+
+// removeSubscription forgets subscription unless it has already been replaced
+func (k *kafkaBroker) removeSubscription(subscription *kafkaSubscription) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.subscribers[subscription.topic] == subscription {
+		delete(k.subscribers, subscription.topic)
+	}
+	if subscription.cancel != nil {
+		subscription.cancel()
+	}
+}
+
+func (k *kafkaBroker) reportConsumerError(topic string, err error, errs chan error) {
+	k.config.logError(err, logrus.Fields{"topic": topic}, "Kafka consumer group error")
+	if errs == nil {
+		return
+	}
+
+	select {
+	case errs <- err:
+	default:
+		k.config.logSampled(logrus.WarnLevel, nil, logrus.Fields{"topic": topic}, "Dropping Kafka consumer error, error channel is full")
+	}
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim. The first session
+// reports OnStart and every later one, which follows a rebalance, OnRebalance.
+func (h *kafkaConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	subscription := h.subscription
+	if subscription.options.StartFromTime != nil {
+		h.broker.mutex.RLock()
+		client := h.broker.client
+		h.broker.mutex.RUnlock()
+		if client == nil {
+			return errBrokerNotConnected
+		}
+
+		if err := subscription.applyStartFromTime(session, client); err != nil {
+			return err
+		}
+	}
+
+	if attempt := subscription.reconnects.Swap(0); attempt > 0 {
+		h.broker.config.reportReconnect(TypeKafka, int(attempt), nil)
+	}
+
+	event := subscription.event()
+	for _, topic := range subscription.subscribedTopics() {
+		event.Partitions = append(event.Partitions, session.Claims()[topic]...)
+	}
+	if onChange := subscription.options.OnPartitionsChanged; onChange != nil {
+		onChange(subscription.claimed(session), nil)
+	}
+	if subscription.started.CompareAndSwap(false, true) {
+		notifySubscription(subscription.options.OnStart, event)
+	} else {
+		notifySubscription(subscription.options.OnRebalance, event)
+	}
 	return nil
 }
 
-// Setup is run at the beginning of a new session, before ConsumeClaim
-func (h *kafkaConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+// claimed returns the partitions of the subscription's topics claimed in session
+func (s *kafkaSubscription) claimed(session sarama.ConsumerGroupSession) []TopicPartition {
+	var partitions []TopicPartition
+	for _, topic := range s.subscribedTopics() {
+		for _, partition := range session.Claims()[topic] {
+			partitions = append(partitions, TopicPartition{Topic: topic, Partition: partition})
+		}
+	}
+	return partitions
+}
+
+func (s *kafkaSubscription) event() SubscriptionEvent {
+	return SubscriptionEvent{Topic: s.topic, Group: s.groupID}
+}
+
+// subscribedTopics returns the Kafka topics the subscription consumes
+func (s *kafkaSubscription) subscribedTopics() []string {
+	if len(s.topics) > 0 {
+		return s.topics
+	}
+	return []string{s.topic}
+}
+
+// topicPartition identifies a partition across the topics of a subscription
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// offsetResolver is the part of sarama.Client used to look up offsets by timestamp
+type offsetResolver interface {
+	GetOffset(topic string, partitionID int32, time int64) (int64, error)
+}
+
+// applyStartFromTime resets newly claimed partitions to StartFromTime. Partitions are only
+// reset the first time they are claimed, so a rebalance does not rewind them again.
+func (s *kafkaSubscription) applyStartFromTime(session sarama.ConsumerGroupSession, resolver offsetResolver) error {
+	s.startMutex.Lock()
+	defer s.startMutex.Unlock()
+
+	if s.startApplied == nil {
+		s.startApplied = make(map[topicPartition]bool)
+	}
+
+	for _, topic := range s.subscribedTopics() {
+		var partitions []int32
+		for _, partition := range session.Claims()[topic] {
+			if !s.startApplied[topicPartition{topic, partition}] {
+				partitions = append(partitions, partition)
+			}
+		}
+
+		offsets, err := resolveStartOffsets(resolver, topic, partitions, *s.options.StartFromTime)
+		if err != nil {
+			return err
+		}
+
+		for partition, offset := range offsets {
+			session.ResetOffset(topic, partition, offset, "")
+			s.startApplied[topicPartition{topic, partition}] = true
+		}
+	}
 	return nil
 }
 
+// resolveStartOffsets finds, per partition, the offset of the first message at or after
+// start. Partitions with no such message start at the log end.
+func resolveStartOffsets(resolver offsetResolver, topic string, partitions []int32, start time.Time) (map[int32]int64, error) {
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := resolver.GetOffset(topic, partition, start.UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve offset for %s/%d at %s: %w", topic, partition, start.Format(time.RFC3339), err)
+		}
+
+		if offset == sarama.OffsetNewest {
+			offset, err = resolver.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve newest offset for %s/%d: %w", topic, partition, err)
+			}
+		}
+
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
-func (h *kafkaConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+func (h *kafkaConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	if onChange := h.subscription.options.OnPartitionsChanged; onChange != nil {
+		onChange(nil, h.subscription.claimed(session))
+	}
 	return nil
 }
 
@@ -387,14 +797,32 @@ func (h *kafkaConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSes
 			if msg == nil {
 				return nil
 			}
-			h.handleKafkaMessage(session, msg)
+			// Hold already-fetched messages while paused; the partitions themselves are paused too
+			if err := h.subscription.gate.Wait(session.Context()); err != nil {
+				return nil
+			}
+			// Disconnecting; skip without marking, as returning here would end the session
+			// and cancel the handlers still working on other partitions
+			if !h.subscription.handling.begin() {
+				continue
+			}
+			// Returning ends the session without marking msg, so the next session starts
+			// from the last committed offset and delivers it again
+			err := h.handleKafkaMessage(session, msg)
+			h.subscription.handling.end()
+			if err != nil {
+				return err
+			}
 		case <-session.Context().Done():
 			return nil
 		}
 	}
 }
 
-func (h *kafkaConsumerGroupHandler) handleKafkaMessage(session sarama.ConsumerGroupSession, kafkaMsg *sarama.ConsumerMessage) {
+// handleKafkaMessage runs the handler and marks the message consumed. A message that
+// failed is first republished to DeadLetterTopic, if set; when that fails the message is
+// left unmarked and the error returned.
+func (h *kafkaConsumerGroupHandler) handleKafkaMessage(session sarama.ConsumerGroupSession, kafkaMsg *sarama.ConsumerMessage) error {
 	message := &Message{
 		ID:              fmt.Sprintf("%s-%d-%d", kafkaMsg.Topic, kafkaMsg.Partition, kafkaMsg.Offset),
 		Topic:           kafkaMsg.Topic,
@@ -402,6 +830,7 @@ func (h *kafkaConsumerGroupHandler) handleKafkaMessage(session sarama.ConsumerGr
 		Headers:         make(map[string]string),
 		Timestamp:       kafkaMsg.Timestamp,
 		OriginalMessage: kafkaMsg,
+		acknowledger:    kafkaAcknowledger{session: session, msg: kafkaMsg},
 	}
 
 	// Convert headers
@@ -410,34 +839,126 @@ func (h *kafkaConsumerGroupHandler) handleKafkaMessage(session sarama.ConsumerGr
 	}
 
 	// Add Kafka-specific metadata
-	message.Headers["kafka.partition"] = strconv.Itoa(int(kafkaMsg.Partition))
+	message.Headers[HeaderKafkaPartition] = strconv.Itoa(int(kafkaMsg.Partition))
 	message.Headers["kafka.offset"] = strconv.FormatInt(kafkaMsg.Offset, 10)
 	if kafkaMsg.Key != nil {
-		message.Headers["kafka.key"] = string(kafkaMsg.Key)
+		message.Headers[HeaderKafkaKey] = string(kafkaMsg.Key)
 	}
 
 	// Process message with retries
-	var lastErr error
-	for retry := 0; retry <= h.subscription.options.MaxRetries; retry++ {
-		message.Retry = retry
-		message.MaxRetries = h.subscription.options.MaxRetries
+	err := runWithRetries(session.Context(), h.subscription.handler, message, h.subscription.options)
+	if err == nil {
+		h.broker.counters.recordConsumed(kafkaMsg.Topic)
+		h.mark(session, kafkaMsg)
+		return nil
+	}
 
-		err := h.subscription.handler(session.Context(), message)
-		if err == nil {
-			// Success - mark message
-			session.MarkMessage(kafkaMsg, "")
-			return
+	// The session ended mid-retry; leave the message unmarked for the next session
+	if abandoned(session.Context(), err) {
+		return nil
+	}
+
+	// Failed permanently or after all retries; without a dead-letter topic it is still
+	// marked to avoid reprocessing
+	h.broker.config.messageFailed(session.Context(), h.subscription.options, message, err, logrus.Fields{"topic": kafkaMsg.Topic, "retries": message.Retry}, "Failed to process Kafka message")
+
+	if deadLetterTopic := h.subscription.options.DeadLetterTopic; deadLetterTopic != "" {
+		if dlqErr := h.broker.deadLetter(kafkaMsg, deadLetterTopic, err, message.Retry); dlqErr != nil {
+			h.broker.config.logError(dlqErr, logrus.Fields{"topic": kafkaMsg.Topic, "dead_letter_topic": deadLetterTopic}, "Failed to dead-letter Kafka message")
+			return fmt.Errorf("failed to dead-letter message at offset %d: %w", kafkaMsg.Offset, dlqErr)
 		}
+	}
+
+	h.mark(session, kafkaMsg)
+	return nil
+}
+
+// mark commits past kafkaMsg unless the handler settles messages itself
+func (h *kafkaConsumerGroupHandler) mark(session sarama.ConsumerGroupSession, kafkaMsg *sarama.ConsumerMessage) {
+	if !h.subscription.options.ManualAck {
+		session.MarkMessage(kafkaMsg, "")
+	}
+}
 
-		lastErr = err
-		if retry < h.subscription.options.MaxRetries {
-			time.Sleep(h.subscription.options.RetryDelay)
+// deadLetter republishes a failed message to topic with its key and headers, adding the
+// handler error, the retry count and where the message came from
+func (k *kafkaBroker) deadLetter(kafkaMsg *sarama.ConsumerMessage, topic string, cause error, retries int) error {
+	k.mutex.RLock()
+	producer := k.producer
+	k.mutex.RUnlock()
+	if producer == nil {
+		return errBrokerNotConnected
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(kafkaMsg.Headers)+5)
+	for _, header := range kafkaMsg.Headers {
+		// A pinned partition refers to the original topic, which may have more partitions
+		if header != nil && string(header.Key) != HeaderKafkaPartition {
+			headers = append(headers, *header)
 		}
 	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterError), Value: []byte(cause.Error())},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterRetries), Value: []byte(strconv.Itoa(retries))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterTopic), Value: []byte(kafkaMsg.Topic)},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterPartition), Value: []byte(strconv.Itoa(int(kafkaMsg.Partition)))},
+		sarama.RecordHeader{Key: []byte(HeaderDeadLetterOffset), Value: []byte(strconv.FormatInt(kafkaMsg.Offset, 10))},
+	)
+
+	producerMsg := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(kafkaMsg.Value),
+		Headers: headers,
+	}
+	if kafkaMsg.Key != nil {
+		producerMsg.Key = sarama.ByteEncoder(kafkaMsg.Key)
+	}
+
+	_, _, err := producer.SendMessage(producerMsg)
+	return err
+}
+
+// PauseSubscription pauses fetching from the topic's partitions without leaving the
+// consumer group, so no rebalance is triggered
+func (k *kafkaBroker) PauseSubscription(topic string) error {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	subscription, exists := k.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
 
-	// Failed after all retries - still mark to avoid reprocessing
-	fmt.Printf("Failed to process Kafka message after %d retries: %v\n", h.subscription.options.MaxRetries, lastErr)
-	session.MarkMessage(kafkaMsg, "")
+	if subscription.gate.Pause() {
+		subscription.consumerGroup.PauseAll()
+	}
+	return nil
+}
+
+// ResumeSubscription resumes fetching from a paused topic's partitions
+func (k *kafkaBroker) ResumeSubscription(topic string) error {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	subscription, exists := k.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	if subscription.gate.Resume() {
+		subscription.consumerGroup.ResumeAll()
+	}
+	return nil
+}
+
+// Pause stops invoking the topic's handler until Resume; see PauseSubscription
+func (k *kafkaBroker) Pause(ctx context.Context, topic string) error {
+	return changePause(ctx, k.PauseSubscription, topic)
+}
+
+// Resume restarts delivery to a paused topic; see ResumeSubscription
+func (k *kafkaBroker) Resume(ctx context.Context, topic string) error {
+	return changePause(ctx, k.ResumeSubscription, topic)
 }
 
 // Unsubscribe unsubscribes from the specified topic
@@ -580,53 +1101,14 @@ func (k *kafkaBroker) Ping(ctx context.Context) error {
 	return nil
 }
 
-// PublishBatch publishes multiple messages in a batch
+// PublishBatch publishes multiple messages in a batch. Each message is sent through the
+// publish interceptors like Publish, and the first failure stops the batch.
 func (k *kafkaBroker) PublishBatch(ctx context.Context, messages []BatchMessage, options *PublishOptions) error {
-	k.mutex.RLock()
-	defer k.mutex.RUnlock()
-
-	if !k.connected {
-		return errBrokerNotConnected
-	}
-
-	// Convert batch messages to Sarama producer messages
-	saramaMessages := make([]*sarama.ProducerMessage, 0, len(messages))
-
 	for _, msg := range messages {
-		saramaMsg := &sarama.ProducerMessage{
-			Topic:     msg.Topic,
-			Value:     sarama.ByteEncoder(msg.Data),
-			Timestamp: time.Now(),
-		}
-
-		// Add headers
-		if len(msg.Headers) > 0 {
-			headers := make([]sarama.RecordHeader, 0, len(msg.Headers))
-			for k, v := range msg.Headers {
-				headers = append(headers, sarama.RecordHeader{
-					Key:   []byte(k),
-					Value: []byte(v),
-				})
-			}
-			saramaMsg.Headers = headers
-		}
-
-		// Set key for partitioning if provided in headers
-		if key, exists := msg.Headers["kafka.key"]; exists {
-			saramaMsg.Key = sarama.StringEncoder(key)
-		}
-
-		saramaMessages = append(saramaMessages, saramaMsg)
-	}
-
-	// Send all messages using sync producer
-	for _, msg := range saramaMessages {
-		_, _, err := k.producer.SendMessage(msg)
-		if err != nil {
+		if err := k.Publish(ctx, msg.Topic, msg.Data, batchMessageOptions(options, msg.Headers)); err != nil {
 			return fmt.Errorf("failed to publish batch message to topic %s: %w", msg.Topic, err)
 		}
 	}
-
 	return nil
 }
 
@@ -650,6 +1132,7 @@ func (k *kafkaBroker) GetStats(ctx context.Context) (*BrokerStats, error) {
 		})
 	}
 	stats.Topics = topics
+	k.counters.apply(stats)
 
 	return stats, nil
 }
@@ -0,0 +1,122 @@
+package messagebroker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// Kafka security protocols accepted in BrokerConfig.KafkaSecurityProtocol
+const (
+	KafkaProtocolPlaintext     = "PLAINTEXT"
+	KafkaProtocolSSL           = "SSL"
+	KafkaProtocolSASLPlaintext = "SASL_PLAINTEXT"
+	KafkaProtocolSASLSSL       = "SASL_SSL"
+)
+
+// Kafka SASL mechanisms accepted in BrokerConfig.KafkaSASLMechanism
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLSCRAMSHA256 = "SCRAM-SHA-256"
+	KafkaSASLSCRAMSHA512 = "SCRAM-SHA-512"
+	KafkaSASLOAuthBearer = "OAUTHBEARER"
+)
+
+// kafkaSecurity reports whether config calls for TLS and for SASL. KafkaSecurityProtocol
+// decides both when set; otherwise TLS follows TLSEnabled and SASL is used when a username
+// and password are configured or the mechanism is OAUTHBEARER.
+func kafkaSecurity(config *BrokerConfig) (useTLS, useSASL bool, err error) {
+	switch strings.ToUpper(config.KafkaSecurityProtocol) {
+	case "":
+		oauth := strings.EqualFold(config.KafkaSASLMechanism, KafkaSASLOAuthBearer)
+		return config.TLSEnabled, oauth || (config.Username != "" && config.Password != ""), nil
+	case KafkaProtocolPlaintext:
+		return false, false, nil
+	case KafkaProtocolSSL:
+		return true, false, nil
+	case KafkaProtocolSASLPlaintext:
+		return false, true, nil
+	case KafkaProtocolSASLSSL:
+		return true, true, nil
+	default:
+		return false, false, fmt.Errorf("unknown Kafka security protocol %q: use %q, %q, %q or %q",
+			config.KafkaSecurityProtocol, KafkaProtocolPlaintext, KafkaProtocolSSL, KafkaProtocolSASLPlaintext, KafkaProtocolSASLSSL)
+	}
+}
+
+// configureKafkaSecurity applies the TLS and SASL settings from config
+func configureKafkaSecurity(saramaConfig *sarama.Config, config *BrokerConfig) error {
+	useTLS, useSASL, err := kafkaSecurity(config)
+	if err != nil {
+		return err
+	}
+
+	if useTLS {
+		tlsConfig, err := config.tlsConfig()
+		if err != nil {
+			return fmt.Errorf("invalid Kafka TLS configuration: %w", err)
+		}
+		saramaConfig.Net.TLS.Enable = true
+		saramaConfig.Net.TLS.Config = tlsConfig
+	}
+
+	if useSASL {
+		return configureKafkaSASL(saramaConfig, config)
+	}
+	return nil
+}
+
+// configureKafkaSASL selects the SASL mechanism. OAUTHBEARER takes its tokens from
+// KafkaTokenProvider, or else sends Token as is; the others authenticate with the
+// username and password, PLAIN being the default.
+func configureKafkaSASL(saramaConfig *sarama.Config, config *BrokerConfig) error {
+	saramaConfig.Net.SASL.Enable = true
+
+	mechanism := strings.ToUpper(config.KafkaSASLMechanism)
+	if mechanism == KafkaSASLOAuthBearer {
+		provider := config.KafkaTokenProvider
+		if provider == nil && config.Token != "" {
+			provider = staticTokenProvider(config.Token)
+		}
+		if provider == nil {
+			return errors.New("Kafka SASL mechanism OAUTHBEARER needs a token provider or a token")
+		}
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		saramaConfig.Net.SASL.TokenProvider = provider
+		return nil
+	}
+
+	if config.Username == "" || config.Password == "" {
+		return fmt.Errorf("Kafka SASL mechanism %s needs a username and password", saslMechanismName(mechanism))
+	}
+	saramaConfig.Net.SASL.User = config.Username
+	saramaConfig.Net.SASL.Password = config.Password
+
+	switch mechanism {
+	case KafkaSASLSCRAMSHA256:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+	case KafkaSASLSCRAMSHA512:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+	default:
+		saramaConfig.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	}
+	return nil
+}
+
+// saslMechanismName names the mechanism in errors, an empty one meaning PLAIN
+func saslMechanismName(mechanism string) string {
+	if mechanism == "" {
+		return KafkaSASLPlain
+	}
+	return mechanism
+}
+
+// staticTokenProvider hands out one fixed OAUTHBEARER token
+type staticTokenProvider string
+
+// Token returns the fixed token
+func (p staticTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: string(p)}, nil
+}
@@ -0,0 +1,89 @@
+package messagebroker
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedTokenProvider struct{ err error }
+
+func (p fixedTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: "signed"}, p.err
+}
+
+func TestConfigureKafkaSecurityMechanisms(t *testing.T) {
+	provider := fixedTokenProvider{}
+
+	tests := []struct {
+		name      string
+		config    *BrokerConfig
+		sasl      bool
+		mechanism sarama.SASLMechanism
+		tls       bool
+	}{
+		{"no credentials", &BrokerConfig{}, false, "", false},
+		{"credentials default to plain", &BrokerConfig{Username: "u", Password: "p"}, true, sarama.SASLTypePlaintext, false},
+		{"scram", &BrokerConfig{Username: "u", Password: "p", KafkaSASLMechanism: KafkaSASLSCRAMSHA512}, true, sarama.SASLTypeSCRAMSHA512, false},
+		{"oauthbearer provider", &BrokerConfig{KafkaSASLMechanism: KafkaSASLOAuthBearer, KafkaTokenProvider: provider}, true, sarama.SASLTypeOAuth, false},
+		{"oauthbearer token", &BrokerConfig{KafkaSASLMechanism: "oauthbearer", Token: "static"}, true, sarama.SASLTypeOAuth, false},
+		{"plaintext protocol ignores credentials", &BrokerConfig{Username: "u", Password: "p", KafkaSecurityProtocol: KafkaProtocolPlaintext}, false, "", false},
+		{"ssl protocol", &BrokerConfig{KafkaSecurityProtocol: KafkaProtocolSSL}, false, "", true},
+		{"sasl_ssl protocol", &BrokerConfig{Username: "u", Password: "p", KafkaSecurityProtocol: "sasl_ssl"}, true, sarama.SASLTypePlaintext, true},
+		{"sasl_plaintext protocol overrides TLSEnabled", &BrokerConfig{Username: "u", Password: "p", TLSEnabled: true, KafkaSecurityProtocol: KafkaProtocolSASLPlaintext}, true, sarama.SASLTypePlaintext, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saramaConfig := sarama.NewConfig()
+			require.NoError(t, configureKafkaSecurity(saramaConfig, tt.config))
+
+			assert.Equal(t, tt.sasl, saramaConfig.Net.SASL.Enable)
+			assert.Equal(t, tt.mechanism, saramaConfig.Net.SASL.Mechanism)
+			assert.Equal(t, tt.tls, saramaConfig.Net.TLS.Enable)
+		})
+	}
+}
+
+func TestConfigureKafkaSecurityUsesTokenProvider(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+	require.NoError(t, configureKafkaSecurity(saramaConfig, NewBrokerConfig(
+		WithKafkaSecurityProtocol(KafkaProtocolSASLSSL),
+		WithKafkaOAuthBearer(fixedTokenProvider{}),
+	)))
+
+	token, err := saramaConfig.Net.SASL.TokenProvider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "signed", token.Token)
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	assert.NoError(t, saramaConfig.Validate())
+
+	saramaConfig = sarama.NewConfig()
+	require.NoError(t, configureKafkaSecurity(saramaConfig, &BrokerConfig{KafkaSASLMechanism: KafkaSASLOAuthBearer, Token: "static"}))
+	token, err = saramaConfig.Net.SASL.TokenProvider.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "static", token.Token)
+}
+
+func TestConfigureKafkaSecurityRejectsIncompleteSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *BrokerConfig
+		want   string
+	}{
+		{"unknown protocol", &BrokerConfig{KafkaSecurityProtocol: "TLS"}, "unknown Kafka security protocol"},
+		{"sasl protocol without credentials", &BrokerConfig{KafkaSecurityProtocol: KafkaProtocolSASLPlaintext}, "PLAIN needs a username and password"},
+		{"oauthbearer without token", &BrokerConfig{KafkaSASLMechanism: KafkaSASLOAuthBearer}, "needs a token provider or a token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, configureKafkaSecurity(sarama.NewConfig(), tt.config), tt.want)
+		})
+	}
+
+	_, err := NewKafkaBroker(NewBrokerConfig(WithBrokers("localhost:9092"), WithKafkaSecurityProtocol("TLS")))
+	assert.ErrorContains(t, err, "unknown Kafka security protocol")
+}
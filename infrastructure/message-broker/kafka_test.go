@@ -0,0 +1,1071 @@
+package messagebroker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockKafkaBroker(t *testing.T, config *BrokerConfig) (*kafkaBroker, *mocks.SyncProducer) {
+	producer := mocks.NewSyncProducer(t, nil)
+	t.Cleanup(func() { producer.Close() })
+
+	if config == nil {
+		config = &BrokerConfig{KafkaBrokers: []string{"localhost:9092"}}
+	}
+	return &kafkaBroker{
+		config:      config,
+		producer:    producer,
+		connected:   true,
+		subscribers: make(map[string]*kafkaSubscription),
+	}, producer
+}
+
+func recordHeader(msg *sarama.ProducerMessage, key string) string {
+	for _, header := range msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func TestKafkaPublishBatchRunsInterceptors(t *testing.T) {
+	stamp := func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+			return next(ctx, topic, message, withHeader(options, "X-Stamped", topic))
+		}
+	}
+	broker, producer := newMockKafkaBroker(t, &BrokerConfig{
+		KafkaBrokers:        []string{"localhost:9092"},
+		PublishInterceptors: []PublishInterceptor{stamp},
+	})
+
+	for _, topic := range []string{"orders", "payments"} {
+		topic := topic
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			assert.Equal(t, topic, recordHeader(msg, "X-Stamped"))
+			return nil
+		})
+	}
+
+	err := broker.PublishBatch(context.Background(), []BatchMessage{
+		{Topic: "orders", Data: []byte("1")},
+		{Topic: "payments", Data: []byte("2")},
+	}, nil)
+	require.NoError(t, err)
+}
+
+func TestKafkaPublishBatchInterceptorsSeeSendResult(t *testing.T) {
+	var results []error
+	record := func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+			err := next(ctx, topic, message, options)
+			results = append(results, err)
+			return err
+		}
+	}
+	broker, producer := newMockKafkaBroker(t, &BrokerConfig{
+		KafkaBrokers:        []string{"localhost:9092"},
+		PublishInterceptors: []PublishInterceptor{record},
+	})
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+
+	err := broker.PublishBatch(context.Background(), []BatchMessage{
+		{Topic: "orders", Data: []byte("1")},
+		{Topic: "orders", Data: []byte("2")},
+	}, nil)
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0])
+	assert.ErrorIs(t, results[1], sarama.ErrMessageSizeTooLarge)
+}
+
+type fakeConsumerGroup struct {
+	sarama.ConsumerGroup
+	paused  int
+	resumed int
+}
+
+func (g *fakeConsumerGroup) PauseAll()  { g.paused++ }
+func (g *fakeConsumerGroup) ResumeAll() { g.resumed++ }
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+	claims map[string][]int32
+	resets map[int32]int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32 { return s.claims }
+
+func (s *fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	if s.resets == nil {
+		s.resets = make(map[int32]int64)
+	}
+	s.resets[partition] = offset
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestKafkaPauseSubscriptionHoldsMessages(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := &fakeConsumerGroup{}
+
+	handled := make(chan int64, 10)
+	subscription := &kafkaSubscription{
+		consumerGroup: group,
+		topic:         "orders",
+		options:       &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error {
+			handled <- message.OriginalMessage.(*sarama.ConsumerMessage).Offset
+			return nil
+		},
+	}
+	broker.subscribers["orders"] = subscription
+
+	require.NoError(t, broker.PauseSubscription("orders"))
+	require.NoError(t, broker.PauseSubscription("orders"))
+	assert.Equal(t, 1, group.paused)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	session := &fakeSession{ctx: ctx}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	done := make(chan struct{})
+	go func() {
+		handler.ConsumeClaim(session, claim)
+		close(done)
+	}()
+
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 7}
+	select {
+	case offset := <-handled:
+		t.Fatalf("handler invoked while paused for offset %d", offset)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, broker.ResumeSubscription("orders"))
+	assert.Equal(t, 1, group.resumed)
+
+	select {
+	case offset := <-handled:
+		assert.Equal(t, int64(7), offset)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered after resume")
+	}
+
+	cancel()
+	<-done
+}
+
+// timeIndex maps partition -> timestamp (ms) -> offset, standing in for the broker's time index
+type timeIndex struct {
+	offsets map[int32]map[int64]int64
+	newest  map[int32]int64
+	calls   int
+}
+
+func (r *timeIndex) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	r.calls++
+	if time == sarama.OffsetNewest {
+		return r.newest[partition], nil
+	}
+	if offset, ok := r.offsets[partition][time]; ok {
+		return offset, nil
+	}
+	return sarama.OffsetNewest, nil
+}
+
+func TestResolveStartOffsets(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	resolver := &timeIndex{
+		offsets: map[int32]map[int64]int64{
+			0: {start.UnixMilli(): 120},
+			1: {start.UnixMilli(): 45},
+		},
+		newest: map[int32]int64{2: 900},
+	}
+
+	offsets, err := resolveStartOffsets(resolver, "orders", []int32{0, 1, 2}, start)
+	require.NoError(t, err)
+	assert.Equal(t, map[int32]int64{0: 120, 1: 45, 2: 900}, offsets)
+}
+
+func TestApplyStartFromTimeOnlyOncePerPartition(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	resolver := &timeIndex{offsets: map[int32]map[int64]int64{
+		0: {start.UnixMilli(): 10},
+		1: {start.UnixMilli(): 20},
+	}}
+	subscription := &kafkaSubscription{topic: "orders", options: &SubscribeOptions{StartFromTime: &start}}
+
+	session := &fakeSession{claims: map[string][]int32{"orders": {0}}}
+	require.NoError(t, subscription.applyStartFromTime(session, resolver))
+	assert.Equal(t, map[int32]int64{0: 10}, session.resets)
+
+	// After a rebalance partition 1 is newly claimed; partition 0 keeps its committed progress
+	session = &fakeSession{claims: map[string][]int32{"orders": {0, 1}}}
+	require.NoError(t, subscription.applyStartFromTime(session, resolver))
+	assert.Equal(t, map[int32]int64{1: 20}, session.resets)
+}
+
+// newMockAsyncKafkaBroker wires a mock async producer the way Connect does, so PublishAsync
+// takes the asynchronous path
+func newMockAsyncKafkaBroker(t *testing.T) (*kafkaBroker, *mocks.AsyncProducer) {
+	saramaConfig := mocks.NewTestConfig()
+	saramaConfig.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, saramaConfig)
+
+	broker, _ := newMockKafkaBroker(t, nil)
+	broker.asyncProducer = producer
+	go broker.drainAsync(producer)
+	return broker, producer
+}
+
+func TestKafkaDisconnectDeliversInFlightMessages(t *testing.T) {
+	broker, producer := newMockAsyncKafkaBroker(t)
+
+	var delivered atomic.Int32
+	for i := 0; i < 3; i++ {
+		producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			time.Sleep(50 * time.Millisecond)
+			delivered.Add(1)
+			return nil
+		})
+	}
+
+	for _, data := range []string{"1", "2", "3"} {
+		require.NoError(t, broker.PublishAsync(context.Background(), "orders", []byte(data), nil))
+	}
+	assert.Less(t, delivered.Load(), int32(3), "PublishAsync should not wait for delivery")
+
+	require.NoError(t, broker.Disconnect(context.Background()))
+	assert.Equal(t, int32(3), delivered.Load())
+}
+
+func TestKafkaDisconnectLetsOnPublishErrorPublish(t *testing.T) {
+	broker, producer := newMockAsyncKafkaBroker(t)
+	syncProducer := mocks.NewSyncProducer(t, nil)
+	broker.producer = syncProducer
+	syncProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		assert.Equal(t, "orders.dlq", msg.Topic)
+		return nil
+	})
+
+	var dlqErr error
+	broker.config.OnPublishError = func(topic string, err error) {
+		dlqErr = broker.Publish(context.Background(), topic+".dlq", []byte("dead"), nil)
+	}
+	producer.ExpectInputAndFail(sarama.ErrMessageSizeTooLarge)
+	require.NoError(t, broker.PublishAsync(context.Background(), "orders", []byte("1"), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := broker.Disconnect(ctx)
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded, "Disconnect blocked the OnPublishError publish")
+	assert.NoError(t, dlqErr)
+}
+
+func TestKafkaFlushReportsDeliveryErrors(t *testing.T) {
+	broker, producer := newMockAsyncKafkaBroker(t)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(sarama.ErrMessageSizeTooLarge)
+
+	require.NoError(t, broker.PublishAsync(context.Background(), "orders", []byte("1"), nil))
+	require.NoError(t, broker.PublishAsync(context.Background(), "orders", []byte("2"), nil))
+
+	err := broker.Flush(context.Background())
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	assert.NoError(t, broker.Flush(context.Background()), "errors are reported once")
+}
+
+func TestKafkaPublishWithResultReportsOffset(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndSucceed()
+
+	first, err := PublishWithResult(context.Background(), broker, "orders", []byte("1"), nil)
+	require.NoError(t, err)
+	second, err := broker.PublishWithResult(context.Background(), "orders", []byte("2"), nil)
+	require.NoError(t, err)
+
+	assert.True(t, first.Supported)
+	assert.False(t, first.Timestamp.IsZero())
+	assert.Positive(t, first.Offset)
+	assert.Equal(t, first.Offset+1, second.Offset, "the mock producer numbers messages across partitions")
+}
+
+func TestKafkaPublishWithResultReturnsPublishErrors(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	producer.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+
+	result, err := broker.PublishWithResult(context.Background(), "orders", []byte("1"), nil)
+	assert.ErrorIs(t, err, sarama.ErrNotLeaderForPartition)
+	assert.Nil(t, result)
+}
+
+func TestKafkaPublishAsyncReportsFailuresToCallback(t *testing.T) {
+	broker, producer := newMockAsyncKafkaBroker(t)
+
+	failures := make(chan error, 1)
+	broker.config.OnPublishError = func(topic string, err error) {
+		assert.Equal(t, "telemetry", topic)
+		failures <- err
+	}
+
+	release := make(chan struct{})
+	producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		<-release
+		return nil
+	})
+	producer.ExpectInputAndFail(sarama.ErrMessageSizeTooLarge)
+
+	// Returns while the first message is still waiting on the producer
+	require.NoError(t, broker.PublishAsync(context.Background(), "telemetry", []byte("1"), nil))
+	require.NoError(t, broker.PublishAsync(context.Background(), "telemetry", []byte("2"), nil))
+	close(release)
+
+	select {
+	case err := <-failures:
+		assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	case <-time.After(time.Second):
+		t.Fatal("delivery failure was not reported")
+	}
+	assert.ErrorIs(t, broker.Flush(context.Background()), sarama.ErrMessageSizeTooLarge)
+}
+
+func TestKafkaFlushHonoursContext(t *testing.T) {
+	broker, producer := newMockAsyncKafkaBroker(t)
+
+	release := make(chan struct{})
+	producer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		<-release
+		return nil
+	})
+
+	require.NoError(t, broker.PublishAsync(context.Background(), "orders", []byte("1"), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, broker.Flush(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, broker.Flush(context.Background()))
+	require.NoError(t, broker.Disconnect(context.Background()))
+}
+
+// scriptedConsumerGroup fails Consume with the queued errors, then blocks until canceled
+type scriptedConsumerGroup struct {
+	sarama.ConsumerGroup
+	consumeErrs chan error
+	groupErrs   chan error
+	closeOnce   sync.Once
+	closed      chan struct{}
+}
+
+func newScriptedConsumerGroup() *scriptedConsumerGroup {
+	return &scriptedConsumerGroup{
+		consumeErrs: make(chan error, 4),
+		groupErrs:   make(chan error, 4),
+		closed:      make(chan struct{}),
+	}
+}
+
+func (g *scriptedConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	select {
+	case err := <-g.consumeErrs:
+		return err
+	case <-ctx.Done():
+		return nil
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	}
+}
+
+func (g *scriptedConsumerGroup) Errors() <-chan error { return g.groupErrs }
+
+func (g *scriptedConsumerGroup) Close() error {
+	g.closeOnce.Do(func() {
+		close(g.closed)
+		close(g.groupErrs)
+	})
+	return nil
+}
+
+func TestKafkaSubscribeWithErrorsDeliversConsumerErrors(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := newScriptedConsumerGroup()
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) { return group, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs, err := broker.SubscribeWithErrors(ctx, "orders", func(context.Context, *Message) error { return nil }, nil)
+	require.NoError(t, err)
+
+	group.groupErrs <- sarama.ErrSASLAuthenticationFailed
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, sarama.ErrSASLAuthenticationFailed)
+	case <-time.After(time.Second):
+		t.Fatal("group error was not delivered")
+	}
+
+	group.consumeErrs <- sarama.ErrOutOfBrokers
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	case <-time.After(time.Second):
+		t.Fatal("Consume error was not delivered")
+	}
+}
+
+func TestKafkaSubscribeWithErrorsClosesChannelOnCancel(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := newScriptedConsumerGroup()
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) { return group, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs, err := broker.SubscribeWithErrors(ctx, "orders", func(context.Context, *Message) error { return nil }, nil)
+	require.NoError(t, err)
+
+	// The loop is waiting out its backoff after this error when the context is canceled
+	group.consumeErrs <- sarama.ErrOutOfBrokers
+	assert.ErrorIs(t, <-errs, sarama.ErrOutOfBrokers)
+	cancel()
+
+	select {
+	case _, open := <-errs:
+		assert.False(t, open, "no further errors expected")
+	case <-time.After(time.Second):
+		t.Fatal("consume loop did not exit on cancel")
+	}
+	select {
+	case <-group.closed:
+	default:
+		t.Fatal("consumer group was not closed")
+	}
+}
+
+// rebalancingConsumerGroup starts a session with the next queued assignment on each
+// Consume, which returns when another assignment is queued, as after a rebalance
+type rebalancingConsumerGroup struct {
+	*scriptedConsumerGroup
+	assignments chan []int32
+}
+
+func (g *rebalancingConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	var partitions []int32
+	select {
+	case partitions = <-g.assignments:
+	case <-ctx.Done():
+		return nil
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	}
+
+	session := &fakeSession{ctx: ctx, claims: map[string][]int32{topics[0]: partitions}}
+	if err := handler.Setup(session); err != nil {
+		return err
+	}
+	defer handler.Cleanup(session)
+
+	select {
+	case next := <-g.assignments:
+		// Hand the assignment to the next session
+		g.assignments <- next
+		return nil
+	case <-ctx.Done():
+		return nil
+	case <-g.closed:
+		return sarama.ErrClosedConsumerGroup
+	}
+}
+
+func TestKafkaSubscriptionLifecycle(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := &rebalancingConsumerGroup{scriptedConsumerGroup: newScriptedConsumerGroup(), assignments: make(chan []int32, 1)}
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) { return group, nil }
+
+	events := make(chan string, 4)
+	record := func(kind string) func(SubscriptionEvent) {
+		return func(event SubscriptionEvent) {
+			events <- fmt.Sprintf("%s %s %s %v", kind, event.Topic, event.Group, event.Partitions)
+		}
+	}
+	options := &SubscribeOptions{
+		QueueName:   "billing",
+		Concurrency: 1,
+		OnStart:     record("start"),
+		OnStop:      record("stop"),
+		OnRebalance: record("rebalance"),
+	}
+	next := func() string {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("lifecycle callback was not called")
+			return ""
+		}
+	}
+
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", func(context.Context, *Message) error { return nil }, options))
+
+	group.assignments <- []int32{0, 1}
+	assert.Equal(t, "start orders billing [0 1]", next())
+
+	group.assignments <- []int32{1}
+	assert.Equal(t, "rebalance orders billing [1]", next())
+
+	require.NoError(t, broker.Unsubscribe(context.Background(), "orders"))
+	assert.Equal(t, "stop orders billing []", next())
+}
+
+func TestKafkaPartitionsChangedAroundRebalance(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := &rebalancingConsumerGroup{scriptedConsumerGroup: newScriptedConsumerGroup(), assignments: make(chan []int32, 1)}
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) { return group, nil }
+
+	type change struct{ assigned, revoked []TopicPartition }
+	changes := make(chan change, 4)
+	options := &SubscribeOptions{
+		QueueName:   "billing",
+		Concurrency: 1,
+		OnPartitionsChanged: func(assigned, revoked []TopicPartition) {
+			changes <- change{assigned, revoked}
+		},
+	}
+	next := func() change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("OnPartitionsChanged was not called")
+			return change{}
+		}
+	}
+
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", func(context.Context, *Message) error { return nil }, options))
+
+	group.assignments <- []int32{0, 1}
+	assert.Equal(t, change{assigned: []TopicPartition{{"orders", 0}, {"orders", 1}}}, next())
+
+	group.assignments <- []int32{1}
+	assert.Equal(t, change{revoked: []TopicPartition{{"orders", 0}, {"orders", 1}}}, next())
+	assert.Equal(t, change{assigned: []TopicPartition{{"orders", 1}}}, next())
+
+	require.NoError(t, broker.Unsubscribe(context.Background(), "orders"))
+	assert.Equal(t, change{revoked: []TopicPartition{{"orders", 1}}}, next())
+}
+
+func TestKafkaHandlerPanicKeepsClaimConsuming(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+
+	var attempts atomic.Int32
+	handled := make(chan int64, 1)
+	subscription := &kafkaSubscription{
+		topic:   "orders",
+		options: &SubscribeOptions{MaxRetries: 1, RetryDelay: time.Millisecond},
+		handler: func(ctx context.Context, message *Message) error {
+			offset := message.OriginalMessage.(*sarama.ConsumerMessage).Offset
+			if offset == 1 {
+				attempts.Add(1)
+				panic("malformed order")
+			}
+			handled <- offset
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	session := &fakeSession{ctx: ctx}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	done := make(chan struct{})
+	go func() {
+		handler.ConsumeClaim(session, claim)
+		close(done)
+	}()
+
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 1}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 2}
+
+	select {
+	case offset := <-handled:
+		assert.Equal(t, int64(2), offset)
+	case <-time.After(time.Second):
+		t.Fatal("claim stopped consuming after the panic")
+	}
+	assert.Equal(t, int32(2), attempts.Load())
+
+	cancel()
+	<-done
+	// The panicking message is marked like any other exhausted failure
+	assert.Equal(t, []int64{1, 2}, session.marked)
+}
+
+func TestConfigureKafkaProducerAcks(t *testing.T) {
+	tests := []struct {
+		acks string
+		want sarama.RequiredAcks
+	}{
+		{"", sarama.WaitForAll},
+		{KafkaAcksAll, sarama.WaitForAll},
+		{KafkaAcksLeader, sarama.WaitForLocal},
+		{"NONE", sarama.NoResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acks, func(t *testing.T) {
+			saramaConfig := sarama.NewConfig()
+			require.NoError(t, configureKafkaProducer(saramaConfig, &BrokerConfig{KafkaRequiredAcks: tt.acks}))
+
+			assert.Equal(t, tt.want, saramaConfig.Producer.RequiredAcks)
+			assert.False(t, saramaConfig.Producer.Idempotent)
+			assert.NoError(t, saramaConfig.Validate())
+		})
+	}
+}
+
+func TestConfigureKafkaProducerIdempotence(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_8_0_0
+	require.NoError(t, configureKafkaProducer(saramaConfig, &BrokerConfig{KafkaIdempotentProducer: true}))
+
+	assert.True(t, saramaConfig.Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, saramaConfig.Producer.RequiredAcks)
+	assert.Equal(t, 1, saramaConfig.Net.MaxOpenRequests)
+	assert.NoError(t, saramaConfig.Validate())
+}
+
+func TestNewKafkaBrokerRejectsInvalidProducerSettings(t *testing.T) {
+	_, err := NewKafkaBroker(NewBrokerConfig(
+		WithBrokers("localhost:9092"),
+		WithIdempotentProducer(),
+		WithRequiredAcks(KafkaAcksLeader),
+	))
+	assert.ErrorContains(t, err, "idempotent")
+
+	_, err = NewKafkaBroker(NewBrokerConfig(WithBrokers("localhost:9092"), WithRequiredAcks("most")))
+	assert.ErrorContains(t, err, "unknown Kafka required acks")
+}
+
+func TestKafkaDeadLettersFailedMessageBeforeMarking(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	subscription := &kafkaSubscription{
+		topic:   "payments",
+		options: &SubscribeOptions{DeadLetterTopic: "payments.DLQ"},
+		handler: func(ctx context.Context, message *Message) error {
+			return Permanent(fmt.Errorf("card declined"))
+		},
+	}
+	session := &fakeSession{ctx: context.Background()}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	require.NoError(t, handler.handleKafkaMessage(session, &sarama.ConsumerMessage{
+		Topic:     "payments",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("customer-1"),
+		Value:     []byte(`{"amount":10}`),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("tenant"), Value: []byte("acme")},
+			{Key: []byte(HeaderKafkaPartition), Value: []byte("3")},
+		},
+	}))
+
+	require.NotNil(t, sent)
+	assert.Equal(t, "payments.DLQ", sent.Topic)
+	key, _ := sent.Key.Encode()
+	assert.Equal(t, "customer-1", string(key))
+	value, _ := sent.Value.Encode()
+	assert.Equal(t, `{"amount":10}`, string(value))
+	assert.Equal(t, "acme", recordHeader(sent, "tenant"))
+	assert.Equal(t, "", recordHeader(sent, HeaderKafkaPartition))
+	assert.Equal(t, "permanent: card declined", recordHeader(sent, HeaderDeadLetterError))
+	assert.Equal(t, "0", recordHeader(sent, HeaderDeadLetterRetries))
+	assert.Equal(t, "payments", recordHeader(sent, HeaderDeadLetterTopic))
+	assert.Equal(t, "3", recordHeader(sent, HeaderDeadLetterPartition))
+	assert.Equal(t, "42", recordHeader(sent, HeaderDeadLetterOffset))
+	assert.Equal(t, []int64{42}, session.marked)
+}
+
+func TestKafkaLeavesMessageUnmarkedWhenDeadLetterFails(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	producer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+
+	subscription := &kafkaSubscription{
+		topic:   "payments",
+		options: &SubscribeOptions{DeadLetterTopic: "payments.DLQ"},
+		handler: func(ctx context.Context, message *Message) error {
+			return Permanent(fmt.Errorf("card declined"))
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	session := &fakeSession{ctx: ctx}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: 7}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "payments", Offset: 8}
+
+	// The claim stops at the failed message so no later offset is committed past it
+	err := handler.ConsumeClaim(session, claim)
+	assert.ErrorIs(t, err, sarama.ErrNotEnoughReplicas)
+	assert.Empty(t, session.marked)
+}
+
+func TestKafkaShutdownDuringRetryLeavesMessageUnmarked(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	subscription := &kafkaSubscription{
+		topic:   "payments",
+		options: &SubscribeOptions{MaxRetries: 3, RetryDelay: time.Hour, DeadLetterTopic: "payments.DLQ"},
+		handler: func(ctx context.Context, message *Message) error {
+			return fmt.Errorf("downstream unavailable")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &fakeSession{ctx: ctx}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.handleKafkaMessage(session, &sarama.ConsumerMessage{Topic: "payments", Offset: 5})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("retry delay ignored the canceled session")
+	}
+	// Neither marked nor dead-lettered; the mock producer fails the test on an unexpected send
+	assert.Empty(t, session.marked)
+}
+
+func TestKafkaStatsCountPublishedAndConsumedMessages(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+
+	ctx := context.Background()
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("1"), nil))
+	require.NoError(t, broker.Publish(ctx, "invoices", []byte("2"), nil))
+	require.Error(t, broker.Publish(ctx, "orders", []byte("3"), nil))
+
+	calls := 0
+	subscription := &kafkaSubscription{
+		topic:   "orders",
+		options: &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error {
+			calls++
+			if calls == 2 {
+				return Permanent(assert.AnError)
+			}
+			return nil
+		},
+	}
+	broker.subscribers["orders"] = subscription
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+	session := &fakeSession{ctx: ctx}
+	require.NoError(t, handler.handleKafkaMessage(session, &sarama.ConsumerMessage{Topic: "orders", Offset: 1}))
+	require.NoError(t, handler.handleKafkaMessage(session, &sarama.ConsumerMessage{Topic: "orders", Offset: 2}))
+
+	stats, err := broker.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.MessagesPublished)
+	assert.Equal(t, int64(1), stats.MessagesConsumed, "only successfully handled messages count")
+	assert.Equal(t, []TopicStats{
+		{Name: "invoices", MessagesPublished: 1},
+		{Name: "orders", MessagesPublished: 1, MessagesConsumed: 1, Subscribers: 1},
+	}, stats.Topics)
+}
+
+func TestKafkaManualAckMarksOnlyWhenHandlerAcks(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	subscription := &kafkaSubscription{
+		topic:   "exports",
+		options: &SubscribeOptions{ManualAck: true},
+		handler: func(ctx context.Context, message *Message) error {
+			if string(message.Data) == "ack" {
+				return message.Ack()
+			}
+			return nil
+		},
+	}
+	session := &fakeSession{ctx: context.Background()}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	require.NoError(t, handler.handleKafkaMessage(session, &sarama.ConsumerMessage{Topic: "exports", Offset: 1, Value: []byte("later")}))
+	require.NoError(t, handler.handleKafkaMessage(session, &sarama.ConsumerMessage{Topic: "exports", Offset: 2, Value: []byte("ack")}))
+	assert.Equal(t, []int64{2}, session.marked)
+
+	message := &Message{Topic: "exports", acknowledger: kafkaAcknowledger{session: session, msg: &sarama.ConsumerMessage{Offset: 3}}}
+	assert.True(t, IsNotSupported(message.Nack(true)), "Kafka cannot requeue")
+}
+
+// topicRecordingConsumerGroup reports the topics passed to Consume, then blocks until canceled
+type topicRecordingConsumerGroup struct {
+	*scriptedConsumerGroup
+	topics chan []string
+}
+
+func (g *topicRecordingConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	select {
+	case g.topics <- topics:
+	default:
+	}
+	return g.scriptedConsumerGroup.Consume(ctx, topics, handler)
+}
+
+func TestKafkaSubscribeManyConsumesAllTopicsInOneGroup(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	group := &topicRecordingConsumerGroup{scriptedConsumerGroup: newScriptedConsumerGroup(), topics: make(chan []string, 1)}
+	var groups atomic.Int32
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) {
+		groups.Add(1)
+		return group, nil
+	}
+
+	handle, err := broker.SubscribeMany(context.Background(), []string{"orders", "payments"}, func(context.Context, *Message) error { return nil }, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "orders,payments", handle)
+
+	select {
+	case topics := <-group.topics:
+		assert.Equal(t, []string{"orders", "payments"}, topics)
+	case <-time.After(time.Second):
+		t.Fatal("consumer group was not started")
+	}
+	assert.Equal(t, int32(1), groups.Load())
+
+	require.NoError(t, broker.Unsubscribe(context.Background(), handle))
+	select {
+	case <-group.closed:
+	case <-time.After(time.Second):
+		t.Fatal("consumer group was not closed")
+	}
+}
+
+func TestKafkaVersionFallsBackToDefault(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+
+	for configured, expected := range map[string]sarama.KafkaVersion{
+		"":          defaultKafkaVersion,
+		"3.6.0":     sarama.V3_6_0_0,
+		"2.1.0":     sarama.V2_1_0_0,
+		"three.six": defaultKafkaVersion,
+	} {
+		config := &BrokerConfig{KafkaVersion: configured, Logger: logger}
+		assert.Equal(t, expected, kafkaVersion(config), configured)
+	}
+
+	require.Len(t, hook.AllEntries(), 1, "only the invalid version is logged")
+	assert.Equal(t, "three.six", hook.LastEntry().Data["kafka_version"])
+}
+
+func TestKafkaSubscribeInitialOffset(t *testing.T) {
+	for configured, expected := range map[string]int64{
+		"":                sarama.OffsetOldest,
+		KafkaOffsetOldest: sarama.OffsetOldest,
+		KafkaOffsetNewest: sarama.OffsetNewest,
+	} {
+		broker, _ := newMockKafkaBroker(t, nil)
+		var initial int64
+		broker.newConsumerGroup = func(_ []string, _ string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+			initial = config.Consumer.Offsets.Initial
+			return newScriptedConsumerGroup(), nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := broker.Subscribe(ctx, "orders", func(context.Context, *Message) error { return nil }, &SubscribeOptions{InitialOffset: configured})
+		cancel()
+		require.NoError(t, err)
+		assert.Equal(t, expected, initial, configured)
+	}
+}
+
+func TestKafkaSubscribeRejectsUnknownInitialOffset(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) {
+		t.Fatal("consumer group created for invalid options")
+		return nil, nil
+	}
+
+	err := broker.Subscribe(context.Background(), "orders", func(context.Context, *Message) error { return nil }, &SubscribeOptions{InitialOffset: "latest"})
+	assert.ErrorContains(t, err, `unknown Kafka initial offset "latest"`)
+}
+
+func TestKafkaGzipCompressionRoundTrip(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	var record *sarama.ConsumerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		record = &sarama.ConsumerMessage{Topic: msg.Topic, Value: value}
+		for i := range msg.Headers {
+			record.Headers = append(record.Headers, &msg.Headers[i])
+		}
+		return nil
+	})
+
+	payload := []byte(strings.Repeat(`{"event":"order.created"}`, 100))
+	require.NoError(t, broker.Publish(context.Background(), "orders", payload, &PublishOptions{Compression: CompressionGzip}))
+	require.NotNil(t, record)
+	assert.Less(t, len(record.Value), len(payload))
+
+	var received *Message
+	subscription := &kafkaSubscription{
+		topic:   "orders",
+		options: &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error {
+			received = message
+			return nil
+		},
+	}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+	require.NoError(t, handler.handleKafkaMessage(&fakeSession{ctx: context.Background()}, record))
+
+	require.NotNil(t, received)
+	assert.Equal(t, payload, received.Data)
+	assert.NotContains(t, received.Headers, HeaderContentEncoding)
+}
+
+func TestKafkaDrainingClaimSkipsMessagesWithoutEndingSession(t *testing.T) {
+	broker, _ := newMockKafkaBroker(t, nil)
+	handled := make(chan int64, 2)
+	subscription := &kafkaSubscription{
+		topic:   "orders",
+		options: &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error {
+			handled <- message.OriginalMessage.(*sarama.ConsumerMessage).Offset
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	session := &fakeSession{ctx: ctx}
+	handler := &kafkaConsumerGroupHandler{subscription: subscription, broker: broker}
+
+	done := make(chan struct{})
+	go func() {
+		handler.ConsumeClaim(session, claim)
+		close(done)
+	}()
+
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 1}
+	assert.Equal(t, int64(1), <-handled)
+	<-subscription.handling.drain()
+
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Offset: 2}
+	select {
+	case <-done:
+		t.Fatal("the claim returned while draining, which would end the session for every partition")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	<-done
+	assert.Empty(t, handled)
+	assert.Equal(t, []int64{1}, session.marked)
+}
+
+func TestKafkaConsumeGivesUpAfterMaxReconnects(t *testing.T) {
+	type attempt struct {
+		number int
+		err    error
+	}
+	attempts := make(chan attempt, 4)
+	broker, _ := newMockKafkaBroker(t, &BrokerConfig{
+		KafkaBrokers:  []string{"localhost:9092"},
+		MaxReconnects: 2,
+		ReconnectWait: time.Millisecond,
+		OnReconnect: func(broker BrokerType, number int, err error) {
+			assert.Equal(t, TypeKafka, broker)
+			attempts <- attempt{number, err}
+		},
+	})
+	group := newScriptedConsumerGroup()
+	broker.newConsumerGroup = func([]string, string, *sarama.Config) (sarama.ConsumerGroup, error) { return group, nil }
+
+	errs, err := broker.SubscribeWithErrors(context.Background(), "orders", func(context.Context, *Message) error { return nil }, nil)
+	require.NoError(t, err)
+
+	// The first error loses the connection, the next two fail both reconnect attempts
+	for i := 0; i < 3; i++ {
+		group.consumeErrs <- sarama.ErrOutOfBrokers
+		assert.ErrorIs(t, <-errs, sarama.ErrOutOfBrokers)
+	}
+
+	assert.Equal(t, attempt{1, sarama.ErrOutOfBrokers}, <-attempts)
+	assert.Equal(t, attempt{2, sarama.ErrOutOfBrokers}, <-attempts)
+
+	// Giving up is reported, then the dead subscription is removed
+	gaveUp := <-errs
+	assert.ErrorIs(t, gaveUp, sarama.ErrOutOfBrokers)
+	assert.Contains(t, gaveUp.Error(), "gave up")
+	select {
+	case _, open := <-errs:
+		assert.False(t, open, "the consume loop stops once the attempts are used up")
+	case <-time.After(time.Second):
+		t.Fatal("consume loop kept reconnecting past MaxReconnects")
+	}
+	assert.ErrorIs(t, broker.Unsubscribe(context.Background(), "orders"), errSubscriptionNotFound)
+}
+
+func TestKafkaPublishBatchReturnsSendErrors(t *testing.T) {
+	broker, producer := newMockKafkaBroker(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+
+	err := broker.PublishBatch(context.Background(), []BatchMessage{
+		{Topic: "orders", Data: []byte("1")},
+		{Topic: "payments", Data: []byte("2")},
+	}, nil)
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	assert.Contains(t, err.Error(), "payments")
+}
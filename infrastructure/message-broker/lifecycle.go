@@ -0,0 +1,26 @@
+package messagebroker
+
+// SubscriptionEvent describes a subscription lifecycle change reported to the
+// SubscribeOptions OnStart, OnStop and OnRebalance callbacks
+type SubscriptionEvent struct {
+	Topic string
+	// Group is the Kafka consumer group, or the queue name when one was given
+	Group string
+	// Partitions are the Kafka partitions of Topic claimed by the consumer in the session
+	// that just began; empty for other brokers and for OnStop
+	Partitions []int32
+}
+
+// TopicPartition identifies a Kafka partition
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+// notifySubscription invokes callback when it is set. Brokers call it without holding
+// their lock so that callbacks may use the broker.
+func notifySubscription(callback func(SubscriptionEvent), event SubscriptionEvent) {
+	if callback != nil {
+		callback(event)
+	}
+}
@@ -0,0 +1,85 @@
+package messagebroker_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleLog records the lifecycle callbacks a subscription receives
+type lifecycleLog struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (l *lifecycleLog) options() *messagebroker.SubscribeOptions {
+	record := func(kind string) func(messagebroker.SubscriptionEvent) {
+		return func(event messagebroker.SubscriptionEvent) {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.events = append(l.events, kind+" "+event.Topic)
+		}
+	}
+
+	return &messagebroker.SubscribeOptions{
+		AutoAck:     true,
+		Concurrency: 1,
+		OnStart:     record("start"),
+		OnStop:      record("stop"),
+		OnRebalance: record("rebalance"),
+	}
+}
+
+func (l *lifecycleLog) recorded() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func TestInMemorySubscriptionLifecycle(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(1))
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("o-1"), nil))
+
+	log := &lifecycleLog{}
+	options := log.options()
+	started := options.OnStart
+	options.OnStart = func(event messagebroker.SubscriptionEvent) {
+		// Retained messages are delivered only once the subscription has started
+		assert.Empty(t, broker.History("orders.seen"))
+		started(event)
+	}
+
+	handler := func(ctx context.Context, message *messagebroker.Message) error {
+		return broker.Publish(ctx, "orders.seen", message.Data, nil)
+	}
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", handler, options))
+	assert.Equal(t, []string{"start orders"}, log.recorded())
+
+	require.NoError(t, broker.Unsubscribe(context.Background(), "orders"))
+	assert.Equal(t, []string{"start orders", "stop orders"}, log.recorded())
+}
+
+func TestInMemoryDisconnectStopsSubscriptions(t *testing.T) {
+	broker := newInMemoryBroker(t)
+
+	log := &lifecycleLog{}
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", collect(new([]string)), log.options()))
+	require.NoError(t, broker.Disconnect(context.Background()))
+
+	assert.Equal(t, []string{"start orders", "stop orders"}, log.recorded())
+}
+
+func TestNATSSubscriptionLifecycle(t *testing.T) {
+	broker := newNATSBroker(t)
+
+	log := &lifecycleLog{}
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", collect(new([]string)), log.options()))
+	assert.Equal(t, []string{"start orders"}, log.recorded())
+
+	require.NoError(t, broker.Unsubscribe(context.Background(), "orders"))
+	assert.Equal(t, []string{"start orders", "stop orders"}, log.recorded())
+}
@@ -0,0 +1,129 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// logSampler collapses identical log entries. The first occurrence is logged at once;
+// repeats within the interval are counted and reported as a single summary entry when the
+// interval ends, so a failing downstream produces one line per interval instead of one
+// per message.
+type logSampler struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	windows  map[string]*sampleWindow
+}
+
+type sampleWindow struct {
+	entry   *logrus.Entry
+	level   logrus.Level
+	message string
+	repeats int
+}
+
+func newLogSampler(interval time.Duration) *logSampler {
+	return &logSampler{
+		interval: interval,
+		windows:  make(map[string]*sampleWindow),
+	}
+}
+
+func (s *logSampler) log(entry *logrus.Entry, level logrus.Level, message string) {
+	key := sampleKey(entry, level, message)
+
+	s.mutex.Lock()
+	if window, open := s.windows[key]; open {
+		window.repeats++
+		s.mutex.Unlock()
+		return
+	}
+	s.windows[key] = &sampleWindow{entry: entry, level: level, message: message}
+	s.mutex.Unlock()
+
+	entry.Log(level, message)
+	time.AfterFunc(s.interval, func() { s.closeWindow(key) })
+}
+
+// closeWindow reports the repeats counted in the window. A window with repeats stays open
+// for another interval, since the error is evidently ongoing; a quiet one is dropped.
+func (s *logSampler) closeWindow(key string) {
+	s.mutex.Lock()
+	window := s.windows[key]
+	repeats := window.repeats
+	if repeats == 0 {
+		delete(s.windows, key)
+		s.mutex.Unlock()
+		return
+	}
+	window.repeats = 0
+	s.mutex.Unlock()
+
+	window.entry.WithField("occurrences", repeats).
+		Logf(window.level, "%s (%d occurrences in the last %s)", window.message, repeats, s.interval)
+	time.AfterFunc(s.interval, func() { s.closeWindow(key) })
+}
+
+// sampleKey identifies entries that are the same apart from their time. A panic stack is
+// left out since it names the goroutine, which differs between otherwise identical panics.
+func sampleKey(entry *logrus.Entry, level logrus.Level, message string) string {
+	fields := make([]string, 0, len(entry.Data))
+	for key, value := range entry.Data {
+		if key == "stack" {
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(fields)
+	return level.String() + "\x00" + message + "\x00" + strings.Join(fields, "\x00")
+}
+
+// logError logs a background error with the broker's logger. When LogSampleInterval is
+// set, repeats with the same message, error and fields are collapsed.
+func (c *BrokerConfig) logError(err error, fields logrus.Fields, message string) {
+	c.logSampled(logrus.ErrorLevel, err, fields, message)
+}
+
+// publishFailed reports a background delivery failure to OnPublishError, or logs it
+func (c *BrokerConfig) publishFailed(topic string, err error) {
+	if c.OnPublishError != nil {
+		c.OnPublishError(topic, err)
+		return
+	}
+	c.logError(err, logrus.Fields{"topic": topic}, "Failed to deliver message")
+}
+
+// messageFailed hands a message that failed for good to SubscribeOptions.OnError, or logs it
+func (c *BrokerConfig) messageFailed(ctx context.Context, options *SubscribeOptions, message *Message, err error, fields logrus.Fields, logMessage string) {
+	if options != nil && options.OnError != nil {
+		options.OnError(ctx, message, err)
+		return
+	}
+	c.logError(err, fields, logMessage)
+}
+
+func (c *BrokerConfig) logSampled(level logrus.Level, err error, fields logrus.Fields, message string) {
+	entry := logrus.NewEntry(c.log()).WithFields(fields)
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	var panicked *PanicError
+	if errors.As(err, &panicked) {
+		entry = entry.WithField("stack", string(panicked.Stack))
+	}
+
+	if c == nil || c.LogSampleInterval <= 0 {
+		entry.Log(level, message)
+		return
+	}
+
+	c.samplerOnce.Do(func() { c.sampler = newLogSampler(c.LogSampleInterval) })
+	c.sampler.log(entry, level, message)
+}
@@ -0,0 +1,67 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSamplingCollapsesRepeatedErrors(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger), messagebroker.WithLogSampling(100*time.Millisecond))
+
+	downstream := errors.New("connection refused")
+	failing := func(ctx context.Context, message *messagebroker.Message) error {
+		if string(message.Data) == "other" {
+			return errors.New("invalid payload")
+		}
+		return downstream
+	}
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", failing, &messagebroker.SubscribeOptions{Concurrency: 1}))
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, broker.Publish(context.Background(), "orders", []byte("order"), nil))
+	}
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("other"), nil))
+
+	// Only the first of each distinct error is logged straight away
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, downstream, entries[0].Data[logrus.ErrorKey])
+	assert.Equal(t, "invalid payload", entries[1].Data[logrus.ErrorKey].(error).Error())
+
+	// The repeats are reported once the interval ends
+	require.Eventually(t, func() bool { return len(hook.AllEntries()) == 3 }, time.Second, 10*time.Millisecond)
+	summary := hook.LastEntry()
+	assert.Equal(t, logrus.ErrorLevel, summary.Level)
+	assert.Equal(t, 4, summary.Data["occurrences"])
+	assert.Equal(t, "orders", summary.Data["topic"])
+	assert.Equal(t, "Failed to process in-memory message (4 occurrences in the last 100ms)", summary.Message)
+
+	// A quiet interval adds nothing further
+	time.Sleep(250 * time.Millisecond)
+	assert.Len(t, hook.AllEntries(), 3)
+}
+
+func TestLogSamplingDisabledLogsEveryError(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger))
+
+	failing := func(ctx context.Context, message *messagebroker.Message) error {
+		return errors.New("connection refused")
+	}
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", failing, &messagebroker.SubscribeOptions{Concurrency: 1}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, broker.Publish(context.Background(), "orders", []byte("order"), nil))
+	}
+
+	assert.Len(t, hook.AllEntries(), 3)
+}
@@ -0,0 +1,205 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+// MetricsCollector observes every publish and every handler call of a broker. Set one with
+// WithMetrics; PrometheusMetrics is a ready-made implementation.
+type MetricsCollector interface {
+	// ObservePublish is called once per published message with the time the broker took
+	// to send it and the resulting error, if any
+	ObservePublish(topic string, duration time.Duration, err error)
+	// ObserveConsume is called once per handler call, so a retried message is observed
+	// on every attempt
+	ObserveConsume(topic string, duration time.Duration, err error)
+}
+
+// observingPublish times next and reports it to the configured MetricsCollector. Without
+// one next is returned as is.
+func (c *BrokerConfig) observingPublish(next PublishFunc) PublishFunc {
+	if c == nil || c.Metrics == nil {
+		return next
+	}
+
+	metrics := c.Metrics
+	return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+		start := time.Now()
+		err := next(ctx, topic, message, options)
+		metrics.ObservePublish(topic, time.Since(start), err)
+		return err
+	}
+}
+
+// observingConsume times every call of handler and reports it to the configured
+// MetricsCollector. Without one handler is returned as is.
+func (c *BrokerConfig) observingConsume(handler MessageHandler) MessageHandler {
+	if c == nil || c.Metrics == nil {
+		return handler
+	}
+
+	metrics := c.Metrics
+	return func(ctx context.Context, message *Message) error {
+		start := time.Now()
+		err := handler(ctx, message)
+		metrics.ObserveConsume(message.Topic, time.Since(start), err)
+		return err
+	}
+}
+
+// PrometheusMetrics is a MetricsCollector exposing latency histograms and error counters
+// labeled by broker type and topic
+type PrometheusMetrics struct {
+	publishLatency prometheus.ObserverVec
+	publishErrors  *prometheus.CounterVec
+	consumeLatency prometheus.ObserverVec
+	consumeErrors  *prometheus.CounterVec
+	handlerLatency *prometheus.HistogramVec
+}
+
+// NewPrometheusMetrics registers the broker metrics with registerer, the default registry
+// when nil, and returns a collector whose observations carry brokerType as the broker
+// label. Collectors for several brokers can share a registerer.
+func NewPrometheusMetrics(registerer prometheus.Registerer, brokerType BrokerType) (*PrometheusMetrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	publishLatency, err := registerOrExisting(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "message_broker",
+		Name:      "publish_latency_seconds",
+		Help:      "Time spent sending a message, by broker and topic.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"broker", "topic"}))
+	if err != nil {
+		return nil, err
+	}
+	publishErrors, err := registerOrExisting(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "message_broker",
+		Name:      "publish_errors_total",
+		Help:      "Messages that failed to publish, by broker and topic.",
+	}, []string{"broker", "topic"}))
+	if err != nil {
+		return nil, err
+	}
+	consumeLatency, err := registerOrExisting(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "message_broker",
+		Name:      "consume_latency_seconds",
+		Help:      "Time spent in subscription handlers, by broker and topic.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"broker", "topic"}))
+	if err != nil {
+		return nil, err
+	}
+	consumeErrors, err := registerOrExisting(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "message_broker",
+		Name:      "consume_errors_total",
+		Help:      "Handler calls that returned an error, by broker and topic.",
+	}, []string{"broker", "topic"}))
+	if err != nil {
+		return nil, err
+	}
+
+	handlerLatency, err := registerHandlerLatency(registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := prometheus.Labels{"broker": string(brokerType)}
+	return &PrometheusMetrics{
+		publishLatency: publishLatency.MustCurryWith(labels),
+		publishErrors:  publishErrors.MustCurryWith(labels),
+		consumeLatency: consumeLatency.MustCurryWith(labels),
+		consumeErrors:  consumeErrors.MustCurryWith(labels),
+		handlerLatency: handlerLatency,
+	}, nil
+}
+
+// registerHandlerLatency registers the histogram of LatencyTrackingHandler with registerer
+func registerHandlerLatency(registerer prometheus.Registerer) (*prometheus.HistogramVec, error) {
+	return registerOrExisting(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "message_broker",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent processing a consumed message, by handler, topic and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"handler", "topic", "outcome"}))
+}
+
+// registerOrExisting registers collector, returning the one already registered under the
+// same name when another broker got there first
+func registerOrExisting[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, fmt.Errorf("failed to register broker metrics: %w", err)
+	}
+	return collector, nil
+}
+
+// ObservePublish records the publish latency and counts the failure, if any
+func (m *PrometheusMetrics) ObservePublish(topic string, duration time.Duration, err error) {
+	m.publishLatency.WithLabelValues(topic).Observe(duration.Seconds())
+	if err != nil {
+		m.publishErrors.WithLabelValues(topic).Inc()
+	}
+}
+
+// ObserveConsume records the handler latency and counts the failure, if any
+func (m *PrometheusMetrics) ObserveConsume(topic string, duration time.Duration, err error) {
+	m.consumeLatency.WithLabelValues(topic).Observe(duration.Seconds())
+	if err != nil {
+		m.consumeErrors.WithLabelValues(topic).Inc()
+	}
+}
+
+// LatencyTrackingHandler wraps a message handler and records its processing duration in a
+// histogram labeled by handler name, topic and outcome, from which p50/p95/p99 can be
+// derived. Unlike ObserveConsume it tells apart handlers sharing a topic.
+func (m *PrometheusMetrics) LatencyTrackingHandler(handler MessageHandler, name string) MessageHandler {
+	return func(ctx context.Context, message *Message) error {
+		start := time.Now()
+		err := handler(ctx, message)
+
+		outcome := outcomeSuccess
+		if err != nil {
+			outcome = outcomeError
+		}
+		m.handlerLatency.WithLabelValues(name, message.Topic, outcome).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+var (
+	defaultHandlerMetricsOnce sync.Once
+	defaultHandlerMetrics     *PrometheusMetrics
+)
+
+// LatencyTrackingHandler wraps a message handler like PrometheusMetrics.LatencyTrackingHandler,
+// recording into the default Prometheus registry so it is served by promhttp.Handler()
+// without building a PrometheusMetrics
+func LatencyTrackingHandler(handler MessageHandler, name string) MessageHandler {
+	defaultHandlerMetricsOnce.Do(func() {
+		handlerLatency, err := registerHandlerLatency(prometheus.DefaultRegisterer)
+		if err != nil {
+			panic(err)
+		}
+		defaultHandlerMetrics = &PrometheusMetrics{handlerLatency: handlerLatency}
+	})
+	return defaultHandlerMetrics.LatencyTrackingHandler(handler, name)
+}
@@ -0,0 +1,195 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latencySamples returns the observation count and sum for one handler/topic/outcome series
+func latencySamples(t *testing.T, registry *prometheus.Registry, handler, topic, outcome string) (uint64, float64) {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "message_broker_handler_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric, map[string]string{"handler": handler, "topic": topic, "outcome": outcome}) {
+				return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return 0, 0
+}
+
+func hasLabels(metric *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, pair := range metric.GetLabel() {
+		if value, ok := labels[pair.GetName()]; ok && value == pair.GetValue() {
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+// newHandlerMetrics returns Prometheus metrics registered with a registry of their own
+func newHandlerMetrics(t *testing.T) (*messagebroker.PrometheusMetrics, *prometheus.Registry) {
+	registry := prometheus.NewRegistry()
+	metrics, err := messagebroker.NewPrometheusMetrics(registry, messagebroker.TypeInMemory)
+	require.NoError(t, err)
+	return metrics, registry
+}
+
+func TestLatencyTrackingHandlerObservesDuration(t *testing.T) {
+	metrics, registry := newHandlerMetrics(t)
+	handler := metrics.LatencyTrackingHandler(func(ctx context.Context, message *messagebroker.Message) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, "latency-success")
+
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "orders"}))
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "orders"}))
+
+	count, sum := latencySamples(t, registry, "latency-success", "orders", "success")
+	assert.Equal(t, uint64(2), count)
+	assert.GreaterOrEqual(t, sum, 0.02)
+}
+
+func TestLatencyTrackingHandlerLabelsErrors(t *testing.T) {
+	metrics, registry := newHandlerMetrics(t)
+	handlerErr := errors.New("boom")
+	handler := metrics.LatencyTrackingHandler(func(ctx context.Context, message *messagebroker.Message) error {
+		if string(message.Data) == "fail" {
+			return handlerErr
+		}
+		return nil
+	}, "latency-outcome")
+
+	assert.ErrorIs(t, handler(context.Background(), &messagebroker.Message{Topic: "payments", Data: []byte("fail")}), handlerErr)
+	assert.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "payments", Data: []byte("ok")}))
+
+	errorCount, _ := latencySamples(t, registry, "latency-outcome", "payments", "error")
+	successCount, _ := latencySamples(t, registry, "latency-outcome", "payments", "success")
+	assert.Equal(t, uint64(1), errorCount)
+	assert.Equal(t, uint64(1), successCount)
+}
+
+func TestLatencyTrackingHandlerComposes(t *testing.T) {
+	metrics, registry := newHandlerMetrics(t)
+	handler := metrics.LatencyTrackingHandler(
+		messagebroker.LoggingMessageHandler(func(ctx context.Context, message *messagebroker.Message) error {
+			return nil
+		}, func(level string, msg string, args ...interface{}) {}),
+		"latency-composed",
+	)
+
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "orders"}))
+
+	count, _ := latencySamples(t, registry, "latency-composed", "orders", "success")
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestPackageLatencyTrackingHandlerUsesDefaultRegistry(t *testing.T) {
+	registry, ok := prometheus.DefaultGatherer.(*prometheus.Registry)
+	require.True(t, ok)
+	// The default registry lives as long as the process, so compare against earlier runs
+	before, _ := latencySamples(t, registry, "latency-default", "orders", "success")
+
+	handler := messagebroker.LatencyTrackingHandler(func(ctx context.Context, message *messagebroker.Message) error {
+		return nil
+	}, "latency-default")
+
+	require.NoError(t, handler(context.Background(), &messagebroker.Message{Topic: "orders"}))
+	count, _ := latencySamples(t, registry, "latency-default", "orders", "success")
+	assert.Equal(t, before+1, count)
+
+	// A PrometheusMetrics on the default registry shares the same histogram
+	metrics, err := messagebroker.NewPrometheusMetrics(nil, messagebroker.TypeInMemory)
+	require.NoError(t, err)
+	require.NoError(t, metrics.LatencyTrackingHandler(handler, "latency-default")(context.Background(), &messagebroker.Message{Topic: "orders"}))
+	count, _ = latencySamples(t, registry, "latency-default", "orders", "success")
+	assert.Equal(t, before+3, count)
+}
+
+// observation is one call of a MetricsCollector
+type observation struct {
+	topic string
+	err   error
+}
+
+type recordingCollector struct {
+	mu        sync.Mutex
+	published []observation
+	consumed  []observation
+}
+
+func (c *recordingCollector) ObservePublish(topic string, _ time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, observation{topic, err})
+}
+
+func (c *recordingCollector) ObserveConsume(topic string, _ time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumed = append(c.consumed, observation{topic, err})
+}
+
+func TestMetricsCollectorObservesPublishAndConsume(t *testing.T) {
+	collector := &recordingCollector{}
+	broker := newInMemoryBroker(t, messagebroker.WithMetrics(collector))
+	handlerErr := errors.New("ward full")
+
+	require.NoError(t, broker.Subscribe(context.Background(), "admissions", func(ctx context.Context, message *messagebroker.Message) error {
+		return handlerErr
+	}, &messagebroker.SubscribeOptions{MaxRetries: 1}))
+	require.NoError(t, broker.Publish(context.Background(), "admissions", []byte("1"), nil))
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	assert.Equal(t, []observation{{"admissions", nil}}, collector.published)
+	assert.Equal(t, []observation{{"admissions", handlerErr}, {"admissions", handlerErr}}, collector.consumed,
+		"every attempt is observed")
+}
+
+func TestPrometheusMetricsLabelsByBrokerAndTopic(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	kafkaMetrics, err := messagebroker.NewPrometheusMetrics(registry, messagebroker.TypeKafka)
+	require.NoError(t, err)
+	natsMetrics, err := messagebroker.NewPrometheusMetrics(registry, messagebroker.TypeNATS)
+	require.NoError(t, err, "collectors for several brokers share the registry")
+
+	kafkaMetrics.ObservePublish("orders", 20*time.Millisecond, nil)
+	kafkaMetrics.ObservePublish("orders", 10*time.Millisecond, errors.New("broker down"))
+	natsMetrics.ObserveConsume("orders", 5*time.Millisecond, errors.New("boom"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	series := map[string]*dto.Metric{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, broker := range []string{"kafka", "nats"} {
+				if hasLabels(metric, map[string]string{"broker": broker, "topic": "orders"}) {
+					series[family.GetName()+"/"+broker] = metric
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, uint64(2), series["message_broker_publish_latency_seconds/kafka"].GetHistogram().GetSampleCount())
+	assert.InDelta(t, 0.03, series["message_broker_publish_latency_seconds/kafka"].GetHistogram().GetSampleSum(), 1e-9)
+	assert.Equal(t, 1.0, series["message_broker_publish_errors_total/kafka"].GetCounter().GetValue())
+	assert.Equal(t, uint64(1), series["message_broker_consume_latency_seconds/nats"].GetHistogram().GetSampleCount())
+	assert.Equal(t, 1.0, series["message_broker_consume_errors_total/nats"].GetCounter().GetValue())
+	assert.NotContains(t, series, "message_broker_consume_errors_total/kafka")
+}
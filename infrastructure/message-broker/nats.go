@@ -2,30 +2,41 @@ package messagebroker
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
 )
 
+// HeaderRedeliveryCount carries the number of times a NATS core message has been re-published
+// after a handler failure when SubscribeOptions.Redeliver is enabled
+const HeaderRedeliveryCount = "X-Redelivery-Count"
+
 type natsBroker struct {
 	conn        *nats.Conn
+	js          nats.JetStreamContext // Set in JetStream mode
 	config      *BrokerConfig
 	subscribers map[string]*natsSubscription
 	mutex       sync.RWMutex
 	connected   bool
+	counters    messageCounters
 }
 
 type natsSubscription struct {
-	subscription *nats.Subscription
-	handler      MessageHandler
-	options      *SubscribeOptions
-	cancel       context.CancelFunc
-	topic        string
+	subscriptions []*nats.Subscription // One per subject
+	handler       MessageHandler
+	options       *SubscribeOptions
+	cancel        context.CancelFunc
+	topic         string
+	gate          pauseGate
+	handling      handling
 }
 
 // NewNATSBroker creates a new NATS-based message broker
@@ -51,10 +62,11 @@ func (n *natsBroker) Connect(ctx context.Context) error {
 
 	var opts []nats.Option
 
-	// Set connection options
-	if n.config.MaxReconnects > 0 {
+	// Set connection options; the client restores subscriptions itself after a reconnect
+	if n.config.MaxReconnects != 0 {
 		opts = append(opts, nats.MaxReconnects(n.config.MaxReconnects))
 	}
+	opts = append(opts, n.reconnectHandlers()...)
 
 	if n.config.ReconnectWait > 0 {
 		opts = append(opts, nats.ReconnectWait(n.config.ReconnectWait))
@@ -113,37 +125,125 @@ func (n *natsBroker) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to connect to NATS: %w", err)
 	}
 
+	if n.config.NATSJetStream {
+		n.js, err = n.conn.JetStream()
+		if err != nil {
+			n.conn.Close()
+			n.conn = nil
+			return fmt.Errorf("failed to get JetStream context: %w", err)
+		}
+	}
+
 	n.connected = true
 	return nil
 }
 
+// reconnectHandlers report the client's reconnect attempts through OnReconnect and mark
+// the broker disconnected once the client gives up
+func (n *natsBroker) reconnectHandlers() []nats.Option {
+	var attempt atomic.Int32
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			if err != nil {
+				n.config.logError(err, logrus.Fields{"server": conn.ConnectedUrlRedacted()}, "NATS connection lost, reconnecting")
+			}
+		}),
+		nats.ReconnectErrHandler(func(_ *nats.Conn, err error) {
+			n.config.reportReconnect(TypeNATS, int(attempt.Add(1)), err)
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			n.config.reportReconnect(TypeNATS, int(attempt.Add(1)), nil)
+			attempt.Store(0)
+		}),
+		nats.ClosedHandler(func(conn *nats.Conn) {
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+
+			// Disconnect clears n.conn before this runs
+			if n.conn != conn {
+				return
+			}
+			n.connected = false
+			n.config.logError(conn.LastError(), logrus.Fields{"subscriptions": len(n.subscribers)}, "NATS connection closed after failed reconnects")
+		}),
+	}
+}
+
 // Disconnect closes the NATS connection
 func (n *natsBroker) Disconnect(ctx context.Context) error {
 	n.mutex.Lock()
-	defer n.mutex.Unlock()
+	stopped := n.subscribers
+	n.subscribers = make(map[string]*natsSubscription)
 
-	// Stop all subscriptions
-	for _, sub := range n.subscribers {
+	// Stop deliveries, then let handlers finish their messages while the connection is
+	// still open, so they can be acked and handlers can publish
+	trackers := make([]*handling, 0, len(stopped))
+	for _, sub := range stopped {
+		sub.unsubscribe()
+		trackers = append(trackers, &sub.handling)
+	}
+	n.mutex.Unlock()
+	n.config.awaitDrained(ctx, trackers)
+
+	n.mutex.Lock()
+	for _, sub := range stopped {
 		if sub.cancel != nil {
 			sub.cancel()
 		}
-		if sub.subscription != nil {
-			sub.subscription.Unsubscribe()
-		}
 	}
-	n.subscribers = make(map[string]*natsSubscription)
 
 	if n.conn != nil {
 		n.conn.Close()
 		n.conn = nil
 	}
+	n.js = nil
 
 	n.connected = false
+	n.mutex.Unlock()
+
+	for _, sub := range stopped {
+		notifySubscription(sub.options.OnStop, sub.event())
+	}
 	return nil
 }
 
 // Publish sends a message to the specified topic/queue
 func (n *natsBroker) Publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(n.config.publishInterceptors(), n.config.observingPublish(n.publish))(ctx, topic, message, options)
+}
+
+func (n *natsBroker) publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	if !n.connected {
+		return errBrokerNotConnected
+	}
+
+	msg := newNATSOutgoing(topic, message, options)
+	var err error
+	if n.js != nil {
+		err = n.publishJetStream(ctx, msg)
+	} else {
+		// Core NATS has no persistence or TTL like RabbitMQ; messages without a live
+		// subscriber are dropped. JetStream mode stores them instead.
+		err = n.conn.PublishMsg(msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	n.counters.recordPublished(topic)
+	return nil
+}
+
+// PublishAsync sends a message without waiting for it to be stored. Core NATS publishes
+// are fire-and-forget already; in JetStream mode the ack is awaited in the background.
+func (n *natsBroker) PublishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(n.config.publishInterceptors(), n.config.observingPublish(n.publishAsync))(ctx, topic, message, options)
+}
+
+func (n *natsBroker) publishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
 	n.mutex.RLock()
 	defer n.mutex.RUnlock()
 
@@ -151,49 +251,88 @@ func (n *natsBroker) Publish(ctx context.Context, topic string, message []byte,
 		return errBrokerNotConnected
 	}
 
-	// Create NATS message
+	msg := newNATSOutgoing(topic, message, options)
+	if n.js != nil {
+		return n.publishJetStreamAsync(msg)
+	}
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	n.counters.recordPublished(topic)
+	return nil
+}
+
+// newNATSOutgoing builds the message to publish. It gets a Nats-Msg-Id unless the headers
+// set one, so consumers see the same Message.ID on every delivery and JetStream drops
+// duplicate publishes.
+func newNATSOutgoing(topic string, message []byte, options *PublishOptions) *nats.Msg {
 	msg := &nats.Msg{
 		Subject: topic,
 		Data:    message,
+		Header:  make(nats.Header),
 	}
 
-	if options != nil && options.Headers != nil {
-		msg.Header = make(nats.Header)
+	if options != nil {
 		for k, v := range options.Headers {
 			msg.Header.Set(k, v)
 		}
 	}
-
-	// For NATS, we don't have built-in persistence or TTL like RabbitMQ
-	// These would need to be handled at the application level or with NATS Streaming
-	return n.conn.PublishMsg(msg)
+	if msg.Header.Get(nats.MsgIdHdr) == "" {
+		msg.Header.Set(nats.MsgIdHdr, uuid.NewString())
+	}
+	return msg
 }
 
-// PublishJSON sends a JSON-encoded message to the specified topic/queue
+// PublishJSON sends a message encoded by the configured Serializer, JSON by default, to
+// the specified topic/queue
 func (n *natsBroker) PublishJSON(ctx context.Context, topic string, message interface{}, options *PublishOptions) error {
-	data, err := json.Marshal(message)
+	data, options, err := serializeForPublish(n.config, message, options)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	if options == nil {
-		options = &PublishOptions{}
-	}
-	if options.Headers == nil {
-		options.Headers = make(map[string]string)
+		return err
 	}
-	options.Headers["Content-Type"] = "application/json"
 
 	return n.Publish(ctx, topic, data, options)
 }
 
 // Subscribe subscribes to messages from the specified topic/queue
 func (n *natsBroker) Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error {
+	subscription, err := n.subscribe(ctx, topic, []string{topic}, handler, options)
+	if err != nil {
+		return err
+	}
+
+	notifySubscription(subscription.options.OnStart, subscription.event())
+	return nil
+}
+
+// SubscribeMany subscribes the handler to every subject under one handle. NATS has no
+// multi-subject subscription, so each subject gets its own, sharing the options, pause
+// state and teardown.
+func (n *natsBroker) SubscribeMany(ctx context.Context, topics []string, handler MessageHandler, options *SubscribeOptions) (string, error) {
+	if err := validateTopics(topics); err != nil {
+		return "", err
+	}
+
+	handle := SubscriptionHandle(topics)
+	subscription, err := n.subscribe(ctx, handle, topics, handler, options)
+	if err != nil {
+		return "", err
+	}
+
+	notifySubscription(subscription.options.OnStart, subscription.event())
+	return handle, nil
+}
+
+// subscribe subscribes to each of topics and stores the subscription under key
+func (n *natsBroker) subscribe(ctx context.Context, key string, topics []string, handler MessageHandler, options *SubscribeOptions) (*natsSubscription, error) {
+	handler = n.config.extractingTraceContext(n.config.deduplicating(options, n.config.observingConsume(timingOut(options, handler))))
+
 	n.mutex.Lock()
 	defer n.mutex.Unlock()
 
 	if !n.connected {
-		return errBrokerNotConnected
+		return nil, errBrokerNotConnected
 	}
 
 	if options == nil {
@@ -205,45 +344,85 @@ func (n *natsBroker) Subscribe(ctx context.Context, topic string, handler Messag
 		}
 	}
 
+	if n.js != nil && options.PullBatchSize <= 0 {
+		// JetStream mode consumes every subscription through a durable pull consumer
+		jetStreamOptions := *options
+		jetStreamOptions.PullBatchSize = natsJetStreamBatchSize
+		options = &jetStreamOptions
+	}
+
 	subCtx, cancel := context.WithCancel(ctx)
 
-	// Create NATS subscription
-	var sub *nats.Subscription
-	var err error
+	natsSubscription := &natsSubscription{
+		handler: handler,
+		options: options,
+		cancel:  cancel,
+		topic:   key,
+	}
 
-	// NATS message handler
+	// NATS message handler. Core NATS has no server-side buffering, so messages that
+	// arrive while the subscription is paused are dropped rather than held.
 	msgHandler := func(msg *nats.Msg) {
+		if natsSubscription.gate.IsPaused() || !natsSubscription.handling.begin() {
+			return
+		}
+		defer natsSubscription.handling.end()
 		n.handleNATSMessage(subCtx, msg, handler, options)
 	}
 
-	// Subscribe based on options
-	if options.QueueName != "" {
-		// Queue subscription (load balancing)
-		sub, err = n.conn.QueueSubscribe(topic, options.QueueName, msgHandler)
-	} else {
-		// Regular subscription
-		sub, err = n.conn.Subscribe(topic, msgHandler)
+	for _, topic := range topics {
+		// Create NATS subscription
+		var sub *nats.Subscription
+		var err error
+
+		// Subscribe based on options
+		if options.PullBatchSize > 0 {
+			// JetStream pull consumer: workers fetch in batches instead of receiving pushes
+			sub, err = n.pullSubscribe(topic, options)
+		} else if options.QueueName != "" {
+			// Queue subscription (load balancing)
+			sub, err = n.conn.QueueSubscribe(topic, options.QueueName, msgHandler)
+		} else {
+			// Regular subscription
+			sub, err = n.conn.Subscribe(topic, msgHandler)
+		}
+
+		if err != nil {
+			cancel()
+			natsSubscription.unsubscribe()
+			return nil, fmt.Errorf("failed to subscribe to topic %s: %w", topic, err)
+		}
+		natsSubscription.subscriptions = append(natsSubscription.subscriptions, sub)
 	}
 
-	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, err)
+	// Pull workers start once every subject is subscribed, so a failure leaves none running
+	if options.PullBatchSize > 0 {
+		for _, sub := range natsSubscription.subscriptions {
+			n.startPullWorkers(subCtx, sub, natsSubscription)
+		}
 	}
 
 	// Store subscription
-	natsSubscription := &natsSubscription{
-		subscription: sub,
-		handler:      handler,
-		options:      options,
-		cancel:       cancel,
-		topic:        topic,
+	n.subscribers[key] = natsSubscription
+	return natsSubscription, nil
+}
+
+// unsubscribe removes every subject subscription, returning the first error
+func (s *natsSubscription) unsubscribe() error {
+	var first error
+	for _, sub := range s.subscriptions {
+		if err := sub.Unsubscribe(); err != nil && first == nil {
+			first = err
+		}
 	}
+	return first
+}
 
-	n.subscribers[topic] = natsSubscription
-	return nil
+func (s *natsSubscription) event() SubscriptionEvent {
+	return SubscriptionEvent{Topic: s.topic, Group: s.options.QueueName}
 }
 
-func (n *natsBroker) handleNATSMessage(ctx context.Context, natsMsg *nats.Msg, handler MessageHandler, options *SubscribeOptions) {
+func newNATSMessage(natsMsg *nats.Msg) *Message {
 	message := &Message{
 		ID:              fmt.Sprintf("%d", time.Now().UnixNano()), // NATS doesn't have message IDs
 		Topic:           natsMsg.Subject,
@@ -261,36 +440,104 @@ func (n *natsBroker) handleNATSMessage(ctx context.Context, natsMsg *nats.Msg, h
 			}
 		}
 	}
+	// A publisher-assigned ID stays the same when the message is delivered again. It is
+	// Message.ID rather than a header, so republishing the headers does not reuse it.
+	if id := natsMsg.Header.Get(nats.MsgIdHdr); id != "" {
+		message.ID = id
+		delete(message.Headers, nats.MsgIdHdr)
+	}
+	return message
+}
+
+func (n *natsBroker) handleNATSMessage(ctx context.Context, natsMsg *nats.Msg, handler MessageHandler, options *SubscribeOptions) {
+	message := newNATSMessage(natsMsg)
+
+	if options.Redeliver {
+		n.redeliverNATSMessage(ctx, natsMsg, message, handler, options)
+		return
+	}
 
 	// Process message with retries
-	var lastErr error
-	for retry := 0; retry <= options.MaxRetries; retry++ {
-		message.Retry = retry
-		message.MaxRetries = options.MaxRetries
-
-		err := handler(ctx, message)
-		if err == nil {
-			// Success - NATS doesn't require explicit acking for regular subscriptions
+	err := runWithRetries(ctx, handler, message, options)
+	if err == nil {
+		// Success - NATS doesn't require explicit acking for regular subscriptions
+		n.counters.recordConsumed(natsMsg.Subject)
+		return
+	}
+
+	fields := logrus.Fields{"subject": natsMsg.Subject, "retries": message.Retry}
+	if abandoned(ctx, err) {
+		n.config.logError(err, fields, "Failed to process NATS message")
+		return
+	}
+
+	n.config.messageFailed(ctx, options, message, err, fields, "Failed to process NATS message")
+	if options.DeadLetterTopic != "" {
+		n.republish(natsMsg, options.DeadLetterTopic, message.Retry)
+	}
+}
+
+// redeliverNATSMessage invokes the handler once per delivery. On failure the message is
+// re-published with an incremented redelivery count; once the count reaches MaxRetries it
+// is dead-lettered instead, so a poison message cannot loop forever.
+func (n *natsBroker) redeliverNATSMessage(ctx context.Context, natsMsg *nats.Msg, message *Message, handler MessageHandler, options *SubscribeOptions) {
+	count, _ := strconv.Atoi(natsMsg.Header.Get(HeaderRedeliveryCount))
+	if count < 0 {
+		count = 0
+	}
+
+	message.Retry = count
+	message.MaxRetries = options.MaxRetries
+
+	err := callHandler(ctx, handler, message, options)
+	if err == nil {
+		n.counters.recordConsumed(natsMsg.Subject)
+		return
+	}
+
+	// A permanent failure skips the remaining redeliveries
+	if IsPermanent(err) || count >= options.MaxRetries {
+		if options.DeadLetterTopic == "" {
+			n.config.messageFailed(ctx, options, message, err, logrus.Fields{"subject": natsMsg.Subject, "redeliveries": count}, "Dropping NATS message")
 			return
 		}
-
-		lastErr = err
-		if retry < options.MaxRetries {
-			time.Sleep(options.RetryDelay)
+		if options.OnError != nil {
+			options.OnError(ctx, message, err)
 		}
+		n.republish(natsMsg, options.DeadLetterTopic, count)
+		return
 	}
 
-	// Failed after all retries
-	fmt.Printf("Failed to process NATS message after %d retries: %v\n", options.MaxRetries, lastErr)
+	n.republish(natsMsg, natsMsg.Subject, count+1)
+}
+
+// republish sends a copy of natsMsg to subject with the redelivery count header set
+func (n *natsBroker) republish(natsMsg *nats.Msg, subject string, count int) {
+	header := make(nats.Header, len(natsMsg.Header)+1)
+	for k, v := range natsMsg.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(HeaderRedeliveryCount, strconv.Itoa(count))
+
+	n.mutex.RLock()
+	conn := n.conn
+	n.mutex.RUnlock()
+	if conn == nil {
+		n.config.logError(errBrokerNotConnected, logrus.Fields{"subject": natsMsg.Subject}, "Failed to redeliver NATS message")
+		return
+	}
+
+	if err := conn.PublishMsg(&nats.Msg{Subject: subject, Data: natsMsg.Data, Header: header}); err != nil {
+		n.config.logError(err, logrus.Fields{"subject": subject}, "Failed to redeliver NATS message")
+	}
 }
 
 // Unsubscribe unsubscribes from the specified topic/queue
 func (n *natsBroker) Unsubscribe(ctx context.Context, topic string) error {
 	n.mutex.Lock()
-	defer n.mutex.Unlock()
-
 	subscription, exists := n.subscribers[topic]
 	if !exists {
+		n.mutex.Unlock()
 		return errSubscriptionNotFound
 	}
 
@@ -298,36 +545,88 @@ func (n *natsBroker) Unsubscribe(ctx context.Context, topic string) error {
 		subscription.cancel()
 	}
 
-	if subscription.subscription != nil {
-		err := subscription.subscription.Unsubscribe()
-		if err != nil {
-			return fmt.Errorf("failed to unsubscribe from topic %s: %w", topic, err)
-		}
+	if err := subscription.unsubscribe(); err != nil {
+		n.mutex.Unlock()
+		return fmt.Errorf("failed to unsubscribe from topic %s: %w", topic, err)
 	}
 
 	delete(n.subscribers, topic)
+	n.mutex.Unlock()
+
+	notifySubscription(subscription.options.OnStop, subscription.event())
+	return nil
+}
+
+// PauseSubscription stops dispatching messages for the topic while keeping the NATS subscription open
+func (n *natsBroker) PauseSubscription(topic string) error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	subscription, exists := n.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	subscription.gate.Pause()
 	return nil
 }
 
-// CreateTopic creates a new topic/queue (NATS doesn't require explicit topic creation)
+// ResumeSubscription restarts dispatching messages for a paused topic
+func (n *natsBroker) ResumeSubscription(topic string) error {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	subscription, exists := n.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	subscription.gate.Resume()
+	return nil
+}
+
+// Pause stops invoking the topic's handler until Resume; see PauseSubscription
+func (n *natsBroker) Pause(ctx context.Context, topic string) error {
+	return changePause(ctx, n.PauseSubscription, topic)
+}
+
+// Resume restarts delivery to a paused topic; see ResumeSubscription
+func (n *natsBroker) Resume(ctx context.Context, topic string) error {
+	return changePause(ctx, n.ResumeSubscription, topic)
+}
+
+// CreateTopic creates a file-backed stream for the topic in JetStream mode; core NATS
+// doesn't require explicit topic creation
 func (n *natsBroker) CreateTopic(ctx context.Context, topic string, options *TopicOptions) error {
+	if n.config.NATSJetStream {
+		return n.createStream(ctx, topic)
+	}
+
 	// NATS doesn't require explicit topic creation
 	// Topics are created dynamically when first published to
 	return nil
 }
 
-// DeleteTopic deletes a topic/queue (NATS doesn't support topic deletion)
+// DeleteTopic deletes the topic's stream in JetStream mode (core NATS doesn't support topic deletion)
 func (n *natsBroker) DeleteTopic(ctx context.Context, topic string) error {
+	if n.config.NATSJetStream {
+		return n.deleteStream(ctx, topic)
+	}
+
 	// NATS doesn't support explicit topic deletion
 	// Topics are automatically cleaned up when no longer used
 	return nil
 }
 
-// ListTopics returns a list of available topics/queues
+// ListTopics returns the subjects of all streams in JetStream mode; core NATS cannot list subjects
 func (n *natsBroker) ListTopics(ctx context.Context) ([]string, error) {
+	if n.config.NATSJetStream {
+		return n.listStreams(ctx)
+	}
+
 	// NATS doesn't provide a direct way to list all subjects
 	// This would require using NATS monitoring or keeping track manually
-	return nil, errors.New("listing topics not supported in NATS")
+	return nil, fmt.Errorf("listing topics in NATS: %w", errNotSupported)
 }
 
 // Ping checks if NATS is accessible
@@ -346,20 +645,7 @@ func (n *natsBroker) Ping(ctx context.Context) error {
 func (n *natsBroker) PublishBatch(ctx context.Context, messages []BatchMessage, options *PublishOptions) error {
 	// NATS doesn't have built-in batch publishing, so we publish one by one
 	for _, msg := range messages {
-		publishOptions := &PublishOptions{}
-		if options != nil {
-			*publishOptions = *options
-		}
-		if publishOptions.Headers == nil {
-			publishOptions.Headers = make(map[string]string)
-		}
-
-		// Merge message headers with global options
-		for k, v := range msg.Headers {
-			publishOptions.Headers[k] = v
-		}
-
-		err := n.Publish(ctx, msg.Topic, msg.Data, publishOptions)
+		err := n.Publish(ctx, msg.Topic, msg.Data, batchMessageOptions(options, msg.Headers))
 		if err != nil {
 			return fmt.Errorf("failed to publish batch message to topic %s: %w", msg.Topic, err)
 		}
@@ -376,6 +662,7 @@ func (n *natsBroker) GetStats(ctx context.Context) (*BrokerStats, error) {
 			"subscribers": len(n.subscribers),
 		},
 	}
+	n.counters.apply(stats)
 
 	if n.conn != nil {
 		natsStats := n.conn.Stats()
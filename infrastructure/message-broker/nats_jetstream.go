@@ -0,0 +1,202 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// natsJetStreamBatchSize is the fetch size used for subscriptions in JetStream mode that
+	// do not set PullBatchSize
+	natsJetStreamBatchSize = 10
+	// natsJetStreamTimeout bounds JetStream API calls made with a context that has no deadline
+	natsJetStreamTimeout = 5 * time.Second
+)
+
+// jetStream returns the broker's JetStream context, or a fresh one for pull subscriptions
+// on a core NATS broker
+func (n *natsBroker) jetStream() (nats.JetStreamContext, error) {
+	if n.js != nil {
+		return n.js, nil
+	}
+
+	js, err := n.conn.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+	return js, nil
+}
+
+// ensureStream creates a file-backed stream named after topic unless one already captures it
+func ensureStream(ctx context.Context, js nats.JetStreamContext, topic string) error {
+	ctx, cancel := withJetStreamTimeout(ctx)
+	defer cancel()
+
+	if _, err := js.StreamNameBySubject(topic, nats.Context(ctx)); err != nil {
+		if !errors.Is(err, nats.ErrNoMatchingStream) {
+			return fmt.Errorf("failed to look up stream for %s: %w", topic, err)
+		}
+		if _, err := js.AddStream(&nats.StreamConfig{
+			Name:     jetStreamName(topic),
+			Subjects: []string{topic},
+			Storage:  nats.FileStorage,
+		}, nats.Context(ctx)); err != nil {
+			return fmt.Errorf("failed to create stream for %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// publishJetStream publishes msg and waits for the stream to store it. A subject no stream
+// captures gets one on first publish, so messages are kept even before anyone subscribes.
+func (n *natsBroker) publishJetStream(ctx context.Context, msg *nats.Msg) error {
+	ctx, cancel := withJetStreamTimeout(ctx)
+	defer cancel()
+
+	_, err := n.js.PublishMsg(msg, nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoStreamResponse) {
+		if err := ensureStream(ctx, n.js, msg.Subject); err != nil {
+			return err
+		}
+		_, err = n.js.PublishMsg(msg, nats.Context(ctx))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish to JetStream subject %s: %w", msg.Subject, err)
+	}
+	return nil
+}
+
+// publishJetStreamAsync hands msg to the JetStream async publisher and reports a failed
+// ack through BrokerConfig.OnPublishError. A subject without a stream is rejected by the
+// server; the stream is then created and the message published again synchronously.
+func (n *natsBroker) publishJetStreamAsync(msg *nats.Msg) error {
+	js := n.js
+	future, err := js.PublishMsgAsync(msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish to JetStream subject %s: %w", msg.Subject, err)
+	}
+
+	go func() {
+		select {
+		case <-future.Ok():
+			n.counters.recordPublished(msg.Subject)
+		case err := <-future.Err():
+			if errors.Is(err, nats.ErrNoResponders) {
+				ctx, cancel := withJetStreamTimeout(context.Background())
+				defer cancel()
+				if err = ensureStream(ctx, js, msg.Subject); err == nil {
+					_, err = js.PublishMsg(msg, nats.Context(ctx))
+				}
+			}
+			if err != nil {
+				n.config.publishFailed(msg.Subject, err)
+				return
+			}
+			n.counters.recordPublished(msg.Subject)
+		}
+	}()
+	return nil
+}
+
+// bindDurableConsumer creates the durable consumer for a JetStream-mode subscription ahead of
+// subscribing. A consumer the nats client creates itself is deleted on Unsubscribe, which
+// would throw away its position and pending acks whenever the broker disconnects.
+func bindDurableConsumer(js nats.JetStreamContext, topic, durable string, options *SubscribeOptions) (nats.SubOpt, error) {
+	stream, err := js.StreamNameBySubject(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stream for %s: %w", topic, err)
+	}
+
+	if _, err := js.ConsumerInfo(stream, durable); err != nil {
+		if !errors.Is(err, nats.ErrConsumerNotFound) {
+			return nil, fmt.Errorf("failed to look up consumer %s: %w", durable, err)
+		}
+
+		consumer := &nats.ConsumerConfig{
+			Durable:       durable,
+			FilterSubject: topic,
+			AckPolicy:     nats.AckExplicitPolicy,
+			AckWait:       options.AckWait,
+			MaxAckPending: options.MaxAckPending,
+		}
+		if _, err := js.AddConsumer(stream, consumer); err != nil {
+			return nil, fmt.Errorf("failed to create consumer %s: %w", durable, err)
+		}
+	}
+
+	return nats.Bind(stream, durable), nil
+}
+
+// createStream is CreateTopic in JetStream mode
+func (n *natsBroker) createStream(ctx context.Context, topic string) error {
+	js, err := n.connectedJetStream()
+	if err != nil {
+		return err
+	}
+	return ensureStream(ctx, js, topic)
+}
+
+// deleteStream is DeleteTopic in JetStream mode; it removes the stream capturing topic
+// together with its messages and consumers
+func (n *natsBroker) deleteStream(ctx context.Context, topic string) error {
+	js, err := n.connectedJetStream()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withJetStreamTimeout(ctx)
+	defer cancel()
+
+	stream, err := js.StreamNameBySubject(topic, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to look up stream for %s: %w", topic, err)
+	}
+	if err := js.DeleteStream(stream, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to delete stream %s: %w", stream, err)
+	}
+	return nil
+}
+
+// listStreams is ListTopics in JetStream mode; it returns the subjects captured by every
+// stream, which are the topics CreateTopic and Publish accept
+func (n *natsBroker) listStreams(ctx context.Context) ([]string, error) {
+	js, err := n.connectedJetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withJetStreamTimeout(ctx)
+	defer cancel()
+
+	var topics []string
+	for info := range js.StreamsInfo(nats.Context(ctx)) {
+		topics = append(topics, info.Config.Subjects...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list streams: %w", err)
+	}
+	return topics, nil
+}
+
+func (n *natsBroker) connectedJetStream() (nats.JetStreamContext, error) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	if !n.connected {
+		return nil, errBrokerNotConnected
+	}
+	return n.js, nil
+}
+
+// withJetStreamTimeout gives ctx a deadline when it has none, so a JetStream request cannot
+// wait forever on a server that never answers
+func withJetStreamTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, natsJetStreamTimeout)
+}
@@ -0,0 +1,145 @@
+package messagebroker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSJetStreamKeepsMessagesPublishedBeforeSubscribe(t *testing.T) {
+	broker, _ := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	require.NoError(t, broker.PublishJSON(ctx, "orders.created", map[string]string{"id": "order-1"}, nil))
+
+	received := make(chan *messagebroker.Message, 1)
+	err := broker.Subscribe(ctx, "orders.created", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message
+		return nil
+	}, &messagebroker.SubscribeOptions{QueueName: "billing", MaxRetries: 3})
+	require.NoError(t, err)
+
+	select {
+	case message := <-received:
+		assert.JSONEq(t, `{"id":"order-1"}`, string(message.Data))
+		assert.Equal(t, "application/json", message.Headers["Content-Type"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message published before subscribing was not delivered")
+	}
+}
+
+func TestNATSJetStreamRedeliversOnHandlerError(t *testing.T) {
+	broker, js := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	var attempts atomic.Int32
+	err := broker.Subscribe(ctx, "payments.capture", func(ctx context.Context, message *messagebroker.Message) error {
+		if attempts.Add(1) < 3 {
+			return messagebroker.Retryable(assert.AnError, 10*time.Millisecond)
+		}
+		return nil
+	}, &messagebroker.SubscribeOptions{QueueName: "capture", MaxRetries: 5})
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "payments.capture", []byte("payment"), nil))
+	require.Eventually(t, func() bool { return attempts.Load() == 3 }, 5*time.Second, 20*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("payments_capture", "capture")
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestNATSJetStreamDurableConsumerSurvivesReconnect(t *testing.T) {
+	broker, js := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	handler := func(ctx context.Context, message *messagebroker.Message) error { return nil }
+	options := &messagebroker.SubscribeOptions{QueueName: "auditors", MaxRetries: 3}
+	require.NoError(t, broker.Subscribe(ctx, "audit.log", handler, options))
+	require.NoError(t, broker.Disconnect(ctx))
+
+	// The consumer outlives the connection and holds what arrives while nobody is consuming
+	_, err := js.Publish("audit.log", []byte("entry"))
+	require.NoError(t, err)
+	info, err := js.ConsumerInfo("audit_log", "auditors")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.NumPending)
+
+	received := make(chan string, 1)
+	require.NoError(t, broker.Connect(ctx))
+	err = broker.Subscribe(ctx, "audit.log", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- string(message.Data)
+		return nil
+	}, options)
+	require.NoError(t, err)
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "entry", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message held by the durable consumer was not delivered after reconnecting")
+	}
+}
+
+func TestNATSJetStreamTopicsAreStreams(t *testing.T) {
+	broker, js := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	require.NoError(t, broker.CreateTopic(ctx, "inventory.adjusted", nil))
+	require.NoError(t, broker.CreateTopic(ctx, "inventory.adjusted", nil), "creating an existing topic is a no-op")
+
+	_, err := js.StreamInfo("inventory_adjusted")
+	require.NoError(t, err)
+
+	topics, err := broker.ListTopics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inventory.adjusted"}, topics)
+
+	require.NoError(t, broker.DeleteTopic(ctx, "inventory.adjusted"))
+	topics, err = broker.ListTopics(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestNATSJetStreamPublishAsyncCreatesStream(t *testing.T) {
+	failures := make(chan error, 1)
+	broker, js := newJetStreamBroker(t,
+		messagebroker.WithJetStream(),
+		messagebroker.WithPublishErrorHandler(func(topic string, err error) { failures <- err }),
+	)
+
+	require.NoError(t, broker.PublishAsync(context.Background(), "metrics.cpu", []byte("42"), nil))
+
+	require.Eventually(t, func() bool {
+		info, err := js.StreamInfo("metrics_cpu")
+		return err == nil && info.State.Msgs == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Empty(t, failures)
+}
+
+func TestNATSJetStreamManualAckRequeuesOnNack(t *testing.T) {
+	broker, js := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	var deliveries atomic.Int32
+	err := broker.Subscribe(ctx, "videos.encode", func(ctx context.Context, message *messagebroker.Message) error {
+		if deliveries.Add(1) == 1 {
+			return message.Nack(true)
+		}
+		return message.Ack()
+	}, &messagebroker.SubscribeOptions{QueueName: "encoders", ManualAck: true, MaxRetries: 5})
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "videos.encode", []byte("clip"), nil))
+
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo("videos_encode", "encoders")
+		return err == nil && deliveries.Load() == 2 && info.NumAckPending == 0 && info.AckFloor.Consumer == 2
+	}, 5*time.Second, 20*time.Millisecond)
+}
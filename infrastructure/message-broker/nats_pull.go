@@ -0,0 +1,158 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// natsPullFetchWait bounds a single fetch so idle workers notice cancellation promptly
+const natsPullFetchWait = 5 * time.Second
+
+// pullSubscribe creates a JetStream pull subscription for topic, creating a stream for the
+// subject when none captures it yet
+func (n *natsBroker) pullSubscribe(topic string, options *SubscribeOptions) (*nats.Subscription, error) {
+	js, err := n.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureStream(context.Background(), js, topic); err != nil {
+		return nil, err
+	}
+
+	durable := options.QueueName
+	if durable == "" {
+		durable = jetStreamName(topic)
+	}
+
+	if n.js != nil {
+		bind, err := bindDurableConsumer(js, topic, durable, options)
+		if err != nil {
+			return nil, err
+		}
+		return js.PullSubscribe(topic, durable, bind)
+	}
+
+	subOpts := []nats.SubOpt{nats.AckExplicit()}
+	if options.AckWait > 0 {
+		subOpts = append(subOpts, nats.AckWait(options.AckWait))
+	}
+	if options.MaxAckPending > 0 {
+		subOpts = append(subOpts, nats.MaxAckPending(options.MaxAckPending))
+	}
+
+	return js.PullSubscribe(topic, durable, subOpts...)
+}
+
+// startPullWorkers runs Concurrency fetch loops against the pull subscription
+func (n *natsBroker) startPullWorkers(ctx context.Context, sub *nats.Subscription, subscription *natsSubscription) {
+	workers := subscription.options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		go n.pullLoop(ctx, sub, subscription)
+	}
+}
+
+// pullLoop fetches up to PullBatchSize messages at a time and processes them in order.
+// Pausing the subscription simply stops fetching, so messages stay in the stream.
+func (n *natsBroker) pullLoop(ctx context.Context, sub *nats.Subscription, subscription *natsSubscription) {
+	for {
+		if err := subscription.gate.Wait(ctx); err != nil {
+			return
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, natsPullFetchWait)
+		messages, err := sub.Fetch(subscription.options.PullBatchSize, nats.Context(fetchCtx))
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			if errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConnectionClosed) {
+				return
+			}
+			n.config.logError(err, logrus.Fields{"subject": subscription.topic}, "Failed to fetch NATS messages")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for _, natsMsg := range messages {
+			// Disconnecting; hand the rest of the batch back to the stream
+			if !subscription.handling.begin() {
+				natsMsg.Nak()
+				continue
+			}
+			n.handlePullMessage(ctx, natsMsg, subscription)
+			subscription.handling.end()
+		}
+	}
+}
+
+// handlePullMessage invokes the handler once per delivery and leaves retries to JetStream:
+// success acks, a retryable failure naks (honouring RetryableError.Delay), and a permanent
+// failure or the last allowed delivery is dead-lettered and terminated.
+func (n *natsBroker) handlePullMessage(ctx context.Context, natsMsg *nats.Msg, subscription *natsSubscription) {
+	options := subscription.options
+	message := newNATSMessage(natsMsg)
+
+	if metadata, err := natsMsg.Metadata(); err == nil && metadata.NumDelivered > 0 {
+		message.Retry = int(metadata.NumDelivered - 1)
+	}
+	message.MaxRetries = options.MaxRetries
+	message.acknowledger = jetStreamAcknowledger{msg: natsMsg}
+
+	err := callHandler(ctx, subscription.handler, message, options)
+	if err == nil {
+		n.counters.recordConsumed(natsMsg.Subject)
+		if options.ManualAck {
+			return
+		}
+		if ackErr := natsMsg.Ack(); ackErr != nil {
+			n.config.logError(ackErr, logrus.Fields{"subject": natsMsg.Subject}, "Failed to ack NATS message")
+		}
+		return
+	}
+
+	if IsPermanent(err) || message.Retry >= options.MaxRetries {
+		n.config.messageFailed(ctx, options, message, err, logrus.Fields{"subject": natsMsg.Subject, "deliveries": message.Retry + 1}, "Failed to process NATS message")
+		if options.DeadLetterTopic != "" {
+			n.republish(natsMsg, options.DeadLetterTopic, message.Retry)
+		}
+		if !options.ManualAck {
+			_ = natsMsg.Term()
+		}
+		return
+	}
+
+	if options.ManualAck {
+		return
+	}
+
+	if delay := retryDelay(err, options); delay > 0 {
+		_ = natsMsg.NakWithDelay(delay)
+		return
+	}
+	_ = natsMsg.Nak()
+}
+
+// jetStreamName derives a stream or consumer name from a subject, which may not contain
+// the separators and wildcards allowed in subjects
+func jetStreamName(subject string) string {
+	return strings.NewReplacer(".", "_", "*", "ALL", ">", "REST").Replace(subject)
+}
@@ -0,0 +1,141 @@
+package messagebroker_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newJetStreamBroker runs an embedded JetStream-enabled server and returns a connected broker
+// plus a separate JetStream context for inspecting consumers
+func newJetStreamBroker(t *testing.T, brokerOpts ...messagebroker.BrokerOption) (messagebroker.MessageBroker, nats.JetStreamContext) {
+	if testing.Short() {
+		t.Skip("skipping JetStream integration test in short mode")
+	}
+
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	brokerOpts = append([]messagebroker.BrokerOption{messagebroker.WithNATSURL(srv.ClientURL())}, brokerOpts...)
+	broker, err := messagebroker.NewNATSBroker(messagebroker.NewBrokerConfig(brokerOpts...))
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { broker.Close() })
+
+	conn, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+
+	return broker, js
+}
+
+func TestNATSPullConsumerFetchesInBatches(t *testing.T) {
+	broker, js := newJetStreamBroker(t)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	err := broker.Subscribe(ctx, "jobs.resize", func(ctx context.Context, message *messagebroker.Message) error {
+		<-release
+		handled.Add(1)
+		return nil
+	}, &messagebroker.SubscribeOptions{
+		QueueName:     "resizers",
+		PullBatchSize: 2,
+		AckWait:       30 * time.Second,
+		Concurrency:   1,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, broker.Publish(ctx, "jobs.resize", []byte{byte(i)}, nil))
+	}
+
+	stream, err := js.StreamNameBySubject("jobs.resize")
+	require.NoError(t, err)
+
+	// While the handler is blocked, only one batch has been handed to the worker
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo(stream, "resizers")
+		return err == nil && info.NumAckPending == 2
+	}, 5*time.Second, 20*time.Millisecond)
+
+	info, err := js.ConsumerInfo(stream, "resizers")
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.NumAckPending)
+	assert.Equal(t, uint64(3), info.NumPending)
+
+	close(release)
+	require.Eventually(t, func() bool { return handled.Load() == 5 }, 5*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		info, err := js.ConsumerInfo(stream, "resizers")
+		return err == nil && info.NumAckPending == 0 && info.NumPending == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestNATSPullConsumerRedeliversAfterAckWait(t *testing.T) {
+	broker, _ := newJetStreamBroker(t)
+	ctx := context.Background()
+
+	deliveries := make(chan int, 10)
+	var calls atomic.Int32
+	err := broker.Subscribe(ctx, "reports.render", func(ctx context.Context, message *messagebroker.Message) error {
+		deliveries <- message.Retry
+		if calls.Add(1) == 1 {
+			// Outlive the ack wait so JetStream hands the message to another worker
+			time.Sleep(1500 * time.Millisecond)
+		}
+		return nil
+	}, &messagebroker.SubscribeOptions{
+		PullBatchSize: 1,
+		AckWait:       500 * time.Millisecond,
+		MaxRetries:    3,
+		Concurrency:   2,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "reports.render", []byte("report"), nil))
+
+	for want := 0; want < 2; want++ {
+		select {
+		case retry := <-deliveries:
+			assert.Equal(t, want, retry)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivery %d not received", want+1)
+		}
+	}
+}
+
+func TestNATSPullConsumerNaksFailedMessages(t *testing.T) {
+	broker, _ := newJetStreamBroker(t)
+	ctx := context.Background()
+
+	var attempts atomic.Int32
+	err := broker.Subscribe(ctx, "emails.send", func(ctx context.Context, message *messagebroker.Message) error {
+		if attempts.Add(1) < 3 {
+			return messagebroker.Retryable(assert.AnError, 10*time.Millisecond)
+		}
+		return nil
+	}, &messagebroker.SubscribeOptions{
+		PullBatchSize: 5,
+		MaxRetries:    3,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "emails.send", []byte("welcome"), nil))
+	require.Eventually(t, func() bool { return attempts.Load() == 3 }, 5*time.Second, 20*time.Millisecond)
+}
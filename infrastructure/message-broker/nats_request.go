@@ -0,0 +1,92 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Requester is implemented by brokers that support request-reply, currently NATS. A
+// subscriber answers a request by calling Reply with the message it received.
+type Requester interface {
+	// Request publishes data to subject and waits up to timeout, or until ctx is done, for
+	// the first reply. When nothing is subscribed to the subject the error wraps
+	// nats.ErrNoResponders; when no reply arrives in time it wraps
+	// context.DeadlineExceeded. In JetStream mode a subject captured by a stream is
+	// rejected with an error IsNotSupported matches, as the stream would answer with its
+	// publish acknowledgement instead of the responder's reply.
+	Request(ctx context.Context, subject string, data []byte, timeout time.Duration) (*Message, error)
+}
+
+// Request sends a core NATS request and returns the reply
+func (n *natsBroker) Request(ctx context.Context, subject string, data []byte, timeout time.Duration) (*Message, error) {
+	n.mutex.RLock()
+	conn, js, connected := n.conn, n.js, n.connected
+	n.mutex.RUnlock()
+
+	if !connected {
+		return nil, errBrokerNotConnected
+	}
+	if js != nil {
+		if err := rejectStreamSubject(ctx, js, subject); err != nil {
+			return nil, err
+		}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reply, err := conn.RequestMsgWithContext(ctx, newNATSOutgoing(subject, data, nil))
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return nil, fmt.Errorf("no responders for request on %s: %w", subject, err)
+		}
+		return nil, fmt.Errorf("request on %s failed: %w", subject, err)
+	}
+
+	n.counters.recordPublished(subject)
+	return newNATSMessage(reply), nil
+}
+
+// rejectStreamSubject fails when a JetStream stream captures subject. The server acks any
+// publish to such a subject, so a request sent there would get the ack as its reply.
+func rejectStreamSubject(ctx context.Context, js nats.JetStreamContext, subject string) error {
+	ctx, cancel := withJetStreamTimeout(ctx)
+	defer cancel()
+
+	stream, err := js.StreamNameBySubject(subject, nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoMatchingStream) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up stream for %s: %w", subject, err)
+	}
+	return fmt.Errorf("request on %s, which stream %s captures: %w", subject, stream, errNotSupported)
+}
+
+// Reply answers a request received by a NATS subscription handler with data. It fails for
+// messages that were not sent with Request, and for JetStream messages, whose reply
+// subject belongs to the acknowledgement protocol.
+func Reply(message *Message, data []byte) error {
+	natsMsg, ok := message.OriginalMessage.(*nats.Msg)
+	if !ok {
+		return fmt.Errorf("replying to message from %s: %w", message.Topic, errNotSupported)
+	}
+	if _, err := natsMsg.Metadata(); err == nil {
+		return fmt.Errorf("replying to JetStream message from %s: %w", message.Topic, errNotSupported)
+	}
+	if natsMsg.Reply == "" {
+		return fmt.Errorf("message from %s expects no reply", message.Topic)
+	}
+
+	if err := natsMsg.Respond(data); err != nil {
+		return fmt.Errorf("failed to reply to message from %s: %w", message.Topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package messagebroker_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNATSRequester(t *testing.T) (messagebroker.MessageBroker, messagebroker.Requester) {
+	broker := newNATSBroker(t)
+	requester, ok := broker.(messagebroker.Requester)
+	require.True(t, ok, "the NATS broker supports request-reply")
+	return broker, requester
+}
+
+func TestNATSRequestReceivesReply(t *testing.T) {
+	broker, requester := newNATSRequester(t)
+	ctx := context.Background()
+
+	err := broker.Subscribe(ctx, "text.upper", func(ctx context.Context, message *messagebroker.Message) error {
+		return messagebroker.Reply(message, bytes.ToUpper(message.Data))
+	}, nil)
+	require.NoError(t, err)
+
+	reply, err := requester.Request(ctx, "text.upper", []byte("hello"), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", string(reply.Data))
+}
+
+func TestNATSRequestWithoutResponders(t *testing.T) {
+	_, requester := newNATSRequester(t)
+
+	_, err := requester.Request(context.Background(), "nobody.home", []byte("?"), time.Second)
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+}
+
+func TestNATSRequestTimesOut(t *testing.T) {
+	broker, requester := newNATSRequester(t)
+	ctx := context.Background()
+
+	// Subscribed, so the request has a responder, but it never answers
+	require.NoError(t, broker.Subscribe(ctx, "slow.service", func(context.Context, *messagebroker.Message) error { return nil }, nil))
+
+	_, err := requester.Request(ctx, "slow.service", []byte("?"), 50*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNATSRequestRejectsStreamSubject(t *testing.T) {
+	broker, _ := newJetStreamBroker(t, messagebroker.WithJetStream())
+	ctx := context.Background()
+
+	// The publish creates a stream capturing the subject
+	require.NoError(t, broker.Publish(ctx, "inventory.check", []byte("stock"), nil))
+
+	requester := broker.(messagebroker.Requester)
+	reply, err := requester.Request(ctx, "inventory.check", []byte("?"), time.Second)
+	assert.Nil(t, reply)
+	assert.True(t, messagebroker.IsNotSupported(err), "got %v", err)
+}
+
+func TestReplyRejectsMessagesWithoutReplySubject(t *testing.T) {
+	err := messagebroker.Reply(&messagebroker.Message{Topic: "orders", OriginalMessage: &nats.Msg{Subject: "orders"}}, []byte("ok"))
+	assert.Error(t, err)
+
+	err = messagebroker.Reply(&messagebroker.Message{Topic: "orders"}, []byte("ok"))
+	assert.True(t, messagebroker.IsNotSupported(err))
+}
@@ -0,0 +1,145 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newNATSBroker(t *testing.T, brokerOpts ...messagebroker.BrokerOption) messagebroker.MessageBroker {
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	srv := natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	brokerOpts = append([]messagebroker.BrokerOption{messagebroker.WithNATSURL(srv.ClientURL())}, brokerOpts...)
+	broker, err := messagebroker.NewNATSBroker(messagebroker.NewBrokerConfig(brokerOpts...))
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+func TestNATSRedeliveryDeadLettersAfterMaxRetries(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	var attempts atomic.Int32
+	err := broker.Subscribe(ctx, "orders.created", func(ctx context.Context, message *messagebroker.Message) error {
+		attempts.Add(1)
+		return errors.New("handler failed")
+	}, &messagebroker.SubscribeOptions{
+		MaxRetries:      2,
+		Redeliver:       true,
+		DeadLetterTopic: "orders.created.dlq",
+	})
+	require.NoError(t, err)
+
+	deadLettered := make(chan *messagebroker.Message, 1)
+	err = broker.Subscribe(ctx, "orders.created.dlq", func(ctx context.Context, message *messagebroker.Message) error {
+		deadLettered <- message
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "orders.created", []byte(`{"id":1}`), nil))
+
+	select {
+	case message := <-deadLettered:
+		assert.Equal(t, `{"id":1}`, string(message.Data))
+		assert.Equal(t, "2", message.Headers[messagebroker.HeaderRedeliveryCount])
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+
+	// The original delivery plus two redeliveries, and nothing after the DLQ
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestNATSRedeliverySucceedsOnRetry(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	succeeded := make(chan int, 1)
+	err := broker.Subscribe(ctx, "orders.paid", func(ctx context.Context, message *messagebroker.Message) error {
+		if message.Retry == 0 {
+			return errors.New("transient failure")
+		}
+		succeeded <- message.Retry
+		return nil
+	}, &messagebroker.SubscribeOptions{MaxRetries: 3, Redeliver: true})
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "orders.paid", []byte("paid"), nil))
+
+	select {
+	case retry := <-succeeded:
+		assert.Equal(t, 1, retry)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not redelivered")
+	}
+}
+
+func TestNATSPublishBatchKeepsHeadersPerMessage(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	received := make(chan *messagebroker.Message, 2)
+	require.NoError(t, broker.Subscribe(ctx, "audit", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message
+		return nil
+	}, nil))
+
+	options := &messagebroker.PublishOptions{Headers: map[string]string{"X-Batch": "b1"}}
+	require.NoError(t, broker.PublishBatch(ctx, []messagebroker.BatchMessage{
+		{Topic: "audit", Data: []byte("first"), Headers: map[string]string{"X-First": "1"}},
+		{Topic: "audit", Data: []byte("second"), Headers: map[string]string{"X-Second": "2"}},
+	}, options))
+
+	byData := make(map[string]map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case message := <-received:
+			byData[string(message.Data)] = message.Headers
+		case <-time.After(5 * time.Second):
+			t.Fatal("batch message not received")
+		}
+	}
+
+	assert.Equal(t, map[string]string{"X-Batch": "b1", "X-First": "1"}, byData["first"])
+	assert.Equal(t, map[string]string{"X-Batch": "b1", "X-Second": "2"}, byData["second"])
+	assert.Equal(t, map[string]string{"X-Batch": "b1"}, options.Headers, "shared options must not be modified")
+}
+
+func TestNATSStatsCountPublishedAndConsumedMessages(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	require.NoError(t, broker.Subscribe(ctx, "clicks", func(ctx context.Context, message *messagebroker.Message) error {
+		return nil
+	}, nil))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, broker.Publish(ctx, "clicks", []byte("click"), nil))
+	}
+
+	var stats *messagebroker.BrokerStats
+	require.Eventually(t, func() bool {
+		var err error
+		stats, err = broker.GetStats(ctx)
+		return err == nil && stats.MessagesConsumed == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(3), stats.MessagesPublished)
+	assert.Equal(t, int64(3), stats.MessagesConsumed)
+	require.Len(t, stats.Topics, 1)
+	assert.Equal(t, messagebroker.TopicStats{Name: "clicks", MessagesPublished: 3, MessagesConsumed: 3}, stats.Topics[0])
+}
@@ -0,0 +1,257 @@
+package messagebroker
+
+import (
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// BrokerOption mutates a BrokerConfig. Options are an alternative to assembling
+// the struct by hand; the struct-based constructors remain available.
+type BrokerOption func(*BrokerConfig)
+
+// NewBrokerConfig builds a BrokerConfig from the given options
+func NewBrokerConfig(opts ...BrokerOption) *BrokerConfig {
+	config := &BrokerConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// NewMessageBrokerFactoryWithOptions creates a broker instance from functional options
+func NewMessageBrokerFactoryWithOptions(instance int, opts ...BrokerOption) (MessageBroker, error) {
+	return NewMessageBrokerFactory(instance, NewBrokerConfig(opts...))
+}
+
+// NewInMemoryBrokerWithOptions creates an in-memory broker from functional options
+func NewInMemoryBrokerWithOptions(opts ...BrokerOption) (MessageBroker, error) {
+	return NewInMemoryBroker(NewBrokerConfig(opts...))
+}
+
+// NewKafkaBrokerWithOptions creates a Kafka broker from functional options
+func NewKafkaBrokerWithOptions(opts ...BrokerOption) (MessageBroker, error) {
+	return NewKafkaBroker(NewBrokerConfig(opts...))
+}
+
+// NewNATSBrokerWithOptions creates a NATS broker from functional options
+func NewNATSBrokerWithOptions(opts ...BrokerOption) (MessageBroker, error) {
+	return NewNATSBroker(NewBrokerConfig(opts...))
+}
+
+// NewRabbitMQBrokerWithOptions creates a RabbitMQ broker from functional options
+func NewRabbitMQBrokerWithOptions(opts ...BrokerOption) (MessageBroker, error) {
+	return NewRabbitMQBroker(NewBrokerConfig(opts...))
+}
+
+// WithBrokers sets the Kafka broker addresses
+func WithBrokers(brokers ...string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaBrokers = append([]string(nil), brokers...)
+	}
+}
+
+// WithIdempotentProducer makes the Kafka producer idempotent so retries cannot duplicate messages
+func WithIdempotentProducer() BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaIdempotentProducer = true
+	}
+}
+
+// WithRequiredAcks sets the Kafka acknowledgement level: KafkaAcksAll, KafkaAcksLeader or KafkaAcksNone
+func WithRequiredAcks(acks string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaRequiredAcks = acks
+	}
+}
+
+// WithKafkaVersion sets the Kafka protocol version, such as "3.6.0"
+func WithKafkaVersion(version string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaVersion = version
+	}
+}
+
+// WithKafkaSecurityProtocol sets the Kafka security protocol: KafkaProtocolPlaintext,
+// KafkaProtocolSSL, KafkaProtocolSASLPlaintext or KafkaProtocolSASLSSL
+func WithKafkaSecurityProtocol(protocol string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaSecurityProtocol = protocol
+	}
+}
+
+// WithKafkaOAuthBearer authenticates to Kafka with SASL OAUTHBEARER tokens from provider
+func WithKafkaOAuthBearer(provider sarama.AccessTokenProvider) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaSASLMechanism = KafkaSASLOAuthBearer
+		c.KafkaTokenProvider = provider
+	}
+}
+
+// WithConsumerGroup sets the Kafka consumer group
+func WithConsumerGroup(group string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.KafkaConsumerGroup = group
+	}
+}
+
+// WithNATSURL sets the NATS server URL
+func WithNATSURL(url string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.NATSURL = url
+	}
+}
+
+// WithNATSServers sets the NATS cluster server list
+func WithNATSServers(servers ...string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.NATSServers = append([]string(nil), servers...)
+	}
+}
+
+// WithJetStream runs the NATS broker on JetStream for durable, acknowledged messaging
+func WithJetStream() BrokerOption {
+	return func(c *BrokerConfig) {
+		c.NATSJetStream = true
+	}
+}
+
+// WithRabbitMQ sets the RabbitMQ URL and exchange
+func WithRabbitMQ(url, exchange string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.RabbitMQURL = url
+		c.RabbitMQExchange = exchange
+	}
+}
+
+// WithRabbitMQExchangeType declares the RabbitMQ exchange as "topic", "direct", "fanout"
+// or "headers"
+func WithRabbitMQExchangeType(kind string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.RabbitMQExchangeType = kind
+	}
+}
+
+// WithRabbitMQDelayedExchange routes delayed publishes through an x-delayed-message exchange
+func WithRabbitMQDelayedExchange(name string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.RabbitMQDelayedExchange = name
+	}
+}
+
+// WithRabbitMQManagement sets the RabbitMQ management API URL and its credentials
+func WithRabbitMQManagement(url, username, password string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.RabbitMQManagementURL = url
+		c.RabbitMQManagementUsername = username
+		c.RabbitMQManagementPassword = password
+	}
+}
+
+// WithCredentials sets the username and password used to authenticate
+func WithCredentials(username, password string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.Username = username
+		c.Password = password
+	}
+}
+
+// WithTLSFromFiles enables TLS using PEM files; empty paths are left unset
+func WithTLSFromFiles(certFile, keyFile, caFile string) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.TLSEnabled = true
+		c.TLSCertFile = certFile
+		c.TLSKeyFile = keyFile
+		c.TLSCAFile = caFile
+	}
+}
+
+// WithTimeout sets the connection timeout
+func WithTimeout(timeout time.Duration) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.Timeout = timeout
+	}
+}
+
+// WithReconnect sets the reconnect attempts and the wait between them
+func WithReconnect(maxReconnects int, wait time.Duration) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.MaxReconnects = maxReconnects
+		c.ReconnectWait = wait
+	}
+}
+
+// WithRetention keeps the last n published messages per topic on the in-memory broker
+func WithRetention(n int) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.InMemoryRetention = n
+	}
+}
+
+// WithLogger sets the logger used for errors raised outside of a caller's request,
+// such as handler failures in subscription goroutines
+func WithLogger(log *logrus.Logger) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.Logger = log
+	}
+}
+
+// WithLogSampling collapses repeats of the same background error within interval into a
+// single summary entry
+func WithLogSampling(interval time.Duration) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.LogSampleInterval = interval
+	}
+}
+
+// WithPublishErrorHandler sets the callback for failed background deliveries
+func WithPublishErrorHandler(handler func(topic string, err error)) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.OnPublishError = handler
+	}
+}
+
+// WithSerializer sets the Serializer PublishJSON encodes messages with
+func WithSerializer(serializer Serializer) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.Serializer = serializer
+	}
+}
+
+// WithTracePropagation carries W3C trace context (traceparent and tracestate headers) from
+// publishers to subscription handlers
+func WithTracePropagation() BrokerOption {
+	return func(c *BrokerConfig) {
+		c.TracePropagator = propagation.TraceContext{}
+	}
+}
+
+// WithReconnectHandler registers a callback for every attempt to restore a lost connection
+func WithReconnectHandler(handler func(broker BrokerType, attempt int, err error)) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.OnReconnect = handler
+	}
+}
+
+// WithDisconnectTimeout bounds how long Disconnect waits for in-flight messages
+func WithDisconnectTimeout(timeout time.Duration) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.DisconnectTimeout = timeout
+	}
+}
+
+// WithMetrics reports publish and handler latencies and errors to collector
+func WithMetrics(collector MetricsCollector) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.Metrics = collector
+	}
+}
+
+// WithPublishInterceptors appends interceptors to the publish path
+func WithPublishInterceptors(interceptors ...PublishInterceptor) BrokerOption {
+	return func(c *BrokerConfig) {
+		c.PublishInterceptors = append(c.PublishInterceptors, interceptors...)
+	}
+}
@@ -0,0 +1,66 @@
+package messagebroker_test
+
+import (
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerOptionsMutateConfig(t *testing.T) {
+	log := logrus.New()
+
+	config := messagebroker.NewBrokerConfig(
+		messagebroker.WithBrokers("kafka-1:9092", "kafka-2:9092"),
+		messagebroker.WithConsumerGroup("orders"),
+		messagebroker.WithNATSURL("nats://localhost:4222"),
+		messagebroker.WithNATSServers("nats://a:4222", "nats://b:4222"),
+		messagebroker.WithRabbitMQ("amqp://localhost:5672", "events"),
+		messagebroker.WithCredentials("user", "secret"),
+		messagebroker.WithTLSFromFiles("client.crt", "client.key", "ca.crt"),
+		messagebroker.WithTimeout(5*time.Second),
+		messagebroker.WithReconnect(10, 2*time.Second),
+		messagebroker.WithLogger(log),
+	)
+
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, config.KafkaBrokers)
+	assert.Equal(t, "orders", config.KafkaConsumerGroup)
+	assert.Equal(t, "nats://localhost:4222", config.NATSURL)
+	assert.Equal(t, []string{"nats://a:4222", "nats://b:4222"}, config.NATSServers)
+	assert.Equal(t, "amqp://localhost:5672", config.RabbitMQURL)
+	assert.Equal(t, "events", config.RabbitMQExchange)
+	assert.Equal(t, "user", config.Username)
+	assert.Equal(t, "secret", config.Password)
+	assert.True(t, config.TLSEnabled)
+	assert.Equal(t, "client.crt", config.TLSCertFile)
+	assert.Equal(t, "client.key", config.TLSKeyFile)
+	assert.Equal(t, "ca.crt", config.TLSCAFile)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, 10, config.MaxReconnects)
+	assert.Equal(t, 2*time.Second, config.ReconnectWait)
+	assert.Same(t, log, config.Logger)
+}
+
+func TestBrokerOptionsConstructors(t *testing.T) {
+	broker, err := messagebroker.NewKafkaBrokerWithOptions(messagebroker.WithBrokers("localhost:9092"))
+	require.NoError(t, err)
+	assert.NotNil(t, broker)
+
+	broker, err = messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceNATS, messagebroker.WithNATSURL("nats://localhost:4222"))
+	require.NoError(t, err)
+	assert.NotNil(t, broker)
+}
+
+func TestBrokerOptionsStillValidateRequiredFields(t *testing.T) {
+	_, err := messagebroker.NewKafkaBrokerWithOptions(messagebroker.WithConsumerGroup("orders"))
+	assert.Error(t, err)
+
+	_, err = messagebroker.NewNATSBrokerWithOptions(messagebroker.WithLogger(logrus.New()))
+	assert.Error(t, err)
+
+	_, err = messagebroker.NewRabbitMQBrokerWithOptions()
+	assert.Error(t, err)
+}
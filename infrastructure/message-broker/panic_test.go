@@ -0,0 +1,143 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	Customer *struct{ Name string }
+}
+
+// panickingHandler dereferences a missing field on "bad" messages, as a handler might on a
+// malformed payload, and records the good ones
+func panickingHandler(attempts *atomic.Int32, handled chan<- string) messagebroker.MessageHandler {
+	return func(ctx context.Context, message *messagebroker.Message) error {
+		if string(message.Data) == "bad" {
+			attempts.Add(1)
+			var o order
+			_ = o.Customer.Name
+		}
+		handled <- string(message.Data)
+		return nil
+	}
+}
+
+func TestInMemoryHandlerPanicIsRetriedAndLogged(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger))
+
+	var attempts atomic.Int32
+	handled := make(chan string, 1)
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", panickingHandler(&attempts, handled), &messagebroker.SubscribeOptions{
+		MaxRetries:  2,
+		RetryDelay:  time.Millisecond,
+		Concurrency: 1,
+	}))
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("bad"), nil))
+	assert.Equal(t, int32(3), attempts.Load())
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	var panicked *messagebroker.PanicError
+	require.ErrorAs(t, entry.Data["error"].(error), &panicked)
+	assert.Contains(t, entry.Data["stack"], "panickingHandler")
+
+	// The subscription keeps consuming
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("good"), nil))
+	assert.Equal(t, "good", <-handled)
+}
+
+func TestInMemoryHandlerPanicWithTimeoutIsRecovered(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	broker := newInMemoryBroker(t, messagebroker.WithLogger(logger))
+
+	var attempts atomic.Int32
+	handled := make(chan string, 1)
+	require.NoError(t, broker.Subscribe(context.Background(), "orders", panickingHandler(&attempts, handled), &messagebroker.SubscribeOptions{
+		RetryDelay:     time.Millisecond,
+		Concurrency:    1,
+		HandlerTimeout: time.Second,
+	}))
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("bad"), nil))
+	assert.Equal(t, int32(1), attempts.Load())
+
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	var panicked *messagebroker.PanicError
+	require.ErrorAs(t, entry.Data["error"].(error), &panicked)
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("good"), nil))
+	assert.Equal(t, "good", <-handled)
+}
+
+func TestNATSHandlerPanicIsDeadLettered(t *testing.T) {
+	for name, options := range map[string]*messagebroker.SubscribeOptions{
+		"in-consumer retries": {MaxRetries: 1, RetryDelay: time.Millisecond, DeadLetterTopic: "orders.dlq"},
+		"redelivery":          {MaxRetries: 1, Redeliver: true, DeadLetterTopic: "orders.dlq"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			broker := newNATSBroker(t)
+			ctx := context.Background()
+
+			var attempts atomic.Int32
+			handled := make(chan string, 1)
+			require.NoError(t, broker.Subscribe(ctx, "orders", panickingHandler(&attempts, handled), options))
+
+			deadLettered := make(chan string, 1)
+			require.NoError(t, broker.Subscribe(ctx, "orders.dlq", func(ctx context.Context, message *messagebroker.Message) error {
+				deadLettered <- string(message.Data)
+				return nil
+			}, nil))
+
+			require.NoError(t, broker.Publish(ctx, "orders", []byte("bad"), nil))
+			select {
+			case data := <-deadLettered:
+				assert.Equal(t, "bad", data)
+			case <-time.After(5 * time.Second):
+				t.Fatal("panicking message was not dead-lettered")
+			}
+			assert.Equal(t, int32(2), attempts.Load())
+
+			require.NoError(t, broker.Publish(ctx, "orders", []byte("good"), nil))
+			select {
+			case data := <-handled:
+				assert.Equal(t, "good", data)
+			case <-time.After(5 * time.Second):
+				t.Fatal("subscription stopped after the panic")
+			}
+		})
+	}
+}
+
+func TestRetryQueueRoutesHandlerPanics(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(10))
+	queue, err := messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 2})
+	require.NoError(t, err)
+
+	var attempts atomic.Int32
+	handled := make(chan string, 1)
+	require.NoError(t, queue.Subscribe(context.Background(), "orders", panickingHandler(&attempts, handled), nil))
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("bad"), nil))
+
+	require.Eventually(t, func() bool { return len(broker.History("orders.dlq")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Len(t, broker.History("orders.retry"), 1)
+}
+
+func TestPanicErrorIsRetryable(t *testing.T) {
+	err := error(&messagebroker.PanicError{Value: errors.New("nil map")})
+	assert.True(t, messagebroker.IsRetryable(err))
+	assert.Equal(t, "handler panicked: nil map", err.Error())
+}
@@ -0,0 +1,85 @@
+package messagebroker
+
+import (
+	"context"
+	"sync"
+)
+
+// SubscriptionPauser stops and restarts delivery to a subscription's handler while the
+// underlying consumer stays connected. Both calls are idempotent and fail for a topic with
+// no subscription. MessageBroker.Pause and Resume do the same but give up if their context
+// is already done.
+type SubscriptionPauser interface {
+	// PauseSubscription stops invoking the handler for the topic until resumed
+	PauseSubscription(topic string) error
+
+	// ResumeSubscription restarts delivery for a paused topic
+	ResumeSubscription(topic string) error
+}
+
+// changePause applies a pause or resume unless ctx is already done, so a caller that has
+// given up does not change the subscription's state
+func changePause(ctx context.Context, change func(topic string) error, topic string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return change(topic)
+}
+
+// pauseGate tracks the paused state of a subscription and lets dispatch wait for a resume
+type pauseGate struct {
+	mutex   sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+// Pause reports whether the gate was running before the call
+func (g *pauseGate) Pause() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume reports whether the gate was paused before the call
+func (g *pauseGate) Resume() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resumed)
+	return true
+}
+
+func (g *pauseGate) IsPaused() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.paused
+}
+
+// Wait blocks while the gate is paused; it returns the context error if ctx ends first
+func (g *pauseGate) Wait(ctx context.Context) error {
+	g.mutex.Lock()
+	if !g.paused {
+		g.mutex.Unlock()
+		return nil
+	}
+	resumed := g.resumed
+	g.mutex.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
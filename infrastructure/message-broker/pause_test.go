@@ -0,0 +1,67 @@
+package messagebroker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSPauseAndResumeSubscription(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	received := make(chan string, 10)
+	require.NoError(t, broker.Subscribe(ctx, "inventory", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- string(message.Data)
+		return nil
+	}, nil))
+
+	pauser, ok := broker.(messagebroker.SubscriptionPauser)
+	require.True(t, ok)
+	require.NoError(t, pauser.PauseSubscription("inventory"))
+
+	require.NoError(t, broker.Publish(ctx, "inventory", []byte("while-paused"), nil))
+	select {
+	case data := <-received:
+		t.Fatalf("handler invoked while paused with %q", data)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, pauser.ResumeSubscription("inventory"))
+	require.NoError(t, broker.Publish(ctx, "inventory", []byte("after-resume"), nil))
+
+	select {
+	case data := <-received:
+		assert.Equal(t, "after-resume", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered after resume")
+	}
+
+	assert.Error(t, pauser.PauseSubscription("unknown"))
+}
+
+func TestPauseAndResumeThroughBrokerInterface(t *testing.T) {
+	var broker messagebroker.MessageBroker = newInMemoryBroker(t)
+	ctx := context.Background()
+
+	var received []string
+	require.NoError(t, broker.Subscribe(ctx, "billing", collect(&received), nil))
+
+	require.NoError(t, broker.Pause(ctx, "billing"))
+	require.NoError(t, broker.Publish(ctx, "billing", []byte("dropped"), nil))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, broker.Resume(canceled, "billing"), context.Canceled)
+	require.NoError(t, broker.Publish(ctx, "billing", []byte("still-paused"), nil))
+
+	require.NoError(t, broker.Resume(ctx, "billing"))
+	require.NoError(t, broker.Publish(ctx, "billing", []byte("delivered"), nil))
+
+	assert.Equal(t, []string{"delivered"}, received, "nothing published while paused is buffered")
+	assert.Error(t, broker.Pause(ctx, "unknown"))
+}
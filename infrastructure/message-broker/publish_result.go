@@ -0,0 +1,52 @@
+package messagebroker
+
+import (
+	"context"
+	"time"
+)
+
+// PublishResult describes where the broker stored a published message
+type PublishResult struct {
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Timestamp time.Time `json:"timestamp"`
+	// Supported is false when the broker does not report where messages are stored, in
+	// which case the other fields are zero
+	Supported bool `json:"supported"`
+}
+
+// ResultPublisher is implemented by brokers that report where each published message was
+// stored, such as the Kafka partition and offset
+type ResultPublisher interface {
+	// PublishWithResult publishes like Publish and returns where the message was stored
+	PublishWithResult(ctx context.Context, topic string, message []byte, options *PublishOptions) (*PublishResult, error)
+}
+
+// PublishWithResult publishes message and returns where the broker stored it. Brokers that
+// are not a ResultPublisher publish as usual and return a result with Supported unset.
+func PublishWithResult(ctx context.Context, broker MessageBroker, topic string, message []byte, options *PublishOptions) (*PublishResult, error) {
+	if publisher, ok := broker.(ResultPublisher); ok {
+		return publisher.PublishWithResult(ctx, topic, message, options)
+	}
+
+	if err := broker.Publish(ctx, topic, message, options); err != nil {
+		return nil, err
+	}
+	return &PublishResult{}, nil
+}
+
+type publishResultKey struct{}
+
+// withPublishResult returns a context asking the publish at the end of the interceptor
+// chain to fill in result
+func withPublishResult(ctx context.Context, result *PublishResult) context.Context {
+	return context.WithValue(ctx, publishResultKey{}, result)
+}
+
+// recordPublishResult stores where a message landed in the result requested by
+// withPublishResult, if any
+func recordPublishResult(ctx context.Context, result PublishResult) {
+	if requested, ok := ctx.Value(publishResultKey{}).(*PublishResult); ok {
+		*requested = result
+	}
+}
@@ -2,13 +2,16 @@ package messagebroker
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
 )
 
 type rabbitMQBroker struct {
@@ -18,16 +21,30 @@ type rabbitMQBroker struct {
 	subscribers map[string]*rabbitMQSubscription
 	mutex       sync.RWMutex
 	connected   bool
+	counters    messageCounters
+	management  *rabbitMQManagement // nil without a management API URL
+
+	// stopSupervisor is closed by Disconnect so a lost connection is not restored
+	stopSupervisor chan struct{}
+
+	// confirmChannel carries publishes with PublishOptions.Confirm, one at a time
+	confirmChannel *amqp.Channel
+	returns        chan amqp.Return
+	confirmMutex   sync.Mutex
 }
 
 type rabbitMQSubscription struct {
 	channel  *amqp.Channel
+	topic    string   // Key in subscribers: the topic, or the SubscribeMany handle
+	topics   []string // Routing keys the queue is bound to
 	queue    string
 	consumer string
 	handler  MessageHandler
 	options  *SubscribeOptions
+	ctx      context.Context
 	cancel   context.CancelFunc
-	done     chan bool
+	handling handling
+	gate     pauseGate
 }
 
 // NewRabbitMQBroker creates a new RabbitMQ-based message broker
@@ -40,9 +57,14 @@ func NewRabbitMQBroker(config *BrokerConfig) (MessageBroker, error) {
 		return nil, errors.New("RabbitMQ URL is required")
 	}
 
+	if _, err := rabbitMQExchangeType(config); err != nil {
+		return nil, err
+	}
+
 	return &rabbitMQBroker{
 		config:      config,
 		subscribers: make(map[string]*rabbitMQSubscription),
+		management:  newRabbitMQManagement(config),
 	}, nil
 }
 
@@ -51,8 +73,36 @@ func (r *rabbitMQBroker) Connect(ctx context.Context) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if err := r.dial(); err != nil {
+		return err
+	}
+
+	r.stopSupervisor = make(chan struct{})
+	go r.superviseConnection(r.conn.NotifyClose(make(chan *amqp.Error, 1)), r.stopSupervisor)
+
+	r.connected = true
+	return nil
+}
+
+// rabbitMQDialConfig returns the connection settings amqp.Dial would use, connecting to
+// RabbitMQVHost when the URL does not name a vhost itself
+func rabbitMQDialConfig(config *BrokerConfig) amqp.Config {
+	dialConfig := amqp.Config{Locale: "en_US"}
+	if config.RabbitMQVHost == "" {
+		return dialConfig
+	}
+
+	// The path is checked escaped so that "/%2F", the default vhost, still counts as set
+	if parsed, err := url.Parse(config.RabbitMQURL); err == nil && strings.Trim(parsed.EscapedPath(), "/") == "" {
+		dialConfig.Vhost = config.RabbitMQVHost
+	}
+	return dialConfig
+}
+
+// dial opens the connection and the publishing channel and declares the exchanges
+func (r *rabbitMQBroker) dial() error {
 	var err error
-	r.conn, err = amqp.Dial(r.config.RabbitMQURL)
+	r.conn, err = amqp.DialConfig(r.config.RabbitMQURL, rabbitMQDialConfig(r.config))
 	if err != nil {
 		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
@@ -60,14 +110,20 @@ func (r *rabbitMQBroker) Connect(ctx context.Context) error {
 	r.channel, err = r.conn.Channel()
 	if err != nil {
 		r.conn.Close()
+		r.conn = nil
 		return fmt.Errorf("failed to open RabbitMQ channel: %w", err)
 	}
 
 	// Declare exchange if specified
 	if r.config.RabbitMQExchange != "" {
+		kind, err := rabbitMQExchangeType(r.config)
+		if err != nil {
+			r.cleanup()
+			return err
+		}
 		err = r.channel.ExchangeDeclare(
 			r.config.RabbitMQExchange,
-			"topic",
+			kind,
 			true,  // durable
 			false, // autoDelete
 			false, // internal
@@ -80,17 +136,132 @@ func (r *rabbitMQBroker) Connect(ctx context.Context) error {
 		}
 	}
 
-	r.connected = true
+	if err := declareDelayedExchange(r.channel, r.config); err != nil {
+		r.cleanup()
+		return err
+	}
+
+	if err := r.openConfirmChannel(); err != nil {
+		r.cleanup()
+		return err
+	}
+
+	return nil
+}
+
+// superviseConnection waits for the connection behind closed to fail, then reconnects and
+// reopens every subscription, up to MaxReconnects attempts ReconnectWait apart. It does
+// nothing once stop is closed, which Disconnect does before closing the connection.
+func (r *rabbitMQBroker) superviseConnection(closed chan *amqp.Error, stop chan struct{}) {
+	for {
+		// A connection closed on purpose closes the channel without an error
+		cause, ok := <-closed
+		if !ok || cause == nil {
+			return
+		}
+
+		r.mutex.Lock()
+		if isClosed(stop) {
+			r.mutex.Unlock()
+			return
+		}
+		r.connected = false
+		subscriptions := len(r.subscribers)
+		r.mutex.Unlock()
+		r.config.logError(cause, logrus.Fields{"subscriptions": subscriptions}, "RabbitMQ connection lost, reconnecting")
+
+		closed = r.reconnect(stop)
+		if closed == nil {
+			return
+		}
+	}
+}
+
+// reconnect restores the connection and the subscriptions, returning the close
+// notifications of the new connection, or nil when Disconnect was called or every
+// attempt failed
+func (r *rabbitMQBroker) reconnect(stop chan struct{}) chan *amqp.Error {
+	attempts, wait := r.config.reconnectLimits()
+	var err error
+	for attempt := 1; attempts < 0 || attempt <= attempts; attempt++ {
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(wait):
+		}
+
+		r.mutex.Lock()
+		if isClosed(stop) {
+			r.mutex.Unlock()
+			return nil
+		}
+		var closed chan *amqp.Error
+		if err = r.restore(); err == nil {
+			closed = r.conn.NotifyClose(make(chan *amqp.Error, 1))
+			r.connected = true
+		}
+		r.mutex.Unlock()
+
+		r.config.reportReconnect(TypeRabbitMQ, attempt, err)
+		if err == nil {
+			return closed
+		}
+	}
+
+	r.config.logError(err, logrus.Fields{"attempts": attempts}, "Giving up reconnecting to RabbitMQ")
+	return nil
+}
+
+// restore replaces the lost connection and reopens every subscription on the new one.
+// The caller holds the mutex.
+func (r *rabbitMQBroker) restore() error {
+	r.cleanup()
+	if err := r.dial(); err != nil {
+		return err
+	}
+
+	for key, subscription := range r.subscribers {
+		if err := r.openSubscription(subscription); err != nil {
+			return fmt.Errorf("failed to restore subscription to %s: %w", key, err)
+		}
+	}
 	return nil
 }
 
+// isClosed reports whether stop has been closed
+func isClosed(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
 // Disconnect closes the RabbitMQ connection
 func (r *rabbitMQBroker) Disconnect(ctx context.Context) error {
 	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	if r.stopSupervisor != nil {
+		close(r.stopSupervisor)
+		r.stopSupervisor = nil
+	}
+	stopped := r.subscribers
+	r.subscribers = make(map[string]*rabbitMQSubscription)
 
-	// Stop all subscriptions
-	for _, sub := range r.subscribers {
+	// Stop deliveries, then let handlers finish their messages while the connection is
+	// still open, so they can be acked and handlers can publish
+	trackers := make([]*handling, 0, len(stopped))
+	for _, sub := range stopped {
+		if sub.channel != nil && !sub.gate.IsPaused() {
+			sub.channel.Cancel(sub.consumer, false)
+		}
+		trackers = append(trackers, &sub.handling)
+	}
+	r.mutex.Unlock()
+	r.config.awaitDrained(ctx, trackers)
+
+	r.mutex.Lock()
+	for _, sub := range stopped {
 		if sub.cancel != nil {
 			sub.cancel()
 		}
@@ -98,9 +269,14 @@ func (r *rabbitMQBroker) Disconnect(ctx context.Context) error {
 			sub.channel.Close()
 		}
 	}
-	r.subscribers = make(map[string]*rabbitMQSubscription)
 
-	return r.cleanup()
+	err := r.cleanup()
+	r.mutex.Unlock()
+
+	for _, sub := range stopped {
+		notifySubscription(sub.options.OnStop, sub.event())
+	}
+	return err
 }
 
 func (r *rabbitMQBroker) cleanup() error {
@@ -111,6 +287,11 @@ func (r *rabbitMQBroker) cleanup() error {
 		r.channel = nil
 	}
 
+	if r.confirmChannel != nil {
+		r.confirmChannel.Close()
+		r.confirmChannel = nil
+	}
+
 	if r.conn != nil {
 		err := r.conn.Close()
 		r.conn = nil
@@ -122,6 +303,16 @@ func (r *rabbitMQBroker) cleanup() error {
 
 // Publish sends a message to the specified topic/queue
 func (r *rabbitMQBroker) Publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return chainPublish(r.config.publishInterceptors(), r.config.observingPublish(r.publish))(ctx, topic, message, options)
+}
+
+// PublishAsync sends a message to the specified topic/queue. The channel does not wait
+// for the broker to confirm publishes, so this is the same as Publish.
+func (r *rabbitMQBroker) PublishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+	return r.Publish(ctx, topic, message, options)
+}
+
+func (r *rabbitMQBroker) publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
@@ -139,6 +330,8 @@ func (r *rabbitMQBroker) Publish(ctx context.Context, topic string, message []by
 		Body:         message,
 		Timestamp:    time.Now(),
 		DeliveryMode: 1, // non-persistent
+		// Kept on redelivery, so it serves as Message.ID for a Deduplicator
+		MessageId: uuid.NewString(),
 	}
 
 	if options.Persistent {
@@ -161,18 +354,19 @@ func (r *rabbitMQBroker) Publish(ctx context.Context, topic string, message []by
 	}
 
 	// Publish to exchange or directly to queue
-	var err error
-	if r.config.RabbitMQExchange != "" {
-		err = r.channel.Publish(
-			r.config.RabbitMQExchange,
-			topic, // routing key
-			false, // mandatory
-			false, // immediate
-			publishing,
-		)
-	} else {
+	exchange, key := r.config.RabbitMQExchange, topic
+	// The delayed-message exchange returns every mandatory message, routable or not
+	mandatory := true
+	if options.Delay > 0 {
+		var err error
+		exchange, key, err = routeDelayed(r.channel, r.config, topic, options.Delay, &publishing)
+		if err != nil {
+			return err
+		}
+		mandatory = exchange != r.config.RabbitMQDelayedExchange
+	} else if exchange == "" {
 		// Ensure queue exists before publishing
-		_, err = r.channel.QueueDeclare(
+		_, err := r.channel.QueueDeclare(
 			topic,
 			true,  // durable
 			false, // autoDelete
@@ -183,10 +377,15 @@ func (r *rabbitMQBroker) Publish(ctx context.Context, topic string, message []by
 		if err != nil {
 			return fmt.Errorf("failed to declare queue: %w", err)
 		}
+	}
 
+	var err error
+	if options.Confirm {
+		err = r.publishConfirmed(ctx, exchange, key, mandatory, publishing)
+	} else {
 		err = r.channel.Publish(
-			"",    // exchange
-			topic, // routing key (queue name)
+			exchange,
+			key,   // routing key, the queue name without an exchange
 			false, // mandatory
 			false, // immediate
 			publishing,
@@ -197,21 +396,16 @@ func (r *rabbitMQBroker) Publish(ctx context.Context, topic string, message []by
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
+	r.counters.recordPublished(topic)
 	return nil
 }
 
-// PublishJSON sends a JSON-encoded message to the specified topic/queue
+// PublishJSON sends a message encoded by the configured Serializer, JSON by default, to
+// the specified topic/queue
 func (r *rabbitMQBroker) PublishJSON(ctx context.Context, topic string, message interface{}, options *PublishOptions) error {
-	data, err := json.Marshal(message)
+	data, options, err := serializeForPublish(r.config, message, options)
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
-
-	if options == nil {
-		options = &PublishOptions{}
-	}
-	if options.ContentType == "" {
-		options.ContentType = "application/json"
+		return err
 	}
 
 	return r.Publish(ctx, topic, data, options)
@@ -219,11 +413,45 @@ func (r *rabbitMQBroker) PublishJSON(ctx context.Context, topic string, message
 
 // Subscribe subscribes to messages from the specified topic/queue
 func (r *rabbitMQBroker) Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error {
+	subscription, err := r.subscribe(ctx, topic, []string{topic}, handler, options)
+	if err != nil {
+		return err
+	}
+
+	notifySubscription(subscription.options.OnStart, subscription.event())
+	return nil
+}
+
+// SubscribeMany binds every topic as a routing key to one queue consumed by a single
+// consumer. It needs RabbitMQExchange, since the default exchange routes by queue name.
+// The queue is named after the returned handle unless QueueName is set.
+func (r *rabbitMQBroker) SubscribeMany(ctx context.Context, topics []string, handler MessageHandler, options *SubscribeOptions) (string, error) {
+	if err := validateTopics(topics); err != nil {
+		return "", err
+	}
+	if len(topics) > 1 && r.config.RabbitMQExchange == "" {
+		return "", errors.New("subscribing to several topics requires a RabbitMQ exchange")
+	}
+
+	handle := SubscriptionHandle(topics)
+	subscription, err := r.subscribe(ctx, handle, topics, handler, options)
+	if err != nil {
+		return "", err
+	}
+
+	notifySubscription(subscription.options.OnStart, subscription.event())
+	return handle, nil
+}
+
+// subscribe declares a queue bound to each of topics and stores the subscription under key
+func (r *rabbitMQBroker) subscribe(ctx context.Context, key string, topics []string, handler MessageHandler, options *SubscribeOptions) (*rabbitMQSubscription, error) {
+	handler = r.config.extractingTraceContext(r.config.deduplicating(options, r.config.observingConsume(timingOut(options, handler))))
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	if !r.connected {
-		return errBrokerNotConnected
+		return nil, errBrokerNotConnected
 	}
 
 	if options == nil {
@@ -235,6 +463,39 @@ func (r *rabbitMQBroker) Subscribe(ctx context.Context, topic string, handler Me
 			PrefetchCount: 1,
 		}
 	}
+	if options.ManualAck && options.AutoAck {
+		return nil, errors.New("ManualAck requires AutoAck to be disabled")
+	}
+	// Without an exchange Publish declares the topic's queue with no arguments, which
+	// RabbitMQ refuses for a queue that carries dead-letter arguments, closing the channel
+	if options.DeadLetterExchange != "" && r.config.RabbitMQExchange == "" {
+		return nil, errors.New("a dead-letter exchange requires RabbitMQExchange to be configured")
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	subscription := &rabbitMQSubscription{
+		topic:   key,
+		topics:  topics,
+		handler: handler,
+		options: options,
+		ctx:     subCtx,
+		cancel:  cancel,
+	}
+
+	if err := r.openSubscription(subscription); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	r.subscribers[key] = subscription
+	return subscription, nil
+}
+
+// openSubscription gives the subscription a channel of its own, declares and binds its
+// queue and, unless the subscription is paused, starts consuming. It is also used to
+// restore subscriptions after a reconnect.
+func (r *rabbitMQBroker) openSubscription(subscription *rabbitMQSubscription) error {
+	options := subscription.options
 
 	// Create a new channel for this subscription
 	ch, err := r.conn.Channel()
@@ -243,19 +504,22 @@ func (r *rabbitMQBroker) Subscribe(ctx context.Context, topic string, handler Me
 	}
 
 	// Set QoS if prefetch count is specified
-	if options.PrefetchCount > 0 {
-		err = ch.Qos(options.PrefetchCount, 0, false)
-		if err != nil {
-			ch.Close()
-			return fmt.Errorf("failed to set QoS: %w", err)
-		}
+	if err := applyQoS(ch, options); err != nil {
+		ch.Close()
+		return err
 	}
 
-	queueName := topic
+	queueName := subscription.topic
 	if options.QueueName != "" {
 		queueName = options.QueueName
 	}
 
+	queueArgs, err := declareDeadLetter(ch, queueName, options)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
 	// Declare queue
 	queue, err := ch.QueueDeclare(
 		queueName,
@@ -263,7 +527,7 @@ func (r *rabbitMQBroker) Subscribe(ctx context.Context, topic string, handler Me
 		false,             // autoDelete
 		options.Exclusive, // exclusive
 		false,             // noWait
-		nil,               // arguments
+		queueArgs,         // arguments
 	)
 	if err != nil {
 		ch.Close()
@@ -271,62 +535,109 @@ func (r *rabbitMQBroker) Subscribe(ctx context.Context, topic string, handler Me
 	}
 
 	// Bind queue to exchange if exchange is configured
-	if r.config.RabbitMQExchange != "" {
-		err = ch.QueueBind(
-			queue.Name,
-			topic, // routing key
-			r.config.RabbitMQExchange,
-			false, // noWait
-			nil,   // arguments
-		)
-		if err != nil {
-			ch.Close()
-			return fmt.Errorf("failed to bind queue: %w", err)
-		}
+	if err := bindQueue(ch, queue.Name, subscription.topics, r.config, options); err != nil {
+		ch.Close()
+		return err
 	}
 
-	// Start consuming
-	msgs, err := ch.Consume(
-		queue.Name,
-		"",                // consumer name (auto-generated)
-		options.AutoAck,   // autoAck
-		options.Exclusive, // exclusive
-		false,             // noLocal
-		false,             // noWait
-		nil,               // arguments
+	subscription.channel = ch
+	subscription.queue = queue.Name
+	subscription.consumer = fmt.Sprintf("%s-%d", queue.Name, time.Now().UnixNano())
+
+	if subscription.gate.IsPaused() {
+		return nil
+	}
+	if err := r.startConsuming(subscription); err != nil {
+		ch.Close()
+		return err
+	}
+	return nil
+}
+
+// startConsuming registers the subscription's consumer on its channel and starts the
+// processing goroutines. It is also used to resume a paused subscription.
+func (r *rabbitMQBroker) startConsuming(subscription *rabbitMQSubscription) error {
+	msgs, err := subscription.channel.Consume(
+		subscription.queue,
+		subscription.consumer,
+		subscription.options.AutoAck,   // autoAck
+		subscription.options.Exclusive, // exclusive
+		false,                          // noLocal
+		false,                          // noWait
+		nil,                            // arguments
 	)
 	if err != nil {
-		ch.Close()
 		return fmt.Errorf("failed to start consuming: %w", err)
 	}
 
-	// Create subscription context
-	subCtx, cancel := context.WithCancel(ctx)
+	// Start message processing goroutines
+	for i := 0; i < subscription.options.Concurrency; i++ {
+		go r.processMessages(subscription.ctx, msgs, subscription)
+	}
 
-	subscription := &rabbitMQSubscription{
-		channel: ch,
-		queue:   queue.Name,
-		handler: handler,
-		options: options,
-		cancel:  cancel,
-		done:    make(chan bool),
+	return nil
+}
+
+// deadLetterDeclarer is the subset of *amqp.Channel used to set up dead-lettering
+type deadLetterDeclarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// declareDeadLetter declares the subscription's dead-letter exchange and queue and returns
+// the arguments that send the queue's rejected messages there, or nil when no
+// DeadLetterExchange is set. The exchange is direct and the dead-letter queue is bound by
+// its own name, so queues sharing one exchange keep their dead letters apart.
+func declareDeadLetter(ch deadLetterDeclarer, queueName string, options *SubscribeOptions) (amqp.Table, error) {
+	exchange := options.DeadLetterExchange
+	if exchange == "" {
+		return nil, nil
+	}
+	if options.AutoAck {
+		return nil, errors.New("dead-letter exchange requires AutoAck to be disabled")
+	}
+
+	deadLetterQueue := options.DeadLetterQueue
+	if deadLetterQueue == "" {
+		deadLetterQueue = queueName + ".dlq"
+	}
+
+	if err := ch.ExchangeDeclare(exchange, "direct", true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+	if _, err := ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+	if err := ch.QueueBind(deadLetterQueue, deadLetterQueue, exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind dead-letter queue: %w", err)
 	}
 
-	r.subscribers[topic] = subscription
+	return amqp.Table{
+		"x-dead-letter-exchange":    exchange,
+		"x-dead-letter-routing-key": deadLetterQueue,
+	}, nil
+}
 
-	// Start message processing goroutines
-	for i := 0; i < options.Concurrency; i++ {
-		go r.processMessages(subCtx, msgs, subscription)
+// qosSetter is the subset of *amqp.Channel used to configure prefetch
+type qosSetter interface {
+	Qos(prefetchCount, prefetchSize int, global bool) error
+}
+
+// applyQoS sets the channel prefetch from the subscribe options. With GlobalQoS the
+// limit is shared by every consumer on the channel; otherwise each consumer gets its own.
+func applyQoS(ch qosSetter, options *SubscribeOptions) error {
+	if options.PrefetchCount <= 0 {
+		return nil
 	}
 
+	if err := ch.Qos(options.PrefetchCount, 0, options.GlobalQoS); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
 	return nil
 }
 
 func (r *rabbitMQBroker) processMessages(ctx context.Context, msgs <-chan amqp.Delivery, subscription *rabbitMQSubscription) {
-	defer func() {
-		subscription.done <- true
-	}()
-
 	for {
 		select {
 		case <-ctx.Done():
@@ -336,7 +647,27 @@ func (r *rabbitMQBroker) processMessages(ctx context.Context, msgs <-chan amqp.D
 				return
 			}
 
+			// Paused after the message was prefetched. Hand it back to the queue, or hold an
+			// auto-acked message, which the server no longer has, until resumed.
+			if subscription.gate.IsPaused() {
+				if !subscription.options.AutoAck {
+					msg.Nack(false, true)
+					continue
+				}
+				if err := subscription.gate.Wait(ctx); err != nil {
+					return
+				}
+			}
+
+			// Disconnecting; hand prefetched messages back to the queue
+			if !subscription.handling.begin() {
+				if !subscription.options.AutoAck {
+					msg.Nack(false, true)
+				}
+				continue
+			}
 			r.handleMessage(ctx, msg, subscription)
+			subscription.handling.end()
 		}
 	}
 }
@@ -350,6 +681,11 @@ func (r *rabbitMQBroker) handleMessage(ctx context.Context, delivery amqp.Delive
 		Timestamp:       delivery.Timestamp,
 		OriginalMessage: delivery,
 	}
+	if !subscription.options.AutoAck {
+		message.acknowledger = rabbitMQAcknowledger{delivery: delivery}
+	}
+	// Without auto-ack the broker settles the delivery, unless the handler does it
+	settle := !subscription.options.AutoAck && !subscription.options.ManualAck
 
 	// Convert headers
 	if delivery.Headers != nil {
@@ -363,42 +699,97 @@ func (r *rabbitMQBroker) handleMessage(ctx context.Context, delivery amqp.Delive
 	}
 
 	// Process message with retries
-	var lastErr error
-	for retry := 0; retry <= subscription.options.MaxRetries; retry++ {
-		message.Retry = retry
-		message.MaxRetries = subscription.options.MaxRetries
-
-		err := subscription.handler(ctx, message)
-		if err == nil {
-			// Success - acknowledge if not auto-ack
-			if !subscription.options.AutoAck {
-				delivery.Ack(false)
-			}
-			return
+	err := runWithRetries(ctx, subscription.handler, message, subscription.options)
+	if err == nil {
+		r.counters.recordConsumed(message.Topic)
+		// Success - acknowledge if not auto-ack
+		if settle {
+			delivery.Ack(false)
 		}
+		return
+	}
 
-		lastErr = err
-		if retry < subscription.options.MaxRetries {
-			time.Sleep(subscription.options.RetryDelay)
+	// Shutting down; requeue so another consumer picks the message up
+	if abandoned(ctx, err) {
+		if settle {
+			delivery.Nack(false, true)
 		}
+		return
 	}
 
-	// Failed after all retries
-	if !subscription.options.AutoAck {
-		delivery.Nack(false, false) // Don't requeue
+	// Failed permanently or after all retries. Report it first, then settle; without
+	// requeue the queue's DLX applies.
+	fields := logrus.Fields{"queue": subscription.queue, "retries": message.Retry}
+	if subscription.options.DeadLetterExchange != "" {
+		fields["dead_letter_exchange"] = subscription.options.DeadLetterExchange
+	}
+	r.config.messageFailed(ctx, subscription.options, message, err, fields, "Failed to process RabbitMQ message")
+
+	if settle {
+		delivery.Nack(false, false)
+	}
+}
+
+// PauseSubscription cancels the consumer on the subscription channel so RabbitMQ stops
+// delivering; the channel, queue and binding are kept for ResumeSubscription. Messages
+// already prefetched are requeued rather than handled, or held until resumed with AutoAck.
+func (r *rabbitMQBroker) PauseSubscription(topic string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscription, exists := r.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
+	}
+
+	if !subscription.gate.Pause() {
+		return nil
+	}
+
+	if err := subscription.channel.Cancel(subscription.consumer, false); err != nil {
+		subscription.gate.Resume()
+		return fmt.Errorf("failed to pause subscription to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// ResumeSubscription re-registers the consumer of a paused subscription
+func (r *rabbitMQBroker) ResumeSubscription(topic string) error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscription, exists := r.subscribers[topic]
+	if !exists {
+		return errSubscriptionNotFound
 	}
 
-	// Log error (in a real implementation, you might want to send to a dead letter queue)
-	fmt.Printf("Failed to process message after %d retries: %v\n", subscription.options.MaxRetries, lastErr)
+	if !subscription.gate.Resume() {
+		return nil
+	}
+
+	if err := r.startConsuming(subscription); err != nil {
+		subscription.gate.Pause()
+		return fmt.Errorf("failed to resume subscription to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Pause stops invoking the topic's handler until Resume; see PauseSubscription
+func (r *rabbitMQBroker) Pause(ctx context.Context, topic string) error {
+	return changePause(ctx, r.PauseSubscription, topic)
+}
+
+// Resume restarts delivery to a paused topic; see ResumeSubscription
+func (r *rabbitMQBroker) Resume(ctx context.Context, topic string) error {
+	return changePause(ctx, r.ResumeSubscription, topic)
 }
 
 // Unsubscribe unsubscribes from the specified topic/queue
 func (r *rabbitMQBroker) Unsubscribe(ctx context.Context, topic string) error {
 	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
 	subscription, exists := r.subscribers[topic]
 	if !exists {
+		r.mutex.Unlock()
 		return errSubscriptionNotFound
 	}
 
@@ -411,9 +802,16 @@ func (r *rabbitMQBroker) Unsubscribe(ctx context.Context, topic string) error {
 	}
 
 	delete(r.subscribers, topic)
+	r.mutex.Unlock()
+
+	notifySubscription(subscription.options.OnStop, subscription.event())
 	return nil
 }
 
+func (s *rabbitMQSubscription) event() SubscriptionEvent {
+	return SubscriptionEvent{Topic: s.topic, Group: s.options.QueueName}
+}
+
 // CreateTopic creates a new topic/queue
 func (r *rabbitMQBroker) CreateTopic(ctx context.Context, topic string, options *TopicOptions) error {
 	r.mutex.RLock()
@@ -454,11 +852,23 @@ func (r *rabbitMQBroker) DeleteTopic(ctx context.Context, topic string) error {
 	return err
 }
 
-// ListTopics returns a list of available topics/queues (limited in RabbitMQ)
+// ListTopics returns the queue names from the management API when RabbitMQManagementURL is set
 func (r *rabbitMQBroker) ListTopics(ctx context.Context) ([]string, error) {
-	// RabbitMQ doesn't provide a direct way to list all queues via AMQP
-	// This would typically require the management API
-	return nil, errors.New("listing topics not supported via AMQP protocol")
+	// AMQP cannot enumerate queues; that takes the management API
+	if r.management == nil {
+		return nil, fmt.Errorf("listing topics via AMQP: %w", errNotSupported)
+	}
+
+	queues, err := r.management.queues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RabbitMQ queues: %w", err)
+	}
+
+	topics := make([]string, 0, len(queues))
+	for _, queue := range queues {
+		topics = append(topics, queue.Name)
+	}
+	return topics, nil
 }
 
 // Ping checks if RabbitMQ is accessible
@@ -476,13 +886,7 @@ func (r *rabbitMQBroker) Ping(ctx context.Context) error {
 // PublishBatch publishes multiple messages in a batch
 func (r *rabbitMQBroker) PublishBatch(ctx context.Context, messages []BatchMessage, options *PublishOptions) error {
 	for _, msg := range messages {
-		err := r.Publish(ctx, msg.Topic, msg.Data, &PublishOptions{
-			Headers:     msg.Headers,
-			Persistent:  options.Persistent,
-			Priority:    options.Priority,
-			TTL:         options.TTL,
-			ContentType: options.ContentType,
-		})
+		err := r.Publish(ctx, msg.Topic, msg.Data, batchMessageOptions(options, msg.Headers))
 		if err != nil {
 			return fmt.Errorf("failed to publish batch message to topic %s: %w", msg.Topic, err)
 		}
@@ -490,17 +894,40 @@ func (r *rabbitMQBroker) PublishBatch(ctx context.Context, messages []BatchMessa
 	return nil
 }
 
-// GetStats returns broker statistics
+// GetStats returns broker statistics. With a management API URL configured the counts are
+// the server's, covering every client; otherwise they cover this process only.
 func (r *rabbitMQBroker) GetStats(ctx context.Context) (*BrokerStats, error) {
-	// Basic stats - in a real implementation, you might use RabbitMQ Management API
-	return &BrokerStats{
+	stats := r.localStats()
+	if r.management == nil {
+		return stats, nil
+	}
+
+	if err := r.management.fillStats(ctx, stats); err != nil {
+		return nil, fmt.Errorf("failed to get RabbitMQ stats: %w", err)
+	}
+	return stats, nil
+}
+
+// localStats reports what this process has published and consumed
+func (r *rabbitMQBroker) localStats() *BrokerStats {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stats := &BrokerStats{
 		ConnectedClients: 1, // Current connection
 		Custom: map[string]interface{}{
 			"type":        "RabbitMQ",
 			"exchange":    r.config.RabbitMQExchange,
 			"subscribers": len(r.subscribers),
 		},
-	}, nil
+	}
+
+	for topic := range r.subscribers {
+		stats.Topics = append(stats.Topics, TopicStats{Name: topic, Subscribers: 1})
+	}
+	r.counters.apply(stats)
+
+	return stats
 }
 
 // Close closes the RabbitMQ broker
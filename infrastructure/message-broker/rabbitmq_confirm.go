@@ -0,0 +1,127 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// defaultConfirmTimeout bounds the wait for a publisher confirm when neither the context
+// nor BrokerConfig.Timeout sets one
+const defaultConfirmTimeout = 10 * time.Second
+
+// ErrUnroutable is returned for a confirmed publish that no queue was bound to receive
+var ErrUnroutable = errors.New("message is unroutable")
+
+// confirmation is the part of *amqp.DeferredConfirmation awaitConfirm needs
+type confirmation interface {
+	Done() <-chan struct{}
+	Acked() bool
+}
+
+// confirmReturnBuffer holds returned messages until a confirmed publish reads them. Returns
+// for publishes that timed out arrive late and are discarded by the next one.
+const confirmReturnBuffer = 16
+
+// openConfirmChannel opens the channel used for publishes with PublishOptions.Confirm. It is
+// in confirm mode and reports mandatory messages that could not be routed.
+func (r *rabbitMQBroker) openConfirmChannel() error {
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open RabbitMQ confirm channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to put RabbitMQ channel into confirm mode: %w", err)
+	}
+
+	r.confirmChannel = ch
+	r.returns = ch.NotifyReturn(make(chan amqp.Return, confirmReturnBuffer))
+	return nil
+}
+
+// publishConfirmed publishes as mandatory on the confirm channel and waits until RabbitMQ
+// has taken responsibility for the message. Confirmed publishes are sent one at a time and
+// carry a message ID, so a return is matched to the publish it belongs to.
+func (r *rabbitMQBroker) publishConfirmed(ctx context.Context, exchange, key string, mandatory bool, publishing amqp.Publishing) error {
+	r.confirmMutex.Lock()
+	defer r.confirmMutex.Unlock()
+
+	discardReturns(r.returns)
+
+	confirm, err := r.confirmChannel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, mandatory, false, publishing)
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultConfirmTimeout
+	if r.config.Timeout > 0 {
+		timeout = r.config.Timeout
+	}
+	return awaitConfirm(ctx, confirm, r.returns, publishing.MessageId, timeout)
+}
+
+// discardReturns empties returns of messages left over from earlier publishes
+func discardReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case <-returns:
+		default:
+			return
+		}
+	}
+}
+
+// awaitConfirm waits for the broker's ack or nack of the publish of messageID. RabbitMQ
+// sends back an unroutable message before acking it, so a return of that message seen by
+// then fails the publish; returns of other messages are discarded.
+func awaitConfirm(ctx context.Context, confirm confirmation, returns <-chan amqp.Return, messageID string, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var returned *amqp.Return
+	for done := false; !done; {
+		select {
+		case <-confirm.Done():
+			done = true
+		case r := <-returns:
+			if r.MessageId == messageID {
+				returned = &r
+			}
+		case <-timer.C:
+			return fmt.Errorf("broker did not confirm the message within %s", timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// The return may still be buffered when the confirm is seen first
+	if returned == nil {
+		returned = takeReturn(returns, messageID)
+	}
+	if returned != nil {
+		return fmt.Errorf("%w: %s (%d)", ErrUnroutable, returned.ReplyText, returned.ReplyCode)
+	}
+
+	if !confirm.Acked() {
+		return errors.New("broker rejected the message")
+	}
+	return nil
+}
+
+// takeReturn reads the buffered returns until it finds the one of messageID
+func takeReturn(returns <-chan amqp.Return, messageID string) *amqp.Return {
+	for {
+		select {
+		case r := <-returns:
+			if r.MessageId == messageID {
+				return &r
+			}
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package messagebroker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitMQDelayQueueIdle is how long an unused delay queue survives after its last message
+// has left, so queues for one-off delays do not accumulate
+const rabbitMQDelayQueueIdle = time.Minute
+
+// delayDeclarer is the subset of *amqp.Channel used to route delayed messages
+type delayDeclarer interface {
+	deadLetterDeclarer
+	ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error
+}
+
+// declareDelayedExchange declares config.RabbitMQDelayedExchange as an x-delayed-message
+// exchange, which needs the rabbitmq_delayed_message_exchange plugin. When RabbitMQExchange
+// is set, the delayed exchange forwards to it so delayed messages reach the same bindings
+// as the rest. Without a delayed exchange it does nothing.
+func declareDelayedExchange(ch delayDeclarer, config *BrokerConfig) error {
+	name := config.RabbitMQDelayedExchange
+	if name == "" {
+		return nil
+	}
+
+	delayedType := "direct"
+	if config.RabbitMQExchange != "" {
+		delayedType = "topic"
+	}
+
+	err := ch.ExchangeDeclare(name, "x-delayed-message", true, false, false, false, amqp.Table{
+		"x-delayed-type": delayedType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to declare delayed exchange: %w", err)
+	}
+
+	if config.RabbitMQExchange != "" {
+		if err := ch.ExchangeBind(config.RabbitMQExchange, "#", name, false, nil); err != nil {
+			return fmt.Errorf("failed to bind delayed exchange: %w", err)
+		}
+	}
+	return nil
+}
+
+// routeDelayed prepares publishing to be delivered to topic after delay and returns the
+// exchange and routing key to publish it with. With a delayed exchange the plugin holds
+// the message for the x-delay header's milliseconds. Otherwise the message waits in a queue
+// per delay whose TTL dead-letters it to its destination once the delay has passed.
+func routeDelayed(ch delayDeclarer, config *BrokerConfig, topic string, delay time.Duration, publishing *amqp.Publishing) (string, string, error) {
+	if config.RabbitMQDelayedExchange != "" {
+		if publishing.Headers == nil {
+			publishing.Headers = make(amqp.Table)
+		}
+		publishing.Headers["x-delay"] = delay.Milliseconds()
+
+		if config.RabbitMQExchange == "" {
+			// Without a topic exchange consumers read the queue named after the topic, so
+			// it has to be reachable from the delayed exchange
+			if _, err := ch.QueueDeclare(topic, true, false, false, false, nil); err != nil {
+				return "", "", fmt.Errorf("failed to declare queue: %w", err)
+			}
+			if err := ch.QueueBind(topic, topic, config.RabbitMQDelayedExchange, false, nil); err != nil {
+				return "", "", fmt.Errorf("failed to bind queue to delayed exchange: %w", err)
+			}
+		}
+		return config.RabbitMQDelayedExchange, topic, nil
+	}
+
+	// RabbitMQ drops the expiration of dead-lettered messages, so a TTL would either cut
+	// the wait short or be lost once the message is released
+	if publishing.Expiration != "" {
+		return "", "", errors.New("TTL cannot be combined with Delay unless a RabbitMQ delayed exchange is configured")
+	}
+
+	if config.RabbitMQExchange == "" {
+		if _, err := ch.QueueDeclare(topic, true, false, false, false, nil); err != nil {
+			return "", "", fmt.Errorf("failed to declare queue: %w", err)
+		}
+	}
+
+	waitQueue := fmt.Sprintf("%s.delay.%d", topic, delay.Milliseconds())
+	_, err := ch.QueueDeclare(waitQueue, true, false, false, false, amqp.Table{
+		"x-message-ttl":             delay.Milliseconds(),
+		"x-expires":                 (delay + rabbitMQDelayQueueIdle).Milliseconds(),
+		"x-dead-letter-exchange":    config.RabbitMQExchange,
+		"x-dead-letter-routing-key": topic,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to declare delay queue: %w", err)
+	}
+	return "", waitQueue, nil
+}
@@ -0,0 +1,74 @@
+package messagebroker
+
+import (
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitMQExchangeType returns config.RabbitMQExchangeType, "topic" when it is unset
+func rabbitMQExchangeType(config *BrokerConfig) (string, error) {
+	switch kind := strings.ToLower(config.RabbitMQExchangeType); kind {
+	case "":
+		return amqp.ExchangeTopic, nil
+	case amqp.ExchangeTopic, amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeHeaders:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown RabbitMQ exchange type %q: use %q, %q, %q or %q",
+			config.RabbitMQExchangeType, amqp.ExchangeTopic, amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeHeaders)
+	}
+}
+
+// queueBinder is the subset of *amqp.Channel used to bind subscription queues
+type queueBinder interface {
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// bindQueue binds a subscription's queue to the configured exchange. Topic and direct
+// exchanges get a binding per topic. A fanout exchange ignores routing keys and a headers
+// exchange routes by SubscribeOptions.BindHeaders, so both get a single binding.
+func bindQueue(ch queueBinder, queue string, topics []string, config *BrokerConfig, options *SubscribeOptions) error {
+	exchange := config.RabbitMQExchange
+	if exchange == "" {
+		return nil
+	}
+
+	kind, err := rabbitMQExchangeType(config)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case amqp.ExchangeFanout:
+		if err := ch.QueueBind(queue, "", exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue to %s: %w", exchange, err)
+		}
+	case amqp.ExchangeHeaders:
+		if err := ch.QueueBind(queue, "", exchange, false, headersBinding(options)); err != nil {
+			return fmt.Errorf("failed to bind queue to %s: %w", exchange, err)
+		}
+	default:
+		for _, topic := range topics {
+			if err := ch.QueueBind(queue, topic, exchange, false, nil); err != nil {
+				return fmt.Errorf("failed to bind queue to %s: %w", topic, err)
+			}
+		}
+	}
+	return nil
+}
+
+// headersBinding returns the arguments binding a queue to a headers exchange: the headers
+// to match and an x-match of "all", or "any" with MatchAnyHeader
+func headersBinding(options *SubscribeOptions) amqp.Table {
+	match := "all"
+	if options.MatchAnyHeader {
+		match = "any"
+	}
+
+	args := amqp.Table{"x-match": match}
+	for name, value := range options.BindHeaders {
+		args[name] = value
+	}
+	return args
+}
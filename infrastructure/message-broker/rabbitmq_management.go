@@ -0,0 +1,170 @@
+package messagebroker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// rabbitMQManagementTimeout bounds a management API request when the config sets no Timeout
+const rabbitMQManagementTimeout = 10 * time.Second
+
+// rabbitMQManagement is a minimal client for the RabbitMQ management plugin's HTTP API,
+// used for what AMQP cannot do such as enumerating queues
+type rabbitMQManagement struct {
+	baseURL  string
+	username string
+	password string
+	vhost    string
+	client   *http.Client
+}
+
+// managementQueue is the subset of a GET /api/queues entry the broker reads
+type managementQueue struct {
+	Name                   string                 `json:"name"`
+	Vhost                  string                 `json:"vhost"`
+	Consumers              int                    `json:"consumers"`
+	Messages               int64                  `json:"messages"`
+	MessagesReady          int64                  `json:"messages_ready"`
+	MessagesUnacknowledged int64                  `json:"messages_unacknowledged"`
+	MessageStats           managementMessageStats `json:"message_stats"`
+}
+
+// managementMessageStats are cumulative message counts since the node started
+type managementMessageStats struct {
+	Publish    int64 `json:"publish"`
+	DeliverGet int64 `json:"deliver_get"`
+}
+
+// managementOverview is the subset of GET /api/overview the broker reads
+type managementOverview struct {
+	ObjectTotals struct {
+		Connections int `json:"connections"`
+		Channels    int `json:"channels"`
+		Consumers   int `json:"consumers"`
+		Queues      int `json:"queues"`
+	} `json:"object_totals"`
+	QueueTotals struct {
+		Messages               int64 `json:"messages"`
+		MessagesReady          int64 `json:"messages_ready"`
+		MessagesUnacknowledged int64 `json:"messages_unacknowledged"`
+	} `json:"queue_totals"`
+	MessageStats managementMessageStats `json:"message_stats"`
+}
+
+// newRabbitMQManagement returns a management client for config, or nil when no management
+// URL is configured. The management credentials default to the AMQP ones.
+func newRabbitMQManagement(config *BrokerConfig) *rabbitMQManagement {
+	if config.RabbitMQManagementURL == "" {
+		return nil
+	}
+
+	username, password := config.RabbitMQManagementUsername, config.RabbitMQManagementPassword
+	if username == "" {
+		username, password = config.Username, config.Password
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = rabbitMQManagementTimeout
+	}
+
+	return &rabbitMQManagement{
+		baseURL:  strings.TrimRight(config.RabbitMQManagementURL, "/"),
+		username: username,
+		password: password,
+		vhost:    config.RabbitMQVHost,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// queues lists the queues in the configured vhost, or in every vhost when none is set
+func (m *rabbitMQManagement) queues(ctx context.Context) ([]managementQueue, error) {
+	path := "/api/queues"
+	if m.vhost != "" {
+		path += "/" + url.PathEscape(m.vhost)
+	}
+
+	var queues []managementQueue
+	if err := m.get(ctx, path, &queues); err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// overview returns the cluster-wide totals
+func (m *rabbitMQManagement) overview(ctx context.Context) (*managementOverview, error) {
+	var overview managementOverview
+	if err := m.get(ctx, "/api/overview", &overview); err != nil {
+		return nil, err
+	}
+	return &overview, nil
+}
+
+// fillStats replaces the process-local counts in stats with the server's. Consumed counts
+// are deliveries to consumers, and a queue's pending messages are those ready for delivery.
+func (m *rabbitMQManagement) fillStats(ctx context.Context, stats *BrokerStats) error {
+	overview, err := m.overview(ctx)
+	if err != nil {
+		return err
+	}
+	queues, err := m.queues(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats.ConnectedClients = overview.ObjectTotals.Connections
+	stats.MessagesPublished = overview.MessageStats.Publish
+	stats.MessagesConsumed = overview.MessageStats.DeliverGet
+	stats.Custom["channels"] = overview.ObjectTotals.Channels
+	stats.Custom["consumers"] = overview.ObjectTotals.Consumers
+	stats.Custom["queues"] = overview.ObjectTotals.Queues
+	stats.Custom["messages"] = overview.QueueTotals.Messages
+	stats.Custom["messages_ready"] = overview.QueueTotals.MessagesReady
+	stats.Custom["messages_unacknowledged"] = overview.QueueTotals.MessagesUnacknowledged
+
+	stats.Topics = make([]TopicStats, 0, len(queues))
+	for _, queue := range queues {
+		stats.Topics = append(stats.Topics, TopicStats{
+			Name:              queue.Name,
+			MessagesPublished: queue.MessageStats.Publish,
+			MessagesConsumed:  queue.MessageStats.DeliverGet,
+			Subscribers:       queue.Consumers,
+			PendingMessages:   queue.MessagesReady,
+		})
+	}
+	return nil
+}
+
+// get requests path and decodes the JSON response into out
+func (m *rabbitMQManagement) get(ctx context.Context, path string, out interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build management API request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	if m.username != "" {
+		request.SetBasicAuth(m.username, m.password)
+	}
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("management API request %s failed: %w", path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("management API request %s returned %s: %s", path, response.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode management API response for %s: %w", path, err)
+	}
+	return nil
+}
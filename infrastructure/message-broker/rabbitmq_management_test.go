@@ -0,0 +1,123 @@
+package messagebroker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newManagementAPI serves canned JSON responses keyed by escaped request path and checks
+// the basic auth credentials
+func newManagementAPI(t *testing.T, responses map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "monitor" || password != "secret" {
+			http.Error(w, `{"error":"not_authorised"}`, http.StatusUnauthorized)
+			return
+		}
+
+		body, exists := responses[r.URL.EscapedPath()]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRabbitMQListTopicsUsesManagementAPI(t *testing.T) {
+	server := newManagementAPI(t, map[string]string{
+		"/api/queues/%2Fbilling": `[{"name":"invoices","vhost":"/billing"},{"name":"invoices.dlq","vhost":"/billing"}]`,
+	})
+
+	broker, err := messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceRabbitMQ,
+		messagebroker.WithRabbitMQ("amqp://localhost:5672/", ""),
+		messagebroker.WithRabbitMQManagement(server.URL+"/", "monitor", "secret"),
+		func(c *messagebroker.BrokerConfig) { c.RabbitMQVHost = "/billing" },
+	)
+	require.NoError(t, err)
+
+	topics, err := broker.ListTopics(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"invoices", "invoices.dlq"}, topics)
+}
+
+func TestRabbitMQListTopicsReportsManagementAPIErrors(t *testing.T) {
+	server := newManagementAPI(t, nil)
+
+	broker, err := messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceRabbitMQ,
+		messagebroker.WithRabbitMQ("amqp://localhost:5672/", ""),
+		messagebroker.WithRabbitMQManagement(server.URL, "monitor", "wrong"),
+	)
+	require.NoError(t, err)
+
+	_, err = broker.ListTopics(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.False(t, messagebroker.IsNotSupported(err))
+}
+
+func TestRabbitMQListTopicsWithoutManagementAPIIsNotSupported(t *testing.T) {
+	broker, err := messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceRabbitMQ,
+		messagebroker.WithRabbitMQ("amqp://localhost:5672/", ""),
+	)
+	require.NoError(t, err)
+
+	_, err = broker.ListTopics(context.Background())
+	assert.True(t, messagebroker.IsNotSupported(err))
+}
+
+func TestRabbitMQStatsComeFromManagementAPI(t *testing.T) {
+	server := newManagementAPI(t, map[string]string{
+		"/api/overview": `{
+			"object_totals": {"connections": 4, "channels": 9, "consumers": 3, "queues": 2},
+			"queue_totals": {"messages": 12, "messages_ready": 10, "messages_unacknowledged": 2},
+			"message_stats": {"publish": 120, "deliver_get": 108}
+		}`,
+		"/api/queues": `[
+			{"name": "orders", "consumers": 2, "messages": 11, "messages_ready": 9, "messages_unacknowledged": 2,
+			 "message_stats": {"publish": 100, "deliver_get": 89}},
+			{"name": "orders.dlq", "consumers": 0, "messages": 1, "messages_ready": 1}
+		]`,
+	})
+
+	broker, err := messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceRabbitMQ,
+		messagebroker.WithRabbitMQ("amqp://localhost:5672/", "events"),
+		messagebroker.WithRabbitMQManagement(server.URL, "monitor", "secret"),
+	)
+	require.NoError(t, err)
+
+	stats, err := broker.GetStats(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, stats.ConnectedClients)
+	assert.Equal(t, int64(120), stats.MessagesPublished)
+	assert.Equal(t, int64(108), stats.MessagesConsumed)
+	assert.Equal(t, int64(2), stats.Custom["messages_unacknowledged"])
+	assert.Equal(t, "events", stats.Custom["exchange"])
+	assert.Equal(t, []messagebroker.TopicStats{
+		{Name: "orders", MessagesPublished: 100, MessagesConsumed: 89, Subscribers: 2, PendingMessages: 9},
+		{Name: "orders.dlq", PendingMessages: 1},
+	}, stats.Topics)
+}
+
+func TestRabbitMQStatsReportManagementAPIErrors(t *testing.T) {
+	server := newManagementAPI(t, map[string]string{"/api/overview": `{}`})
+
+	broker, err := messagebroker.NewMessageBrokerFactoryWithOptions(messagebroker.InstanceRabbitMQ,
+		messagebroker.WithRabbitMQ("amqp://localhost:5672/", ""),
+		messagebroker.WithRabbitMQManagement(server.URL, "monitor", "secret"),
+	)
+	require.NoError(t, err)
+
+	_, err = broker.GetStats(context.Background())
+	assert.ErrorContains(t, err, "/api/queues")
+}
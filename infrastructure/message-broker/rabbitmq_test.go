@@ -0,0 +1,497 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingQoS struct {
+	calls         int
+	prefetchCount int
+	global        bool
+}
+
+func (r *recordingQoS) Qos(prefetchCount, prefetchSize int, global bool) error {
+	r.calls++
+	r.prefetchCount = prefetchCount
+	r.global = global
+	return nil
+}
+
+func TestApplyQoSPassesGlobalFlag(t *testing.T) {
+	for _, global := range []bool{false, true} {
+		ch := &recordingQoS{}
+		require.NoError(t, applyQoS(ch, &SubscribeOptions{PrefetchCount: 10, GlobalQoS: global}))
+
+		assert.Equal(t, 1, ch.calls)
+		assert.Equal(t, 10, ch.prefetchCount)
+		assert.Equal(t, global, ch.global)
+	}
+}
+
+func TestApplyQoSSkipsWithoutPrefetch(t *testing.T) {
+	ch := &recordingQoS{}
+	require.NoError(t, applyQoS(ch, &SubscribeOptions{GlobalQoS: true}))
+	assert.Equal(t, 0, ch.calls)
+}
+
+type recordingDeclarer struct {
+	exchanges []string
+	queues    []string
+	bindings  []string
+	arguments map[string]amqp.Table // Declaration arguments by exchange or queue name
+}
+
+func (r *recordingDeclarer) recordArguments(name string, args amqp.Table) {
+	if args == nil {
+		return
+	}
+	if r.arguments == nil {
+		r.arguments = make(map[string]amqp.Table)
+	}
+	r.arguments[name] = args
+}
+
+func (r *recordingDeclarer) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	r.exchanges = append(r.exchanges, name+":"+kind)
+	r.recordArguments(name, args)
+	return nil
+}
+
+func (r *recordingDeclarer) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	r.queues = append(r.queues, name)
+	r.recordArguments(name, args)
+	return amqp.Queue{Name: name}, nil
+}
+
+func (r *recordingDeclarer) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	r.bindings = append(r.bindings, exchange+"->"+name+"@"+key)
+	r.recordArguments(exchange+"->"+name, args)
+	return nil
+}
+
+func (r *recordingDeclarer) ExchangeBind(destination, key, source string, noWait bool, args amqp.Table) error {
+	r.bindings = append(r.bindings, source+"->"+destination+"@"+key)
+	return nil
+}
+
+func TestDeclareDeadLetterRoutesQueueToExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	args, err := declareDeadLetter(ch, "payments", DeadLetterSubscribeOptions("payments.dlx"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"payments.dlx:direct"}, ch.exchanges)
+	assert.Equal(t, []string{"payments.dlq"}, ch.queues)
+	assert.Equal(t, []string{"payments.dlx->payments.dlq@payments.dlq"}, ch.bindings)
+	assert.Equal(t, amqp.Table{
+		"x-dead-letter-exchange":    "payments.dlx",
+		"x-dead-letter-routing-key": "payments.dlq",
+	}, args)
+}
+
+func TestDeclareDeadLetterUsesConfiguredQueue(t *testing.T) {
+	ch := &recordingDeclarer{}
+	options := DeadLetterSubscribeOptions("dlx")
+	options.DeadLetterQueue = "poison"
+
+	args, err := declareDeadLetter(ch, "payments", options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"poison"}, ch.queues)
+	assert.Equal(t, "poison", args["x-dead-letter-routing-key"])
+}
+
+func TestDeclareDeadLetterSkippedWithoutExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	args, err := declareDeadLetter(ch, "payments", DefaultSubscribeOptions())
+	require.NoError(t, err)
+	assert.Nil(t, args)
+	assert.Empty(t, ch.queues)
+}
+
+func TestDeclareDeadLetterRejectsAutoAck(t *testing.T) {
+	options := DeadLetterSubscribeOptions("dlx")
+	options.AutoAck = true
+
+	_, err := declareDeadLetter(&recordingDeclarer{}, "payments", options)
+	assert.Error(t, err)
+}
+
+func TestRabbitMQDeadLetterRequiresExchange(t *testing.T) {
+	broker, err := NewRabbitMQBroker(&BrokerConfig{RabbitMQURL: "amqp://localhost:5672/"})
+	require.NoError(t, err)
+	broker.(*rabbitMQBroker).connected = true
+
+	err = broker.Subscribe(context.Background(), "payments", func(context.Context, *Message) error { return nil }, DeadLetterSubscribeOptions("payments.dlx"))
+	assert.ErrorContains(t, err, "RabbitMQExchange")
+}
+
+type recordingAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestRabbitMQShutdownDuringRetryRequeuesMessage(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	subscription := &rabbitMQSubscription{
+		queue:   "orders",
+		options: &SubscribeOptions{MaxRetries: 3, RetryDelay: time.Hour},
+		handler: func(ctx context.Context, message *Message) error {
+			return errors.New("downstream unavailable")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acknowledger := &recordingAcknowledger{}
+	done := make(chan struct{})
+	go func() {
+		broker.handleMessage(ctx, amqp.Delivery{Acknowledger: acknowledger}, subscription)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("retry delay ignored the canceled context")
+	}
+
+	assert.False(t, acknowledger.acked)
+	assert.True(t, acknowledger.nacked)
+	assert.True(t, acknowledger.requeue)
+}
+
+func TestRabbitMQPauseRequeuesPrefetchedMessages(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	var handled atomic.Int32
+	subscription := &rabbitMQSubscription{
+		queue:   "orders",
+		options: &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error {
+			handled.Add(1)
+			return nil
+		},
+	}
+	subscription.gate.Pause()
+
+	// Deliveries RabbitMQ pushed to the consumer before it was canceled
+	msgs := make(chan amqp.Delivery, 3)
+	acknowledgers := make([]*recordingAcknowledger, 3)
+	for i := range acknowledgers {
+		acknowledgers[i] = &recordingAcknowledger{}
+		msgs <- amqp.Delivery{Acknowledger: acknowledgers[i]}
+	}
+	close(msgs)
+
+	broker.processMessages(context.Background(), msgs, subscription)
+
+	assert.Zero(t, handled.Load(), "a paused subscription must not reach the handler")
+	for _, acknowledger := range acknowledgers {
+		assert.False(t, acknowledger.acked)
+		assert.True(t, acknowledger.nacked)
+		assert.True(t, acknowledger.requeue)
+	}
+}
+
+func TestRabbitMQPauseHoldsPrefetchedAutoAckMessages(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	handled := make(chan struct{}, 1)
+	subscription := &rabbitMQSubscription{
+		queue:   "orders",
+		options: &SubscribeOptions{AutoAck: true},
+		handler: func(ctx context.Context, message *Message) error {
+			handled <- struct{}{}
+			return nil
+		},
+	}
+	subscription.gate.Pause()
+
+	msgs := make(chan amqp.Delivery, 1)
+	msgs <- amqp.Delivery{}
+	close(msgs)
+	go broker.processMessages(context.Background(), msgs, subscription)
+
+	select {
+	case <-handled:
+		t.Fatal("a paused subscription must not reach the handler")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	subscription.gate.Resume()
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("the held message was not handled after resume")
+	}
+}
+
+func TestRabbitMQCountsConsumedByRoutingKey(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	subscription := &rabbitMQSubscription{
+		topic:   "orders+payments",
+		topics:  []string{"orders", "payments"},
+		queue:   "billing",
+		options: &SubscribeOptions{},
+		handler: func(ctx context.Context, message *Message) error { return nil },
+	}
+
+	broker.handleMessage(context.Background(), amqp.Delivery{RoutingKey: "orders", Acknowledger: &recordingAcknowledger{}}, subscription)
+	broker.handleMessage(context.Background(), amqp.Delivery{RoutingKey: "payments", Acknowledger: &recordingAcknowledger{}}, subscription)
+
+	stats := &BrokerStats{}
+	broker.counters.apply(stats)
+	assert.Equal(t, int64(2), stats.MessagesConsumed)
+	require.Len(t, stats.Topics, 2)
+	assert.Equal(t, TopicStats{Name: "orders", MessagesConsumed: 1}, stats.Topics[0])
+	assert.Equal(t, TopicStats{Name: "payments", MessagesConsumed: 1}, stats.Topics[1])
+}
+
+func TestRabbitMQOnErrorRunsBeforeDeadLettering(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	acknowledger := &recordingAcknowledger{}
+	var settledBeforeHook bool
+	subscription := &rabbitMQSubscription{
+		queue: "orders",
+		options: &SubscribeOptions{OnError: func(ctx context.Context, message *Message, err error) {
+			settledBeforeHook = acknowledger.nacked
+		}},
+		handler: func(ctx context.Context, message *Message) error {
+			return Permanent(errors.New("malformed order"))
+		},
+	}
+
+	broker.handleMessage(context.Background(), amqp.Delivery{Acknowledger: acknowledger}, subscription)
+
+	assert.False(t, settledBeforeHook, "OnError must run before the message is dead-lettered")
+	assert.True(t, acknowledger.nacked)
+	assert.False(t, acknowledger.requeue)
+}
+
+func TestRabbitMQManualAckLeavesSettlingToHandler(t *testing.T) {
+	broker := &rabbitMQBroker{config: &BrokerConfig{}}
+	subscription := &rabbitMQSubscription{
+		queue:   "reports",
+		options: &SubscribeOptions{ManualAck: true},
+		handler: func(ctx context.Context, message *Message) error {
+			return message.Nack(true)
+		},
+	}
+
+	acknowledger := &recordingAcknowledger{}
+	broker.handleMessage(context.Background(), amqp.Delivery{Acknowledger: acknowledger}, subscription)
+
+	assert.False(t, acknowledger.acked, "the broker must not ack on the handler's behalf")
+	assert.True(t, acknowledger.nacked)
+	assert.True(t, acknowledger.requeue)
+}
+
+func TestDeclareDelayedExchangeForwardsToTopicExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	config := &BrokerConfig{RabbitMQExchange: "events", RabbitMQDelayedExchange: "events.delayed"}
+	require.NoError(t, declareDelayedExchange(ch, config))
+
+	assert.Equal(t, []string{"events.delayed:x-delayed-message"}, ch.exchanges)
+	assert.Equal(t, amqp.Table{"x-delayed-type": "topic"}, ch.arguments["events.delayed"])
+	assert.Equal(t, []string{"events.delayed->events@#"}, ch.bindings)
+
+	ch = &recordingDeclarer{}
+	require.NoError(t, declareDelayedExchange(ch, &BrokerConfig{}))
+	assert.Empty(t, ch.exchanges)
+}
+
+func TestRouteDelayedThroughDelayedExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	config := &BrokerConfig{RabbitMQExchange: "events", RabbitMQDelayedExchange: "events.delayed"}
+	publishing := amqp.Publishing{Headers: amqp.Table{"trace": "abc"}}
+
+	exchange, key, err := routeDelayed(ch, config, "invoices.due", 90*time.Second, &publishing)
+	require.NoError(t, err)
+	assert.Equal(t, "events.delayed", exchange)
+	assert.Equal(t, "invoices.due", key)
+	assert.Equal(t, amqp.Table{"trace": "abc", "x-delay": int64(90000)}, publishing.Headers)
+	assert.Empty(t, ch.queues)
+}
+
+func TestRouteDelayedBindsTopicQueueWithoutExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	config := &BrokerConfig{RabbitMQDelayedExchange: "delayed"}
+
+	exchange, key, err := routeDelayed(ch, config, "invoices", time.Second, &amqp.Publishing{})
+	require.NoError(t, err)
+	assert.Equal(t, "delayed", exchange)
+	assert.Equal(t, "invoices", key)
+	assert.Equal(t, []string{"invoices"}, ch.queues)
+	assert.Equal(t, []string{"delayed->invoices@invoices"}, ch.bindings)
+}
+
+func TestRouteDelayedFallsBackToTTLQueue(t *testing.T) {
+	ch := &recordingDeclarer{}
+	config := &BrokerConfig{RabbitMQExchange: "events"}
+
+	exchange, key, err := routeDelayed(ch, config, "invoices.due", 30*time.Second, &amqp.Publishing{})
+	require.NoError(t, err)
+	assert.Equal(t, "", exchange)
+	assert.Equal(t, "invoices.due.delay.30000", key)
+	assert.Equal(t, []string{"invoices.due.delay.30000"}, ch.queues)
+	assert.Equal(t, amqp.Table{
+		"x-message-ttl":             int64(30000),
+		"x-expires":                 int64(90000),
+		"x-dead-letter-exchange":    "events",
+		"x-dead-letter-routing-key": "invoices.due",
+	}, ch.arguments["invoices.due.delay.30000"])
+}
+
+func TestRouteDelayedFallbackRejectsTTL(t *testing.T) {
+	_, _, err := routeDelayed(&recordingDeclarer{}, &BrokerConfig{}, "invoices", time.Second, &amqp.Publishing{Expiration: "5000"})
+	assert.Error(t, err)
+}
+
+// settledConfirm is a publisher confirm that has already arrived
+type settledConfirm struct {
+	done  chan struct{}
+	acked bool
+}
+
+func newSettledConfirm(acked bool) settledConfirm {
+	done := make(chan struct{})
+	close(done)
+	return settledConfirm{done: done, acked: acked}
+}
+
+func (c settledConfirm) Done() <-chan struct{} { return c.done }
+func (c settledConfirm) Acked() bool           { return c.acked }
+
+func TestAwaitConfirm(t *testing.T) {
+	ctx := context.Background()
+
+	assert.NoError(t, awaitConfirm(ctx, newSettledConfirm(true), make(chan amqp.Return), "m1", time.Second))
+
+	err := awaitConfirm(ctx, newSettledConfirm(false), make(chan amqp.Return), "m1", time.Second)
+	assert.EqualError(t, err, "broker rejected the message")
+
+	returns := make(chan amqp.Return, 1)
+	returns <- amqp.Return{MessageId: "m1", ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE"}
+	err = awaitConfirm(ctx, newSettledConfirm(true), returns, "m1", time.Second)
+	assert.ErrorIs(t, err, ErrUnroutable)
+	assert.Contains(t, err.Error(), "NO_ROUTE")
+
+	pending := settledConfirm{done: make(chan struct{})}
+	err = awaitConfirm(ctx, pending, make(chan amqp.Return), "m1", 10*time.Millisecond)
+	assert.EqualError(t, err, "broker did not confirm the message within 10ms")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, awaitConfirm(canceled, pending, make(chan amqp.Return), "m1", time.Second), context.Canceled)
+}
+
+func TestAwaitConfirmIgnoresStaleReturns(t *testing.T) {
+	// A publish that timed out is returned after its caller gave up
+	returns := make(chan amqp.Return, 2)
+	returns <- amqp.Return{MessageId: "timed-out", ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE"}
+	returns <- amqp.Return{MessageId: "timed-out-too", ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE"}
+
+	assert.NoError(t, awaitConfirm(context.Background(), newSettledConfirm(true), returns, "next", time.Second))
+	assert.Empty(t, returns, "stale returns are drained")
+
+	returns <- amqp.Return{MessageId: "timed-out", ReplyCode: amqp.NoRoute}
+	discardReturns(returns)
+	assert.Empty(t, returns)
+}
+
+func TestRabbitMQDialConfigAppliesVHost(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		vhost string
+		want  string
+	}{
+		{"no vhost configured", "amqp://localhost:5672/", "", ""},
+		{"url without path", "amqp://localhost:5672", "tenant-a", "tenant-a"},
+		{"url with bare slash", "amqp://localhost:5672/", "tenant-a", "tenant-a"},
+		{"url names a vhost", "amqp://localhost:5672/tenant-b", "tenant-a", ""},
+		{"url names the default vhost", "amqp://localhost:5672/%2F", "tenant-a", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := rabbitMQDialConfig(&BrokerConfig{RabbitMQURL: tt.url, RabbitMQVHost: tt.vhost})
+			assert.Equal(t, tt.want, config.Vhost)
+		})
+	}
+}
+
+func TestBindQueueByExchangeType(t *testing.T) {
+	topics := []string{"orders.created", "orders.paid"}
+
+	tests := []struct {
+		kind     string
+		bindings []string
+	}{
+		{"", []string{"events->orders@orders.created", "events->orders@orders.paid"}},
+		{"direct", []string{"events->orders@orders.created", "events->orders@orders.paid"}},
+		{"fanout", []string{"events->orders@"}},
+		{"headers", []string{"events->orders@"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			ch := &recordingDeclarer{}
+			config := &BrokerConfig{RabbitMQExchange: "events", RabbitMQExchangeType: tt.kind}
+			require.NoError(t, bindQueue(ch, "orders", topics, config, &SubscribeOptions{}))
+			assert.Equal(t, tt.bindings, ch.bindings)
+		})
+	}
+}
+
+func TestBindQueueToHeadersExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	config := &BrokerConfig{RabbitMQExchange: "events", RabbitMQExchangeType: "headers"}
+
+	require.NoError(t, bindQueue(ch, "eu-orders", []string{"orders"}, config, &SubscribeOptions{
+		BindHeaders:    map[string]string{"region": "eu", "tier": "gold"},
+		MatchAnyHeader: true,
+	}))
+	assert.Equal(t, amqp.Table{"x-match": "any", "region": "eu", "tier": "gold"}, ch.arguments["events->eu-orders"])
+
+	ch = &recordingDeclarer{}
+	require.NoError(t, bindQueue(ch, "eu-orders", []string{"orders"}, config, &SubscribeOptions{
+		BindHeaders: map[string]string{"region": "eu"},
+	}))
+	assert.Equal(t, amqp.Table{"x-match": "all", "region": "eu"}, ch.arguments["events->eu-orders"])
+}
+
+func TestBindQueueWithoutExchange(t *testing.T) {
+	ch := &recordingDeclarer{}
+	require.NoError(t, bindQueue(ch, "orders", []string{"orders"}, &BrokerConfig{RabbitMQExchangeType: "fanout"}, &SubscribeOptions{}))
+	assert.Empty(t, ch.bindings)
+}
+
+func TestNewRabbitMQBrokerRejectsUnknownExchangeType(t *testing.T) {
+	_, err := NewRabbitMQBroker(NewBrokerConfig(WithRabbitMQ("amqp://localhost:5672/", "events"), WithRabbitMQExchangeType("x-consistent-hash")))
+	assert.ErrorContains(t, err, "unknown RabbitMQ exchange type")
+
+	_, err = NewRabbitMQBroker(NewBrokerConfig(WithRabbitMQ("amqp://localhost:5672/", "events"), WithRabbitMQExchangeType("Fanout")))
+	assert.NoError(t, err)
+}
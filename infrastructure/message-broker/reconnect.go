@@ -0,0 +1,44 @@
+package messagebroker
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reconnect defaults, the same as the NATS client's
+const (
+	defaultMaxReconnects = 60
+	defaultReconnectWait = 2 * time.Second
+)
+
+// reconnectLimits returns how many times to try reconnecting, negative for no limit, and
+// how long to wait before each attempt
+func (c *BrokerConfig) reconnectLimits() (int, time.Duration) {
+	attempts, wait := defaultMaxReconnects, defaultReconnectWait
+	if c == nil {
+		return attempts, wait
+	}
+	if c.MaxReconnects != 0 {
+		attempts = c.MaxReconnects
+	}
+	if c.ReconnectWait > 0 {
+		wait = c.ReconnectWait
+	}
+	return attempts, wait
+}
+
+// reportReconnect logs the outcome of a reconnect attempt, err being nil on success, and
+// passes it to OnReconnect
+func (c *BrokerConfig) reportReconnect(broker BrokerType, attempt int, err error) {
+	fields := logrus.Fields{"broker": broker, "attempt": attempt}
+	if err != nil {
+		c.logSampled(logrus.WarnLevel, err, fields, "Reconnect attempt failed")
+	} else {
+		c.log().WithFields(fields).Info("Reconnected")
+	}
+
+	if c != nil && c.OnReconnect != nil {
+		c.OnReconnect(broker, attempt, err)
+	}
+}
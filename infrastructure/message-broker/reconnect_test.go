@@ -0,0 +1,60 @@
+package messagebroker_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSSubscriptionSurvivesServerRestart(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = server.RANDOM_PORT
+	srv := natstest.RunServer(&opts)
+	url := srv.ClientURL()
+	opts.Port = srv.Addr().(*net.TCPAddr).Port
+
+	reconnected := make(chan error, 1)
+	broker, err := messagebroker.NewNATSBrokerWithOptions(
+		messagebroker.WithNATSURL(url),
+		messagebroker.WithReconnect(-1, 10*time.Millisecond),
+		messagebroker.WithReconnectHandler(func(broker messagebroker.BrokerType, attempt int, err error) {
+			if err == nil {
+				reconnected <- nil
+			}
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { broker.Close() })
+
+	received := make(chan string, 1)
+	require.NoError(t, broker.Subscribe(context.Background(), "wards", func(ctx context.Context, message *messagebroker.Message) error {
+		received <- string(message.Data)
+		return nil
+	}, nil))
+
+	srv.Shutdown()
+	srv = natstest.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the broker did not reconnect")
+	}
+
+	require.NoError(t, broker.Publish(context.Background(), "wards", []byte("icu"), nil))
+	select {
+	case data := <-received:
+		assert.Equal(t, "icu", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not restored")
+	}
+}
@@ -4,7 +4,12 @@ package messagebroker
 
 import (
 	"context"
+	"sync"
 	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // MessageBroker interface defines the contract for message broker management
@@ -18,15 +23,31 @@ type MessageBroker interface {
 	// Publish sends a message to the specified topic/queue
 	Publish(ctx context.Context, topic string, message []byte, options *PublishOptions) error
 
-	// PublishJSON sends a JSON-encoded message to the specified topic/queue
+	// PublishAsync queues a message and returns without waiting for the broker to confirm
+	// it. Delivery failures are passed to BrokerConfig.OnPublishError.
+	PublishAsync(ctx context.Context, topic string, message []byte, options *PublishOptions) error
+
+	// PublishJSON encodes message with BrokerConfig.Serializer, JSON by default, and sends
+	// it to the specified topic/queue with its content type in the Content-Type header
 	PublishJSON(ctx context.Context, topic string, message interface{}, options *PublishOptions) error
 
 	// Subscribe subscribes to messages from the specified topic/queue
 	Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error
 
-	// Unsubscribe unsubscribes from the specified topic/queue
+	// SubscribeMany subscribes one handler to several topics/queues as a single subscription
+	// and returns its handle, which Unsubscribe, Pause and Resume accept in place of a topic
+	SubscribeMany(ctx context.Context, topics []string, handler MessageHandler, options *SubscribeOptions) (string, error)
+
+	// Unsubscribe unsubscribes from the specified topic/queue, or a SubscribeMany handle
 	Unsubscribe(ctx context.Context, topic string) error
 
+	// Pause stops invoking the topic's handler without tearing down the subscription.
+	// Nothing is buffered for the handler while paused.
+	Pause(ctx context.Context, topic string) error
+
+	// Resume restarts delivery to a paused subscription
+	Resume(ctx context.Context, topic string) error
+
 	// CreateTopic creates a new topic/queue (if supported by the broker)
 	CreateTopic(ctx context.Context, topic string, options *TopicOptions) error
 
@@ -64,6 +85,8 @@ type Message struct {
 
 	// Broker-specific fields
 	OriginalMessage interface{} `json:"-"` // Store original message for acking
+
+	acknowledger acknowledger // Backs Ack and Nack; nil when the broker cannot settle messages
 }
 
 // BatchMessage represents a message for batch publishing
@@ -79,8 +102,16 @@ type PublishOptions struct {
 	Persistent  bool              `json:"persistent"`   // For durability
 	Priority    int               `json:"priority"`     // Message priority (0-9)
 	TTL         time.Duration     `json:"ttl"`          // Time to live
-	Delay       time.Duration     `json:"delay"`        // Delay before delivery
+	Delay       time.Duration     `json:"delay"`        // Delay before delivery (RabbitMQ only)
 	ContentType string            `json:"content_type"` // Content type
+	// Compression compresses the payload before it is sent: CompressionGzip, or
+	// CompressionNone (default). A Content-Encoding header tells consumers of this package to
+	// decompress it before their handler runs.
+	Compression string `json:"compression"`
+	// Confirm waits until the broker has taken responsibility for the message (RabbitMQ
+	// only). The publish fails when RabbitMQ nacks it, cannot route it to any queue or does
+	// not confirm it within the broker Timeout.
+	Confirm bool `json:"confirm"`
 }
 
 // SubscribeOptions contains options for subscribing to messages
@@ -93,6 +124,79 @@ type SubscribeOptions struct {
 	RetryDelay    time.Duration `json:"retry_delay"`    // Delay between retries
 	Concurrency   int           `json:"concurrency"`    // Number of concurrent handlers
 	PrefetchCount int           `json:"prefetch_count"` // Number of messages to prefetch
+	// HandlerTimeout bounds each handler call. A call still running when it expires fails
+	// with context.DeadlineExceeded and counts toward MaxRetries like any other error; its
+	// context is canceled, and the handler should return once it notices.
+	HandlerTimeout time.Duration `json:"handler_timeout"`
+	// MaxDecompressedSize limits, in bytes, how large a compressed payload may grow when it
+	// is decompressed. A larger message fails permanently. Defaults to
+	// DefaultMaxDecompressedSize.
+	MaxDecompressedSize int64 `json:"max_decompressed_size"`
+	// ManualAck stops the broker from settling messages after the handler returns: RabbitMQ
+	// does not ack or nack, Kafka does not mark offsets and JetStream does not ack, nak or
+	// terminate. The handler must call Message.Ack or Message.Nack instead. Logging and
+	// dead-letter topics work as usual. Core NATS has no acks and ignores it.
+	ManualAck bool `json:"manual_ack"`
+	// GlobalQoS applies PrefetchCount to the whole channel instead of to each consumer
+	// on it (RabbitMQ only). Use it to cap unacknowledged messages across all
+	// concurrent consumers sharing the subscription channel.
+	GlobalQoS bool `json:"global_qos"`
+	// BindHeaders binds the queue to a RabbitMQ headers exchange by these header values
+	// instead of by topic. A message is routed to the queue when all of them match, or any
+	// one with MatchAnyHeader. Without BindHeaders the queue receives every message.
+	BindHeaders    map[string]string `json:"bind_headers"`
+	MatchAnyHeader bool              `json:"match_any_header"`
+	// Redeliver enables application-level redelivery for NATS core subscriptions: a failed
+	// message is re-published to its subject with an incremented X-Redelivery-Count header
+	// until MaxRetries is reached, then sent to DeadLetterTopic (if set).
+	Redeliver       bool   `json:"redeliver"`
+	DeadLetterTopic string `json:"dead_letter_topic"` // Topic receiving messages that exhausted their retries
+	// DeadLetterExchange makes RabbitMQ route messages that failed permanently or exhausted
+	// their retries to this exchange instead of dropping them. RabbitMQ keeps the original
+	// headers and adds an x-death header counting how often the message was dead-lettered.
+	// It needs BrokerConfig.RabbitMQExchange.
+	DeadLetterExchange string `json:"dead_letter_exchange"`
+	// DeadLetterQueue is the queue bound to DeadLetterExchange; defaults to "<queue>.dlq"
+	DeadLetterQueue string `json:"dead_letter_queue"`
+	// StartFromTime makes a Kafka subscription begin, on each partition, at the first message
+	// produced at or after this time, e.g. to reprocess a window of events. It is applied once
+	// per partition for the lifetime of the subscription.
+	StartFromTime *time.Time `json:"start_from_time,omitempty"`
+	// InitialOffset is where a Kafka consumer group with no committed offset starts on each
+	// partition: KafkaOffsetOldest (default) replays the whole topic, KafkaOffsetNewest only
+	// receives messages produced after the group joined. Committed offsets always take
+	// precedence.
+	InitialOffset string `json:"initial_offset"`
+	// PullBatchSize switches a NATS subscription to a JetStream pull consumer that fetches up
+	// to this many messages per request, with Concurrency workers fetching in parallel. The
+	// subject must be captured by a stream; one named after the subject is created if none is.
+	// QueueName, when set, is used as the durable consumer name.
+	PullBatchSize int           `json:"pull_batch_size"`
+	AckWait       time.Duration `json:"ack_wait"`        // Time JetStream waits for an ack before redelivering
+	MaxAckPending int           `json:"max_ack_pending"` // Unacknowledged messages allowed before JetStream stops delivering
+	// OnStart is called once the subscription is consuming. For Kafka that is when the
+	// consumer group's first session begins, so it can gate readiness on partition assignment.
+	OnStart func(SubscriptionEvent) `json:"-"`
+	// OnStop is called once the subscription has stopped, after Unsubscribe or Disconnect
+	// or, for Kafka, when the subscribe context is canceled
+	OnStop func(SubscriptionEvent) `json:"-"`
+	// OnRebalance is called when a Kafka consumer group session begins after a rebalance,
+	// with the partitions claimed in the new session. Other brokers never rebalance.
+	OnRebalance func(SubscriptionEvent) `json:"-"`
+	// OnPartitionsChanged is called when a Kafka consumer group session begins, with the
+	// partitions assigned to the consumer, and when it ends, with the partitions revoked
+	// from it, so per-partition state can be loaded and flushed around a rebalance. Every
+	// rebalance revokes the whole assignment before handing out the new one.
+	OnPartitionsChanged func(assigned, revoked []TopicPartition) `json:"-"`
+	// OnError is called with a message whose handler failed permanently or exhausted its
+	// retries, before it is dead-lettered where configured. Such failures are logged when
+	// it is nil. Messages abandoned because the subscription stopped are not reported.
+	OnError func(ctx context.Context, message *Message, err error) `json:"-"`
+	// Deduplicator, when set, skips messages whose ID it has seen and records the ID of
+	// every message handled successfully. Skipped messages are acked. Kafka and in-memory
+	// IDs are always stable. RabbitMQ and NATS publishes from this package carry an ID;
+	// other publishers must set the AMQP message-id or the Nats-Msg-Id header.
+	Deduplicator Deduplicator `json:"-"`
 }
 
 // TopicOptions contains options for creating topics/queues
@@ -126,21 +230,55 @@ type BrokerConfig struct {
 	// RabbitMQ configuration
 	RabbitMQURL      string `json:"rabbitmq_url"`
 	RabbitMQExchange string `json:"rabbitmq_exchange"`
-	RabbitMQVHost    string `json:"rabbitmq_vhost"`
+	// RabbitMQExchangeType is the kind of exchange RabbitMQExchange is declared as: "topic"
+	// (default), "direct", "fanout" or "headers"
+	RabbitMQExchangeType string `json:"rabbitmq_exchange_type"`
+	RabbitMQVHost        string `json:"rabbitmq_vhost"`
+	// RabbitMQDelayedExchange names an x-delayed-message exchange, declared on Connect, that
+	// publishes with PublishOptions.Delay go through. It needs the delayed message exchange
+	// plugin; without it delayed messages wait in per-delay TTL queues instead.
+	RabbitMQDelayedExchange string `json:"rabbitmq_delayed_exchange"`
+	// RabbitMQManagementURL is the base URL of the management plugin's HTTP API, e.g.
+	// http://localhost:15672. When set, ListTopics lists queues through it. The management
+	// credentials default to Username and Password.
+	RabbitMQManagementURL      string `json:"rabbitmq_management_url"`
+	RabbitMQManagementUsername string `json:"rabbitmq_management_username"`
+	RabbitMQManagementPassword string `json:"rabbitmq_management_password"`
 
 	// NATS configuration
 	NATSURL     string   `json:"nats_url"`
 	NATSCluster string   `json:"nats_cluster"`
 	NATSServers []string `json:"nats_servers"`
+	// NATSJetStream backs the NATS broker with JetStream: topics are streams, publishes are
+	// persisted and acknowledged by the server, and every subscription is a durable pull
+	// consumer that acks on success and redelivers on handler error.
+	NATSJetStream bool `json:"nats_jetstream"`
 
 	// Kafka configuration
-	KafkaURL              string   `json:"kafka_url"`
-	KafkaBrokers          []string `json:"kafka_brokers"`
-	KafkaConsumerGroup    string   `json:"kafka_consumer_group"`
-	KafkaSASLMechanism    string   `json:"kafka_sasl_mechanism"`
-	KafkaSecurityProtocol string   `json:"kafka_security_protocol"`
+	KafkaURL           string   `json:"kafka_url"`
+	KafkaBrokers       []string `json:"kafka_brokers"`
+	KafkaConsumerGroup string   `json:"kafka_consumer_group"`
+	// KafkaSASLMechanism is PLAIN (default), SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	KafkaSASLMechanism string `json:"kafka_sasl_mechanism"`
+	// KafkaSecurityProtocol is PLAINTEXT, SSL, SASL_PLAINTEXT or SASL_SSL and decides whether
+	// TLS and SASL are used. When empty TLS follows TLSEnabled and SASL is used whenever
+	// credentials are set.
+	KafkaSecurityProtocol string `json:"kafka_security_protocol"`
+	// KafkaTokenProvider supplies the tokens for SASL OAUTHBEARER, for example signed AWS MSK
+	// IAM tokens. Without one, Token is sent as a fixed bearer token.
+	KafkaTokenProvider sarama.AccessTokenProvider `json:"-"`
+	// KafkaIdempotentProducer stops producer retries from writing duplicates. It needs
+	// KafkaRequiredAcks "all" and limits each broker connection to one in-flight request.
+	KafkaIdempotentProducer bool `json:"kafka_idempotent_producer"`
+	// KafkaRequiredAcks is how many replicas must acknowledge a write: "all" (default),
+	// "leader" or "none". Fewer acks raise throughput at the risk of losing messages.
+	KafkaRequiredAcks string `json:"kafka_required_acks"`
+	// KafkaVersion is the protocol version spoken to the brokers, such as "3.6.0". Empty or
+	// unparsable values fall back to 2.8.0.
+	KafkaVersion string `json:"kafka_version"`
 
-	// Connection settings
+	// Connection settings. A lost connection is retried MaxReconnects times, 60 when zero
+	// and without limit when negative, waiting ReconnectWait (2s when zero) before each try.
 	MaxReconnects int           `json:"max_reconnects"`
 	ReconnectWait time.Duration `json:"reconnect_wait"`
 	Timeout       time.Duration `json:"timeout"`
@@ -160,4 +298,42 @@ type BrokerConfig struct {
 	TLSKeyFile    string `json:"tls_key_file"`
 	TLSCAFile     string `json:"tls_ca_file"`
 	TLSSkipVerify bool   `json:"tls_skip_verify"`
+
+	// In-memory configuration: number of published messages kept per topic for replay (0 keeps none)
+	InMemoryRetention int `json:"in_memory_retention"`
+
+	// Logger receives background errors; the standard logrus logger is used when nil
+	Logger *logrus.Logger `json:"-"`
+	// LogSampleInterval collapses repeated identical background errors: the first is logged
+	// and further ones within the interval are reported as one summary entry. 0 logs each.
+	LogSampleInterval time.Duration `json:"log_sample_interval"`
+	sampler           *logSampler
+	samplerOnce       sync.Once
+
+	// PublishInterceptors wrap every Publish, PublishJSON and PublishBatch call, first one outermost
+	PublishInterceptors []PublishInterceptor `json:"-"`
+	// OnPublishError receives delivery failures of messages the broker confirms in the
+	// background, such as those sent with PublishAsync. They are logged when it is nil.
+	OnPublishError func(topic string, err error) `json:"-"`
+	// Serializer encodes the values given to PublishJSON; JSONSerializer is used when nil
+	Serializer Serializer `json:"-"`
+	// TracePropagator, when set, injects the span context of the publishing context into
+	// message headers and extracts it into the context handed to subscription handlers,
+	// so a trace continues from producer to consumer. Nil disables propagation.
+	TracePropagator propagation.TextMapPropagator `json:"-"`
+	// Metrics, when set, observes the latency and outcome of every publish and handler call
+	Metrics MetricsCollector `json:"-"`
+	// DisconnectTimeout bounds how long Disconnect waits for handlers to finish the messages
+	// they are processing before subscriptions are canceled; 30 seconds when zero
+	DisconnectTimeout time.Duration `json:"disconnect_timeout"`
+	// OnReconnect is called after every attempt to restore a lost connection with the
+	// attempt number, counting from 1, and its error, nil once reconnected
+	OnReconnect func(broker BrokerType, attempt int, err error) `json:"-"`
+}
+
+func (c *BrokerConfig) log() *logrus.Logger {
+	if c == nil || c.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return c.Logger
 }
@@ -0,0 +1,178 @@
+package messagebroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderRetryAttempt counts the deliveries of a message through a RetryQueue
+	HeaderRetryAttempt = "X-Retry-Attempt"
+	// HeaderRetryNotBefore holds the time, in RFC 3339, before which a queued retry must not run
+	HeaderRetryNotBefore = "X-Retry-Not-Before"
+)
+
+// RetryQueueOptions configures a RetryQueue
+type RetryQueueOptions struct {
+	// MaxAttempts is the number of times the handler runs before the message is dead-lettered
+	MaxAttempts int
+	// Delay is how long a failed message waits in the retry queue. RetryableError.Delay
+	// overrides it for a single failure.
+	Delay time.Duration
+	// RetrySuffix names the retry queue of a topic; defaults to ".retry"
+	RetrySuffix string
+	// DeadLetterSuffix names the dead-letter topic when SubscribeOptions.DeadLetterTopic is
+	// empty; defaults to ".dlq"
+	DeadLetterSuffix string
+}
+
+// RetryQueue retries failed messages through a second, per-topic queue instead of inside
+// the consumer. A failed message is republished to "<topic>.retry" with PublishOptions.Delay
+// and an incremented X-Retry-Attempt header, and the consumer moves on to fresh messages.
+// A retry consumer re-dispatches it to the same handler once the delay has passed. After
+// MaxAttempts it goes to the dead-letter topic.
+//
+// X-Retry-Not-Before is also checked, so the delay holds on brokers that deliver delayed
+// messages early.
+type RetryQueue struct {
+	broker  MessageBroker
+	options RetryQueueOptions
+}
+
+// NewRetryQueue creates a RetryQueue publishing and consuming through broker
+func NewRetryQueue(broker MessageBroker, options RetryQueueOptions) (*RetryQueue, error) {
+	if broker == nil {
+		return nil, errors.New("broker is required")
+	}
+	if options.MaxAttempts <= 0 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	if options.Delay < 0 {
+		return nil, errors.New("retry delay cannot be negative")
+	}
+	if options.RetrySuffix == "" {
+		options.RetrySuffix = ".retry"
+	}
+	if options.DeadLetterSuffix == "" {
+		options.DeadLetterSuffix = ".dlq"
+	}
+
+	return &RetryQueue{broker: broker, options: options}, nil
+}
+
+// RetryTopic returns the retry queue used for topic
+func (q *RetryQueue) RetryTopic(topic string) string {
+	return topic + q.options.RetrySuffix
+}
+
+// Subscribe consumes topic and its retry queue with handler. In-consumer retries are
+// disabled on both subscriptions; QueueName, when set, gets the retry suffix for the retry
+// consumer.
+func (q *RetryQueue) Subscribe(ctx context.Context, topic string, handler MessageHandler, options *SubscribeOptions) error {
+	main := SubscribeOptions{AutoAck: true, Concurrency: 1}
+	if options != nil {
+		main = *options
+	}
+	main.MaxRetries = 0
+	main.Redeliver = false
+
+	deadLetter := main.DeadLetterTopic
+	if deadLetter == "" {
+		deadLetter = topic + q.options.DeadLetterSuffix
+	}
+	main.DeadLetterTopic = ""
+
+	retry := main
+	if retry.QueueName != "" {
+		retry.QueueName += q.options.RetrySuffix
+	}
+
+	dispatch := func(ctx context.Context, message *Message) error {
+		return q.dispatch(ctx, topic, deadLetter, handler, message)
+	}
+
+	if err := q.broker.Subscribe(ctx, topic, dispatch, &main); err != nil {
+		return err
+	}
+	if err := q.broker.Subscribe(ctx, q.RetryTopic(topic), q.waitForRetry(dispatch), &retry); err != nil {
+		_ = q.broker.Unsubscribe(ctx, topic)
+		return fmt.Errorf("failed to subscribe to retry queue: %w", err)
+	}
+	return nil
+}
+
+// Unsubscribe stops consuming topic and its retry queue
+func (q *RetryQueue) Unsubscribe(ctx context.Context, topic string) error {
+	return errors.Join(q.broker.Unsubscribe(ctx, topic), q.broker.Unsubscribe(ctx, q.RetryTopic(topic)))
+}
+
+// dispatch runs handler once and routes a failure to the retry queue or the dead-letter
+// topic. Only a failed publish is returned, leaving the message to the broker's redelivery.
+func (q *RetryQueue) dispatch(ctx context.Context, topic, deadLetter string, handler MessageHandler, message *Message) error {
+	attempt, _ := strconv.Atoi(message.Headers[HeaderRetryAttempt])
+	if attempt < 1 {
+		attempt = 1
+	}
+	message.Topic = topic
+	message.Retry = attempt - 1
+	message.MaxRetries = q.options.MaxAttempts - 1
+
+	// The subscription has already decoded the payload under its own options
+	err := callHandler(ctx, handler, message, nil)
+	if err == nil {
+		return nil
+	}
+
+	headers := make(map[string]string, len(message.Headers)+2)
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	delete(headers, HeaderRetryNotBefore)
+	// The partition and offset describe where the message was consumed from. Republished,
+	// the partition header would pin it there or fail on a topic with fewer partitions.
+	delete(headers, HeaderKafkaPartition)
+	delete(headers, "kafka.offset")
+
+	if IsPermanent(err) || attempt >= q.options.MaxAttempts {
+		headers[HeaderRetryAttempt] = strconv.Itoa(attempt)
+		if publishErr := q.broker.Publish(ctx, deadLetter, message.Data, &PublishOptions{Headers: headers}); publishErr != nil {
+			return fmt.Errorf("failed to dead-letter message after %d attempts: %w", attempt, publishErr)
+		}
+		return nil
+	}
+
+	delay := q.options.Delay
+	var retryable *RetryableError
+	if errors.As(err, &retryable) && retryable.Delay > 0 {
+		delay = retryable.Delay
+	}
+
+	headers[HeaderRetryAttempt] = strconv.Itoa(attempt + 1)
+	headers[HeaderRetryNotBefore] = time.Now().Add(delay).UTC().Format(time.RFC3339Nano)
+	if publishErr := q.broker.Publish(ctx, q.RetryTopic(topic), message.Data, &PublishOptions{Headers: headers, Delay: delay}); publishErr != nil {
+		return fmt.Errorf("failed to queue retry %d: %w", attempt+1, publishErr)
+	}
+	return nil
+}
+
+// waitForRetry holds a retry until its X-Retry-Not-Before time before dispatching it
+func (q *RetryQueue) waitForRetry(dispatch MessageHandler) MessageHandler {
+	return func(ctx context.Context, message *Message) error {
+		notBefore, err := time.Parse(time.RFC3339Nano, message.Headers[HeaderRetryNotBefore])
+		if err == nil {
+			if wait := time.Until(notBefore); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+		return dispatch(ctx, message)
+	}
+}
@@ -0,0 +1,144 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attemptLog records handler invocations from the in-memory broker's timer goroutines
+type attemptLog struct {
+	mutex sync.Mutex
+	times []time.Time
+	data  []string
+}
+
+func (l *attemptLog) record(message *messagebroker.Message) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.times = append(l.times, time.Now())
+	l.data = append(l.data, string(message.Data))
+}
+
+func (l *attemptLog) count() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.times)
+}
+
+func TestRetryQueueRetriesAfterDelay(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	queue, err := messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 3, Delay: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	attempts := &attemptLog{}
+	handler := func(ctx context.Context, message *messagebroker.Message) error {
+		attempts.record(message)
+		if message.Retry == 0 && string(message.Data) == "flaky" {
+			return errors.New("downstream unavailable")
+		}
+		return nil
+	}
+	require.NoError(t, queue.Subscribe(context.Background(), "orders", handler, nil))
+
+	published := time.Now()
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("flaky"), nil))
+	// The failed message is parked, so the next one is handled right away
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("fresh"), nil))
+	assert.Equal(t, []string{"flaky", "fresh"}, attempts.data)
+
+	require.Eventually(t, func() bool { return attempts.count() == 3 }, time.Second, 5*time.Millisecond)
+	attempts.mutex.Lock()
+	defer attempts.mutex.Unlock()
+	assert.Equal(t, "flaky", attempts.data[2])
+	assert.GreaterOrEqual(t, attempts.times[2].Sub(published), 50*time.Millisecond)
+}
+
+func TestRetryQueueDeadLettersAfterMaxAttempts(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(10))
+	queue, err := messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 3, Delay: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	attempts := &attemptLog{}
+	handler := func(ctx context.Context, message *messagebroker.Message) error {
+		attempts.record(message)
+		return errors.New("always fails")
+	}
+	require.NoError(t, queue.Subscribe(context.Background(), "orders", handler, nil))
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("poison"), &messagebroker.PublishOptions{
+		Headers: map[string]string{"X-Source": "test"},
+	}))
+
+	require.Eventually(t, func() bool { return len(broker.History("orders.dlq")) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, attempts.count())
+
+	dead := broker.History("orders.dlq")[0]
+	assert.Equal(t, "poison", string(dead.Data))
+	assert.Equal(t, "3", dead.Headers[messagebroker.HeaderRetryAttempt])
+	assert.Equal(t, "test", dead.Headers["X-Source"])
+	assert.Len(t, broker.History("orders.retry"), 2)
+}
+
+func TestRetryQueueDeadLettersPermanentErrorsImmediately(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(10))
+	queue, err := messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 5, Delay: time.Hour})
+	require.NoError(t, err)
+
+	handler := func(ctx context.Context, message *messagebroker.Message) error {
+		return messagebroker.Permanent(errors.New("malformed payload"))
+	}
+	options := &messagebroker.SubscribeOptions{DeadLetterTopic: "orders.invalid"}
+	require.NoError(t, queue.Subscribe(context.Background(), "orders", handler, options))
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("bad"), nil))
+
+	assert.Len(t, broker.History("orders.invalid"), 1)
+	assert.Empty(t, broker.History("orders.retry"))
+}
+
+func TestNewRetryQueueValidates(t *testing.T) {
+	broker := newInMemoryBroker(t)
+
+	_, err := messagebroker.NewRetryQueue(nil, messagebroker.RetryQueueOptions{MaxAttempts: 1})
+	assert.Error(t, err)
+	_, err = messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{})
+	assert.Error(t, err)
+	_, err = messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 1, Delay: -time.Second})
+	assert.Error(t, err)
+}
+
+func TestRetryQueueDropsConsumedKafkaPosition(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	queue, err := messagebroker.NewRetryQueue(broker, messagebroker.RetryQueueOptions{MaxAttempts: 2, Delay: time.Millisecond})
+	require.NoError(t, err)
+
+	retried := make(chan map[string]string, 1)
+	handler := func(ctx context.Context, message *messagebroker.Message) error {
+		if message.Retry == 0 {
+			return errors.New("downstream unavailable")
+		}
+		retried <- message.Headers
+		return nil
+	}
+	require.NoError(t, queue.Subscribe(context.Background(), "orders", handler, nil))
+
+	require.NoError(t, broker.Publish(context.Background(), "orders", []byte("order"), &messagebroker.PublishOptions{Headers: map[string]string{
+		messagebroker.HeaderKafkaKey:       "customer-1",
+		messagebroker.HeaderKafkaPartition: "7",
+		"kafka.offset":                     "42",
+	}}))
+
+	select {
+	case headers := <-retried:
+		assert.Equal(t, "customer-1", headers[messagebroker.HeaderKafkaKey])
+		assert.NotContains(t, headers, messagebroker.HeaderKafkaPartition)
+		assert.NotContains(t, headers, "kafka.offset")
+	case <-time.After(time.Second):
+		t.Fatal("message was not retried")
+	}
+}
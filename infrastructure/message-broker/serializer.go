@@ -0,0 +1,83 @@
+package messagebroker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// HeaderContentType carries the content type of a serialized payload
+const HeaderContentType = "Content-Type"
+
+// Serializer encodes values published with PublishJSON and CreateBatchMessage. Register one
+// with WithSerializer to publish Avro, protobuf or schema-validated payloads instead of
+// plain JSON.
+type Serializer interface {
+	// Serialize encodes v and returns the payload with its content type
+	Serialize(v interface{}) ([]byte, string, error)
+}
+
+// Deserializer decodes payloads for TypedMessageHandler. contentType is the message's
+// Content-Type header, empty when the publisher did not set one.
+type Deserializer interface {
+	Deserialize(data []byte, contentType string, v interface{}) error
+}
+
+// JSONSerializer is the default Serializer, and a Deserializer for TypedMessageHandler that
+// decodes like JSONMessageHandler
+type JSONSerializer struct{}
+
+// Serialize encodes v with json.Marshal
+func (JSONSerializer) Serialize(v interface{}) ([]byte, string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "application/json", nil
+}
+
+// Deserialize decodes data with json.Unmarshal, whatever the content type
+func (JSONSerializer) Deserialize(data []byte, contentType string, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// serializer returns the configured Serializer, JSON when none is set
+func (c *BrokerConfig) serializer() Serializer {
+	if c == nil || c.Serializer == nil {
+		return JSONSerializer{}
+	}
+	return c.Serializer
+}
+
+// serializeForPublish encodes message with the configured serializer and returns it with
+// publish options carrying its content type, both as the Content-Type header and, unless
+// the caller chose one, as PublishOptions.ContentType. The caller's options are not modified.
+func serializeForPublish(config *BrokerConfig, message interface{}, options *PublishOptions) ([]byte, *PublishOptions, error) {
+	data, contentType, err := config.serializer().Serialize(message)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	serialized := batchMessageOptions(options, nil)
+	if contentType != "" {
+		serialized.Headers[HeaderContentType] = contentType
+		if serialized.ContentType == "" {
+			serialized.ContentType = contentType
+		}
+	}
+	return data, serialized, nil
+}
+
+// TypedMessageHandler creates a handler that decodes each message into T with deserializer
+// before calling handler. A payload that cannot be decoded fails permanently, since
+// retrying will not change it.
+func TypedMessageHandler[T any](deserializer Deserializer, handler func(ctx context.Context, data T) error) MessageHandler {
+	return func(ctx context.Context, message *Message) error {
+		var data T
+		if err := deserializer.Deserialize(message.Data, message.Headers[HeaderContentType], &data); err != nil {
+			return Permanent(fmt.Errorf("failed to deserialize message from %s: %w", message.Topic, err))
+		}
+
+		return handler(ctx, data)
+	}
+}
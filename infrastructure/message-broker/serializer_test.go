@@ -0,0 +1,103 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reading struct {
+	Sensor string
+	Value  int
+}
+
+// readingCodec encodes readings as "sensor=value" lines, standing in for a binary format
+type readingCodec struct{}
+
+func (readingCodec) Serialize(v interface{}) ([]byte, string, error) {
+	r, ok := v.(reading)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported type %T", v)
+	}
+	return []byte(fmt.Sprintf("%s=%d", r.Sensor, r.Value)), "text/x-reading", nil
+}
+
+func (readingCodec) Deserialize(data []byte, contentType string, v interface{}) error {
+	if contentType != "text/x-reading" {
+		return fmt.Errorf("unexpected content type %q", contentType)
+	}
+	sensor, value, found := strings.Cut(string(data), "=")
+	if !found {
+		return errors.New("missing '='")
+	}
+	r := v.(*reading)
+	r.Sensor = sensor
+	_, err := fmt.Sscan(value, &r.Value)
+	return err
+}
+
+func TestPublishJSONUsesConfiguredSerializer(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithSerializer(readingCodec{}))
+	ctx := context.Background()
+
+	received := make(chan reading, 1)
+	require.NoError(t, broker.Subscribe(ctx, "readings", messagebroker.TypedMessageHandler(readingCodec{}, func(ctx context.Context, r reading) error {
+		received <- r
+		return nil
+	}), nil))
+
+	options := &messagebroker.PublishOptions{Headers: map[string]string{"X-Site": "north"}}
+	require.NoError(t, broker.PublishJSON(ctx, "readings", reading{Sensor: "t1", Value: 21}, options))
+	assert.Equal(t, reading{Sensor: "t1", Value: 21}, <-received)
+	assert.NotContains(t, options.Headers, messagebroker.HeaderContentType, "caller's headers are not modified")
+
+	err := broker.PublishJSON(ctx, "readings", "not a reading", nil)
+	assert.ErrorContains(t, err, "failed to serialize message")
+}
+
+func TestPublishJSONDefaultsToJSON(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(1))
+	ctx := context.Background()
+
+	require.NoError(t, broker.PublishJSON(ctx, "readings", reading{Sensor: "t1", Value: 21}, nil))
+
+	history := broker.History("readings")
+	require.Len(t, history, 1)
+	assert.JSONEq(t, `{"Sensor":"t1","Value":21}`, string(history[0].Data))
+	assert.Equal(t, "application/json", history[0].Headers[messagebroker.HeaderContentType])
+}
+
+func TestTypedMessageHandlerFailsPermanentlyOnUndecodablePayload(t *testing.T) {
+	handler := messagebroker.TypedMessageHandler(readingCodec{}, func(ctx context.Context, r reading) error {
+		t.Fatal("handler called with undecodable payload")
+		return nil
+	})
+
+	err := handler(context.Background(), &messagebroker.Message{
+		Topic:   "readings",
+		Data:    []byte("garbage"),
+		Headers: map[string]string{messagebroker.HeaderContentType: "text/x-reading"},
+	})
+	assert.True(t, messagebroker.IsPermanent(err))
+}
+
+func TestCreateBatchMessageWithSerializer(t *testing.T) {
+	headers := map[string]string{"X-Site": "north"}
+	message, err := messagebroker.CreateBatchMessageWithSerializer(readingCodec{}, "readings", reading{Sensor: "t2", Value: 7}, headers)
+	require.NoError(t, err)
+
+	assert.Equal(t, "t2=7", string(message.Data))
+	assert.Equal(t, map[string]string{"X-Site": "north", messagebroker.HeaderContentType: "text/x-reading"}, message.Headers)
+	assert.Len(t, headers, 1, "caller's headers are not modified")
+
+	// Raw payloads are passed through without a content type
+	message, err = messagebroker.CreateBatchMessageWithSerializer(readingCodec{}, "readings", []byte("raw"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, message.Headers)
+}
@@ -0,0 +1,66 @@
+package messagebroker
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// messageCounters counts messages published and successfully handled by a broker, in
+// total and per topic. It is safe for concurrent use.
+type messageCounters struct {
+	published atomic.Int64
+	consumed  atomic.Int64
+	topics    sync.Map // topic -> *topicCounters
+}
+
+type topicCounters struct {
+	published atomic.Int64
+	consumed  atomic.Int64
+}
+
+func (c *messageCounters) topic(name string) *topicCounters {
+	if counters, ok := c.topics.Load(name); ok {
+		return counters.(*topicCounters)
+	}
+	counters, _ := c.topics.LoadOrStore(name, &topicCounters{})
+	return counters.(*topicCounters)
+}
+
+// recordPublished counts a message the broker accepted for topic
+func (c *messageCounters) recordPublished(topic string) {
+	c.published.Add(1)
+	c.topic(topic).published.Add(1)
+}
+
+// recordConsumed counts a message from topic whose handler succeeded
+func (c *messageCounters) recordConsumed(topic string) {
+	c.consumed.Add(1)
+	c.topic(topic).consumed.Add(1)
+}
+
+// apply sets the totals on stats and fills in the per-topic counts, adding a TopicStats
+// entry for every counted topic that stats does not list yet. Topics are sorted by name.
+func (c *messageCounters) apply(stats *BrokerStats) {
+	stats.MessagesPublished = c.published.Load()
+	stats.MessagesConsumed = c.consumed.Load()
+
+	index := make(map[string]int, len(stats.Topics))
+	for i, topic := range stats.Topics {
+		index[topic.Name] = i
+	}
+
+	c.topics.Range(func(key, value any) bool {
+		name, counters := key.(string), value.(*topicCounters)
+		i, exists := index[name]
+		if !exists {
+			stats.Topics = append(stats.Topics, TopicStats{Name: name})
+			i = len(stats.Topics) - 1
+		}
+		stats.Topics[i].MessagesPublished = counters.published.Load()
+		stats.Topics[i].MessagesConsumed = counters.consumed.Load()
+		return true
+	})
+
+	sort.Slice(stats.Topics, func(i, j int) bool { return stats.Topics[i].Name < stats.Topics[j].Name })
+}
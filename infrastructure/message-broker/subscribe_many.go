@@ -0,0 +1,32 @@
+package messagebroker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SubscriptionHandle names the subscription SubscribeMany creates for topics. Unsubscribe,
+// Pause and Resume take it in place of a topic. For a single topic it is the topic itself.
+func SubscriptionHandle(topics []string) string {
+	return strings.Join(topics, ",")
+}
+
+// validateTopics checks the topic list passed to SubscribeMany
+func validateTopics(topics []string) error {
+	if len(topics) == 0 {
+		return errors.New("at least one topic is required")
+	}
+
+	seen := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if topic == "" {
+			return errors.New("topic names cannot be empty")
+		}
+		if seen[topic] {
+			return fmt.Errorf("topic %s is listed more than once", topic)
+		}
+		seen[topic] = true
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package messagebroker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeManyRejectsInvalidTopicLists(t *testing.T) {
+	broker := newInMemoryBroker(t)
+	handler := collect(new([]string))
+
+	for name, topics := range map[string][]string{
+		"empty":     nil,
+		"blank":     {"orders", ""},
+		"duplicate": {"orders", "orders"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := broker.SubscribeMany(context.Background(), topics, handler, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestInMemorySubscribeManyDeliversEveryTopic(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(10))
+	ctx := context.Background()
+
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("order-1"), nil))
+
+	var received []string
+	handle, err := broker.SubscribeMany(ctx, []string{"orders", "payments"}, collect(&received), nil)
+	require.NoError(t, err)
+	assert.Equal(t, messagebroker.SubscriptionHandle([]string{"orders", "payments"}), handle)
+
+	require.NoError(t, broker.Publish(ctx, "payments", []byte("payment-1"), nil))
+	require.NoError(t, broker.Publish(ctx, "refunds", []byte("refund-1"), nil))
+	assert.Equal(t, []string{"order-1", "payment-1"}, received)
+
+	require.NoError(t, broker.Unsubscribe(ctx, handle))
+	require.NoError(t, broker.Publish(ctx, "orders", []byte("order-2"), nil))
+	assert.Equal(t, []string{"order-1", "payment-1"}, received)
+}
+
+func TestNATSSubscribeManyUnsubscribesAllSubjects(t *testing.T) {
+	broker := newNATSBroker(t)
+	ctx := context.Background()
+
+	received := make(chan string, 4)
+	handle, err := broker.SubscribeMany(ctx, []string{"orders.created", "orders.paid"}, func(ctx context.Context, message *messagebroker.Message) error {
+		received <- message.Topic
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, broker.Publish(ctx, "orders.created", []byte("1"), nil))
+	require.NoError(t, broker.Publish(ctx, "orders.paid", []byte("1"), nil))
+	var topics []string
+	for len(topics) < 2 {
+		select {
+		case topic := <-received:
+			topics = append(topics, topic)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received only %v", topics)
+		}
+	}
+	assert.ElementsMatch(t, []string{"orders.created", "orders.paid"}, topics)
+
+	require.NoError(t, broker.Unsubscribe(ctx, handle))
+	require.NoError(t, broker.Publish(ctx, "orders.paid", []byte("2"), nil))
+	select {
+	case topic := <-received:
+		t.Fatalf("received %s after unsubscribing", topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,89 @@
+package messagebroker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM files in dir
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestConfigureKafkaSecurityLoadsCertificates(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+
+	saramaConfig := sarama.NewConfig()
+	require.NoError(t, configureKafkaSecurity(saramaConfig, &BrokerConfig{
+		TLSEnabled:    true,
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSCAFile:     certFile,
+		TLSSkipVerify: true,
+	}))
+
+	assert.True(t, saramaConfig.Net.TLS.Enable)
+	tlsConfig := saramaConfig.Net.TLS.Config
+	require.NotNil(t, tlsConfig)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+}
+
+func TestConfigureKafkaSecurityWithoutTLS(t *testing.T) {
+	saramaConfig := sarama.NewConfig()
+	require.NoError(t, configureKafkaSecurity(saramaConfig, &BrokerConfig{TLSCertFile: "missing.crt"}))
+
+	assert.False(t, saramaConfig.Net.TLS.Enable)
+	assert.Nil(t, saramaConfig.Net.TLS.Config)
+}
+
+func TestNewKafkaBrokerRejectsInvalidTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeCertificate(t, dir)
+
+	_, err := NewKafkaBroker(NewBrokerConfig(WithBrokers("localhost:9092"), WithTLSFromFiles(certFile, "", "")))
+	assert.ErrorContains(t, err, "both a cert file and a key file")
+
+	_, err = NewKafkaBroker(NewBrokerConfig(WithBrokers("localhost:9092"), WithTLSFromFiles("", "", filepath.Join(dir, "missing.pem"))))
+	assert.ErrorContains(t, err, "failed to read TLS CA file")
+
+	notPEM := filepath.Join(dir, "ca.txt")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+	_, err = NewKafkaBroker(NewBrokerConfig(WithBrokers("localhost:9092"), WithTLSFromFiles("", "", notPEM)))
+	assert.ErrorContains(t, err, "no certificates found")
+}
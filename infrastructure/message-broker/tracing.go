@@ -0,0 +1,86 @@
+package messagebroker
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans WithTracing starts
+const tracerName = "github.com/prayaspoudel/infrastructure/message-broker"
+
+// publishInterceptors returns the configured interceptors, preceded by trace context
+// injection when a TracePropagator is set so that the interceptors see the final headers
+func (c *BrokerConfig) publishInterceptors() []PublishInterceptor {
+	if c == nil {
+		return nil
+	}
+	if c.TracePropagator == nil {
+		return c.PublishInterceptors
+	}
+
+	interceptors := make([]PublishInterceptor, 0, len(c.PublishInterceptors)+1)
+	interceptors = append(interceptors, traceContextInterceptor(c.TracePropagator))
+	return append(interceptors, c.PublishInterceptors...)
+}
+
+// traceContextInterceptor writes the span context of the publishing ctx into the message
+// headers, e.g. as W3C traceparent and tracestate
+func traceContextInterceptor(propagator propagation.TextMapPropagator) PublishInterceptor {
+	return func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, topic string, message []byte, options *PublishOptions) error {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return next(ctx, topic, message, options)
+			}
+
+			traced := batchMessageOptions(options, nil)
+			propagator.Inject(ctx, propagation.MapCarrier(traced.Headers))
+			return next(ctx, topic, message, traced)
+		}
+	}
+}
+
+// extractingTraceContext wraps handler so that the context it receives carries the span
+// context found in the message headers. Without a TracePropagator handler is returned as is.
+func (c *BrokerConfig) extractingTraceContext(handler MessageHandler) MessageHandler {
+	if c == nil || c.TracePropagator == nil {
+		return handler
+	}
+
+	propagator := c.TracePropagator
+	return func(ctx context.Context, message *Message) error {
+		if len(message.Headers) > 0 {
+			ctx = propagator.Extract(ctx, propagation.MapCarrier(message.Headers))
+		}
+		return handler(ctx, message)
+	}
+}
+
+// WithTracing wraps handler in a consumer span from the global tracer provider. With trace
+// propagation enabled on the broker the span continues the publisher's trace. A handler
+// error is recorded on the span and marks it as failed.
+func WithTracing(handler MessageHandler) MessageHandler {
+	return func(ctx context.Context, message *Message) error {
+		ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("%s process", message.Topic),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.destination.name", message.Topic),
+				attribute.String("messaging.message.id", message.ID),
+				attribute.String("messaging.operation.type", "process"),
+			),
+		)
+		defer span.End()
+
+		err := handler(ctx, message)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,139 @@
+package messagebroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var publisherSpan = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+	SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	TraceFlags: trace.FlagsSampled,
+})
+
+func TestTracePropagationFromPublisherToHandler(t *testing.T) {
+	for name, broker := range map[string]messagebroker.MessageBroker{
+		"inmemory": newInMemoryBroker(t, messagebroker.WithTracePropagation()),
+		"nats":     newNATSBroker(t, messagebroker.WithTracePropagation()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			received := make(chan context.Context, 1)
+			headers := make(chan map[string]string, 1)
+			require.NoError(t, broker.Subscribe(context.Background(), "visits", func(ctx context.Context, message *messagebroker.Message) error {
+				headers <- message.Headers
+				received <- ctx
+				return nil
+			}, nil))
+
+			ctx := trace.ContextWithSpanContext(context.Background(), publisherSpan)
+			require.NoError(t, broker.PublishJSON(ctx, "visits", map[string]string{"patient": "1"}, nil))
+
+			select {
+			case ctx := <-received:
+				spanContext := trace.SpanContextFromContext(ctx)
+				assert.Equal(t, publisherSpan.TraceID(), spanContext.TraceID())
+				assert.Equal(t, publisherSpan.SpanID(), spanContext.SpanID())
+				assert.True(t, spanContext.IsRemote())
+				assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", (<-headers)["traceparent"])
+			case <-time.After(5 * time.Second):
+				t.Fatal("message was not delivered")
+			}
+		})
+	}
+}
+
+func TestTracePropagationIsOptIn(t *testing.T) {
+	broker := newInMemoryBroker(t, messagebroker.WithRetention(1))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), publisherSpan)
+	require.NoError(t, broker.Publish(ctx, "visits", []byte("1"), nil))
+
+	history := broker.History("visits")
+	require.Len(t, history, 1)
+	assert.NotContains(t, history[0].Headers, "traceparent")
+}
+
+// recordingSpan is a noop span that remembers how it ended
+type recordingSpan struct {
+	noop.Span
+	spanContext trace.SpanContext
+	name        string
+	config      trace.SpanConfig
+	status      codes.Code
+	errs        []error
+	ended       bool
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext                { return s.spanContext }
+func (s *recordingSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+func (s *recordingSpan) End(...trace.SpanEndOption)                    { s.ended = true }
+
+// recordingTracerProvider hands out tracers that record every span they start
+type recordingTracerProvider struct {
+	noop.TracerProvider
+	spans []*recordingSpan
+}
+
+func (p *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	provider *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{
+		name:   name,
+		config: trace.NewSpanStartConfig(options...),
+		spanContext: trace.SpanContextFromContext(ctx).WithSpanID(
+			trace.SpanID{byte(len(t.provider.spans) + 1)},
+		),
+	}
+	t.provider.spans = append(t.provider.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func useRecordingTracerProvider(t *testing.T) *recordingTracerProvider {
+	provider := &recordingTracerProvider{}
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return provider
+}
+
+func TestWithTracingStartsConsumerSpanInPublisherTrace(t *testing.T) {
+	provider := useRecordingTracerProvider(t)
+	broker := newInMemoryBroker(t, messagebroker.WithTracePropagation())
+
+	var handlerSpan trace.SpanContext
+	require.NoError(t, broker.Subscribe(context.Background(), "visits", messagebroker.WithTracing(func(ctx context.Context, message *messagebroker.Message) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return errors.New("ward full")
+	}), &messagebroker.SubscribeOptions{}))
+
+	ctx := trace.ContextWithSpanContext(context.Background(), publisherSpan)
+	require.NoError(t, broker.Publish(ctx, "visits", []byte("1"), nil))
+
+	require.Len(t, provider.spans, 1)
+	span := provider.spans[0]
+	assert.Equal(t, "visits process", span.name)
+	assert.Equal(t, trace.SpanKindConsumer, span.config.SpanKind())
+	assert.Equal(t, publisherSpan.TraceID(), span.spanContext.TraceID())
+	assert.Equal(t, span.spanContext, handlerSpan, "the handler runs inside the consumer span")
+	assert.Equal(t, codes.Error, span.status)
+	assert.EqualError(t, span.errs[0], "ward full")
+	assert.True(t, span.ended)
+}
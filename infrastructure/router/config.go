@@ -18,6 +18,7 @@ type config struct {
 	ctxTimeout    time.Duration
 	webServerPort Port
 	webServer     Server
+	maxInFlight   int
 }
 
 func NewConfig() *config {
@@ -29,6 +30,13 @@ func (c *config) ContextTimeout(t time.Duration) *config {
 	return c
 }
 
+// MaxInFlight caps the requests the web server handles at once; excess requests get 503.
+// It must be set before WebServer; DefaultMaxInFlight applies when unset.
+func (c *config) MaxInFlight(n int) *config {
+	c.maxInFlight = n
+	return c
+}
+
 func (c *config) Name(name string) *config {
 	c.appName = name
 	return c
@@ -90,6 +98,7 @@ func (c *config) WebServer(instance int) *config {
 		c.validator,
 		c.webServerPort,
 		c.ctxTimeout,
+		c.maxInFlight,
 	)
 
 	if err != nil {
@@ -28,12 +28,13 @@ func NewWebServerFactory(
 	validator validator.Validator,
 	port Port,
 	ctxTimeout time.Duration,
+	maxInFlight int,
 ) (Server, error) {
 	switch instance {
 	case InstanceGorillaMux:
-		return newGorillaMux(log, dbSQL, validator, port, ctxTimeout), nil
+		return newGorillaMux(log, dbSQL, validator, port, ctxTimeout, maxInFlight), nil
 	case InstanceGin:
-		return newGinServer(log, dbNoSQL, validator, port, ctxTimeout), nil
+		return newGinServer(log, dbNoSQL, validator, port, ctxTimeout, maxInFlight), nil
 	default:
 		return nil, errInvalidWebServerInstance
 	}
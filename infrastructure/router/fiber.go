@@ -1,16 +1,51 @@
 package router
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/spf13/viper"
 )
 
-// NewFiberApp creates a new Fiber application instance based on configuration
+// Defaults applied when the corresponding web.* key is not set
+const (
+	DefaultBodyLimit    = 4 * 1024 * 1024
+	DefaultReadTimeout  = 10 * time.Second
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+// NewFiberApp creates a new Fiber application instance based on configuration.
+// web.body_limit accepts a byte count or a size such as "2MB"; web.read_timeout and
+// web.write_timeout accept durations such as "15s".
 func NewFiberApp(config *viper.Viper) *fiber.App {
+	bodyLimit := int(config.GetSizeInBytes("web.body_limit"))
+	if bodyLimit <= 0 {
+		bodyLimit = DefaultBodyLimit
+	}
+
+	readTimeout := config.GetDuration("web.read_timeout")
+	if readTimeout <= 0 {
+		readTimeout = DefaultReadTimeout
+	}
+
+	writeTimeout := config.GetDuration("web.write_timeout")
+	if writeTimeout <= 0 {
+		writeTimeout = DefaultWriteTimeout
+	}
+
+	concurrency := config.GetInt("web.concurrency")
+	if concurrency <= 0 {
+		concurrency = fiber.DefaultConcurrency
+	}
+
 	var app = fiber.New(fiber.Config{
 		AppName:      config.GetString("app.name"),
 		ErrorHandler: NewFiberErrorHandler(),
 		Prefork:      config.GetBool("web.prefork"),
+		BodyLimit:    bodyLimit,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		Concurrency:  concurrency,
 	})
 
 	return app
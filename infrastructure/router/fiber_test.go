@@ -0,0 +1,57 @@
+package router_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/infrastructure/router"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFiberAppAppliesWebConfig(t *testing.T) {
+	config := viper.New()
+	config.Set("app.name", "sso")
+	config.Set("web.body_limit", "1MB")
+	config.Set("web.read_timeout", "5s")
+	config.Set("web.write_timeout", "7s")
+	config.Set("web.concurrency", 512)
+
+	app := router.NewFiberApp(config)
+	fiberConfig := app.Config()
+
+	assert.Equal(t, "sso", fiberConfig.AppName)
+	assert.Equal(t, 1024*1024, fiberConfig.BodyLimit)
+	assert.Equal(t, 5*time.Second, fiberConfig.ReadTimeout)
+	assert.Equal(t, 7*time.Second, fiberConfig.WriteTimeout)
+	assert.Equal(t, 512, fiberConfig.Concurrency)
+}
+
+func TestNewFiberAppDefaults(t *testing.T) {
+	fiberConfig := router.NewFiberApp(viper.New()).Config()
+
+	assert.Equal(t, router.DefaultBodyLimit, fiberConfig.BodyLimit)
+	assert.Equal(t, router.DefaultReadTimeout, fiberConfig.ReadTimeout)
+	assert.Equal(t, router.DefaultWriteTimeout, fiberConfig.WriteTimeout)
+	assert.Equal(t, fiber.DefaultConcurrency, fiberConfig.Concurrency)
+}
+
+func TestNewFiberAppRejectsBodiesOverLimit(t *testing.T) {
+	config := viper.New()
+	config.Set("web.body_limit", 16)
+
+	app := router.NewFiberApp(config)
+	app.Post("/", func(ctx *fiber.Ctx) error { return ctx.SendStatus(fiber.StatusNoContent) })
+
+	// fasthttp rejects the oversized body while reading it and closes the connection
+	_, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(strings.Repeat("x", 64))))
+	assert.ErrorContains(t, err, "body size exceeds")
+
+	response, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader("small")))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, response.StatusCode)
+}
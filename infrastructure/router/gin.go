@@ -16,12 +16,13 @@ import (
 )
 
 type ginEngine struct {
-	router     *gin.Engine
-	log        logger.Logger
-	db         database.NoSQL
-	validator  validator.Validator
-	port       Port
-	ctxTimeout time.Duration
+	router      *gin.Engine
+	log         logger.Logger
+	db          database.NoSQL
+	validator   validator.Validator
+	port        Port
+	ctxTimeout  time.Duration
+	maxInFlight int
 }
 
 func newGinServer(
@@ -30,14 +31,16 @@ func newGinServer(
 	validator validator.Validator,
 	port Port,
 	t time.Duration,
+	maxInFlight int,
 ) *ginEngine {
 	return &ginEngine{
-		router:     gin.New(),
-		log:        log,
-		db:         db,
-		validator:  validator,
-		port:       port,
-		ctxTimeout: t,
+		router:      gin.New(),
+		log:         log,
+		db:          db,
+		validator:   validator,
+		port:        port,
+		ctxTimeout:  t,
+		maxInFlight: maxInFlight,
 	}
 }
 
@@ -45,13 +48,11 @@ func (g ginEngine) Listen() {
 	gin.SetMode(gin.ReleaseMode)
 	gin.Recovery()
 
-	g.setAppHandlers(g.router)
-
 	server := &http.Server{
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		Addr:         fmt.Sprintf(":%d", g.port),
-		Handler:      g.router,
+		Handler:      g.handler(),
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -78,6 +79,12 @@ func (g ginEngine) Listen() {
 	g.log.Infof("Service down")
 }
 
+// handler registers the routes and caps the requests served at once
+func (g ginEngine) handler() http.Handler {
+	g.setAppHandlers(g.router)
+	return LimitInFlight(g.maxInFlight, g.router)
+}
+
 func (g ginEngine) setAppHandlers(router *gin.Engine) {
 	router.GET("/v1/health", g.healthcheck())
 }
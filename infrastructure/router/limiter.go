@@ -0,0 +1,29 @@
+package router
+
+import "net/http"
+
+// DefaultMaxInFlight is the number of requests a server handles at once when no limit is configured
+const DefaultMaxInFlight = 1000
+
+// LimitInFlight lets at most limit requests run through next at the same time. Requests
+// beyond the limit are answered with 503 straight away rather than queued, so a flood
+// cannot pile up goroutines and memory. A limit of 0 or less uses DefaultMaxInFlight.
+func LimitInFlight(limit int, next http.Handler) http.Handler {
+	if limit <= 0 {
+		limit = DefaultMaxInFlight
+	}
+
+	slots := make(chan struct{}, limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"errors":"server is busy"}`))
+		}
+	})
+}
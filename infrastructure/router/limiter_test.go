@@ -0,0 +1,114 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingRoute holds requests until release is closed, reporting each one as it starts
+type blockingRoute struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingRoute() *blockingRoute {
+	return &blockingRoute{started: make(chan struct{}, 8), release: make(chan struct{})}
+}
+
+func (b *blockingRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.started <- struct{}{}
+	<-b.release
+	w.WriteHeader(http.StatusOK)
+}
+
+func assertLimitsInFlight(t *testing.T, handler http.Handler, route *blockingRoute, limit int) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := http.Get(server.URL + "/v1/slow")
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			response.Body.Close()
+			statuses <- response.StatusCode
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		select {
+		case <-route.started:
+		case <-time.After(time.Second):
+			t.Fatal("under-limit request did not reach the handler")
+		}
+	}
+
+	// Every slot is taken, so further requests are turned away
+	for i := 0; i < 3; i++ {
+		response, err := http.Get(server.URL + "/v1/health")
+		require.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+		assert.Equal(t, "1", response.Header.Get("Retry-After"))
+	}
+
+	close(route.release)
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		assert.Equal(t, http.StatusOK, status)
+	}
+
+	// Released slots are available again
+	response, err := http.Get(server.URL + "/v1/health")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestGorillaMuxLimitsInFlightRequests(t *testing.T) {
+	route := newBlockingRoute()
+	server := newGorillaMux(nil, nil, nil, 0, 0, 2)
+	server.router.Handle("/v1/slow", route)
+
+	assertLimitsInFlight(t, server.handler(), route, 2)
+}
+
+func TestGinLimitsInFlightRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	route := newBlockingRoute()
+	server := newGinServer(nil, nil, nil, 0, 0, 3)
+	server.router.GET("/v1/slow", gin.WrapH(route))
+
+	assertLimitsInFlight(t, server.handler(), route, 3)
+}
+
+func TestLimitInFlightDefaultsWhenUnset(t *testing.T) {
+	route := newBlockingRoute()
+	close(route.release)
+	handler := LimitInFlight(0, route)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		}()
+	}
+	wg.Wait()
+}
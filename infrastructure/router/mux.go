@@ -16,12 +16,13 @@ import (
 )
 
 type gorillaMux struct {
-	router     *mux.Router
-	log        logger.Logger
-	db         database.SQL
-	validator  validator.Validator
-	port       Port
-	ctxTimeout time.Duration
+	router      *mux.Router
+	log         logger.Logger
+	db          database.SQL
+	validator   validator.Validator
+	port        Port
+	ctxTimeout  time.Duration
+	maxInFlight int
 }
 
 func newGorillaMux(
@@ -30,25 +31,25 @@ func newGorillaMux(
 	validator validator.Validator,
 	port Port,
 	t time.Duration,
+	maxInFlight int,
 ) *gorillaMux {
 	return &gorillaMux{
-		router:     mux.NewRouter(),
-		log:        log,
-		db:         db,
-		validator:  validator,
-		port:       port,
-		ctxTimeout: t,
+		router:      mux.NewRouter(),
+		log:         log,
+		db:          db,
+		validator:   validator,
+		port:        port,
+		ctxTimeout:  t,
+		maxInFlight: maxInFlight,
 	}
 }
 
 func (g gorillaMux) Listen() {
-	g.setAppHandlers(g.router)
-
 	server := &http.Server{
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		Addr:         fmt.Sprintf(":%d", g.port),
-		Handler:      g.router,
+		Handler:      g.handler(),
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -75,6 +76,12 @@ func (g gorillaMux) Listen() {
 	g.log.Infof("Service down")
 }
 
+// handler registers the routes and caps the requests served at once
+func (g gorillaMux) handler() http.Handler {
+	g.setAppHandlers(g.router)
+	return LimitInFlight(g.maxInFlight, g.router)
+}
+
 func (g gorillaMux) setAppHandlers(router *mux.Router) {
 	api := router.PathPrefix("/v1").Subrouter()
 	api.HandleFunc("/health", g.healthCheck).Methods(http.MethodGet)
@@ -0,0 +1,214 @@
+//go:build integration
+
+// Package testharness starts throwaway Kafka, RabbitMQ, NATS and Redis containers for
+// integration tests and hands back configs, brokers and caches pointed at them. It needs a
+// Docker daemon and is only built with the integration tag:
+//
+//	go test -tags integration ./infrastructure/...
+package testharness
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Images used by the harness. They are variables so CI can pin mirrors.
+var (
+	KafkaImage    = "apache/kafka:3.7.0"
+	RabbitMQImage = "rabbitmq:3.13-management-alpine"
+	NATSImage     = "nats:2.10-alpine"
+	RedisImage    = "redis:7-alpine"
+)
+
+// startupTimeout bounds how long a container may take to report that it is ready
+const startupTimeout = 2 * time.Minute
+
+// Kafka starts a single-node KRaft Kafka and returns a broker config for it
+func Kafka(t testing.TB) *messagebroker.BrokerConfig {
+	t.Helper()
+
+	// Kafka hands clients the advertised address, so it has to be known before the
+	// container starts. Bind a free host port to the same port inside the container.
+	port := freePort(t)
+	listener := fmt.Sprintf("%d/tcp", port)
+
+	request := testcontainers.ContainerRequest{
+		Image:        KafkaImage,
+		ExposedPorts: []string{listener},
+		Env: map[string]string{
+			"KAFKA_NODE_ID":                                  "1",
+			"KAFKA_PROCESS_ROLES":                            "broker,controller",
+			"KAFKA_LISTENERS":                                fmt.Sprintf("PLAINTEXT://:%d,CONTROLLER://:9093", port),
+			"KAFKA_ADVERTISED_LISTENERS":                     fmt.Sprintf("PLAINTEXT://localhost:%d", port),
+			"KAFKA_CONTROLLER_LISTENER_NAMES":                "CONTROLLER",
+			"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":           "CONTROLLER:PLAINTEXT,PLAINTEXT:PLAINTEXT",
+			"KAFKA_CONTROLLER_QUORUM_VOTERS":                 "1@localhost:9093",
+			"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR":         "1",
+			"KAFKA_TRANSACTION_STATE_LOG_REPLICATION_FACTOR": "1",
+			"KAFKA_TRANSACTION_STATE_LOG_MIN_ISR":            "1",
+			"KAFKA_GROUP_INITIAL_REBALANCE_DELAY_MS":         "0",
+		},
+		HostConfigModifier: func(hostConfig *container.HostConfig) {
+			hostConfig.PortBindings = nat.PortMap{
+				nat.Port(listener): {{HostIP: "0.0.0.0", HostPort: strconv.Itoa(port)}},
+			}
+		},
+		WaitingFor: wait.ForLog("Kafka Server started").WithStartupTimeout(startupTimeout),
+	}
+	start(t, request)
+
+	return messagebroker.NewBrokerConfig(
+		messagebroker.WithBrokers(fmt.Sprintf("localhost:%d", port)),
+	)
+}
+
+// RabbitMQ starts RabbitMQ and returns a broker config for it
+func RabbitMQ(t testing.TB) *messagebroker.BrokerConfig {
+	t.Helper()
+
+	endpoint := start(t, testcontainers.ContainerRequest{
+		Image:        RabbitMQImage,
+		ExposedPorts: []string{"5672/tcp"},
+		WaitingFor:   wait.ForLog("Server startup complete").WithStartupTimeout(startupTimeout),
+	}, "5672/tcp")
+
+	return messagebroker.NewBrokerConfig(
+		messagebroker.WithRabbitMQ("amqp://guest:guest@"+endpoint+"/", "evero-test"),
+	)
+}
+
+// NATS starts NATS with JetStream enabled and returns a broker config for it
+func NATS(t testing.TB) *messagebroker.BrokerConfig {
+	t.Helper()
+
+	endpoint := start(t, testcontainers.ContainerRequest{
+		Image:        NATSImage,
+		Cmd:          []string{"-js"},
+		ExposedPorts: []string{"4222/tcp"},
+		WaitingFor:   wait.ForLog("Server is ready").WithStartupTimeout(startupTimeout),
+	}, "4222/tcp")
+
+	return messagebroker.NewBrokerConfig(
+		messagebroker.WithNATSURL("nats://" + endpoint),
+	)
+}
+
+// Redis starts Redis and returns a cache config for it
+func Redis(t testing.TB) *cache.CacheConfig {
+	t.Helper()
+
+	endpoint := start(t, testcontainers.ContainerRequest{
+		Image:        RedisImage,
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections").WithStartupTimeout(startupTimeout),
+	}, "6379/tcp")
+
+	return &cache.CacheConfig{
+		RedisAddr:   endpoint,
+		DialTimeout: 5 * time.Second,
+	}
+}
+
+// BrokerConfig starts the container backing instance and returns a config for it. The
+// in-memory broker needs no container and gets the default config.
+func BrokerConfig(t testing.TB, instance int) *messagebroker.BrokerConfig {
+	t.Helper()
+
+	switch instance {
+	case messagebroker.InstanceKafka:
+		return Kafka(t)
+	case messagebroker.InstanceRabbitMQ:
+		return RabbitMQ(t)
+	case messagebroker.InstanceNATS:
+		return NATS(t)
+	case messagebroker.InstanceInMemory:
+		return messagebroker.NewBrokerConfig()
+	default:
+		t.Fatalf("testharness: no container for broker instance %d", instance)
+		return nil
+	}
+}
+
+// NewBroker returns a connected broker of the given instance backed by a fresh container.
+// The broker is closed when the test finishes.
+func NewBroker(t testing.TB, instance int) messagebroker.MessageBroker {
+	t.Helper()
+
+	broker, err := messagebroker.NewMessageBrokerFactory(instance, BrokerConfig(t, instance))
+	if err != nil {
+		t.Fatalf("testharness: create broker: %v", err)
+	}
+	if err := broker.Connect(context.Background()); err != nil {
+		t.Fatalf("testharness: connect broker: %v", err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+
+	return broker
+}
+
+// NewRedisCache returns a connected Redis cache backed by a fresh container. The cache is
+// closed when the test finishes.
+func NewRedisCache(t testing.TB) cache.CacheManager {
+	t.Helper()
+
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceRedis, Redis(t))
+	if err != nil {
+		t.Fatalf("testharness: create cache: %v", err)
+	}
+	if err := cacheManager.Connect(context.Background()); err != nil {
+		t.Fatalf("testharness: connect cache: %v", err)
+	}
+	t.Cleanup(func() { _ = cacheManager.Close() })
+
+	return cacheManager
+}
+
+// start runs the container, terminates it when the test finishes and returns the
+// host:port mapped to port, if one is given
+func start(t testing.TB, request testcontainers.ContainerRequest, port ...nat.Port) string {
+	t.Helper()
+
+	ctx := context.Background()
+	started, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: request,
+		Started:          true,
+	})
+	testcontainers.CleanupContainer(t, started)
+	if err != nil {
+		t.Fatalf("testharness: start %s: %v", request.Image, err)
+	}
+
+	if len(port) == 0 {
+		return ""
+	}
+
+	endpoint, err := started.PortEndpoint(ctx, port[0], "")
+	if err != nil {
+		t.Fatalf("testharness: resolve %s port %s: %v", request.Image, port[0], err)
+	}
+	return endpoint
+}
+
+// freePort asks the kernel for a TCP port that is currently unused
+func freePort(t testing.TB) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("testharness: find free port: %v", err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
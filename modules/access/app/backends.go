@@ -0,0 +1,144 @@
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/spf13/viper"
+)
+
+const (
+	brokerTypeNone = "none"
+	brokerTypeAuto = "auto"
+
+	cacheBackendNone   = "none"
+	cacheBackendRedis  = "redis"
+	cacheBackendMemory = "memory"
+)
+
+// NewBrokerFromConfig creates the message broker selected by broker.type: kafka, rabbitmq,
+// nats, auto (detected from whichever addresses are configured) or none. An empty type
+// means none, in which case a nil broker is returned. The broker is not connected.
+func NewBrokerFromConfig(config *viper.Viper) (messagebroker.MessageBroker, error) {
+	brokerType := strings.ToLower(strings.TrimSpace(config.GetString("broker.type")))
+	if brokerType == "" || brokerType == brokerTypeNone {
+		return nil, nil
+	}
+
+	brokerConfig := brokerConfigFromViper(config)
+	if brokerType == brokerTypeAuto {
+		return messagebroker.CreateBrokerAuto(brokerConfig)
+	}
+
+	if err := validateBrokerConfig(messagebroker.BrokerType(brokerType), brokerConfig); err != nil {
+		return nil, err
+	}
+	return messagebroker.CreateBroker(messagebroker.BrokerType(brokerType), brokerConfig)
+}
+
+// brokerConfigFromViper reads every backend's settings; only the selected one is used.
+// Kafka keeps its existing kafka.* keys so the producer and broker share one section.
+func brokerConfigFromViper(config *viper.Viper) *messagebroker.BrokerConfig {
+	var kafkaBrokers []string
+	for _, server := range strings.Split(config.GetString("kafka.bootstrap.servers"), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			kafkaBrokers = append(kafkaBrokers, server)
+		}
+	}
+
+	return messagebroker.NewBrokerConfig(
+		messagebroker.WithBrokers(kafkaBrokers...),
+		messagebroker.WithConsumerGroup(config.GetString("kafka.group.id")),
+		messagebroker.WithRabbitMQ(config.GetString("broker.rabbitmq.url"), config.GetString("broker.rabbitmq.exchange")),
+		messagebroker.WithNATSURL(config.GetString("broker.nats.url")),
+		messagebroker.WithNATSServers(config.GetStringSlice("broker.nats.servers")...),
+		messagebroker.WithCredentials(config.GetString("broker.username"), config.GetString("broker.password")),
+	)
+}
+
+// validateBrokerConfig reports the missing setting for the selected broker by its config key
+func validateBrokerConfig(brokerType messagebroker.BrokerType, brokerConfig *messagebroker.BrokerConfig) error {
+	switch brokerType {
+	case messagebroker.TypeKafka:
+		if len(brokerConfig.KafkaBrokers) == 0 {
+			return fmt.Errorf("broker.type is kafka but kafka.bootstrap.servers is empty")
+		}
+	case messagebroker.TypeRabbitMQ:
+		if brokerConfig.RabbitMQURL == "" {
+			return fmt.Errorf("broker.type is rabbitmq but broker.rabbitmq.url is empty")
+		}
+	case messagebroker.TypeNATS:
+		if brokerConfig.NATSURL == "" && len(brokerConfig.NATSServers) == 0 {
+			return fmt.Errorf("broker.type is nats but neither broker.nats.url nor broker.nats.servers is set")
+		}
+	default:
+		return fmt.Errorf("unsupported broker.type %q", brokerType)
+	}
+	return nil
+}
+
+// NewCacheFromConfig creates the cache selected by cache.backend: redis, memory or none.
+// An empty backend means none, in which case a nil cache is returned. The cache is not connected.
+func NewCacheFromConfig(config *viper.Viper) (cache.CacheManager, error) {
+	return newCacheFromSection(config, "cache")
+}
+
+// NewCacheRegistryFromConfig creates one cache per entry under caches, e.g. caches.session
+// and caches.reference. Each entry takes the same keys as the cache section, plus ttl, the
+// expiration of writes that do not set one, and prefix, which scopes its keys so entries can
+// share a Redis database. Entries with backend none are skipped. The caches are not connected.
+func NewCacheRegistryFromConfig(config *viper.Viper) (*cache.CacheRegistry, error) {
+	registry := cache.NewCacheRegistry()
+	for name := range config.GetStringMap("caches") {
+		section := "caches." + name
+		cacheManager, err := newCacheFromSection(config, section)
+		if err != nil {
+			return nil, err
+		}
+		if cacheManager == nil {
+			continue
+		}
+
+		if prefix := config.GetString(section + ".prefix"); prefix != "" {
+			cacheManager = cache.NewPrefixedCacheManager(cacheManager, prefix)
+		}
+		if ttl := config.GetDuration(section + ".ttl"); ttl > 0 {
+			cacheManager = cache.NewDefaultTTLCacheManager(cacheManager, ttl)
+		}
+		if err := registry.Register(name, cacheManager); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func newCacheFromSection(config *viper.Viper, section string) (cache.CacheManager, error) {
+	backend := strings.ToLower(strings.TrimSpace(config.GetString(section + ".backend")))
+
+	switch backend {
+	case "", cacheBackendNone:
+		return nil, nil
+	case cacheBackendRedis:
+		addr := config.GetString(section + ".redis.addr")
+		if addr == "" {
+			return nil, fmt.Errorf("%s.backend is redis but %s.redis.addr is empty", section, section)
+		}
+		return cache.NewCacheManagerFactory(cache.InstanceRedis, &cache.CacheConfig{
+			RedisAddr:     addr,
+			RedisPassword: config.GetString(section + ".redis.password"),
+			RedisDB:       config.GetInt(section + ".redis.db"),
+			PoolSize:      config.GetInt(section + ".redis.pool_size"),
+		})
+	case cacheBackendMemory:
+		return cache.NewCacheManagerFactory(cache.InstanceInMemory, &cache.CacheConfig{
+			DefaultExpiration: config.GetDuration(section + ".memory.default_expiration"),
+			CleanupInterval:   config.GetDuration(section + ".memory.cleanup_interval"),
+			MaxSize:           config.GetInt(section + ".memory.max_size"),
+			EvictionPolicy:    cache.EvictionPolicy(config.GetString(section + ".memory.eviction_policy")),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported %s.backend %q", section, backend)
+	}
+}
@@ -0,0 +1,168 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfig(values map[string]any) *viper.Viper {
+	config := viper.New()
+	for key, value := range values {
+		config.Set(key, value)
+	}
+	return config
+}
+
+func TestNewBrokerFromConfigSelectsImplementation(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]any
+		wantType string
+	}{
+		{
+			name:     "kafka",
+			values:   map[string]any{"broker.type": "kafka", "kafka.bootstrap.servers": "localhost:9092", "kafka.group.id": "access"},
+			wantType: "*messagebroker.kafkaBroker",
+		},
+		{
+			name:     "rabbitmq",
+			values:   map[string]any{"broker.type": "rabbitmq", "broker.rabbitmq.url": "amqp://localhost:5672", "broker.rabbitmq.exchange": "access"},
+			wantType: "*messagebroker.rabbitMQBroker",
+		},
+		{
+			name:     "nats",
+			values:   map[string]any{"broker.type": "NATS", "broker.nats.url": "nats://localhost:4222"},
+			wantType: "*messagebroker.natsBroker",
+		},
+		{
+			name:     "auto",
+			values:   map[string]any{"broker.type": "auto", "broker.nats.servers": []string{"nats://a:4222"}},
+			wantType: "*messagebroker.natsBroker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, err := NewBrokerFromConfig(newConfig(tt.values))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, fmt.Sprintf("%T", broker))
+		})
+	}
+}
+
+func TestNewBrokerFromConfigDisabled(t *testing.T) {
+	for _, brokerType := range []string{"", "none"} {
+		broker, err := NewBrokerFromConfig(newConfig(map[string]any{"broker.type": brokerType}))
+		require.NoError(t, err)
+		assert.Nil(t, broker)
+	}
+}
+
+func TestNewBrokerFromConfigValidatesRequiredSettings(t *testing.T) {
+	tests := map[string]struct {
+		values  map[string]any
+		wantErr string
+	}{
+		"kafka without servers":  {map[string]any{"broker.type": "kafka"}, "kafka.bootstrap.servers"},
+		"rabbitmq without url":   {map[string]any{"broker.type": "rabbitmq"}, "broker.rabbitmq.url"},
+		"nats without url":       {map[string]any{"broker.type": "nats"}, "broker.nats.url"},
+		"unknown type":           {map[string]any{"broker.type": "sqs"}, "unsupported broker.type"},
+		"auto without addresses": {map[string]any{"broker.type": "auto"}, "could not detect"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewBrokerFromConfig(newConfig(tt.values))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestNewCacheFromConfigSelectsImplementation(t *testing.T) {
+	redisCache, err := NewCacheFromConfig(newConfig(map[string]any{"cache.backend": "redis", "cache.redis.addr": "localhost:6379"}))
+	require.NoError(t, err)
+	assert.Equal(t, "*cache.redisCacheManager", fmt.Sprintf("%T", redisCache))
+
+	memoryCache, err := NewCacheFromConfig(newConfig(map[string]any{"cache.backend": "memory", "cache.memory.max_size": 10}))
+	require.NoError(t, err)
+	assert.Equal(t, "*cache.inMemoryCacheManager", fmt.Sprintf("%T", memoryCache))
+
+	disabled, err := NewCacheFromConfig(newConfig(nil))
+	require.NoError(t, err)
+	assert.Nil(t, disabled)
+}
+
+func TestNewCacheFromConfigValidatesRequiredSettings(t *testing.T) {
+	_, err := NewCacheFromConfig(newConfig(map[string]any{"cache.backend": "redis"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cache.redis.addr")
+
+	_, err = NewCacheFromConfig(newConfig(map[string]any{"cache.backend": "memcached"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported cache.backend")
+}
+
+func TestNewCacheRegistryFromConfigBuildsIndependentCaches(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	registry, err := NewCacheRegistryFromConfig(newConfig(map[string]any{
+		"caches.session.backend":         "memory",
+		"caches.session.ttl":             "1m",
+		"caches.session.memory.max_size": 100,
+		"caches.reference.backend":       "redis",
+		"caches.reference.redis.addr":    redisServer.Addr(),
+		"caches.reference.ttl":           "24h",
+		"caches.reference.prefix":        "ref:",
+		"caches.disabled.backend":        "none",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reference", "session"}, registry.Names())
+
+	ctx := context.Background()
+	require.NoError(t, registry.Connect(ctx))
+	t.Cleanup(func() { registry.Close() })
+
+	session, ok := registry.Get("session")
+	require.True(t, ok)
+	reference, ok := registry.Get("reference")
+	require.True(t, ok)
+
+	require.NoError(t, session.Set(ctx, "user-1", "session-token", 0))
+	require.NoError(t, reference.Set(ctx, "country:np", "Nepal", 0))
+
+	exists, err := reference.Exists(ctx, "user-1")
+	require.NoError(t, err)
+	assert.False(t, exists, "caches must not share keys")
+	exists, err = session.Exists(ctx, "country:np")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	sessionTTL, err := session.TTL(ctx, "user-1")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, sessionTTL, float64(time.Second))
+	referenceTTL, err := reference.TTL(ctx, "country:np")
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, referenceTTL)
+	assert.True(t, redisServer.Exists("ref:country:np"))
+
+	require.NoError(t, session.Clear(ctx))
+	value, err := reference.GetString(ctx, "country:np")
+	require.NoError(t, err)
+	assert.Equal(t, "Nepal", value)
+
+	_, ok = registry.Get("disabled")
+	assert.False(t, ok)
+}
+
+func TestNewCacheRegistryFromConfigValidatesEntries(t *testing.T) {
+	_, err := NewCacheRegistryFromConfig(newConfig(map[string]any{"caches.session.backend": "redis"}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "caches.session.redis.addr")
+}
@@ -1,12 +1,14 @@
 package access
 
 import (
-	"github.com/IBM/sarama"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
 	"github.com/prayaspoudel/modules/access/delivery/http"
 	"github.com/prayaspoudel/modules/access/delivery/http/route"
 	"github.com/prayaspoudel/modules/access/features/auth"
+	"github.com/prayaspoudel/modules/access/features/oauth"
 	"github.com/prayaspoudel/modules/access/middleware"
 	"github.com/prayaspoudel/modules/access/repository"
 	"github.com/sirupsen/logrus"
@@ -20,7 +22,10 @@ type BootstrapConfig struct {
 	Log      *logrus.Logger
 	Validate *validator.Validate
 	Config   *viper.Viper
-	Producer sarama.SyncProducer
+	// Broker is optional; when set user events are published and the admin topic API is exposed
+	Broker messagebroker.MessageBroker
+	// Cache is optional and selected by cache.backend
+	Cache cache.CacheManager
 }
 
 func Bootstrap(config *BootstrapConfig) {
@@ -29,6 +34,11 @@ func Bootstrap(config *BootstrapConfig) {
 	sessionRepository := repository.NewSessionRepository(config.Log)
 	tokenRepository := repository.NewRefreshTokenRepository(config.Log)
 	companyRepository := repository.NewCompanyRepository(config.Log)
+	passwordResetRepository := repository.NewPasswordResetTokenRepository(config.Log)
+	emailVerificationRepository := repository.NewEmailVerificationTokenRepository(config.Log)
+	oauthClientRepository := repository.NewOAuth2ClientRepository(config.Log)
+	oauthAuthCodeRepository := repository.NewOAuth2AuthCodeRepository(config.Log)
+	userConsentRepository := repository.NewUserConsentRepository(config.Log)
 
 	// Setup use cases
 	authUseCase := auth.NewAuthUseCase(
@@ -39,19 +49,54 @@ func Bootstrap(config *BootstrapConfig) {
 		sessionRepository,
 		tokenRepository,
 		companyRepository,
+		passwordResetRepository,
+		emailVerificationRepository,
+	)
+	authUseCase.Cache = config.Cache
+	if config.Broker != nil {
+		// NewEventPublisher only fails for a nil broker
+		authUseCase.Events, _ = messagebroker.NewEventPublisher(config.Broker)
+	}
+	oauthUseCase := oauth.NewOAuthUseCase(
+		config.DB,
+		config.Log,
+		config.Config,
+		oauthClientRepository,
+		oauthAuthCodeRepository,
+		userConsentRepository,
 	)
 
+	// Response envelope field names (defaults to data/error/status)
+	http.SetResponseFieldNames(http.ResponseFieldNames{
+		Data:   config.Config.GetString("web.response.fields.data"),
+		Error:  config.Config.GetString("web.response.fields.error"),
+		Status: config.Config.GetString("web.response.fields.status"),
+	})
+
 	// Setup controllers
 	authController := http.NewAuthController(config.Log, authUseCase, config.Validate)
+	oauthController := http.NewOAuthController(config.Log, oauthUseCase, config.Validate)
+
+	var topicController *http.TopicController
+	if config.Broker != nil {
+		topicController = http.NewTopicController(config.Log, config.Broker, config.Validate)
+	}
 
 	// Setup middleware
 	authMiddleware := middleware.NewAuthMiddleware(authUseCase)
+	var accessLog *middleware.AccessLogMiddleware
+	if config.Config.GetBool("log.access.enabled") {
+		accessLog = middleware.NewAccessLogMiddleware(config.Log, config.Config)
+	}
 
 	// Setup routes
 	routeConfig := route.RouteConfig{
-		App:            config.App,
-		AuthController: authController,
-		AuthMiddleware: authMiddleware,
+		App:             config.App,
+		AuthController:  authController,
+		OAuthController: oauthController,
+		AuthMiddleware:  authMiddleware,
+		AccessLog:       accessLog,
+		TopicController: topicController,
 	}
 	routeConfig.Setup()
 }
@@ -1,12 +1,13 @@
 package access
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/prayaspoudel/infrastructure/bootstrap"
 	"github.com/prayaspoudel/infrastructure/config"
 	"github.com/prayaspoudel/infrastructure/database"
 	"github.com/prayaspoudel/infrastructure/logger"
-	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
 	"github.com/prayaspoudel/infrastructure/router"
 	"github.com/prayaspoudel/infrastructure/validator"
 )
@@ -18,7 +19,48 @@ func Setup() {
 	db := database.NewDatabase(viperConfig, log)
 	validate := validator.NewValidator(viperConfig)
 	app := router.NewFiber(viperConfig)
-	producer := messagebroker.NewKafkaProducer(viperConfig, log)
+
+	// Backends are chosen declaratively through broker.type and cache.backend
+	broker, err := NewBrokerFromConfig(viperConfig)
+	if err != nil {
+		log.Fatalf("Invalid broker configuration: %v", err)
+	}
+	cacheManager, err := NewCacheFromConfig(viperConfig)
+	if err != nil {
+		log.Fatalf("Invalid cache configuration: %v", err)
+	}
+
+	// Verify dependencies before serving traffic
+	ctx := context.Background()
+	dependencies := []bootstrap.Dependency{
+		bootstrap.DatabaseDependency(db, fmt.Sprintf("%s:%d", viperConfig.GetString("database.host"), viperConfig.GetInt("database.port"))),
+	}
+	var features []string
+	if broker != nil {
+		if err := broker.Connect(ctx); err != nil {
+			log.Errorf("Failed to connect to message broker: %v", err)
+		}
+		features = append(features, "broker:"+viperConfig.GetString("broker.type"))
+		dependencies = append(dependencies, bootstrap.BrokerDependency(broker, viperConfig.GetString("broker.type")))
+	}
+	if cacheManager != nil {
+		if err := cacheManager.Connect(ctx); err != nil {
+			log.Errorf("Failed to connect to cache: %v", err)
+		}
+		features = append(features, "cache:"+viperConfig.GetString("cache.backend"))
+		dependencies = append(dependencies, bootstrap.CacheDependency(cacheManager, viperConfig.GetString("cache.backend")))
+	}
+
+	err = bootstrap.PreflightWithOptions(ctx, &bootstrap.Options{
+		Log:      log,
+		Mode:     bootstrap.ParseMode(viperConfig.GetString("preflight.mode")),
+		App:      viperConfig.GetString("app.name"),
+		Version:  viperConfig.GetString("app.version"),
+		Features: features,
+	}, dependencies...)
+	if err != nil {
+		log.Fatalf("Preflight failed: %v", err)
+	}
 
 	// Bootstrap access module
 	Bootstrap(&BootstrapConfig{
@@ -27,7 +69,8 @@ func Setup() {
 		Log:      log,
 		Validate: validate,
 		Config:   viperConfig,
-		Producer: producer,
+		Broker:   broker,
+		Cache:    cacheManager,
 	})
 
 	webPort := viperConfig.GetInt("web.port")
@@ -35,7 +78,7 @@ func Setup() {
 		webPort = 8080 // Default port for SSO service
 	}
 
-	err := app.Listen(fmt.Sprintf(":%d", webPort))
+	err = app.Listen(fmt.Sprintf(":%d", webPort))
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
@@ -23,13 +23,6 @@ func NewAuthController(log *logrus.Logger, authUseCase *auth.AuthUseCase, valida
 	}
 }
 
-// WebResponse generic response wrapper
-type WebResponse[T any] struct {
-	Data   T      `json:"data,omitempty"`
-	Error  string `json:"error,omitempty"`
-	Status string `json:"status"`
-}
-
 func (c *AuthController) Register(ctx *fiber.Ctx) error {
 	var req model.RegisterUserRequest
 	if err := ctx.BodyParser(&req); err != nil {
@@ -40,7 +33,7 @@ func (c *AuthController) Register(ctx *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	user, err := c.AuthUseCase.Register(&req)
+	user, err := c.AuthUseCase.Register(ctx.UserContext(), &req)
 	if err != nil {
 		return err
 	}
@@ -84,7 +77,7 @@ func (c *AuthController) Logout(ctx *fiber.Ctx) error {
 		token = token[7:]
 	}
 
-	if err := c.AuthUseCase.Logout(authCtx.UserID, token); err != nil {
+	if err := c.AuthUseCase.Logout(ctx.UserContext(), authCtx.UserID, token); err != nil {
 		return err
 	}
 
@@ -94,6 +87,22 @@ func (c *AuthController) Logout(ctx *fiber.Ctx) error {
 	})
 }
 
+func (c *AuthController) LogoutAll(ctx *fiber.Ctx) error {
+	authCtx := middleware.GetAuth(ctx)
+	if authCtx == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	if err := c.AuthUseCase.LogoutAll(ctx.UserContext(), authCtx.UserID); err != nil {
+		return err
+	}
+
+	return ctx.JSON(WebResponse[any]{
+		Status: "success",
+		Data:   fiber.Map{"message": "logged out of all sessions"},
+	})
+}
+
 func (c *AuthController) RefreshToken(ctx *fiber.Ctx) error {
 	var req model.RefreshTokenRequest
 	if err := ctx.BodyParser(&req); err != nil {
@@ -114,3 +123,73 @@ func (c *AuthController) RefreshToken(ctx *fiber.Ctx) error {
 		Data:   response,
 	})
 }
+
+// UpdateProfile updates the authenticated user's name and email
+func (c *AuthController) UpdateProfile(ctx *fiber.Ctx) error {
+	authCtx := middleware.GetAuth(ctx)
+	if authCtx == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var req model.UpdateUserRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	req.UserID = authCtx.UserID
+
+	if err := c.Validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	user, err := c.AuthUseCase.UpdateProfile(ctx.UserContext(), &req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(WebResponse[*model.UserResponse]{
+		Status: "success",
+		Data:   user,
+	})
+}
+
+// ResendVerification always answers 202 so callers cannot probe which emails are registered
+func (c *AuthController) ResendVerification(ctx *fiber.Ctx) error {
+	var req model.ResendEmailRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := c.AuthUseCase.ResendVerification(req.Email); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(WebResponse[any]{
+		Status: "success",
+		Data:   fiber.Map{"message": "if the email is registered and unverified, a verification email has been sent"},
+	})
+}
+
+// ResendPasswordReset always answers 202 so callers cannot probe which emails are registered
+func (c *AuthController) ResendPasswordReset(ctx *fiber.Ctx) error {
+	var req model.ResendEmailRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := c.AuthUseCase.ResendPasswordReset(req.Email); err != nil {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(WebResponse[any]{
+		Status: "success",
+		Data:   fiber.Map{"message": "if the email is registered, a password reset email has been sent"},
+	})
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/features/oauth"
+	"github.com/prayaspoudel/modules/access/middleware"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/sirupsen/logrus"
+)
+
+// OAuthController serves the OAuth2 authorization endpoint for signed-in users
+type OAuthController struct {
+	Log          *logrus.Logger
+	OAuthUseCase *oauth.OAuthUseCase
+	Validator    *validator.Validate
+}
+
+func NewOAuthController(log *logrus.Logger, oauthUseCase *oauth.OAuthUseCase, validator *validator.Validate) *OAuthController {
+	return &OAuthController{
+		Log:          log,
+		OAuthUseCase: oauthUseCase,
+		Validator:    validator,
+	}
+}
+
+// Authorize issues an authorization code, or asks for consent when the client requests
+// scopes the user has not granted yet
+func (c *OAuthController) Authorize(ctx *fiber.Ctx) error {
+	authCtx := middleware.GetAuth(ctx)
+	if authCtx == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var req model.AuthorizeRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	req.UserID = authCtx.UserID
+
+	if err := c.Validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	response, err := c.OAuthUseCase.Authorize(&req)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(WebResponse[*model.AuthorizeResponse]{
+		Status: "success",
+		Data:   response,
+	})
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync/atomic"
+)
+
+// ResponseFieldNames holds the JSON keys used for the WebResponse envelope
+type ResponseFieldNames struct {
+	Data   string
+	Error  string
+	Status string
+}
+
+// DefaultResponseFieldNames is the envelope used unless SetResponseFieldNames overrides it
+var DefaultResponseFieldNames = ResponseFieldNames{
+	Data:   "data",
+	Error:  "error",
+	Status: "status",
+}
+
+var responseFieldNames atomic.Pointer[ResponseFieldNames]
+
+// SetResponseFieldNames overrides the envelope keys, e.g. "result" instead of "data"
+// for clients that expect it. Empty names keep their default.
+func SetResponseFieldNames(names ResponseFieldNames) {
+	if names.Data == "" {
+		names.Data = DefaultResponseFieldNames.Data
+	}
+	if names.Error == "" {
+		names.Error = DefaultResponseFieldNames.Error
+	}
+	if names.Status == "" {
+		names.Status = DefaultResponseFieldNames.Status
+	}
+	responseFieldNames.Store(&names)
+}
+
+func currentResponseFieldNames() ResponseFieldNames {
+	if names := responseFieldNames.Load(); names != nil {
+		return *names
+	}
+	return DefaultResponseFieldNames
+}
+
+// WebResponse generic response wrapper
+type WebResponse[T any] struct {
+	Data   T
+	Error  string
+	Status string
+}
+
+// MarshalJSON writes the envelope with the configured field names. Empty collections are
+// kept as [] or {} so clients can tell "no results" apart from "no data"; only nil pointers
+// and interfaces are omitted.
+func (r WebResponse[T]) MarshalJSON() ([]byte, error) {
+	names := currentResponseFieldNames()
+	envelope := map[string]any{
+		names.Status: r.Status,
+	}
+
+	if data, ok := responseData(r.Data); ok {
+		envelope[names.Data] = data
+	}
+	if r.Error != "" {
+		envelope[names.Error] = r.Error
+	}
+
+	return json.Marshal(envelope)
+}
+
+func responseData(data any) (any, bool) {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return nil, false
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.IsNil() {
+			return []any{}, true
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			return map[string]any{}, true
+		}
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil, false
+		}
+	}
+
+	return data, true
+}
@@ -0,0 +1,39 @@
+package http_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prayaspoudel/modules/access/delivery/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebResponseKeepsEmptyCollections(t *testing.T) {
+	data, err := json.Marshal(http.WebResponse[[]string]{Status: "success", Data: []string{}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"success","data":[]}`, string(data))
+
+	data, err = json.Marshal(http.WebResponse[[]string]{Status: "success"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"success","data":[]}`, string(data))
+
+	data, err = json.Marshal(http.WebResponse[map[string]int]{Status: "success"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"success","data":{}}`, string(data))
+}
+
+func TestWebResponseOmitsNilPointer(t *testing.T) {
+	data, err := json.Marshal(http.WebResponse[*struct{}]{Status: "error", Error: "not found"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"error","error":"not found"}`, string(data))
+}
+
+func TestWebResponseHonorsFieldNames(t *testing.T) {
+	http.SetResponseFieldNames(http.ResponseFieldNames{Data: "result"})
+	t.Cleanup(func() { http.SetResponseFieldNames(http.DefaultResponseFieldNames) })
+
+	data, err := json.Marshal(http.WebResponse[[]int]{Status: "success", Data: []int{1, 2}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"success","result":[1,2]}`, string(data))
+}
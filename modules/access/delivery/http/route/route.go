@@ -9,10 +9,20 @@ import (
 type RouteConfig struct {
 	App            *fiber.App
 	AuthController *http.AuthController
-	AuthMiddleware *middleware.AuthMiddleware
+	// OAuthController is optional; the authorize endpoint is only mounted when set
+	OAuthController *http.OAuthController
+	AuthMiddleware  *middleware.AuthMiddleware
+	// AccessLog is optional; request logging is disabled when nil
+	AccessLog *middleware.AccessLogMiddleware
+	// TopicController is optional; the admin topic API is only mounted when a broker is configured
+	TopicController *http.TopicController
 }
 
 func (c *RouteConfig) Setup() {
+	if c.AccessLog != nil {
+		c.App.Use(c.AccessLog.Handle)
+	}
+
 	// API group
 	api := c.App.Group("/api")
 
@@ -21,9 +31,27 @@ func (c *RouteConfig) Setup() {
 	auth.Post("/register", c.AuthController.Register)
 	auth.Post("/login", c.AuthController.Login)
 	auth.Post("/refresh", c.AuthController.RefreshToken)
+	auth.Post("/verification/resend", c.AuthController.ResendVerification)
+	auth.Post("/password/resend", c.AuthController.ResendPasswordReset)
 
 	// Protected routes
 	auth.Post("/logout", c.AuthMiddleware.Authenticate, c.AuthController.Logout)
+	auth.Post("/logout-all", c.AuthMiddleware.Authenticate, c.AuthController.LogoutAll)
+	auth.Patch("/profile", c.AuthMiddleware.Authenticate, c.AuthController.UpdateProfile)
+
+	// OAuth2 routes
+	if c.OAuthController != nil {
+		oauth := api.Group("/oauth", c.AuthMiddleware.Authenticate)
+		oauth.Post("/authorize", c.OAuthController.Authorize)
+	}
+
+	// Admin routes
+	if c.TopicController != nil {
+		admin := api.Group("/admin", c.AuthMiddleware.Authenticate, middleware.RequireRole("admin"))
+		admin.Get("/topics", c.TopicController.List)
+		admin.Post("/topics", c.TopicController.Create)
+		admin.Delete("/topics/:name", c.TopicController.Delete)
+	}
 
 	// Health check
 	c.App.Get("/health", func(ctx *fiber.Ctx) error {
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/sirupsen/logrus"
+)
+
+// TopicController exposes the broker's topic management for administrators
+type TopicController struct {
+	Log       *logrus.Logger
+	Broker    messagebroker.MessageBroker
+	Validator *validator.Validate
+}
+
+func NewTopicController(log *logrus.Logger, broker messagebroker.MessageBroker, validator *validator.Validate) *TopicController {
+	return &TopicController{
+		Log:       log,
+		Broker:    broker,
+		Validator: validator,
+	}
+}
+
+func (c *TopicController) List(ctx *fiber.Ctx) error {
+	return c.respondWithTopics(ctx, fiber.StatusOK)
+}
+
+func (c *TopicController) Create(ctx *fiber.Ctx) error {
+	var req model.CreateTopicRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+
+	if err := c.Validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	options := &messagebroker.TopicOptions{
+		Durable:   req.Durable,
+		Arguments: map[string]interface{}{},
+	}
+	if req.Partitions > 0 {
+		options.Arguments["partitions"] = req.Partitions
+	}
+	if req.ReplicationFactor > 0 {
+		options.Arguments["replication-factor"] = req.ReplicationFactor
+	}
+
+	if err := c.Broker.CreateTopic(ctx.UserContext(), req.Name, options); err != nil {
+		return c.brokerError(err, "failed to create topic")
+	}
+
+	c.Log.WithField("topic", req.Name).Info("Topic created")
+	return c.respondAfterChange(ctx, fiber.StatusCreated, fiber.StatusCreated)
+}
+
+func (c *TopicController) Delete(ctx *fiber.Ctx) error {
+	name := ctx.Params("name")
+	if name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "topic name is required")
+	}
+
+	if err := c.Broker.DeleteTopic(ctx.UserContext(), name); err != nil {
+		return c.brokerError(err, "failed to delete topic")
+	}
+
+	c.Log.WithField("topic", name).Info("Topic deleted")
+	return c.respondAfterChange(ctx, fiber.StatusOK, fiber.StatusNoContent)
+}
+
+func (c *TopicController) respondWithTopics(ctx *fiber.Ctx, status int) error {
+	topics, err := c.Broker.ListTopics(ctx.UserContext())
+	if err != nil {
+		return c.brokerError(err, "failed to list topics")
+	}
+
+	return ctx.Status(status).JSON(WebResponse[[]string]{
+		Status: "success",
+		Data:   topics,
+	})
+}
+
+// respondAfterChange answers a create or delete that succeeded with the updated topic list.
+// Brokers that cannot list topics get unlisted, with no body, rather than a 501 for a change
+// that was made.
+func (c *TopicController) respondAfterChange(ctx *fiber.Ctx, listed, unlisted int) error {
+	topics, err := c.Broker.ListTopics(ctx.UserContext())
+	if messagebroker.IsNotSupported(err) {
+		return ctx.SendStatus(unlisted)
+	}
+	if err != nil {
+		return c.brokerError(err, "failed to list topics")
+	}
+
+	return ctx.Status(listed).JSON(WebResponse[[]string]{
+		Status: "success",
+		Data:   topics,
+	})
+}
+
+func (c *TopicController) brokerError(err error, message string) error {
+	if messagebroker.IsNotSupported(err) {
+		return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+	}
+
+	c.Log.WithError(err).Error(message)
+	return fiber.NewError(fiber.StatusBadGateway, message)
+}
@@ -0,0 +1,145 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/prayaspoudel/infrastructure/router"
+	"github.com/prayaspoudel/modules/access/delivery/http"
+	"github.com/prayaspoudel/modules/access/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTopicBroker keeps topics in memory; other broker methods are not used by the controller
+type memoryTopicBroker struct {
+	messagebroker.MessageBroker
+	topics map[string]*messagebroker.TopicOptions
+}
+
+func (b *memoryTopicBroker) CreateTopic(ctx context.Context, topic string, options *messagebroker.TopicOptions) error {
+	b.topics[topic] = options
+	return nil
+}
+
+func (b *memoryTopicBroker) DeleteTopic(ctx context.Context, topic string) error {
+	delete(b.topics, topic)
+	return nil
+}
+
+func (b *memoryTopicBroker) ListTopics(ctx context.Context) ([]string, error) {
+	topics := make([]string, 0, len(b.topics))
+	for topic := range b.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// unlistableTopicBroker manages topics but cannot list them, like core NATS
+type unlistableTopicBroker struct {
+	*memoryTopicBroker
+	nats messagebroker.MessageBroker
+}
+
+func (b *unlistableTopicBroker) ListTopics(ctx context.Context) ([]string, error) {
+	return b.nats.ListTopics(ctx)
+}
+
+func newTopicApp(broker messagebroker.MessageBroker, role string) *fiber.App {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	controller := http.NewTopicController(log, broker, validator.New())
+
+	app := fiber.New(fiber.Config{ErrorHandler: router.NewFiberErrorHandler()})
+	admin := app.Group("/api/admin", func(ctx *fiber.Ctx) error {
+		ctx.Locals("auth", &middleware.AuthContext{UserID: "user-1", Role: role})
+		return ctx.Next()
+	}, middleware.RequireRole("admin"))
+	admin.Get("/topics", controller.List)
+	admin.Post("/topics", controller.Create)
+	admin.Delete("/topics/:name", controller.Delete)
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, method, path, body string) (int, map[string]any) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var payload map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payload))
+	return resp.StatusCode, payload
+}
+
+func TestTopicControllerCreateAndList(t *testing.T) {
+	broker := &memoryTopicBroker{topics: map[string]*messagebroker.TopicOptions{"audit": nil}}
+	app := newTopicApp(broker, "admin")
+
+	status, payload := doRequest(t, app, fiber.MethodPost, "/api/admin/topics", `{"name":"orders","partitions":6,"replicationFactor":3}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, []any{"audit", "orders"}, payload["data"])
+	assert.Equal(t, 6, broker.topics["orders"].Arguments["partitions"])
+	assert.Equal(t, 3, broker.topics["orders"].Arguments["replication-factor"])
+
+	status, payload = doRequest(t, app, fiber.MethodGet, "/api/admin/topics", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []any{"audit", "orders"}, payload["data"])
+
+	status, payload = doRequest(t, app, fiber.MethodDelete, "/api/admin/topics/audit", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []any{"orders"}, payload["data"])
+}
+
+func TestTopicControllerValidatesRequest(t *testing.T) {
+	app := newTopicApp(&memoryTopicBroker{topics: map[string]*messagebroker.TopicOptions{}}, "admin")
+
+	status, _ := doRequest(t, app, fiber.MethodPost, "/api/admin/topics", `{"partitions":3}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+func TestTopicControllerRequiresAdminRole(t *testing.T) {
+	app := newTopicApp(&memoryTopicBroker{topics: map[string]*messagebroker.TopicOptions{}}, "member")
+
+	status, _ := doRequest(t, app, fiber.MethodGet, "/api/admin/topics", "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+}
+
+func TestTopicControllerUnsupportedBroker(t *testing.T) {
+	broker, err := messagebroker.NewNATSBroker(&messagebroker.BrokerConfig{NATSURL: "nats://localhost:4222"})
+	require.NoError(t, err)
+	app := newTopicApp(broker, "admin")
+
+	status, payload := doRequest(t, app, fiber.MethodGet, "/api/admin/topics", "")
+	assert.Equal(t, fiber.StatusNotImplemented, status)
+	assert.Contains(t, payload["errors"], "not supported")
+}
+
+func TestTopicControllerChangesWithoutListing(t *testing.T) {
+	nats, err := messagebroker.NewNATSBroker(&messagebroker.BrokerConfig{NATSURL: "nats://localhost:4222"})
+	require.NoError(t, err)
+	broker := &unlistableTopicBroker{memoryTopicBroker: &memoryTopicBroker{topics: map[string]*messagebroker.TopicOptions{}}, nats: nats}
+	app := newTopicApp(broker, "admin")
+
+	req := httptest.NewRequest(fiber.MethodPost, "/api/admin/topics", strings.NewReader(`{"name":"orders"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Contains(t, broker.topics, "orders")
+
+	resp, err = app.Test(httptest.NewRequest(fiber.MethodDelete, "/api/admin/topics/orders", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	assert.NotContains(t, broker.topics, "orders")
+}
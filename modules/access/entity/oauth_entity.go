@@ -56,3 +56,17 @@ type OAuth2Token struct {
 func (ot *OAuth2Token) TableName() string {
 	return "sso_oauth_tokens"
 }
+
+// UserConsent records the scopes a user has granted to an OAuth2 client
+type UserConsent struct {
+	ID        string `gorm:"column:id;primaryKey"`
+	UserID    string `gorm:"column:user_id;not null"`
+	ClientID  string `gorm:"column:client_id;not null"`
+	Scopes    string `gorm:"column:scopes;type:text"` // JSON array as string
+	CreatedAt int64  `gorm:"column:created_at;autoCreateTime:milli"`
+	UpdatedAt int64  `gorm:"column:updated_at;autoCreateTime:milli;autoUpdateTime:milli"`
+}
+
+func (uc *UserConsent) TableName() string {
+	return "sso_oauth_consents"
+}
@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/prayaspoudel/infrastructure/cache"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
 	"github.com/prayaspoudel/modules/access/entity"
 	"github.com/prayaspoudel/modules/access/model"
 	"github.com/prayaspoudel/modules/access/model/converter"
@@ -18,13 +21,23 @@ import (
 )
 
 type AuthUseCase struct {
-	DB                *gorm.DB
-	Log               *logrus.Logger
-	Viper             *viper.Viper
-	UserRepository    *repository.UserRepository
-	SessionRepository *repository.SessionRepository
-	RefreshTokenRepo  *repository.RefreshTokenRepository
-	CompanyRepository *repository.CompanyRepository
+	DB                    *gorm.DB
+	Log                   *logrus.Logger
+	Viper                 *viper.Viper
+	UserRepository        *repository.UserRepository
+	SessionRepository     *repository.SessionRepository
+	RefreshTokenRepo      *repository.RefreshTokenRepository
+	CompanyRepository     *repository.CompanyRepository
+	PasswordResetRepo     *repository.PasswordResetTokenRepository
+	EmailVerificationRepo *repository.EmailVerificationTokenRepository
+	// Cache throttles resend requests; they are not throttled when nil
+	Cache cache.CacheManager
+	// Mailer delivers issued tokens; when nil they are only logged as issued
+	Mailer TokenMailer
+	// Clock is the time source for token expiry; defaults to the system clock
+	Clock Clock
+	// Events publishes user events; nothing is published when nil
+	Events *messagebroker.EventPublisher
 }
 
 func NewAuthUseCase(
@@ -35,19 +48,24 @@ func NewAuthUseCase(
 	sessionRepo *repository.SessionRepository,
 	refreshTokenRepo *repository.RefreshTokenRepository,
 	companyRepo *repository.CompanyRepository,
+	passwordResetRepo *repository.PasswordResetTokenRepository,
+	emailVerificationRepo *repository.EmailVerificationTokenRepository,
 ) *AuthUseCase {
 	return &AuthUseCase{
-		DB:                db,
-		Log:               log,
-		Viper:             viper,
-		UserRepository:    userRepo,
-		SessionRepository: sessionRepo,
-		RefreshTokenRepo:  refreshTokenRepo,
-		CompanyRepository: companyRepo,
+		DB:                    db,
+		Log:                   log,
+		Viper:                 viper,
+		UserRepository:        userRepo,
+		SessionRepository:     sessionRepo,
+		RefreshTokenRepo:      refreshTokenRepo,
+		CompanyRepository:     companyRepo,
+		PasswordResetRepo:     passwordResetRepo,
+		EmailVerificationRepo: emailVerificationRepo,
+		Clock:                 systemClock{},
 	}
 }
 
-func (uc *AuthUseCase) Register(req *model.RegisterUserRequest) (*model.UserResponse, error) {
+func (uc *AuthUseCase) Register(ctx context.Context, req *model.RegisterUserRequest) (*model.UserResponse, error) {
 	// Check if user exists
 	var existingUser entity.User
 	err := uc.UserRepository.FindByEmail(uc.DB, &existingUser, req.Email)
@@ -83,6 +101,8 @@ func (uc *AuthUseCase) Register(req *model.RegisterUserRequest) (*model.UserResp
 		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
 	}
 
+	uc.publishEvent(ctx, converter.UserToEvent(user, model.UserRegistered, uc.now()))
+
 	return converter.UserToResponse(user), nil
 }
 
@@ -120,7 +140,7 @@ func (uc *AuthUseCase) Login(req *model.LoginUserRequest, ipAddress string) (*mo
 		ID:        uuid.New().String(),
 		UserID:    user.ID,
 		Token:     refreshTokenStr,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 30), // 30 days
+		ExpiresAt: uc.now().Add(time.Hour * 24 * 30), // 30 days
 	}
 
 	if err := uc.RefreshTokenRepo.Create(uc.DB, refreshToken); err != nil {
@@ -129,18 +149,8 @@ func (uc *AuthUseCase) Login(req *model.LoginUserRequest, ipAddress string) (*mo
 	}
 
 	// Create session
-	session := &entity.Session{
-		ID:           uuid.New().String(),
-		UserID:       user.ID,
-		SessionToken: accessToken,
-		IPAddress:    ipAddress,
-		UserAgent:    "", // TODO: Get from request
-		ExpiresAt:    time.Now().Add(time.Duration(expiresIn) * time.Second),
-	}
-
-	if err := uc.SessionRepository.Create(uc.DB, session); err != nil {
-		uc.Log.WithError(err).Error("error creating session")
-		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	if err := uc.createSession(user.ID, accessToken, ipAddress, expiresIn); err != nil {
+		return nil, err
 	}
 
 	// Update last login
@@ -170,11 +180,12 @@ func (uc *AuthUseCase) Login(req *model.LoginUserRequest, ipAddress string) (*mo
 	}, nil
 }
 
-func (uc *AuthUseCase) Logout(userID string, token string) error {
+func (uc *AuthUseCase) Logout(ctx context.Context, userID string, token string) error {
 	// Delete session
 	if err := uc.SessionRepository.DeleteByToken(uc.DB, token); err != nil {
 		uc.Log.WithError(err).Error("error deleting session")
 	}
+	uc.evictSessions(ctx, userID, token)
 
 	// Revoke all refresh tokens for user
 	if err := uc.RefreshTokenRepo.RevokeByUserID(uc.DB, userID); err != nil {
@@ -197,8 +208,8 @@ func (uc *AuthUseCase) RefreshToken(req *model.RefreshTokenRequest) (*model.Logi
 		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
 	}
 
-	// Check if token is expired
-	if time.Now().After(refreshToken.ExpiresAt) {
+	// Check if token is expired; it is no longer valid at ExpiresAt itself
+	if !uc.now().Before(refreshToken.ExpiresAt) {
 		return nil, fiber.NewError(fiber.StatusUnauthorized, "refresh token expired")
 	}
 
@@ -215,13 +226,18 @@ func (uc *AuthUseCase) RefreshToken(req *model.RefreshTokenRequest) (*model.Logi
 		return nil, err
 	}
 
+	// The new access token gets its own session so it passes session validation
+	if err := uc.createSession(user.ID, accessToken, "", expiresIn); err != nil {
+		return nil, err
+	}
+
 	// Rotate refresh token
 	newRefreshTokenStr := uuid.New().String()
 	newRefreshToken := &entity.RefreshToken{
 		ID:        uuid.New().String(),
 		UserID:    user.ID,
 		Token:     newRefreshTokenStr,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 30),
+		ExpiresAt: uc.now().Add(time.Hour * 24 * 30),
 	}
 
 	if err := uc.RefreshTokenRepo.Create(uc.DB, newRefreshToken); err != nil {
@@ -261,11 +277,14 @@ func (uc *AuthUseCase) generateAccessToken(user *entity.User) (string, int, erro
 		expiresIn = 3600 // Default 1 hour
 	}
 
+	now := uc.now()
 	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"exp":   time.Now().Add(time.Duration(expiresIn) * time.Second).Unix(),
-		"iat":   time.Now().Unix(),
+		"sub":        user.ID,
+		"email":      user.Email,
+		"role":       user.Role,
+		"company_id": user.CompanyID,
+		"exp":        now.Add(time.Duration(expiresIn) * time.Second).Unix(),
+		"iat":        now.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -290,7 +309,7 @@ func (uc *AuthUseCase) VerifyAccessToken(tokenString string) (*jwt.MapClaims, er
 			return nil, fiber.NewError(fiber.StatusUnauthorized, "unexpected signing method")
 		}
 		return []byte(uc.Viper.GetString("jwt.secret")), nil
-	})
+	}, jwt.WithTimeFunc(uc.now))
 
 	if err != nil {
 		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token")
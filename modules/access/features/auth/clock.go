@@ -0,0 +1,22 @@
+package auth
+
+import "time"
+
+// Clock tells the current time. AuthUseCase reads every expiry through it so tests can
+// control time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (uc *AuthUseCase) now() time.Time {
+	if uc.Clock == nil {
+		return time.Now()
+	}
+	return uc.Clock.Now()
+}
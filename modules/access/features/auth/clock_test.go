@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+var refreshExpiry = time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func expectRefreshToken(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_refresh_tokens" WHERE token = $1 AND revoked_at IS NULL`)).
+		WithArgs("refresh-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token", "expires_at"}).
+			AddRow("refresh-token-1", "user-1", "refresh-1", refreshExpiry))
+}
+
+func TestRefreshTokenRejectedAtExpiry(t *testing.T) {
+	useCase, mock, _ := newAuthUseCase(t)
+	useCase.Clock = &fakeClock{now: refreshExpiry}
+
+	expectRefreshToken(mock)
+
+	_, err := useCase.RefreshToken(&model.RefreshTokenRequest{RefreshToken: "refresh-1"})
+
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, fiber.StatusUnauthorized, fiberErr.Code)
+	assert.Equal(t, "refresh token expired", fiberErr.Message)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenRotatesJustBeforeExpiry(t *testing.T) {
+	useCase, mock, _ := newAuthUseCase(t)
+	now := refreshExpiry.Add(-time.Nanosecond)
+	useCase.Clock = &fakeClock{now: now}
+	useCase.Viper.Set("jwt.secret", "test-secret")
+
+	expectRefreshToken(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE id = $1`)).
+		WithArgs("user-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow("user-1", "jane@example.com"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sso_sessions"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sso_refresh_tokens"`)).
+		WithArgs(sqlmock.AnyArg(), "user-1", sqlmock.AnyArg(), sqlmock.AnyArg(), now.Add(30*24*time.Hour), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_refresh_tokens" SET "revoked_at"=NOW() WHERE token = $1`)).
+		WithArgs("refresh-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM sso_companies c INNER JOIN sso_user_companies uc`).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	response, err := useCase.RefreshToken(&model.RefreshTokenRequest{RefreshToken: "refresh-1"})
+	require.NoError(t, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.NotEqual(t, "refresh-1", response.RefreshToken)
+	assert.NotEmpty(t, response.AccessToken)
+
+	// The access token is checked against the same clock
+	_, err = useCase.VerifyAccessToken(response.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestCleanupExpiredTokensUsesClock(t *testing.T) {
+	useCase, mock, _ := newAuthUseCase(t)
+	useCase.Clock = &fakeClock{now: refreshExpiry}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sso_sessions" WHERE expires_at < $1`)).
+		WithArgs(refreshExpiry).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sso_refresh_tokens" WHERE expires_at < $1`)).
+		WithArgs(refreshExpiry).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, useCase.CleanupExpiredTokens())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
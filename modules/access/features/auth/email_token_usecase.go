@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prayaspoudel/modules/access/entity"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultResendThrottle          = time.Minute
+	defaultVerificationTokenExpiry = 24 * time.Hour
+	defaultResetTokenExpiry        = time.Hour
+)
+
+// TokenMailer delivers verification and password reset tokens to users
+type TokenMailer interface {
+	SendVerificationEmail(user *entity.User, token string) error
+	SendPasswordResetEmail(user *entity.User, token string) error
+}
+
+// ResendVerification issues a new email verification token, invalidating earlier ones.
+// It returns nil for unknown, already verified and throttled addresses alike so the
+// response does not reveal which emails are registered.
+func (uc *AuthUseCase) ResendVerification(email string) error {
+	user, ok, err := uc.resendTarget("verification", email)
+	if err != nil || !ok || user.EmailVerified {
+		return err
+	}
+
+	var token string
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		token, err = uc.issueVerificationToken(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		uc.Log.WithError(err).Error("error issuing verification token")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	uc.sendVerificationEmail(user, token)
+	return nil
+}
+
+// ResendPasswordReset issues a new password reset token, invalidating earlier ones. Like
+// ResendVerification it succeeds without doing anything for unknown or throttled addresses.
+func (uc *AuthUseCase) ResendPasswordReset(email string) error {
+	user, ok, err := uc.resendTarget("password_reset", email)
+	if err != nil || !ok {
+		return err
+	}
+
+	token := uuid.New().String()
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := uc.PasswordResetRepo.InvalidateByUserID(tx, user.ID, uc.now()); err != nil {
+			return err
+		}
+		return uc.PasswordResetRepo.Create(tx, &entity.PasswordResetToken{
+			ID:        uuid.New().String(),
+			UserID:    user.ID,
+			Token:     token,
+			ExpiresAt: uc.now().Add(uc.durationSetting("auth.reset_token_expiry", defaultResetTokenExpiry)),
+		})
+	})
+	if err != nil {
+		uc.Log.WithError(err).Error("error issuing password reset token")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	if uc.Mailer == nil {
+		uc.Log.WithField("user_id", user.ID).Warn("password reset token issued but no mailer is configured")
+		return nil
+	}
+	if err := uc.Mailer.SendPasswordResetEmail(user, token); err != nil {
+		uc.Log.WithError(err).Error("error sending password reset email")
+	}
+	return nil
+}
+
+// issueVerificationToken expires the user's outstanding verification tokens and stores a
+// new one, returning its value
+func (uc *AuthUseCase) issueVerificationToken(tx *gorm.DB, userID string) (string, error) {
+	if err := uc.EmailVerificationRepo.InvalidateByUserID(tx, userID, uc.now()); err != nil {
+		return "", err
+	}
+
+	token := uuid.New().String()
+	err := uc.EmailVerificationRepo.Create(tx, &entity.EmailVerificationToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Token:     token,
+		ExpiresAt: uc.now().Add(uc.durationSetting("auth.verification_token_expiry", defaultVerificationTokenExpiry)),
+	})
+	return token, err
+}
+
+func (uc *AuthUseCase) sendVerificationEmail(user *entity.User, token string) {
+	if uc.Mailer == nil {
+		uc.Log.WithField("user_id", user.ID).Warn("verification token issued but no mailer is configured")
+		return
+	}
+	if err := uc.Mailer.SendVerificationEmail(user, token); err != nil {
+		uc.Log.WithError(err).Error("error sending verification email")
+	}
+}
+
+// resendTarget applies the per-email throttle and looks up the user. ok is false when
+// the request is throttled or the email is unknown.
+func (uc *AuthUseCase) resendTarget(kind string, email string) (*entity.User, bool, error) {
+	email = strings.TrimSpace(email)
+	if uc.throttled("resend:"+kind+":"+strings.ToLower(email), uc.durationSetting("auth.resend_throttle", defaultResendThrottle)) {
+		return nil, false, nil
+	}
+
+	var user entity.User
+	err := uc.UserRepository.FindByEmail(uc.DB, &user, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		uc.Log.WithError(err).Error("error finding user")
+		return nil, false, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	return &user, true, nil
+}
+
+// throttled reports whether key was seen within window, and records it otherwise. The
+// key is claimed with SetNX, so of concurrent requests only one gets through.
+// Without a cache there is no throttling.
+func (uc *AuthUseCase) throttled(key string, window time.Duration) bool {
+	if uc.Cache == nil {
+		return false
+	}
+
+	claimed, err := uc.Cache.SetNX(context.Background(), key, uc.now().Unix(), window)
+	if err != nil {
+		uc.Log.WithError(err).Warn("error recording resend throttle")
+		return false
+	}
+	return !claimed
+}
+
+func (uc *AuthUseCase) durationSetting(key string, fallback time.Duration) time.Duration {
+	if d := uc.Viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,176 @@
+package auth_test
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prayaspoudel/infrastructure/cache"
+	"github.com/prayaspoudel/modules/access/entity"
+	"github.com/prayaspoudel/modules/access/features/auth"
+	"github.com/prayaspoudel/modules/access/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type recordingMailer struct {
+	verificationTokens []string
+	resetTokens        []string
+}
+
+func (m *recordingMailer) SendVerificationEmail(user *entity.User, token string) error {
+	m.verificationTokens = append(m.verificationTokens, token)
+	return nil
+}
+
+func (m *recordingMailer) SendPasswordResetEmail(user *entity.User, token string) error {
+	m.resetTokens = append(m.resetTokens, token)
+	return nil
+}
+
+func newAuthUseCase(t *testing.T) (*auth.AuthUseCase, sqlmock.Sqlmock, *recordingMailer) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	log := logrus.New()
+	useCase := auth.NewAuthUseCase(
+		db,
+		log,
+		viper.New(),
+		repository.NewUserRepository(log),
+		repository.NewSessionRepository(log),
+		repository.NewRefreshTokenRepository(log),
+		repository.NewCompanyRepository(log),
+		repository.NewPasswordResetTokenRepository(log),
+		repository.NewEmailVerificationTokenRepository(log),
+	)
+
+	mailer := &recordingMailer{}
+	useCase.Mailer = mailer
+	return useCase, mock, mailer
+}
+
+func newThrottleCache(t *testing.T) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	require.NoError(t, err)
+	require.NoError(t, cacheManager.Connect(context.Background()))
+	t.Cleanup(func() { cacheManager.Close() })
+	return cacheManager
+}
+
+func expectUser(mock sqlmock.Sqlmock, email string, verified bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE email = $1`)).
+		WithArgs(email, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "email_verified"}).AddRow("user-1", email, verified))
+}
+
+func expectTokenIssued(mock sqlmock.Sqlmock, table string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "`+table+`" SET "expires_at"=$1 WHERE user_id = $2 AND expires_at > $3`)).
+		WithArgs(sqlmock.AnyArg(), "user-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "` + table + `"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestResendVerificationThrottlesRapidRequests(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+	useCase.Cache = newThrottleCache(t)
+
+	expectUser(mock, "jane@example.com", false)
+	expectTokenIssued(mock, "sso_email_verification_tokens")
+
+	require.NoError(t, useCase.ResendVerification("jane@example.com"))
+	// The second request, even with different casing, hits the throttle before any query
+	require.NoError(t, useCase.ResendVerification("Jane@Example.com"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, mailer.verificationTokens, 1)
+}
+
+func TestResendPasswordResetThrottlesRapidRequests(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+	useCase.Cache = newThrottleCache(t)
+
+	expectUser(mock, "jane@example.com", true)
+	expectTokenIssued(mock, "sso_password_reset_tokens")
+
+	require.NoError(t, useCase.ResendPasswordReset("jane@example.com"))
+	require.NoError(t, useCase.ResendPasswordReset("jane@example.com"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, mailer.resetTokens, 1)
+}
+
+func TestResendThrottleAdmitsOneOfConcurrentRequests(t *testing.T) {
+	useCase, mock, _ := newAuthUseCase(t)
+	useCase.Cache = newThrottleCache(t)
+
+	// Only the request that claims the throttle looks the address up; any other query
+	// would fail against the mock and be returned as an error
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE email = $1`)).
+		WithArgs("nobody@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- useCase.ResendVerification("nobody@example.com")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResendPasswordResetInvalidatesPreviousToken(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+
+	// Without a cache there is no throttle, so both requests issue a token and each
+	// expires the user's outstanding tokens before inserting the new one
+	for i := 0; i < 2; i++ {
+		expectUser(mock, "jane@example.com", true)
+		expectTokenIssued(mock, "sso_password_reset_tokens")
+	}
+
+	require.NoError(t, useCase.ResendPasswordReset("jane@example.com"))
+	require.NoError(t, useCase.ResendPasswordReset("jane@example.com"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, mailer.resetTokens, 2)
+	assert.NotEqual(t, mailer.resetTokens[0], mailer.resetTokens[1])
+}
+
+func TestResendDoesNotRevealUnknownOrVerifiedEmails(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE email = $1`)).
+		WithArgs("nobody@example.com", 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	expectUser(mock, "verified@example.com", true)
+
+	assert.NoError(t, useCase.ResendPasswordReset("nobody@example.com"))
+	assert.NoError(t, useCase.ResendVerification("verified@example.com"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, mailer.resetTokens)
+	assert.Empty(t, mailer.verificationTokens)
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+)
+
+// publishEvent sends evt through Events when it is configured, with the request's ctx so the
+// event carries its correlation ID. The change it describes is already committed, so a
+// failed publish is logged rather than failing the request.
+func (uc *AuthUseCase) publishEvent(ctx context.Context, evt messagebroker.DomainEvent) {
+	if uc.Events == nil {
+		return
+	}
+
+	if err := uc.Events.PublishEvent(ctx, evt); err != nil {
+		uc.Log.WithError(err).WithField("topic", evt.Topic()).Warn("error publishing event")
+	}
+}
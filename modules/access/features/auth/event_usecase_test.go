@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateProfilePublishesUserUpdatedEvent(t *testing.T) {
+	useCase, mock, _ := newAuthUseCase(t)
+
+	broker, err := messagebroker.NewInMemoryBroker(messagebroker.NewBrokerConfig())
+	require.NoError(t, err)
+	require.NoError(t, broker.Connect(context.Background()))
+	t.Cleanup(func() { broker.Close() })
+
+	var received []*messagebroker.Message
+	require.NoError(t, broker.Subscribe(context.Background(), model.UserEventsTopic, func(ctx context.Context, message *messagebroker.Message) error {
+		received = append(received, message)
+		return nil
+	}, nil))
+
+	useCase.Events, err = messagebroker.NewEventPublisher(broker)
+	require.NoError(t, err)
+
+	expectUserByID(mock, "jane@example.com", true)
+	mock.ExpectBegin()
+	expectUserSaved(mock, "jane@example.com", true)
+	mock.ExpectCommit()
+
+	ctx := messagebroker.ContextWithCorrelationID(context.Background(), "request-42")
+	_, err = useCase.UpdateProfile(ctx, &model.UpdateUserRequest{UserID: "user-1", FirstName: "Janet"})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, received, 1)
+	message := received[0]
+	assert.Equal(t, "user-1", message.Headers[messagebroker.HeaderKafkaKey])
+	assert.Equal(t, model.UserUpdated, message.Headers[messagebroker.HeaderEventType])
+	assert.NotEmpty(t, message.Headers[messagebroker.HeaderOccurredAt])
+	assert.Equal(t, "request-42", message.Headers[messagebroker.HeaderCorrelationID])
+
+	var event model.UserEvent
+	require.NoError(t, json.Unmarshal(message.Data, &event))
+	assert.Equal(t, "user-1", event.ID)
+	assert.Equal(t, "Janet", event.FirstName)
+	assert.Equal(t, model.UserUpdated, event.Type)
+}
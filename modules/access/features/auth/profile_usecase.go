@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/entity"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/prayaspoudel/modules/access/model/converter"
+	"gorm.io/gorm"
+)
+
+// UpdateProfile changes the user's name and email. Empty fields are left unchanged. A new
+// email must not belong to another user; it marks the account unverified and sends a
+// verification email to the new address.
+func (uc *AuthUseCase) UpdateProfile(ctx context.Context, req *model.UpdateUserRequest) (*model.UserResponse, error) {
+	var user entity.User
+	if err := uc.UserRepository.FindById(uc.DB, &user, req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		uc.Log.WithError(err).Error("error finding user")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.LastName != "" {
+		user.LastName = req.LastName
+	}
+
+	email := strings.TrimSpace(req.Email)
+	emailChanged := email != "" && !strings.EqualFold(email, user.Email)
+	if emailChanged {
+		var existing entity.User
+		err := uc.UserRepository.FindByEmail(uc.DB, &existing, email)
+		if err == nil {
+			return nil, fiber.NewError(fiber.StatusConflict, "email already exists")
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			uc.Log.WithError(err).Error("error checking existing user")
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+		}
+
+		user.Email = email
+		user.EmailVerified = false
+	}
+
+	var token string
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := uc.UserRepository.Update(tx, &user); err != nil {
+			return err
+		}
+		if !emailChanged {
+			return nil
+		}
+
+		var err error
+		token, err = uc.issueVerificationToken(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		uc.Log.WithError(err).Error("error updating user profile")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	if emailChanged {
+		uc.sendVerificationEmail(&user, token)
+	}
+	uc.publishEvent(ctx, converter.UserToEvent(&user, model.UserUpdated, uc.now()))
+
+	return converter.UserToResponse(&user), nil
+}
@@ -0,0 +1,88 @@
+package auth_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectUserByID(mock sqlmock.Sqlmock, email string, verified bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE id = $1`)).
+		WithArgs("user-1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "email_verified"}).
+			AddRow("user-1", email, "Jane", "Doe", verified))
+}
+
+func expectUserSaved(mock sqlmock.Sqlmock, email string, verified bool) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_users" SET`)).
+		WithArgs(email, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), verified, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), "user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestUpdateProfileNameOnlyKeepsEmailVerified(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+
+	expectUserByID(mock, "jane@example.com", true)
+	mock.ExpectBegin()
+	expectUserSaved(mock, "jane@example.com", true)
+	mock.ExpectCommit()
+
+	user, err := useCase.UpdateProfile(context.Background(), &model.UpdateUserRequest{UserID: "user-1", FirstName: "Janet", Email: "JANE@example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "Janet", user.FirstName)
+	assert.Equal(t, "Doe", user.LastName)
+	assert.True(t, user.EmailVerified)
+	assert.Empty(t, mailer.verificationTokens)
+}
+
+func TestUpdateProfileEmailChangeRequiresVerification(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+
+	expectUserByID(mock, "jane@example.com", true)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE email = $1`)).
+		WithArgs("jane@new.example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	expectUserSaved(mock, "jane@new.example.com", false)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_email_verification_tokens" SET "expires_at"=$1 WHERE user_id = $2 AND expires_at > $3`)).
+		WithArgs(sqlmock.AnyArg(), "user-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sso_email_verification_tokens"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	user, err := useCase.UpdateProfile(context.Background(), &model.UpdateUserRequest{UserID: "user-1", Email: "jane@new.example.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "jane@new.example.com", user.Email)
+	assert.False(t, user.EmailVerified)
+	assert.Len(t, mailer.verificationTokens, 1)
+}
+
+func TestUpdateProfileRejectsEmailInUse(t *testing.T) {
+	useCase, mock, mailer := newAuthUseCase(t)
+
+	expectUserByID(mock, "jane@example.com", true)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_users" WHERE email = $1`)).
+		WithArgs("john@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow("user-2", "john@example.com"))
+
+	_, err := useCase.UpdateProfile(context.Background(), &model.UpdateUserRequest{UserID: "user-1", Email: "john@example.com"})
+
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, fiber.StatusConflict, fiberErr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, mailer.verificationTokens)
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prayaspoudel/modules/access/entity"
+	"gorm.io/gorm"
+)
+
+const defaultSessionCacheTTL = 30 * time.Second
+
+// ValidateSession checks that token belongs to a session that has not been logged out or
+// expired. It only applies when auth.session_validation is enabled; otherwise a valid
+// access token is accepted on its own. A live session is remembered in the cache for
+// auth.session_cache_ttl (30s by default) so repeated requests skip the database, and
+// Logout and LogoutAll evict it. A validation that read the session just before it was
+// deleted finds the user's revocation marker after caching and drops its entry again.
+func (uc *AuthUseCase) ValidateSession(ctx context.Context, token string) error {
+	if !uc.Viper.GetBool("auth.session_validation") {
+		return nil
+	}
+
+	key := sessionCacheKey(token)
+	if uc.Cache != nil {
+		if exists, err := uc.Cache.Exists(ctx, key); err == nil && exists {
+			return nil
+		}
+	}
+
+	var session entity.Session
+	err := uc.SessionRepository.FindByToken(uc.DB, &session, token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(fiber.StatusUnauthorized, "session expired or revoked")
+	}
+	if err != nil {
+		uc.Log.WithError(err).Error("error finding session")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	remaining := session.ExpiresAt.Sub(uc.now())
+	if remaining <= 0 {
+		return fiber.NewError(fiber.StatusUnauthorized, "session expired or revoked")
+	}
+
+	if uc.Cache != nil {
+		// Never cache a session past its own expiry
+		ttl := min(uc.durationSetting("auth.session_cache_ttl", defaultSessionCacheTTL), remaining)
+		if err := uc.Cache.Set(ctx, key, session.UserID, ttl); err != nil {
+			uc.Log.WithError(err).Warn("error caching session")
+		} else if revoked, err := uc.Cache.Exists(ctx, revokedSessionsKey(session.UserID)); err != nil || revoked {
+			// The user's sessions were ended after the lookup, possibly evicting before the
+			// entry was written; the next request goes back to the database
+			if err := uc.Cache.Delete(ctx, key); err != nil {
+				uc.Log.WithError(err).Warn("error evicting cached session")
+			}
+		}
+	}
+	return nil
+}
+
+// LogoutAll ends every session of the user and revokes their refresh tokens
+func (uc *AuthUseCase) LogoutAll(ctx context.Context, userID string) error {
+	var tokens []string
+	if err := uc.SessionRepository.FindTokensByUserID(uc.DB, &tokens, userID); err != nil {
+		uc.Log.WithError(err).Error("error finding sessions")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := uc.SessionRepository.DeleteByUserID(tx, userID); err != nil {
+			return err
+		}
+		return uc.RefreshTokenRepo.RevokeByUserID(tx, userID)
+	})
+	if err != nil {
+		uc.Log.WithError(err).Error("error ending sessions")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	uc.evictSessions(ctx, userID, tokens...)
+	return nil
+}
+
+// CleanupExpiredTokens deletes sessions and refresh tokens that have expired
+func (uc *AuthUseCase) CleanupExpiredTokens() error {
+	now := uc.now()
+	if err := uc.SessionRepository.DeleteExpired(uc.DB, now); err != nil {
+		uc.Log.WithError(err).Error("error deleting expired sessions")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+	if err := uc.RefreshTokenRepo.DeleteExpired(uc.DB, now); err != nil {
+		uc.Log.WithError(err).Error("error deleting expired refresh tokens")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+	return nil
+}
+
+func (uc *AuthUseCase) createSession(userID string, token string, ipAddress string, expiresIn int) error {
+	session := &entity.Session{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		SessionToken: token,
+		IPAddress:    ipAddress,
+		UserAgent:    "", // TODO: Get from request
+		ExpiresAt:    uc.now().Add(time.Duration(expiresIn) * time.Second),
+	}
+
+	if err := uc.SessionRepository.Create(uc.DB, session); err != nil {
+		uc.Log.WithError(err).Error("error creating session")
+		return fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+	return nil
+}
+
+// evictSessions drops cached validations so logged-out tokens are rejected immediately.
+// It first marks the user's sessions as revoked for auth.session_cache_ttl, so a
+// ValidateSession racing the logout does not leave its cache entry behind. The mark covers
+// every session of the user, not just the logged-out tokens, so until it expires their
+// other sessions, including one from an immediate re-login, are cached and dropped again
+// on each request and validated against the database every time. The eviction outlives a
+// canceled ctx, since the sessions are already gone from the database.
+func (uc *AuthUseCase) evictSessions(ctx context.Context, userID string, tokens ...string) {
+	if uc.Cache == nil || len(tokens) == 0 {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	ttl := uc.durationSetting("auth.session_cache_ttl", defaultSessionCacheTTL)
+	if err := uc.Cache.Set(ctx, revokedSessionsKey(userID), true, ttl); err != nil {
+		uc.Log.WithError(err).Warn("error marking sessions revoked")
+	}
+
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = sessionCacheKey(token)
+	}
+	if err := uc.Cache.DeleteMultiple(ctx, keys); err != nil {
+		uc.Log.WithError(err).Warn("error evicting cached sessions")
+	}
+}
+
+// revokedSessionsKey marks a user whose sessions were recently ended
+func revokedSessionsKey(userID string) string {
+	return "session-revoked:" + userID
+}
+
+// sessionCacheKey hashes the token so access tokens are not stored in the cache as keys
+func sessionCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "session:" + hex.EncodeToString(sum[:])
+}
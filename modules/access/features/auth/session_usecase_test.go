@@ -0,0 +1,142 @@
+package auth_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/features/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionUseCase(t *testing.T) (*auth.AuthUseCase, sqlmock.Sqlmock) {
+	useCase, mock, _ := newAuthUseCase(t)
+	useCase.Viper.Set("auth.session_validation", true)
+	useCase.Cache = newThrottleCache(t)
+	return useCase, mock
+}
+
+func expectSession(mock sqlmock.Sqlmock, token string, found bool) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "session_token", "expires_at"})
+	if found {
+		rows.AddRow("session-1", "user-1", token, time.Now().Add(time.Hour))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_sessions" WHERE session_token = $1`)).
+		WithArgs(token, 1).
+		WillReturnRows(rows)
+}
+
+func TestValidateSessionServesRepeatFromCache(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+
+	expectSession(mock, "token-1", true)
+
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateSessionRejectsUnknownSession(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+
+	expectSession(mock, "token-1", false)
+
+	err := useCase.ValidateSession(context.Background(), "token-1")
+
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, fiber.StatusUnauthorized, fiberErr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateSessionDisabledSkipsLookup(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+	useCase.Viper.Set("auth.session_validation", false)
+
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutEvictsCachedSession(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+
+	expectSession(mock, "token-1", true)
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sso_sessions" WHERE session_token = $1`)).
+		WithArgs("token-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_refresh_tokens" SET "revoked_at"=NOW() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs("user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, useCase.Logout(context.Background(), "user-1", "token-1"))
+
+	expectSession(mock, "token-1", false)
+	assert.Error(t, useCase.ValidateSession(context.Background(), "token-1"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutAllEvictsEveryCachedSession(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+
+	expectSession(mock, "token-1", true)
+	expectSession(mock, "token-2", true)
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-2"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "session_token" FROM "sso_sessions" WHERE user_id = $1`)).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"session_token"}).AddRow("token-1").AddRow("token-2"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sso_sessions" WHERE user_id = $1`)).
+		WithArgs("user-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_refresh_tokens" SET "revoked_at"=NOW() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs("user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, useCase.LogoutAll(context.Background(), "user-1"))
+
+	expectSession(mock, "token-1", false)
+	expectSession(mock, "token-2", false)
+	assert.Error(t, useCase.ValidateSession(context.Background(), "token-1"))
+	assert.Error(t, useCase.ValidateSession(context.Background(), "token-2"))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidateSessionRacingLogoutAllLeavesNoCacheEntry(t *testing.T) {
+	useCase, mock := newSessionUseCase(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "session_token" FROM "sso_sessions" WHERE user_id = $1`)).
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"session_token"}).AddRow("token-1"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sso_sessions" WHERE user_id = $1`)).
+		WithArgs("user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_refresh_tokens" SET "revoked_at"=NOW() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs("user-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, useCase.LogoutAll(context.Background(), "user-1"))
+
+	// A validation that read the session before the delete finishes after the eviction
+	expectSession(mock, "token-1", true)
+	require.NoError(t, useCase.ValidateSession(context.Background(), "token-1"))
+
+	expectSession(mock, "token-1", false)
+	assert.Error(t, useCase.ValidateSession(context.Background(), "token-1"), "the revoked token was served from the cache")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,222 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prayaspoudel/modules/access/entity"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/prayaspoudel/modules/access/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuthorizationCodeExpiry = 10 * time.Minute
+	defaultConsentTokenExpiry      = 10 * time.Minute
+)
+
+type OAuthUseCase struct {
+	DB                 *gorm.DB
+	Log                *logrus.Logger
+	Viper              *viper.Viper
+	ClientRepository   *repository.OAuth2ClientRepository
+	AuthCodeRepository *repository.OAuth2AuthCodeRepository
+	ConsentRepository  *repository.UserConsentRepository
+}
+
+func NewOAuthUseCase(
+	db *gorm.DB,
+	log *logrus.Logger,
+	viper *viper.Viper,
+	clientRepo *repository.OAuth2ClientRepository,
+	authCodeRepo *repository.OAuth2AuthCodeRepository,
+	consentRepo *repository.UserConsentRepository,
+) *OAuthUseCase {
+	return &OAuthUseCase{
+		DB:                 db,
+		Log:                log,
+		Viper:              viper,
+		ClientRepository:   clientRepo,
+		AuthCodeRepository: authCodeRepo,
+		ConsentRepository:  consentRepo,
+	}
+}
+
+// Authorize runs the authorization code flow for an authenticated user. A code is issued
+// straight away when the user has already granted every requested scope to the client.
+// Otherwise the response asks for consent and carries a short-lived consent token; the
+// repeated request with that token records the grant and issues the code.
+func (uc *OAuthUseCase) Authorize(req *model.AuthorizeRequest) (*model.AuthorizeResponse, error) {
+	if req.ResponseType != "code" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "unsupported response type")
+	}
+
+	var client entity.OAuth2Client
+	if err := uc.ClientRepository.FindByClientID(uc.DB, &client, req.ClientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid client")
+		}
+		uc.Log.WithError(err).Error("error finding oauth client")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	if !slices.Contains(decodeList(client.RedirectURIs), req.RedirectURI) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid redirect uri")
+	}
+
+	allowed := decodeList(client.Scopes)
+	requested := strings.Fields(req.Scope)
+	if len(requested) == 0 {
+		requested = allowed
+	}
+	requested = normalizeScopes(requested)
+	for _, scope := range requested {
+		if !slices.Contains(allowed, scope) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid scope")
+		}
+	}
+
+	var consent entity.UserConsent
+	err := uc.ConsentRepository.FindByUserAndClient(uc.DB, &consent, req.UserID, client.ClientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		uc.Log.WithError(err).Error("error finding user consent")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+	consented := err == nil
+	granted := decodeList(consent.Scopes)
+
+	needsConsent := false
+	for _, scope := range requested {
+		if !slices.Contains(granted, scope) {
+			needsConsent = true
+			break
+		}
+	}
+
+	if needsConsent && req.ConsentToken == "" {
+		token, err := uc.consentToken(req.UserID, client.ClientID, requested, time.Now().Add(uc.durationSetting("oauth.consent_token_expiry", defaultConsentTokenExpiry)))
+		if err != nil {
+			return nil, err
+		}
+		return &model.AuthorizeResponse{
+			State:           req.State,
+			ConsentRequired: true,
+			ConsentToken:    token,
+			Scopes:          requested,
+		}, nil
+	}
+	if needsConsent && !uc.validConsentToken(req.ConsentToken, req.UserID, client.ClientID, requested) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid consent token")
+	}
+
+	code := &entity.OAuth2AuthorizationCode{
+		ID:          uuid.New().String(),
+		Code:        strings.ReplaceAll(uuid.New().String()+uuid.New().String(), "-", ""),
+		ClientID:    client.ClientID,
+		UserID:      req.UserID,
+		RedirectURI: req.RedirectURI,
+		Scopes:      encodeList(requested),
+		ExpiresAt:   time.Now().Add(uc.durationSetting("oauth.authorization_code_expiry", defaultAuthorizationCodeExpiry)),
+	}
+
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		if needsConsent {
+			consent.Scopes = encodeList(normalizeScopes(append(granted, requested...)))
+			if consented {
+				if err := uc.ConsentRepository.Update(tx, &consent); err != nil {
+					return err
+				}
+			} else {
+				consent.ID = uuid.New().String()
+				consent.UserID = req.UserID
+				consent.ClientID = client.ClientID
+				if err := uc.ConsentRepository.Create(tx, &consent); err != nil {
+					return err
+				}
+			}
+		}
+		return uc.AuthCodeRepository.Create(tx, code)
+	})
+	if err != nil {
+		uc.Log.WithError(err).Error("error issuing authorization code")
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	return &model.AuthorizeResponse{
+		Code:  code.Code,
+		State: req.State,
+	}, nil
+}
+
+// consentToken binds an approval to the user, client and exact scopes shown on the
+// consent screen, so it cannot be replayed for another grant. It has the form
+// "<expiry unix seconds>.<hex HMAC-SHA256>", keyed by jwt.secret.
+func (uc *OAuthUseCase) consentToken(userID string, clientID string, scopes []string, expiresAt time.Time) (string, error) {
+	secret := uc.Viper.GetString("jwt.secret")
+	if secret == "" {
+		uc.Log.Error("JWT secret not configured")
+		return "", fiber.NewError(fiber.StatusInternalServerError, "internal server error")
+	}
+
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{expiry, userID, clientID, strings.Join(scopes, " ")}, "\n")))
+	return expiry + "." + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (uc *OAuthUseCase) validConsentToken(token string, userID string, clientID string, scopes []string) bool {
+	expiry, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+
+	expected, err := uc.consentToken(userID, clientID, scopes, time.Unix(unix, 0))
+	return err == nil && hmac.Equal([]byte(token), []byte(expected))
+}
+
+func (uc *OAuthUseCase) durationSetting(key string, fallback time.Duration) time.Duration {
+	if d := uc.Viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// normalizeScopes sorts scopes and drops duplicates so grants compare and sign consistently
+func normalizeScopes(scopes []string) []string {
+	normalized := append([]string(nil), scopes...)
+	sort.Strings(normalized)
+	return slices.Compact(normalized)
+}
+
+// decodeList reads the JSON arrays the OAuth2 entities store as strings
+func decodeList(value string) []string {
+	var list []string
+	if value == "" {
+		return list
+	}
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil
+	}
+	return list
+}
+
+func encodeList(list []string) string {
+	data, _ := json.Marshal(list)
+	return string(data)
+}
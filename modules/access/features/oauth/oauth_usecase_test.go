@@ -0,0 +1,184 @@
+package oauth_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/features/oauth"
+	"github.com/prayaspoudel/modules/access/model"
+	"github.com/prayaspoudel/modules/access/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newOAuthUseCase(t *testing.T) (*oauth.OAuthUseCase, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	config := viper.New()
+	config.Set("jwt.secret", "test-secret")
+
+	log := logrus.New()
+	return oauth.NewOAuthUseCase(
+		db,
+		log,
+		config,
+		repository.NewOAuth2ClientRepository(log),
+		repository.NewOAuth2AuthCodeRepository(log),
+		repository.NewUserConsentRepository(log),
+	), mock
+}
+
+func authorizeRequest(scope string) *model.AuthorizeRequest {
+	return &model.AuthorizeRequest{
+		UserID:       "user-1",
+		ResponseType: "code",
+		ClientID:     "client-1",
+		RedirectURI:  "https://app.example.com/callback",
+		Scope:        scope,
+		State:        "xyz",
+	}
+}
+
+func expectClient(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_oauth_clients" WHERE client_id = $1 AND active = $2`)).
+		WithArgs("client-1", true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "client_id", "redirect_uris", "scopes", "active"}).
+			AddRow("1", "client-1", `["https://app.example.com/callback"]`, `["email","profile","orders"]`, true))
+}
+
+func expectConsent(mock sqlmock.Sqlmock, scopes string) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "client_id", "scopes"})
+	if scopes != "" {
+		rows.AddRow("consent-1", "user-1", "client-1", scopes)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_oauth_consents" WHERE user_id = $1 AND client_id = $2`)).
+		WithArgs("user-1", "client-1", 1).
+		WillReturnRows(rows)
+}
+
+func expectCodeIssued(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sso_oauth_authorization_codes"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestAuthorizeRequiresConsentTheFirstTime(t *testing.T) {
+	useCase, mock := newOAuthUseCase(t)
+
+	expectClient(mock)
+	expectConsent(mock, "")
+	response, err := useCase.Authorize(authorizeRequest("profile email"))
+	require.NoError(t, err)
+
+	assert.True(t, response.ConsentRequired)
+	assert.Empty(t, response.Code)
+	assert.NotEmpty(t, response.ConsentToken)
+	assert.Equal(t, []string{"email", "profile"}, response.Scopes)
+	assert.Equal(t, "xyz", response.State)
+
+	// Approving on the consent screen records the grant and issues the code
+	expectClient(mock)
+	expectConsent(mock, "")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "sso_oauth_consents"`)).
+		WithArgs(sqlmock.AnyArg(), "user-1", "client-1", `["email","profile"]`, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectCodeIssued(mock)
+
+	approved := authorizeRequest("profile email")
+	approved.ConsentToken = response.ConsentToken
+	response, err = useCase.Authorize(approved)
+	require.NoError(t, err)
+
+	assert.False(t, response.ConsentRequired)
+	assert.NotEmpty(t, response.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizeSkipsConsentForGrantedScopes(t *testing.T) {
+	useCase, mock := newOAuthUseCase(t)
+
+	expectClient(mock)
+	expectConsent(mock, `["email","profile"]`)
+	mock.ExpectBegin()
+	expectCodeIssued(mock)
+
+	response, err := useCase.Authorize(authorizeRequest("email"))
+	require.NoError(t, err)
+
+	assert.False(t, response.ConsentRequired)
+	assert.NotEmpty(t, response.Code)
+	assert.Equal(t, "xyz", response.State)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizeRequiresConsentForNewScopes(t *testing.T) {
+	useCase, mock := newOAuthUseCase(t)
+
+	expectClient(mock)
+	expectConsent(mock, `["email"]`)
+	response, err := useCase.Authorize(authorizeRequest("email orders"))
+	require.NoError(t, err)
+
+	assert.True(t, response.ConsentRequired)
+	assert.Equal(t, []string{"email", "orders"}, response.Scopes)
+
+	expectClient(mock)
+	expectConsent(mock, `["email"]`)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_oauth_consents" SET`)).
+		WithArgs("user-1", "client-1", `["email","orders"]`, sqlmock.AnyArg(), sqlmock.AnyArg(), "consent-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectCodeIssued(mock)
+
+	approved := authorizeRequest("email orders")
+	approved.ConsentToken = response.ConsentToken
+	response, err = useCase.Authorize(approved)
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, response.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizeRejectsConsentTokenForOtherScopes(t *testing.T) {
+	useCase, mock := newOAuthUseCase(t)
+
+	expectClient(mock)
+	expectConsent(mock, "")
+	response, err := useCase.Authorize(authorizeRequest("email"))
+	require.NoError(t, err)
+
+	expectClient(mock)
+	expectConsent(mock, "")
+	widened := authorizeRequest("email orders")
+	widened.ConsentToken = response.ConsentToken
+	_, err = useCase.Authorize(widened)
+
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizeRejectsScopesOutsideClient(t *testing.T) {
+	useCase, mock := newOAuthUseCase(t)
+
+	expectClient(mock)
+	_, err := useCase.Authorize(authorizeRequest("email admin"))
+
+	var fiberErr *fiber.Error
+	require.ErrorAs(t, err, &fiberErr)
+	assert.Equal(t, "invalid scope", fiberErr.Message)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const redactedValue = "[REDACTED]"
+
+// DefaultSensitiveFields are redacted from logged request bodies when no list is configured
+var DefaultSensitiveFields = []string{
+	"password",
+	"newPassword",
+	"oldPassword",
+	"client_secret",
+	"token",
+	"refreshToken",
+}
+
+type AccessLogMiddleware struct {
+	Log             *logrus.Logger
+	Level           logrus.Level // level request lines are logged at
+	LogBodies       bool
+	sensitiveFields map[string]struct{}
+}
+
+// NewAccessLogMiddleware reads log.access.level, log.access.body and
+// log.access.sensitive_fields from config. Request lines are logged at info unless
+// log.access.level names another level, so they can be kept above a stricter log.level.
+func NewAccessLogMiddleware(log *logrus.Logger, config *viper.Viper) *AccessLogMiddleware {
+	fields := config.GetStringSlice("log.access.sensitive_fields")
+	if len(fields) == 0 {
+		fields = DefaultSensitiveFields
+	}
+
+	level := logrus.InfoLevel
+	if name := config.GetString("log.access.level"); name != "" {
+		parsed, err := logrus.ParseLevel(name)
+		if err != nil {
+			log.WithError(err).Warn("invalid log.access.level, logging requests at info")
+		} else {
+			level = parsed
+		}
+	}
+
+	m := &AccessLogMiddleware{
+		Log:             log,
+		Level:           level,
+		LogBodies:       config.GetBool("log.access.body"),
+		sensitiveFields: make(map[string]struct{}, len(fields)),
+	}
+	for _, field := range fields {
+		m.sensitiveFields[normalizeFieldName(field)] = struct{}{}
+	}
+	return m
+}
+
+func (m *AccessLogMiddleware) Handle(ctx *fiber.Ctx) error {
+	start := time.Now()
+	err := ctx.Next()
+
+	status := ctx.Response().StatusCode()
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	}
+
+	fields := logrus.Fields{
+		"method":   ctx.Method(),
+		"path":     ctx.Path(),
+		"status":   status,
+		"duration": time.Since(start).String(),
+		"ip":       ctx.IP(),
+	}
+
+	if m.LogBodies && len(ctx.Body()) > 0 {
+		fields["body"] = m.redactBody(ctx.Body())
+	}
+
+	m.Log.WithFields(fields).Log(m.Level, "HTTP request")
+	return err
+}
+
+// redactBody masks sensitive fields at any depth of a JSON body. Bodies that are not
+// JSON cannot be inspected, so they are omitted rather than risk logging secrets.
+func (m *AccessLogMiddleware) redactBody(body []byte) string {
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "[omitted]"
+	}
+
+	redacted, err := json.Marshal(m.redact(payload))
+	if err != nil {
+		return "[omitted]"
+	}
+	return string(redacted)
+}
+
+func (m *AccessLogMiddleware) redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if _, sensitive := m.sensitiveFields[normalizeFieldName(key)]; sensitive {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = m.redact(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = m.redact(item)
+		}
+	}
+	return value
+}
+
+// normalizeFieldName lets "client_secret", "clientSecret" and "Client-Secret" match the same entry
+func normalizeFieldName(name string) string {
+	name = strings.ReplaceAll(name, "_", "")
+	name = strings.ReplaceAll(name, "-", "")
+	return strings.ToLower(name)
+}
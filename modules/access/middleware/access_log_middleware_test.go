@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prayaspoudel/modules/access/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAccessLogApp(t *testing.T, config *viper.Viper) (*fiber.App, *bytes.Buffer) {
+	var output bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&output)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	app := fiber.New()
+	app.Use(middleware.NewAccessLogMiddleware(log, config).Handle)
+	app.Post("/api/auth/login", func(ctx *fiber.Ctx) error {
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+	return app, &output
+}
+
+func post(t *testing.T, app *fiber.App, contentType, body string) {
+	req := httptest.NewRequest(fiber.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, contentType)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestAccessLogRedactsLoginPassword(t *testing.T) {
+	config := viper.New()
+	config.Set("log.access.body", true)
+	app, output := newAccessLogApp(t, config)
+
+	post(t, app, fiber.MIMEApplicationJSON, `{"email":"jane@example.com","password":"s3cret-pass","nested":{"refreshToken":"r-token"}}`)
+
+	line := output.String()
+	assert.Contains(t, line, "jane@example.com")
+	assert.Contains(t, line, "[REDACTED]")
+	assert.NotContains(t, line, "s3cret-pass")
+	assert.NotContains(t, line, "r-token")
+}
+
+func TestAccessLogOmitsNonJSONBody(t *testing.T) {
+	config := viper.New()
+	config.Set("log.access.body", true)
+	app, output := newAccessLogApp(t, config)
+
+	post(t, app, fiber.MIMEApplicationForm, "email=jane%40example.com&password=s3cret-pass")
+
+	assert.NotContains(t, output.String(), "s3cret-pass")
+}
+
+func TestAccessLogUsesConfiguredSensitiveFields(t *testing.T) {
+	config := viper.New()
+	config.Set("log.access.body", true)
+	config.Set("log.access.sensitive_fields", []string{"pin"})
+	app, output := newAccessLogApp(t, config)
+
+	post(t, app, fiber.MIMEApplicationJSON, `{"pin":"4321","email":"jane@example.com"}`)
+
+	assert.NotContains(t, output.String(), "4321")
+}
+
+func TestAccessLogSkipsBodyByDefault(t *testing.T) {
+	app, output := newAccessLogApp(t, viper.New())
+
+	post(t, app, fiber.MIMEApplicationJSON, `{"email":"jane@example.com","password":"s3cret-pass"}`)
+
+	assert.Contains(t, output.String(), "/api/auth/login")
+	assert.NotContains(t, output.String(), "jane@example.com")
+}
+
+func TestAccessLogUsesConfiguredLevel(t *testing.T) {
+	config := viper.New()
+	config.Set("log.access.level", "warn")
+	app, output := newAccessLogApp(t, config)
+
+	post(t, app, fiber.MIMEApplicationJSON, `{"email":"jane@example.com"}`)
+
+	assert.Contains(t, output.String(), `"level":"warning"`)
+}
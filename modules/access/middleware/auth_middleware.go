@@ -18,8 +18,10 @@ func NewAuthMiddleware(authUseCase *auth.AuthUseCase) *AuthMiddleware {
 }
 
 type AuthContext struct {
-	UserID string
-	Email  string
+	UserID    string
+	Email     string
+	Role      string
+	CompanyID string
 }
 
 func (m *AuthMiddleware) Authenticate(ctx *fiber.Ctx) error {
@@ -43,15 +45,45 @@ func (m *AuthMiddleware) Authenticate(ctx *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
 	}
 
+	// Reject tokens whose session has been logged out
+	if err := m.AuthUseCase.ValidateSession(ctx.UserContext(), token); err != nil {
+		return err
+	}
+
 	// Set user context
-	ctx.Locals("auth", &AuthContext{
-		UserID: (*claims)["sub"].(string),
-		Email:  (*claims)["email"].(string),
-	})
+	role, _ := (*claims)["role"].(string)
+	companyID, _ := (*claims)["company_id"].(string)
+	auth := &AuthContext{
+		UserID:    (*claims)["sub"].(string),
+		Email:     (*claims)["email"].(string),
+		Role:      role,
+		CompanyID: companyID,
+	}
+	ctx.Locals("auth", auth)
+	ctx.SetUserContext(WithAuthContext(ctx.UserContext(), auth))
 
 	return ctx.Next()
 }
 
+// RequireRole only lets requests through whose authenticated user has one of the roles.
+// It must run after Authenticate.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		auth := GetAuth(ctx)
+		if auth == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+		}
+
+		for _, role := range roles {
+			if auth.Role == role {
+				return ctx.Next()
+			}
+		}
+
+		return fiber.NewError(fiber.StatusForbidden, "insufficient role")
+	}
+}
+
 // GetAuth retrieves auth context from fiber context
 func GetAuth(ctx *fiber.Ctx) *AuthContext {
 	auth, ok := ctx.Locals("auth").(*AuthContext)
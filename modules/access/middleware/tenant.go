@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+)
+
+var errMissingTenant = errors.New("no tenant on context")
+
+type authContextKey struct{}
+
+// WithAuthContext stores the authenticated user on a context.Context so code below the
+// HTTP layer (use cases, repositories, publishers) can read it
+func WithAuthContext(ctx context.Context, auth *AuthContext) context.Context {
+	return context.WithValue(ctx, authContextKey{}, auth)
+}
+
+// AuthFromContext returns the AuthContext stored by WithAuthContext, or nil
+func AuthFromContext(ctx context.Context) *AuthContext {
+	auth, _ := ctx.Value(authContextKey{}).(*AuthContext)
+	return auth
+}
+
+// TenantID returns the company the authenticated user belongs to. It fails closed:
+// an anonymous context or a user without a company is an error.
+func TenantID(ctx context.Context) (string, error) {
+	auth := AuthFromContext(ctx)
+	if auth == nil || auth.CompanyID == "" {
+		return "", errMissingTenant
+	}
+	return auth.CompanyID, nil
+}
+
+// TenantCache scopes the cache to the tenant on ctx, storing keys as "tenant:<company>:<key>"
+func TenantCache(ctx context.Context, base cache.CacheManager) (cache.CacheManager, error) {
+	tenantID, err := TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cache.NewPrefixedCacheManager(base, "tenant:"+tenantID+":"), nil
+}
+
+// TenantTopic scopes a topic name to the tenant on ctx as "tenant.<company>.<topic>"
+func TenantTopic(ctx context.Context, base string) (string, error) {
+	tenantID, err := TenantID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "tenant." + tenantID + "." + base, nil
+}
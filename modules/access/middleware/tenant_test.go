@@ -0,0 +1,77 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+	"github.com/prayaspoudel/modules/access/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tenantContext(companyID string) context.Context {
+	return middleware.WithAuthContext(context.Background(), &middleware.AuthContext{
+		UserID:    "user-" + companyID,
+		CompanyID: companyID,
+	})
+}
+
+func TestTenantCacheIsolatesTenants(t *testing.T) {
+	base, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	require.NoError(t, err)
+
+	acmeCtx, globexCtx := tenantContext("acme"), tenantContext("globex")
+	acme, err := middleware.TenantCache(acmeCtx, base)
+	require.NoError(t, err)
+	globex, err := middleware.TenantCache(globexCtx, base)
+	require.NoError(t, err)
+
+	require.NoError(t, acme.Set(acmeCtx, "settings", "acme-settings", time.Minute))
+	require.NoError(t, globex.Set(globexCtx, "settings", "globex-settings", time.Minute))
+
+	value, err := acme.GetString(acmeCtx, "settings")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-settings", value)
+
+	value, err = globex.GetString(globexCtx, "settings")
+	require.NoError(t, err)
+	assert.Equal(t, "globex-settings", value)
+
+	keys, err := acme.Keys(acmeCtx, "*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"settings"}, keys)
+
+	// Clearing one tenant leaves the other untouched
+	require.NoError(t, acme.Clear(acmeCtx))
+	exists, err := globex.Exists(globexCtx, "settings")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestTenantTopicIsolatesTenants(t *testing.T) {
+	acme, err := middleware.TenantTopic(tenantContext("acme"), "orders.created")
+	require.NoError(t, err)
+	globex, err := middleware.TenantTopic(tenantContext("globex"), "orders.created")
+	require.NoError(t, err)
+
+	assert.Equal(t, "tenant.acme.orders.created", acme)
+	assert.NotEqual(t, acme, globex)
+}
+
+func TestTenantHelpersFailWithoutTenant(t *testing.T) {
+	base, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	require.NoError(t, err)
+
+	_, err = middleware.TenantCache(context.Background(), base)
+	assert.Error(t, err)
+
+	_, err = middleware.TenantTopic(context.Background(), "orders")
+	assert.Error(t, err)
+
+	// Authenticated but not attached to a company
+	noCompany := middleware.WithAuthContext(context.Background(), &middleware.AuthContext{UserID: "user-1"})
+	_, err = middleware.TenantTopic(noCompany, "orders")
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package converter
 
 import (
+	"time"
+
 	"github.com/prayaspoudel/modules/access/entity"
 	"github.com/prayaspoudel/modules/access/model"
 )
@@ -32,6 +34,18 @@ func CompanyToResponse(company *entity.Company) *model.CompanyResponse {
 	}
 }
 
+func UserToEvent(user *entity.User, eventType string, occurredAt time.Time) *model.UserEvent {
+	return &model.UserEvent{
+		Type:          eventType,
+		ID:            user.ID,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		EmailVerified: user.EmailVerified,
+		Time:          occurredAt,
+	}
+}
+
 func OAuth2ClientToResponse(client *entity.OAuth2Client) *model.OAuth2ClientResponse {
 	return &model.OAuth2ClientResponse{
 		ID:          client.ID,
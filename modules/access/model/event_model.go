@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Topics the access module publishes its domain events to
+const (
+	UserEventsTopic = "access.users"
+)
+
+// Domain event types, sent in the X-Event-Type header
+const (
+	UserRegistered = "user.registered"
+	UserUpdated    = "user.updated"
+)
+
+// UserEvent is published when a user account is created or changed. Events for one user
+// share a key, so consumers see them in order.
+type UserEvent struct {
+	Type          string    `json:"type"`
+	ID            string    `json:"id"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"firstName"`
+	LastName      string    `json:"lastName"`
+	EmailVerified bool      `json:"emailVerified"`
+	Time          time.Time `json:"occurredAt"`
+}
+
+func (e *UserEvent) Topic() string         { return UserEventsTopic }
+func (e *UserEvent) Key() string           { return e.ID }
+func (e *UserEvent) EventType() string     { return e.Type }
+func (e *UserEvent) OccurredAt() time.Time { return e.Time }
@@ -39,17 +39,25 @@ type UpdateOAuth2ClientRequest struct {
 
 // AuthorizeRequest represents an OAuth2 authorization request
 type AuthorizeRequest struct {
+	UserID       string `json:"-" validate:"required"`
 	ResponseType string `json:"responseType" validate:"required"`
 	ClientID     string `json:"clientId" validate:"required"`
 	RedirectURI  string `json:"redirectUri" validate:"required,url"`
 	Scope        string `json:"scope,omitempty"`
 	State        string `json:"state,omitempty"`
+	// ConsentToken is sent back from the consent screen to approve the requested scopes
+	ConsentToken string `json:"consentToken,omitempty"`
 }
 
-// AuthorizeResponse represents an OAuth2 authorization response
+// AuthorizeResponse represents an OAuth2 authorization response. When ConsentRequired is
+// set no code is issued; the consent screen shows Scopes and, once the user approves,
+// repeats the request with ConsentToken.
 type AuthorizeResponse struct {
-	Code  string `json:"code"`
-	State string `json:"state,omitempty"`
+	Code            string   `json:"code,omitempty"`
+	State           string   `json:"state,omitempty"`
+	ConsentRequired bool     `json:"consentRequired,omitempty"`
+	ConsentToken    string   `json:"consentToken,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
 }
 
 // TokenRequest represents an OAuth2 token request
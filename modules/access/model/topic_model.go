@@ -0,0 +1,9 @@
+package model
+
+// CreateTopicRequest represents an admin request to create a broker topic
+type CreateTopicRequest struct {
+	Name              string `json:"name" validate:"required,max=249"`
+	Partitions        int    `json:"partitions" validate:"omitempty,min=1"`
+	ReplicationFactor int    `json:"replicationFactor" validate:"omitempty,min=1"`
+	Durable           bool   `json:"durable"`
+}
@@ -57,6 +57,11 @@ type ForgotPasswordRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// ResendEmailRequest represents a request to resend a verification or password reset email
+type ResendEmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 // ResetPasswordRequest represents a password reset request
 type ResetPasswordRequest struct {
 	Token       string `json:"token" validate:"required"`
@@ -78,7 +83,7 @@ type UpdateUserRequest struct {
 	UserID    string `json:"-" validate:"required"`
 	FirstName string `json:"firstName" validate:"max=100"`
 	LastName  string `json:"lastName" validate:"max=100"`
-	Email     string `json:"email" validate:"email,max=255"`
+	Email     string `json:"email" validate:"omitempty,email,max=255"`
 }
 
 // LogoutRequest represents a logout request
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/prayaspoudel/infrastructure/cache"
+	"gorm.io/gorm"
+)
+
+// CachedRepository is a cache-aside decorator over Repository. FindById is
+// served from the cache when possible, and Update/Delete evict the cached entry.
+type CachedRepository[T any] struct {
+	Repository[T]
+	Cache cache.CacheManager
+	TTL   time.Duration
+}
+
+func NewCachedRepository[T any](cacheManager cache.CacheManager, ttl time.Duration) *CachedRepository[T] {
+	return &CachedRepository[T]{
+		Cache: cacheManager,
+		TTL:   ttl,
+	}
+}
+
+func (r *CachedRepository[T]) FindById(db *gorm.DB, entity *T, id any) error {
+	ctx := statementContext(db)
+	key, err := r.cacheKey(db, id)
+	if err != nil {
+		return r.Repository.FindById(db, entity, id)
+	}
+
+	if cached, err := r.Cache.GetString(ctx, key); err == nil {
+		if err := json.Unmarshal([]byte(cached), entity); err == nil {
+			return nil
+		}
+	}
+
+	if err := r.Repository.FindById(db, entity, id); err != nil {
+		return err
+	}
+
+	if data, err := json.Marshal(entity); err == nil {
+		_ = r.Cache.Set(ctx, key, data, r.TTL)
+	}
+
+	return nil
+}
+
+func (r *CachedRepository[T]) Update(db *gorm.DB, entity *T) error {
+	if err := r.Repository.Update(db, entity); err != nil {
+		return err
+	}
+	return r.evict(db, entity)
+}
+
+func (r *CachedRepository[T]) Delete(db *gorm.DB, entity *T) error {
+	if err := r.Repository.Delete(db, entity); err != nil {
+		return err
+	}
+	return r.evict(db, entity)
+}
+
+// Invalidate removes the cached entry for the given id
+func (r *CachedRepository[T]) Invalidate(db *gorm.DB, id any) error {
+	key, err := r.cacheKey(db, id)
+	if err != nil {
+		return err
+	}
+	return r.Cache.Delete(statementContext(db), key)
+}
+
+func (r *CachedRepository[T]) evict(db *gorm.DB, entity *T) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil {
+		return err
+	}
+
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	id, zero := field.ValueOf(statementContext(db), reflect.ValueOf(entity).Elem())
+	if zero {
+		return nil
+	}
+
+	return r.Cache.Delete(statementContext(db), fmt.Sprintf("%s:%v", stmt.Schema.Table, id))
+}
+
+// cacheKey namespaces the id by the entity's table name
+func (r *CachedRepository[T]) cacheKey(db *gorm.DB, id any) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%v", stmt.Schema.Table, id), nil
+}
+
+func statementContext(db *gorm.DB) context.Context {
+	if db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
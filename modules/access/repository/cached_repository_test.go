@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prayaspoudel/infrastructure/cache"
+	"github.com/prayaspoudel/modules/access/entity"
+	"github.com/prayaspoudel/modules/access/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func newCache(t *testing.T) cache.CacheManager {
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	require.NoError(t, err)
+	require.NoError(t, cacheManager.Connect(context.Background()))
+	return cacheManager
+}
+
+func TestCachedRepositoryFindByIdHitsCache(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := repository.NewCachedRepository[entity.Company](newCache(t), time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_companies" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("company-1", "Evero"))
+
+	var first entity.Company
+	require.NoError(t, repo.FindById(db, &first, "company-1"))
+	assert.Equal(t, "Evero", first.Name)
+
+	// No further query is expected: the second read must be served from cache
+	var second entity.Company
+	require.NoError(t, repo.FindById(db, &second, "company-1"))
+	assert.Equal(t, "Evero", second.Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCachedRepositoryUpdateInvalidates(t *testing.T) {
+	db, mock := newMockDB(t)
+	cacheManager := newCache(t)
+	repo := repository.NewCachedRepository[entity.Company](cacheManager, time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_companies" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("company-1", "Evero"))
+
+	var company entity.Company
+	require.NoError(t, repo.FindById(db, &company, "company-1"))
+
+	exists, err := cacheManager.Exists(context.Background(), "sso_companies:company-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sso_companies"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	company.Name = "Evero Health"
+	require.NoError(t, repo.Update(db, &company))
+
+	exists, err = cacheManager.Exists(context.Background(), "sso_companies:company-1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sso_companies" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("company-1", "Evero Health"))
+
+	var reloaded entity.Company
+	require.NoError(t, repo.FindById(db, &reloaded, "company-1"))
+	assert.Equal(t, "Evero Health", reloaded.Name)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -56,3 +56,18 @@ func (r *OAuth2TokenRepository) RevokeByAccessToken(db *gorm.DB, accessToken str
 		Where("access_token = ?", accessToken).
 		Update("revoked_at", gorm.Expr("NOW()")).Error
 }
+
+type UserConsentRepository struct {
+	Repository[entity.UserConsent]
+	Log *logrus.Logger
+}
+
+func NewUserConsentRepository(log *logrus.Logger) *UserConsentRepository {
+	return &UserConsentRepository{
+		Log: log,
+	}
+}
+
+func (r *UserConsentRepository) FindByUserAndClient(db *gorm.DB, consent *entity.UserConsent, userID string, clientID string) error {
+	return db.Where("user_id = ? AND client_id = ?", userID, clientID).First(consent).Error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/prayaspoudel/modules/access/entity"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -33,6 +35,7 @@ func (r *RefreshTokenRepository) RevokeByUserID(db *gorm.DB, userID string) erro
 		Update("revoked_at", gorm.Expr("NOW()")).Error
 }
 
-func (r *RefreshTokenRepository) DeleteExpired(db *gorm.DB) error {
-	return db.Where("expires_at < NOW()").Delete(&entity.RefreshToken{}).Error
+// DeleteExpired removes the rows that expired before now
+func (r *RefreshTokenRepository) DeleteExpired(db *gorm.DB, now time.Time) error {
+	return db.Where("expires_at < ?", now).Delete(&entity.RefreshToken{}).Error
 }
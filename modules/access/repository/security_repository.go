@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/prayaspoudel/modules/access/entity"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -21,6 +23,13 @@ func (r *PasswordResetTokenRepository) FindByToken(db *gorm.DB, token *entity.Pa
 	return db.Where("token = ? AND used_at IS NULL AND expires_at > NOW()", tokenStr).First(token).Error
 }
 
+// InvalidateByUserID expires the user's outstanding reset tokens so only a newly issued one works
+func (r *PasswordResetTokenRepository) InvalidateByUserID(db *gorm.DB, userID string, now time.Time) error {
+	return db.Model(&entity.PasswordResetToken{}).
+		Where("user_id = ? AND expires_at > ?", userID, now).
+		Update("expires_at", now).Error
+}
+
 type EmailVerificationTokenRepository struct {
 	Repository[entity.EmailVerificationToken]
 	Log *logrus.Logger
@@ -36,6 +45,13 @@ func (r *EmailVerificationTokenRepository) FindByToken(db *gorm.DB, token *entit
 	return db.Where("token = ? AND used_at IS NULL AND expires_at > NOW()", tokenStr).First(token).Error
 }
 
+// InvalidateByUserID expires the user's outstanding verification tokens so only a newly issued one works
+func (r *EmailVerificationTokenRepository) InvalidateByUserID(db *gorm.DB, userID string, now time.Time) error {
+	return db.Model(&entity.EmailVerificationToken{}).
+		Where("user_id = ? AND expires_at > ?", userID, now).
+		Update("expires_at", now).Error
+}
+
 type AuditLogRepository struct {
 	Repository[entity.AuditLog]
 	Log *logrus.Logger
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/prayaspoudel/modules/access/entity"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -25,6 +27,15 @@ func (r *SessionRepository) DeleteByToken(db *gorm.DB, token string) error {
 	return db.Where("session_token = ?", token).Delete(&entity.Session{}).Error
 }
 
-func (r *SessionRepository) DeleteExpired(db *gorm.DB) error {
-	return db.Where("expires_at < NOW()").Delete(&entity.Session{}).Error
+func (r *SessionRepository) FindTokensByUserID(db *gorm.DB, tokens *[]string, userID string) error {
+	return db.Model(&entity.Session{}).Where("user_id = ?", userID).Pluck("session_token", tokens).Error
+}
+
+func (r *SessionRepository) DeleteByUserID(db *gorm.DB, userID string) error {
+	return db.Where("user_id = ?", userID).Delete(&entity.Session{}).Error
+}
+
+// DeleteExpired removes the rows that expired before now
+func (r *SessionRepository) DeleteExpired(db *gorm.DB, now time.Time) error {
+	return db.Where("expires_at < ?", now).Delete(&entity.Session{}).Error
 }
@@ -8,11 +8,14 @@ import (
 	"time"
 
 	"github.com/prayaspoudel/infrastructure/config"
+	"github.com/prayaspoudel/infrastructure/database"
 	"github.com/prayaspoudel/infrastructure/logger"
 	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
 	"github.com/prayaspoudel/modules/healthcare/delivery/messaging"
+	"github.com/prayaspoudel/modules/healthcare/repository"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -20,11 +23,13 @@ func main() {
 	logger := logger.NewLogger(viperConfig)
 	logger.Info("Starting worker service")
 
+	db := database.NewDatabase(viperConfig, logger)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	go RunUserConsumer(logger, viperConfig, ctx)
-	go RunContactConsumer(logger, viperConfig, ctx)
-	go RunAddressConsumer(logger, viperConfig, ctx)
+	go RunUserConsumer(logger, viperConfig, db, ctx)
+	go RunContactConsumer(logger, viperConfig, db, ctx)
+	go RunAddressConsumer(logger, viperConfig, db, ctx)
 
 	terminateSignals := make(chan os.Signal, 1)
 	signal.Notify(terminateSignals, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
@@ -42,23 +47,34 @@ func main() {
 	time.Sleep(5 * time.Second) // wait for all consumers to finish processing
 }
 
-func RunAddressConsumer(logger *logrus.Logger, viperConfig *viper.Viper, ctx context.Context) {
+func RunAddressConsumer(logger *logrus.Logger, viperConfig *viper.Viper, db *gorm.DB, ctx context.Context) {
 	logger.Info("setup address consumer")
 	addressConsumerGroup := messagebroker.NewKafkaConsumerGroup(viperConfig, logger)
-	addressHandler := messaging.NewAddressConsumer(logger)
-	messaging.ConsumeTopic(ctx, addressConsumerGroup, "addresses", logger, addressHandler.Consume)
+	addressHandler := messaging.NewAddressConsumer(logger, repository.NewAddressRepository(logger))
+	messaging.ConsumeTopicWith(ctx, addressConsumerGroup, "addresses", logger, newBatchSink(logger, viperConfig, db, addressHandler.WriteBatch))
 }
 
-func RunContactConsumer(logger *logrus.Logger, viperConfig *viper.Viper, ctx context.Context) {
+func RunContactConsumer(logger *logrus.Logger, viperConfig *viper.Viper, db *gorm.DB, ctx context.Context) {
 	logger.Info("setup contact consumer")
 	contactConsumerGroup := messagebroker.NewKafkaConsumerGroup(viperConfig, logger)
-	contactHandler := messaging.NewContactConsumer(logger)
-	messaging.ConsumeTopic(ctx, contactConsumerGroup, "contacts", logger, contactHandler.Consume)
+	contactHandler := messaging.NewContactConsumer(logger, repository.NewContactRepository(logger))
+	messaging.ConsumeTopicWith(ctx, contactConsumerGroup, "contacts", logger, newBatchSink(logger, viperConfig, db, contactHandler.WriteBatch))
 }
 
-func RunUserConsumer(logger *logrus.Logger, viperConfig *viper.Viper, ctx context.Context) {
+func RunUserConsumer(logger *logrus.Logger, viperConfig *viper.Viper, db *gorm.DB, ctx context.Context) {
 	logger.Info("setup user consumer")
 	userConsumerGroup := messagebroker.NewKafkaConsumerGroup(viperConfig, logger)
-	userHandler := messaging.NewUserConsumer(logger)
-	messaging.ConsumeTopic(ctx, userConsumerGroup, "users", logger, userHandler.Consume)
+	userHandler := messaging.NewUserConsumer(logger, repository.NewUserRepository(logger))
+	messaging.ConsumeTopicWith(ctx, userConsumerGroup, "users", logger, newBatchSink(logger, viperConfig, db, userHandler.WriteBatch))
+}
+
+// newBatchSink sizes batches from worker.batch.size and worker.batch.flush_interval
+func newBatchSink(logger *logrus.Logger, viperConfig *viper.Viper, db *gorm.DB, write messaging.BatchWriter) *messaging.BatchSink {
+	return messaging.NewBatchSink(
+		db,
+		logger,
+		write,
+		viperConfig.GetInt("worker.batch.size"),
+		viperConfig.GetDuration("worker.batch.flush_interval"),
+	)
 }
@@ -4,17 +4,22 @@ import (
 	"encoding/json"
 
 	"github.com/IBM/sarama"
+	"github.com/prayaspoudel/modules/healthcare/entity"
 	"github.com/prayaspoudel/modules/healthcare/model"
+	"github.com/prayaspoudel/modules/healthcare/repository"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type AddressConsumer struct {
-	Log *logrus.Logger
+	Log        *logrus.Logger
+	Repository *repository.AddressRepository
 }
 
-func NewAddressConsumer(log *logrus.Logger) *AddressConsumer {
+func NewAddressConsumer(log *logrus.Logger, addressRepository *repository.AddressRepository) *AddressConsumer {
 	return &AddressConsumer{
-		Log: log,
+		Log:        log,
+		Repository: addressRepository,
 	}
 }
 
@@ -29,3 +34,30 @@ func (c AddressConsumer) Consume(message *sarama.ConsumerMessage) error {
 	c.Log.Infof("Received topic addresses with event: %v from partition %d", addressEvent, message.Partition)
 	return nil
 }
+
+// WriteBatch saves a batch of address events in tx. An address changed several times in
+// the batch is written once, from its latest event; the update keeps created_at and may
+// move the address to another contact. Undecodable events are logged and skipped.
+func (c AddressConsumer) WriteBatch(tx *gorm.DB, messages []*sarama.ConsumerMessage) error {
+	addresses := make([]*entity.Address, 0, len(messages))
+	for _, message := range messages {
+		event := new(model.AddressEvent)
+		if err := json.Unmarshal(message.Value, event); err != nil {
+			c.Log.WithError(err).Errorf("error unmarshalling Address event at offset %d", message.Offset)
+			continue
+		}
+		addresses = append(addresses, &entity.Address{
+			ID:         event.ID,
+			ContactId:  event.ContactId,
+			Street:     event.Street,
+			City:       event.City,
+			Province:   event.Province,
+			PostalCode: event.PostalCode,
+			Country:    event.Country,
+			CreatedAt:  event.CreatedAt,
+			UpdatedAt:  event.UpdatedAt,
+		})
+	}
+
+	return c.Repository.Upsert(tx, lastByID(addresses, func(address *entity.Address) string { return address.ID }), "contact_id", "street", "city", "province", "postal_code", "country", "updated_at")
+}
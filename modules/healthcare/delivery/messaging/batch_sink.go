@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	messagebroker "github.com/prayaspoudel/infrastructure/message-broker"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// BatchWriter stores a batch of consumed messages using tx
+type BatchWriter func(tx *gorm.DB, messages []*sarama.ConsumerMessage) error
+
+// BatchSink is a consumer group handler that writes messages to the database in batches
+// instead of one row per message. Messages are collected by a
+// messagebroker.BulkMessageHandler and written in a single transaction once BatchSize
+// messages have arrived, or once FlushInterval has passed even if no more messages come.
+// Their offsets are marked only after that transaction commits. A partial batch is also
+// written when the claim ends, before ConsumeClaim returns.
+//
+// Messages waiting in a batch are not yet marked, so if the consumer stops or the
+// partition is reassigned they are delivered again. Writers should therefore be
+// idempotent, for example by upserting.
+type BatchSink struct {
+	DB            *gorm.DB
+	Log           *logrus.Logger
+	Write         BatchWriter
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+func NewBatchSink(db *gorm.DB, log *logrus.Logger, write BatchWriter, batchSize int, flushInterval time.Duration) *BatchSink {
+	return &BatchSink{
+		DB:            db,
+		Log:           log,
+		Write:         write,
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+	}
+}
+
+func (s *BatchSink) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (s *BatchSink) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim batches one partition. A batch is only marked once written. A failed write
+// of a full batch ends the claim, which ends the session so its messages are consumed
+// again from the last commit; a failed timer write is retried by the next flush. The final
+// flush of a partial batch runs before it returns, while the session can still mark the
+// batch's offsets, and its failure is returned.
+func (s *BatchSink) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) (err error) {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	flushInterval := s.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	ctx, cancel := context.WithCancel(session.Context())
+	bulk, wait := messagebroker.NewBulkMessageHandler(ctx, batchSize, flushInterval, func(ctx context.Context, batch []*messagebroker.Message) error {
+		return s.flush(session, batch)
+	})
+	defer func() {
+		cancel()
+		if flushErr := wait(); err == nil {
+			err = flushErr
+		}
+	}()
+
+	for {
+		select {
+		case message := <-claim.Messages():
+			if message == nil {
+				return nil
+			}
+
+			err = bulk(ctx, &messagebroker.Message{
+				Topic:           message.Topic,
+				Data:            message.Value,
+				Timestamp:       message.Timestamp,
+				OriginalMessage: message,
+			})
+			if err != nil {
+				return err
+			}
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *BatchSink) flush(session sarama.ConsumerGroupSession, batch []*messagebroker.Message) error {
+	messages := make([]*sarama.ConsumerMessage, 0, len(batch))
+	for _, message := range batch {
+		messages = append(messages, message.OriginalMessage.(*sarama.ConsumerMessage))
+	}
+
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		return s.Write(tx, messages)
+	}); err != nil {
+		s.Log.WithError(err).Errorf("Failed to write batch of %d messages", len(messages))
+		return err
+	}
+
+	for _, message := range messages {
+		session.MarkMessage(message, "")
+	}
+	return nil
+}
+
+// lastByID drops all but the last entity with each id, keeping first-seen order, since
+// a single upsert statement cannot update the same row twice
+func lastByID[E any](entities []*E, id func(*E) string) []*E {
+	index := make(map[string]int, len(entities))
+	deduplicated := make([]*E, 0, len(entities))
+	for _, entity := range entities {
+		key := id(entity)
+		if i, seen := index[key]; seen {
+			deduplicated[i] = entity
+			continue
+		}
+		index[key] = len(deduplicated)
+		deduplicated = append(deduplicated, entity)
+	}
+	return deduplicated
+}
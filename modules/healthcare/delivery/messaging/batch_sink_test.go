@@ -0,0 +1,174 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/prayaspoudel/modules/healthcare/delivery/messaging"
+	"github.com/prayaspoudel/modules/healthcare/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	mutex  sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(message *sarama.ConsumerMessage, metadata string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.marked = append(s.marked, message.Offset)
+}
+
+func (s *fakeSession) markedOffsets() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int64(nil), s.marked...)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func newUserSink(t *testing.T, batchSize int) (*messaging.BatchSink, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	log := logrus.New()
+	consumer := messaging.NewUserConsumer(log, repository.NewUserRepository(log))
+	return messaging.NewBatchSink(db, log, consumer.WriteBatch, batchSize, time.Hour), mock
+}
+
+// runSink consumes claim until the test ends, returning the error ConsumeClaim exits with
+func runSink(t *testing.T, sink *messaging.BatchSink) (*fakeSession, *fakeClaim, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage)}
+	done := make(chan error, 1)
+	go func() { done <- sink.ConsumeClaim(session, claim) }()
+	return session, claim, done
+}
+
+func userMessage(offset int64, id string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Topic:  "users",
+		Offset: offset,
+		Value:  []byte(fmt.Sprintf(`{"id":%q,"name":"user %d"}`, id, offset)),
+	}
+}
+
+func TestBatchSinkWritesBatchInOneStatementAndMarksAfterCommit(t *testing.T) {
+	sink, mock := newUserSink(t, 5)
+	session, claim, _ := runSink(t, sink)
+
+	for offset := int64(0); offset < 4; offset++ {
+		claim.messages <- userMessage(offset, fmt.Sprintf("user-%d", offset%3))
+	}
+	assert.Empty(t, session.markedOffsets(), "nothing is acknowledged before the batch is written")
+
+	mock.ExpectBegin()
+	// user-0 appears twice but is written once, with its latest event
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users"`)+`.*ON CONFLICT \("id"\) DO UPDATE SET "name"="excluded"."name","updated_at"="excluded"."updated_at"`).
+		WithArgs(
+			"user-0", sqlmock.AnyArg(), "user 3", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"user-1", sqlmock.AnyArg(), "user 1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"user-2", sqlmock.AnyArg(), "user 2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"user-4", sqlmock.AnyArg(), "user 4", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectCommit()
+
+	claim.messages <- userMessage(4, "user-4")
+
+	require.Eventually(t, func() bool { return len(session.markedOffsets()) == 5 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, session.markedOffsets())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchSinkLeavesBatchUnmarkedWhenWriteFails(t *testing.T) {
+	sink, mock := newUserSink(t, 2)
+	session, claim, done := runSink(t, sink)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users"`)).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	claim.messages <- userMessage(0, "user-0")
+	claim.messages <- userMessage(1, "user-1")
+
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "connection reset")
+	case <-time.After(time.Second):
+		t.Fatal("claim did not end after the failed write")
+	}
+	assert.Empty(t, session.markedOffsets())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchSinkWritesPartialBatchBeforeClaimEnds(t *testing.T) {
+	sink, mock := newUserSink(t, 5)
+	session, claim, done := runSink(t, sink)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users"`)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	claim.messages <- userMessage(0, "user-0")
+	claim.messages <- userMessage(1, "user-1")
+	close(claim.messages)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("claim did not end after its messages channel closed")
+	}
+	assert.Equal(t, []int64{0, 1}, session.markedOffsets(), "the partial batch is written before ConsumeClaim returns")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchSinkReturnsFailedFinalWrite(t *testing.T) {
+	sink, mock := newUserSink(t, 5)
+	session, claim, done := runSink(t, sink)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "users"`)).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	claim.messages <- userMessage(0, "user-0")
+	close(claim.messages)
+
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "connection reset")
+	case <-time.After(time.Second):
+		t.Fatal("claim did not end after its messages channel closed")
+	}
+	assert.Empty(t, session.markedOffsets())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -44,11 +44,14 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 }
 
 func ConsumeTopic(ctx context.Context, consumerGroup sarama.ConsumerGroup, topic string, log *logrus.Logger, handler ConsumerHandler) {
-	consumerHandler := &ConsumerGroupHandler{
+	ConsumeTopicWith(ctx, consumerGroup, topic, log, &ConsumerGroupHandler{
 		Handler: handler,
 		Log:     log,
-	}
+	})
+}
 
+// ConsumeTopicWith is ConsumeTopic for a ready-made consumer group handler such as a BatchSink
+func ConsumeTopicWith(ctx context.Context, consumerGroup sarama.ConsumerGroup, topic string, log *logrus.Logger, consumerHandler sarama.ConsumerGroupHandler) {
 	go func() {
 		for {
 			if err := consumerGroup.Consume(ctx, []string{topic}, consumerHandler); err != nil {
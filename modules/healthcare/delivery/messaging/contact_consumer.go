@@ -4,17 +4,22 @@ import (
 	"encoding/json"
 
 	"github.com/IBM/sarama"
+	"github.com/prayaspoudel/modules/healthcare/entity"
 	"github.com/prayaspoudel/modules/healthcare/model"
+	"github.com/prayaspoudel/modules/healthcare/repository"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type ContactConsumer struct {
-	Log *logrus.Logger
+	Log        *logrus.Logger
+	Repository *repository.ContactRepository
 }
 
-func NewContactConsumer(log *logrus.Logger) *ContactConsumer {
+func NewContactConsumer(log *logrus.Logger, contactRepository *repository.ContactRepository) *ContactConsumer {
 	return &ContactConsumer{
-		Log: log,
+		Log:        log,
+		Repository: contactRepository,
 	}
 }
 
@@ -29,3 +34,29 @@ func (c ContactConsumer) Consume(message *sarama.ConsumerMessage) error {
 	c.Log.Infof("Received topic contacts with event: %v from partition %d", ContactEvent, message.Partition)
 	return nil
 }
+
+// WriteBatch saves a batch of contact events in tx, writing each contact once from its
+// latest event in the batch. Existing rows get the event's name, email, phone and owning
+// user but keep their created_at. Undecodable events are logged and skipped.
+func (c ContactConsumer) WriteBatch(tx *gorm.DB, messages []*sarama.ConsumerMessage) error {
+	contacts := make([]*entity.Contact, 0, len(messages))
+	for _, message := range messages {
+		event := new(model.ContactEvent)
+		if err := json.Unmarshal(message.Value, event); err != nil {
+			c.Log.WithError(err).Errorf("error unmarshalling Contact event at offset %d", message.Offset)
+			continue
+		}
+		contacts = append(contacts, &entity.Contact{
+			ID:        event.ID,
+			UserId:    event.UserID,
+			FirstName: event.FirstName,
+			LastName:  event.LastName,
+			Email:     event.Email,
+			Phone:     event.Phone,
+			CreatedAt: event.CreatedAt,
+			UpdatedAt: event.UpdatedAt,
+		})
+	}
+
+	return c.Repository.Upsert(tx, lastByID(contacts, func(contact *entity.Contact) string { return contact.ID }), "first_name", "last_name", "email", "phone", "user_id", "updated_at")
+}
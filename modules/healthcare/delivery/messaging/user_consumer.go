@@ -4,17 +4,22 @@ import (
 	"encoding/json"
 
 	"github.com/IBM/sarama"
+	"github.com/prayaspoudel/modules/healthcare/entity"
 	"github.com/prayaspoudel/modules/healthcare/model"
+	"github.com/prayaspoudel/modules/healthcare/repository"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type UserConsumer struct {
-	Log *logrus.Logger
+	Log        *logrus.Logger
+	Repository *repository.UserRepository
 }
 
-func NewUserConsumer(log *logrus.Logger) *UserConsumer {
+func NewUserConsumer(log *logrus.Logger, userRepository *repository.UserRepository) *UserConsumer {
 	return &UserConsumer{
-		Log: log,
+		Log:        log,
+		Repository: userRepository,
 	}
 }
 
@@ -29,3 +34,25 @@ func (c UserConsumer) Consume(message *sarama.ConsumerMessage) error {
 	c.Log.Infof("Received topic users with event: %v from partition %d", UserEvent, message.Partition)
 	return nil
 }
+
+// WriteBatch saves a batch of user events in tx. Only the name and updated_at of an
+// existing user change, taken from the user's latest event in the batch. Undecodable
+// events are logged and skipped.
+func (c UserConsumer) WriteBatch(tx *gorm.DB, messages []*sarama.ConsumerMessage) error {
+	users := make([]*entity.User, 0, len(messages))
+	for _, message := range messages {
+		event := new(model.UserEvent)
+		if err := json.Unmarshal(message.Value, event); err != nil {
+			c.Log.WithError(err).Errorf("error unmarshalling User event at offset %d", message.Offset)
+			continue
+		}
+		users = append(users, &entity.User{
+			ID:        event.ID,
+			Name:      event.Name,
+			CreatedAt: event.CreatedAt,
+			UpdatedAt: event.UpdatedAt,
+		})
+	}
+
+	return c.Repository.Upsert(tx, lastByID(users, func(user *entity.User) string { return user.ID }), "name", "updated_at")
+}
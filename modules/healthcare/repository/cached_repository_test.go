@@ -0,0 +1,44 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prayaspoudel/infrastructure/cache"
+	"github.com/prayaspoudel/modules/healthcare/entity"
+	"github.com/prayaspoudel/modules/healthcare/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// The cache-aside behaviour is covered in modules/access/repository; this checks that
+// healthcare users are cached under their own table name
+func TestCachedRepositoryKeysUsersByTable(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	cacheManager, err := cache.NewCacheManagerFactory(cache.InstanceInMemory, nil)
+	require.NoError(t, err)
+	require.NoError(t, cacheManager.Connect(context.Background()))
+	repo := repository.NewCachedRepository[entity.User](cacheManager, time.Minute)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("user-1", "Evero"))
+
+	var user entity.User
+	require.NoError(t, repo.FindById(db, &user, "user-1"))
+	assert.Equal(t, "Evero", user.Name)
+
+	exists, err := cacheManager.Exists(context.Background(), "users:user-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,6 +1,9 @@
 package repository
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 type Repository[T any] struct {
 	DB *gorm.DB
@@ -10,6 +13,29 @@ func (r *Repository[T]) Create(db *gorm.DB, entity *T) error {
 	return db.Create(entity).Error
 }
 
+// BatchCreate inserts entities with one statement per batchSize rows. Associations are
+// not saved.
+func (r *Repository[T]) BatchCreate(db *gorm.DB, entities []*T, batchSize int) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	return db.Omit(clause.Associations).CreateInBatches(entities, batchSize).Error
+}
+
+// Upsert inserts entities in one statement, updating the given columns of rows whose id
+// already exists, or every column when none are given. Associations are not saved.
+func (r *Repository[T]) Upsert(db *gorm.DB, entities []*T, columns ...string) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}
+	if len(columns) > 0 {
+		onConflict = clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoUpdates: clause.AssignmentColumns(columns)}
+	}
+	return db.Omit(clause.Associations).Clauses(onConflict).Create(entities).Error
+}
+
 func (r *Repository[T]) Update(db *gorm.DB, entity *T) error {
 	return db.Save(entity).Error
 }